@@ -2,45 +2,126 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
 	"github.com/JorgeePG/prueba-api-http-postgresql-/cmd/config"
 	"github.com/JorgeePG/prueba-api-http-postgresql-/cmd/server"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/http/handler"
 	"github.com/JorgeePG/prueba-api-http-postgresql-/infraestructure/db"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/container"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/embedded"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/pipeline"
 	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/subscriber"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/repository"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/tracing"
 	"github.com/rs/zerolog/log"
 )
 
 type App struct {
-	server *server.Server
-	config *config.Config
+	server    *server.Server
+	config    *config.Config
+	container *container.Container
+	pipeline  *pipeline.Pipeline
+	broker    *embedded.Broker
+	// shutdownTracing exporta las trazas pendientes al parar
+	shutdownTracing func(context.Context) error
+	// stopRetention cancela la purga periódica; retentionDone se cierra al terminar
+	stopRetention context.CancelFunc
+	retentionDone chan struct{}
+	// partitions crea y elimina las particiones de mqtt_messages; stopPartitions
+	// cancela su mantenimiento y partitionsDone se cierra al terminar
+	partitions     *db.PartitionManager
+	stopPartitions context.CancelFunc
+	partitionsDone chan struct{}
+	// stopRollups cancela el cálculo de agregados; rollupsDone se cierra al terminar
+	stopRollups context.CancelFunc
+	rollupsDone chan struct{}
+	// stopAlerts cancela la evaluación de alertas; alertsDone se cierra al terminar
+	stopAlerts context.CancelFunc
+	alertsDone chan struct{}
 }
 
-func New() *App {
-	return &App{
-		config: config.Load(),
+// tracingShutdownTimeout limita la exportación de las trazas pendientes al parar
+const tracingShutdownTimeout = 5 * time.Second
+
+func New() (*App, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
 	}
+
+	return &App{
+		config: cfg,
+	}, nil
 }
 
 func (a *App) Initialize() error {
 	log.Info().Msg("Initializing application...")
-	// Inicializar base de datos
-	if err := db.Initialize(a.config.Database.ConnectionString()); err != nil {
-		log.Error().Err(err).Msg("Error initializing database")
-		return err
+	if !a.config.Server.ServesLegacy() && !a.config.Server.ServesV1() {
+		return fmt.Errorf("API_MODE no válido: %q (valores admitidos: legacy, v1, both)", a.config.Server.APIMode)
 	}
 
-	// Ejecutar migraciones
-	if err := db.RunMigrations(); err != nil {
-		log.Error().Err(err).Msg("Error running migrations")
+	if err := a.initTracing(); err != nil {
 		return err
 	}
 
-	log.Info().Msg("Database initialized and migrations applied successfully")
+	if err := a.initDatabase(); err != nil {
+		return err
+	}
+	// Antes de arrancar el pipeline, para que los mensajes caigan en su partición
+	if err := a.initPartitions(); err != nil {
+		return err
+	}
 
 	// Configurar la base de datos en el SubscriberManager
 	subscriberManager := subscriber.GetSubscriberManager()
 	subscriberManager.SetDatabase(db.DB)
 	log.Info().Msg("MQTT SubscriberManager database configured successfully")
 
+	// Los mensajes recibidos se guardan por lotes en segundo plano
+	p, err := pipeline.New(a.config.Pipeline, repository.NewMqttMessageRepository(db.DB))
+	if err != nil {
+		log.Error().Err(err).Msg("Error configuring MQTT message pipeline")
+		return err
+	}
+	p.Start()
+	a.pipeline = p
+	subscriberManager.SetPipeline(p)
+
+	if a.config.EmbeddedBroker.Enabled {
+		if err := a.startEmbeddedBroker(); err != nil {
+			return err
+		}
+	}
+
+	// Construir las dependencias de la API /api/v1
+	c, err := container.NewContainer(db.DB, a.config)
+	if err != nil {
+		log.Error().Err(err).Msg("Error building dependency container")
+		return err
+	}
+	a.container = c
+
+	// Antes de suscribirse, para evaluar las alertas desde el primer mensaje
+	if a.config.Alerts.Enabled {
+		a.startAlerts(subscriberManager)
+	}
+
+	if err := subscriberManager.Configure(a.config.MQTT); err != nil {
+		// Sin conexión MQTT la API sigue funcionando; las suscripciones fallarán
+		log.Error().Err(err).Msg("Error configuring MQTT connection")
+	}
+	a.restoreSubscriptions(subscriberManager)
+
+	// Las rutas legacy comparten el hasher de contraseñas configurado
+	handler.SetPasswordHasher(a.container.PasswordHasher)
+	handler.SetTopicACLService(a.container.TopicACLService)
+
 	// Configurar servidor con SSL
 	a.server = server.New(
 		a.config.Server.Port,
@@ -48,18 +129,336 @@ func (a *App) Initialize() error {
 		a.config.Server.SSLCert,
 		a.config.Server.SSLKey,
 	)
-	a.server.SetupRoutes()
+	a.server.SetupHealthRoutes(a.container.HealthHandler.Liveness, a.container.HealthHandler.Readiness)
+	if a.container.Metrics != nil {
+		a.server.SetupMetrics(a.container.Metrics, a.config.Metrics.Path, a.container.Metrics.Handler())
+	}
+	if a.config.Server.ServesLegacy() {
+		a.server.SetupRoutes(a.container.Authenticate)
+	}
+	if a.config.Server.ServesV1() {
+		a.server.Mount(a.container.Router.Setup())
+	}
+
+	log.Info().Str("api_mode", a.config.Server.APIMode).Msg("Server routes set up successfully")
+
+	if a.config.Retention.Enabled {
+		a.startRetention()
+	}
+	if a.config.Rollups.Enabled {
+		a.startRollups()
+	}
+	a.startPartitionMaintenance()
+	return nil
+}
+
+// startPartitionMaintenance crea periódicamente las particiones de los
+// próximos periodos y, con la retención activa, elimina las que solo contienen
+// mensajes caducados
+func (a *App) startPartitionMaintenance() {
+	if a.partitions == nil {
+		return
+	}
+	if a.config.Partition.CheckInterval <= 0 {
+		log.Warn().Dur("interval", a.config.Partition.CheckInterval).Msg("Invalid MQTT partition check interval, maintenance disabled")
+		return
+	}
+
+	var cutoff func(context.Context) (*time.Time, error)
+	if a.config.Retention.Enabled {
+		cutoff = a.container.RetentionService.PartitionCutoff
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.stopPartitions = cancel
+	a.partitionsDone = make(chan struct{})
+	go func() {
+		defer close(a.partitionsDone)
+		a.partitions.RunMaintenance(ctx, a.config.Partition.CheckInterval, cutoff)
+	}()
+
+	log.Info().Dur("interval", a.config.Partition.CheckInterval).Msg("MQTT partition maintenance started")
+}
+
+// startRetention purga en segundo plano los mensajes MQTT caducados según las
+// reglas de retención
+func (a *App) startRetention() {
+	if a.config.Retention.Interval <= 0 {
+		log.Warn().Dur("interval", a.config.Retention.Interval).Msg("Invalid MQTT retention interval, purge disabled")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.stopRetention = cancel
+	a.retentionDone = make(chan struct{})
+	go func() {
+		defer close(a.retentionDone)
+		a.container.RetentionService.RunPurges(ctx, a.config.Retention.Interval)
+	}()
+
+	log.Info().Dur("interval", a.config.Retention.Interval).Msg("MQTT message retention started")
+}
+
+// startRollups calcula en segundo plano los agregados de los mensajes MQTT
+// según las configuraciones de agregados
+func (a *App) startRollups() {
+	if a.config.Rollups.Interval <= 0 {
+		log.Warn().Dur("interval", a.config.Rollups.Interval).Msg("Invalid MQTT rollup interval, rollups disabled")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.stopRollups = cancel
+	a.rollupsDone = make(chan struct{})
+	go func() {
+		defer close(a.rollupsDone)
+		a.container.RollupService.RunRollups(ctx, a.config.Rollups.Interval)
+	}()
+
+	log.Info().Dur("interval", a.config.Rollups.Interval).Msg("MQTT message rollups started")
+}
+
+// startAlerts carga las reglas de alerta y evalúa en segundo plano los
+// mensajes MQTT que recibe el manager
+func (a *App) startAlerts(manager *subscriber.SubscriberManager) {
+	if err := a.container.AlertService.Load(context.Background()); err != nil {
+		// Las reglas que se creen desde la API se evaluarán igualmente
+		log.Error().Err(err).Msg("Error loading MQTT alert rules")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.stopAlerts = cancel
+	a.alertsDone = make(chan struct{})
+	go func() {
+		defer close(a.alertsDone)
+		a.container.AlertService.Run(ctx)
+	}()
+	manager.OnReceived(a.container.AlertService.Observe)
+
+	log.Info().Msg("MQTT alert evaluation started")
+}
+
+// initTracing configura el exportador de trazas OpenTelemetry
+func (a *App) initTracing() error {
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    a.config.Tracing.Exporter,
+		ServiceName: a.config.Tracing.ServiceName,
+		Endpoint:    a.config.Tracing.OTLPEndpoint,
+		File:        a.config.Tracing.File,
+		SampleRatio: a.config.Tracing.SampleRatio,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Error configuring tracing")
+		return err
+	}
+	a.shutdownTracing = shutdown
+
+	log.Info().Str("exporter", a.config.Tracing.Exporter).Msg("Tracing configured successfully")
+	return nil
+}
+
+// initDatabase abre la conexión a la base de datos y aplica las migraciones
+func (a *App) initDatabase() error {
+	if err := a.openDatabase(); err != nil {
+		return err
+	}
+
+	// Ejecutar migraciones
+	if err := db.RunMigrations(a.config.Database.MigrationsDir); err != nil {
+		log.Error().Err(err).Msg("Error running migrations")
+		return err
+	}
+
+	log.Info().Msg("Database initialized and migrations applied successfully")
+	return nil
+}
+
+// initPartitions crea las particiones de mqtt_messages de los próximos periodos
+// antes de que empiecen a llegar mensajes. Si falla, los mensajes van a la
+// partición por defecto y se vuelve a intentar en el mantenimiento periódico.
+func (a *App) initPartitions() error {
+	manager, err := db.NewPartitionManager(db.DB, a.config.Partition.Interval, a.config.Partition.Ahead)
+	if err != nil {
+		log.Error().Err(err).Msg("Error configuring MQTT message partitions")
+		return err
+	}
+	a.partitions = manager
+
+	if err := manager.Maintain(context.Background(), nil); err != nil {
+		log.Error().Err(err).Msg("Error creating MQTT message partitions")
+	}
+	return nil
+}
+
+// openDatabase abre la conexión a la base de datos sin aplicar migraciones
+func (a *App) openDatabase() error {
+	if err := db.Initialize(a.config.Database.ConnectionString()); err != nil {
+		log.Error().Err(err).Msg("Error initializing database")
+		return err
+	}
+	return nil
+}
+
+// EnableEmbeddedBroker activa el broker MQTT embebido (opción --embedded-broker)
+func (a *App) EnableEmbeddedBroker() {
+	a.config.EmbeddedBroker.Enabled = true
+}
+
+// startEmbeddedBroker arranca el broker MQTT embebido y hace que la aplicación
+// se conecte a él en lugar de a los brokers configurados
+func (a *App) startEmbeddedBroker() error {
+	cfg := a.config.EmbeddedBroker
+	broker, err := embedded.Start(embedded.Options{
+		Address: cfg.Address,
+		TLS:     cfg.TLS,
+		CertDir: cfg.CertDir,
+		Logger:  slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})),
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Error starting embedded MQTT broker")
+		return err
+	}
+	a.broker = broker
+	a.config.MQTT = broker.ClientConfig(a.config.MQTT)
 
-	log.Info().Msg("Server routes set up successfully")
+	log.Warn().
+		Str("url", broker.URL()).
+		Str("ca_cert", broker.CACertFile()).
+		Msg("Using embedded MQTT broker: development only, it accepts any client")
 	return nil
 }
 
-func (a *App) Run() error {
+// restoreSubscriptions vuelve a suscribirse a los topics persistidos. Los fallos
+// se registran pero no impiden arrancar la aplicación.
+func (a *App) restoreSubscriptions(manager *subscriber.SubscriberManager) {
+	result, err := manager.RestoreSubscriptions()
+	if err != nil {
+		log.Error().Err(err).Msg("Error restoring MQTT subscriptions")
+		return
+	}
+
+	for topic, err := range result.Failed {
+		log.Error().Err(err).Str("topic", topic).Msg("Failed to restore MQTT subscription")
+	}
+	log.Info().
+		Int("restored", len(result.Restored)).
+		Int("failed", len(result.Failed)).
+		Msg("MQTT subscriptions restored")
+}
+
+// Run sirve la API hasta que el servidor falla o se cancela ctx (señal de
+// parada). En ambos casos hay que llamar después a Shutdown.
+func (a *App) Run(ctx context.Context) error {
 	log.Info().Msg("Starting application server...")
-	return a.server.Start()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- a.server.Start()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		log.Info().Msg("Shutdown signal received")
+		return nil
+	}
 }
 
-func (a *App) Shutdown() {
+// Shutdown para la aplicación en orden: primero deja de aceptar peticiones y
+// espera a las que están en curso, después corta la entrada de mensajes MQTT,
+// vacía la cola de escritura y por último cierra la base de datos. Cada fase
+// se ejecuta aunque falle la anterior; los errores se devuelven juntos.
+func (a *App) Shutdown() error {
 	log.Info().Msg("Shutting down application...")
-	db.Close()
+	var errs []error
+
+	if a.server != nil {
+		// Los streams SSE y WebSocket no terminan solos: se cierran antes de
+		// esperar a las peticiones HTTP
+		subscriber.GetSubscriberManager().Stream().Close()
+
+		log.Info().Dur("timeout", a.config.Server.ShutdownTimeout).Msg("Draining HTTP requests...")
+		ctx, cancel := context.WithTimeout(context.Background(), a.config.Server.ShutdownTimeout)
+		err := a.server.Shutdown(ctx)
+		cancel()
+		if err != nil {
+			log.Error().Err(err).Msg("Error draining HTTP requests")
+			errs = append(errs, fmt.Errorf("servidor HTTP: %w", err))
+		} else {
+			log.Info().Msg("HTTP server stopped")
+		}
+	}
+
+	// Una purga en curso se interrumpe; el lote que se estaba borrando se deshace
+	if a.stopRetention != nil {
+		a.stopRetention()
+		<-a.retentionDone
+		log.Info().Msg("MQTT message retention stopped")
+	}
+	// Un lote de agregados interrumpido se deshace y se repite en el siguiente arranque
+	if a.stopRollups != nil {
+		a.stopRollups()
+		<-a.rollupsDone
+		log.Info().Msg("MQTT message rollups stopped")
+	}
+	// Antes de desconectar, para que las notificaciones por MQTT se puedan publicar
+	if a.stopAlerts != nil {
+		a.stopAlerts()
+		<-a.alertsDone
+		log.Info().Msg("MQTT alert evaluation stopped")
+	}
+	if a.stopPartitions != nil {
+		a.stopPartitions()
+		<-a.partitionsDone
+		log.Info().Msg("MQTT partition maintenance stopped")
+	}
+
+	// Primero se corta la entrada de mensajes y después se vacía la cola
+	log.Info().Msg("Disconnecting MQTT subscribers...")
+	subscriber.DisconnectAllSubscribers()
+
+	if a.pipeline != nil {
+		log.Info().Dur("timeout", a.config.Pipeline.ShutdownTimeout).Msg("Flushing pending MQTT messages...")
+		ctx, cancel := context.WithTimeout(context.Background(), a.config.Pipeline.ShutdownTimeout)
+		err := a.pipeline.Close(ctx)
+		cancel()
+		if err != nil {
+			log.Error().Err(err).Msg("Error flushing MQTT message pipeline")
+			errs = append(errs, fmt.Errorf("pipeline MQTT: %w", err))
+		} else {
+			log.Info().Msg("MQTT message pipeline flushed")
+		}
+	}
+
+	if a.broker != nil {
+		if err := a.broker.Close(); err != nil {
+			log.Error().Err(err).Msg("Error stopping embedded MQTT broker")
+			errs = append(errs, fmt.Errorf("broker MQTT embebido: %w", err))
+		} else {
+			log.Info().Msg("Embedded MQTT broker stopped")
+		}
+	}
+
+	// Después del pipeline, para exportar también los spans de sus últimas escrituras
+	if a.shutdownTracing != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		err := a.shutdownTracing(ctx)
+		cancel()
+		if err != nil {
+			log.Error().Err(err).Msg("Error flushing traces")
+			errs = append(errs, fmt.Errorf("trazas: %w", err))
+		} else {
+			log.Info().Msg("Traces flushed")
+		}
+	}
+
+	if err := db.Close(); err != nil {
+		log.Error().Err(err).Msg("Error closing database")
+		errs = append(errs, fmt.Errorf("base de datos: %w", err))
+	} else {
+		log.Info().Msg("Database connection closed")
+	}
+
+	return errors.Join(errs...)
 }
@@ -0,0 +1,138 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/infraestructure/db"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/container"
+	"github.com/rs/zerolog/log"
+)
+
+// FlagPlaintextPasswords hashea las contraseñas heredadas guardadas en claro y obliga
+// a sus usuarios a cambiarlas. Es un comando de ejecución única, no arranca el servidor.
+func (a *App) FlagPlaintextPasswords(ctx context.Context) error {
+	if err := a.initDatabase(); err != nil {
+		return err
+	}
+
+	c, err := container.NewContainer(db.DB, a.config)
+	if err != nil {
+		return err
+	}
+
+	flagged, err := c.UserService.FlagPlaintextPasswords(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Info().Int("flagged_users", flagged).Msg("Contraseñas en claro marcadas para reseteo")
+	return nil
+}
+
+// GrantRole asigna un rol a un usuario existente. Permite dar de alta el primer
+// administrador, ya que la API solo deja gestionar roles a quien ya lo es.
+func (a *App) GrantRole(ctx context.Context, username, role string) error {
+	if err := a.initDatabase(); err != nil {
+		return err
+	}
+
+	c, err := container.NewContainer(db.DB, a.config)
+	if err != nil {
+		return err
+	}
+
+	user, err := c.UserRepository.GetByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("user not found: %s", username)
+	}
+
+	if err := c.UserService.AssignRole(ctx, user.ID, role); err != nil {
+		return err
+	}
+
+	log.Info().Str("username", username).Str("role", role).Msg("Rol asignado")
+	return nil
+}
+
+// Migrate gestiona las migraciones del esquema: up aplica las pendientes, down
+// [n] deshace las n últimas (1 por defecto), redo deshace y vuelve a aplicar la
+// última y status muestra qué versiones están aplicadas.
+func (a *App) Migrate(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("uso: migrate up|down [n]|redo|status")
+	}
+
+	if err := a.openDatabase(); err != nil {
+		return err
+	}
+	migrator, err := db.DefaultMigrator(db.DB, a.config.Database.MigrationsDir)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		log.Info().Int("applied", len(applied)).Msg("Migraciones aplicadas")
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("el número de migraciones a deshacer debe ser positivo: %s", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		log.Info().Int("reverted", len(reverted)).Msg("Migraciones deshechas")
+	case "redo":
+		redone, err := migrator.Redo(ctx)
+		if err != nil {
+			return err
+		}
+		if redone == nil {
+			log.Info().Msg("No hay migraciones aplicadas")
+			return nil
+		}
+		log.Info().Int64("version", redone.Version).Str("name", redone.Name).Msg("Migración rehecha")
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printMigrationStatus(status)
+	default:
+		return fmt.Errorf("subcomando de migrate desconocido: %s (disponibles: up, down, redo, status)", args[0])
+	}
+	return nil
+}
+
+// printMigrationStatus muestra el estado de las migraciones como una tabla
+func printMigrationStatus(status []db.MigrationStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNOMBRE\tESTADO\tAPLICADA")
+	for _, migration := range status {
+		state, appliedAt := "pendiente", ""
+		if migration.Applied {
+			state = "aplicada"
+			appliedAt = migration.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		if migration.Missing {
+			state = "sin fichero"
+		}
+		fmt.Fprintf(w, "%03d\t%s\t%s\t%s\n", migration.Version, migration.Name, state, appliedAt)
+	}
+	w.Flush()
+}
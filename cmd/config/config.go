@@ -1,13 +1,30 @@
 package config
 
 import (
+	"bufio"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Auth      AuthConfig
+	MQTT      MQTTConfig
+	Pipeline  PipelineConfig
+	Stream    StreamConfig
+	Retention RetentionConfig
+	Partition PartitionConfig
+	Rollups   RollupsConfig
+	Alerts    AlertsConfig
+	Health    HealthConfig
+	Metrics   MetricsConfig
+	Tracing   TracingConfig
+	// EmbeddedBroker arranca un broker MQTT dentro del proceso (solo desarrollo)
+	EmbeddedBroker EmbeddedBrokerConfig
 }
 
 type DatabaseConfig struct {
@@ -16,6 +33,9 @@ type DatabaseConfig struct {
 	User     string
 	Password string
 	DBName   string
+	// MigrationsDir sustituye las migraciones incluidas en el binario por las de
+	// ese directorio; vacío usa las incluidas
+	MigrationsDir string
 }
 
 type ServerConfig struct {
@@ -23,33 +43,384 @@ type ServerConfig struct {
 	SSLCert string
 	SSLKey  string
 	UseSSL  bool
+	// APIMode selecciona las rutas servidas: "legacy", "v1" o "both"
+	APIMode string
+	// ShutdownTimeout es lo que se espera a que terminen las peticiones en curso al parar
+	ShutdownTimeout time.Duration
 }
 
-func Load() *Config {
+// AuthConfig contiene la configuración de autenticación y hasheo de contraseñas
+type AuthConfig struct {
+	// PasswordAlgorithm es el algoritmo para los hashes nuevos: "argon2id" o "bcrypt"
+	PasswordAlgorithm string
+	Argon2Memory      int // KiB
+	Argon2Iterations  int
+	Argon2Parallelism int
+	BcryptCost        int
+
+	// JWTSecret firma los access tokens (HMAC-SHA256)
+	JWTSecret       string
+	JWTIssuer       string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+// MQTTConfig contiene la conexión con el broker MQTT, compartida por el
+// suscriptor de la aplicación y el publisher de pruebas
+type MQTTConfig struct {
+	// Brokers se prueban en orden, p. ej. "ssl://localhost:8883"
+	Brokers        []string
+	Username       string
+	Password       string
+	ClientIDPrefix string
+
+	// TLS (brokers ssl://, tls://, mqtts:// o wss://)
+	CACert     string
+	ClientCert string
+	ClientKey  string
+	ServerName string
+	// TLSInsecureSkipVerify desactiva la verificación del certificado del broker (solo pruebas)
+	TLSInsecureSkipVerify bool
+
+	ConnectTimeout       time.Duration
+	KeepAlive            time.Duration
+	PingTimeout          time.Duration
+	WriteTimeout         time.Duration
+	MaxReconnectInterval time.Duration
+	// OperationTimeout limita la espera por las confirmaciones del broker (SUBACK, PUBACK...)
+	OperationTimeout time.Duration
+	CleanSession     bool
+}
+
+// PipelineConfig controla la cola en memoria que agrupa los mensajes MQTT
+// recibidos antes de guardarlos en base de datos
+type PipelineConfig struct {
+	QueueSize     int
+	BatchSize     int
+	Workers       int
+	FlushInterval time.Duration
+	FlushTimeout  time.Duration
+	// Backpressure decide qué hacer con la cola llena: "block", "drop_oldest" o "spill"
+	Backpressure string
+	// SpillDir guarda los mensajes desbordados y los lotes que no se pudieron escribir
+	SpillDir        string
+	ShutdownTimeout time.Duration
+}
+
+// StreamConfig controla el stream en vivo de mensajes MQTT (SSE y WebSocket)
+type StreamConfig struct {
+	// Heartbeat es cada cuánto se envía un latido a los clientes
+	Heartbeat time.Duration
+	// ClientBuffer son los mensajes pendientes que admite un cliente antes de ser expulsado
+	ClientBuffer int
+	// ReplayLimit son los mensajes reenviados como máximo al reanudar desde Last-Event-ID
+	ReplayLimit  int
+	WriteTimeout time.Duration
+}
+
+// RetentionConfig controla la purga periódica de mensajes MQTT según las
+// reglas de retención guardadas en la base de datos
+type RetentionConfig struct {
+	Enabled  bool
+	Interval time.Duration
+	// BatchSize son los mensajes que se borran en cada sentencia, para no bloquear la tabla
+	BatchSize  int
+	BatchPause time.Duration
+}
+
+// PartitionConfig controla las particiones de mqtt_messages por received_at
+type PartitionConfig struct {
+	// Interval es el periodo de cada partición: day o month
+	Interval string
+	// Ahead son los periodos futuros que deben tener ya su partición
+	Ahead int
+	// CheckInterval es cada cuánto se crean las nuevas y se eliminan las caducadas
+	CheckInterval time.Duration
+}
+
+// RollupsConfig controla el cálculo periódico de los agregados por minuto,
+// hora y día de los mensajes MQTT
+type RollupsConfig struct {
+	Enabled  bool
+	Interval time.Duration
+	// Lag es el margen que se deja a los mensajes recientes antes de agregarlos
+	Lag time.Duration
+}
+
+// AlertsConfig controla la evaluación de las reglas de alerta con cada mensaje MQTT recibido
+type AlertsConfig struct {
+	Enabled bool
+	// QueueSize son los mensajes pendientes de evaluar; si se llena se descartan
+	QueueSize int
+	// NotifyTimeout limita cada notificación a un destino (webhook, MQTT...)
+	NotifyTimeout time.Duration
+}
+
+// HealthConfig controla las comprobaciones de /readyz
+type HealthConfig struct {
+	// CheckTimeout limita cada comprobación (ping a la base de datos, etc.)
+	CheckTimeout time.Duration
+	// CertExpiryWarning es la antelación con la que se avisa de que un certificado caduca
+	CertExpiryWarning time.Duration
+}
+
+// MetricsConfig controla el endpoint de métricas en formato Prometheus
+type MetricsConfig struct {
+	Enabled bool
+	Path    string
+}
+
+// TracingConfig controla la exportación de trazas OpenTelemetry
+type TracingConfig struct {
+	// Exporter es none, otlp, stdout o file
+	Exporter    string
+	ServiceName string
+	// OTLPEndpoint es la URL del colector (http://localhost:4318); vacío usa OTEL_EXPORTER_OTLP_*
+	OTLPEndpoint string
+	// File es el fichero del exportador file
+	File        string
+	SampleRatio float64
+}
+
+// EmbeddedBrokerConfig controla el broker MQTT embebido para desarrollo local.
+// Si está activo, la aplicación se conecta a él en lugar de a MQTT_BROKERS.
+type EmbeddedBrokerConfig struct {
+	Enabled bool
+	Address string
+	// TLS genera una CA y un certificado para localhost en CertDir
+	TLS     bool
+	CertDir string
+}
+
+// Modos de API soportados por el servidor
+const (
+	APIModeLegacy = "legacy"
+	APIModeV1     = "v1"
+	APIModeBoth   = "both"
+)
+
+// fileValues contiene las variables leídas de CONFIG_FILE; las variables de
+// entorno tienen prioridad sobre ellas
+var fileValues = map[string]string{}
+
+// Load lee la configuración de las variables de entorno y, si se indica
+// CONFIG_FILE, de un fichero con líneas CLAVE=valor
+func Load() (*Config, error) {
+	fileValues = map[string]string{}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		values, err := readConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		fileValues = values
+	}
+
 	return &Config{
 		Server: ServerConfig{
-			Port:    getEnv("SERVER_PORT", "8080"),
-			SSLCert: getEnv("SSL_CERT_PATH", "certs/ssl/server.crt"),
-			SSLKey:  getEnv("SSL_KEY_PATH", "certs/ssl/server.key"),
-			UseSSL:  getEnv("USE_SSL", "false") == "true",
+			Port:            getEnv("SERVER_PORT", "8080"),
+			SSLCert:         getEnv("SSL_CERT_PATH", "certs/ssl/server.crt"),
+			SSLKey:          getEnv("SSL_KEY_PATH", "certs/ssl/server.key"),
+			UseSSL:          getEnv("USE_SSL", "false") == "true",
+			APIMode:         getEnv("API_MODE", APIModeBoth),
+			ShutdownTimeout: getEnvDuration("SERVER_SHUTDOWN_TIMEOUT", 30*time.Second),
 		},
 		Database: DatabaseConfig{
-			Host:     "localhost",
-			Port:     5432,
-			DBName:   "api_db",
-			User:     getEnv("DB_USER", "postgres"),
-			Password: getEnv("DB_PASSWORD", "postgres"),
+			Host:          getEnv("DB_HOST", "localhost"),
+			Port:          getEnvInt("DB_PORT", 5432),
+			DBName:        getEnv("DB_NAME", "api_db"),
+			User:          getEnv("DB_USER", "postgres"),
+			Password:      getEnv("DB_PASSWORD", "postgres"),
+			MigrationsDir: getEnv("DB_MIGRATIONS_DIR", ""),
+		},
+		Auth: AuthConfig{
+			PasswordAlgorithm: getEnv("PASSWORD_ALGORITHM", "argon2id"),
+			Argon2Memory:      getEnvInt("ARGON2_MEMORY_KB", 64*1024),
+			Argon2Iterations:  getEnvInt("ARGON2_ITERATIONS", 3),
+			Argon2Parallelism: getEnvInt("ARGON2_PARALLELISM", 2),
+			BcryptCost:        getEnvInt("BCRYPT_COST", 12),
+			JWTSecret:         getEnv("JWT_SECRET", ""),
+			JWTIssuer:         getEnv("JWT_ISSUER", "prueba-api-http-postgresql"),
+			AccessTokenTTL:    getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+			RefreshTokenTTL:   getEnvDuration("REFRESH_TOKEN_TTL", 7*24*time.Hour),
+		},
+		MQTT: MQTTConfig{
+			Brokers:               getEnvList("MQTT_BROKERS", []string{"ssl://localhost:8883"}),
+			Username:              getEnv("MQTT_USERNAME", "publisher"),
+			Password:              getEnv("MQTT_PASSWORD", "publisher"),
+			ClientIDPrefix:        getEnv("MQTT_CLIENT_ID_PREFIX", "go"),
+			CACert:                getEnv("MQTT_CA_CERT", getEnv("CERT_PATH", "mqtt/publisher/cert")+"/ca.crt"),
+			ClientCert:            getEnv("MQTT_CLIENT_CERT", ""),
+			ClientKey:             getEnv("MQTT_CLIENT_KEY", ""),
+			ServerName:            getEnv("MQTT_SERVER_NAME", "localhost"),
+			TLSInsecureSkipVerify: getEnv("MQTT_TLS_INSECURE_SKIP_VERIFY", "false") == "true",
+			ConnectTimeout:        getEnvDuration("MQTT_CONNECT_TIMEOUT", 10*time.Second),
+			KeepAlive:             getEnvDuration("MQTT_KEEPALIVE", 30*time.Second),
+			PingTimeout:           getEnvDuration("MQTT_PING_TIMEOUT", 5*time.Second),
+			WriteTimeout:          getEnvDuration("MQTT_WRITE_TIMEOUT", 5*time.Second),
+			MaxReconnectInterval:  getEnvDuration("MQTT_MAX_RECONNECT_INTERVAL", 5*time.Second),
+			OperationTimeout:      getEnvDuration("MQTT_OPERATION_TIMEOUT", 10*time.Second),
+			CleanSession:          getEnv("MQTT_CLEAN_SESSION", "true") == "true",
+		},
+		Pipeline: PipelineConfig{
+			QueueSize:       getEnvInt("MQTT_PIPELINE_QUEUE_SIZE", 10000),
+			BatchSize:       getEnvInt("MQTT_PIPELINE_BATCH_SIZE", 500),
+			Workers:         getEnvInt("MQTT_PIPELINE_WORKERS", 2),
+			FlushInterval:   getEnvDuration("MQTT_PIPELINE_FLUSH_INTERVAL", time.Second),
+			FlushTimeout:    getEnvDuration("MQTT_PIPELINE_FLUSH_TIMEOUT", 10*time.Second),
+			Backpressure:    getEnv("MQTT_PIPELINE_BACKPRESSURE", "block"),
+			SpillDir:        getEnv("MQTT_PIPELINE_SPILL_DIR", "data/mqtt-spill"),
+			ShutdownTimeout: getEnvDuration("MQTT_PIPELINE_SHUTDOWN_TIMEOUT", 15*time.Second),
+		},
+		Stream: StreamConfig{
+			Heartbeat:    getEnvDuration("MQTT_STREAM_HEARTBEAT", 15*time.Second),
+			ClientBuffer: getEnvInt("MQTT_STREAM_CLIENT_BUFFER", 256),
+			ReplayLimit:  getEnvInt("MQTT_STREAM_REPLAY_LIMIT", 1000),
+			WriteTimeout: getEnvDuration("MQTT_STREAM_WRITE_TIMEOUT", 10*time.Second),
+		},
+		Retention: RetentionConfig{
+			Enabled:    getEnv("MQTT_RETENTION_ENABLED", "true") == "true",
+			Interval:   getEnvDuration("MQTT_RETENTION_INTERVAL", time.Hour),
+			BatchSize:  getEnvInt("MQTT_RETENTION_BATCH_SIZE", 5000),
+			BatchPause: getEnvDuration("MQTT_RETENTION_BATCH_PAUSE", 100*time.Millisecond),
+		},
+		Partition: PartitionConfig{
+			Interval:      getEnv("MQTT_PARTITION_INTERVAL", "day"),
+			Ahead:         getEnvInt("MQTT_PARTITIONS_AHEAD", 3),
+			CheckInterval: getEnvDuration("MQTT_PARTITION_CHECK_INTERVAL", time.Hour),
+		},
+		Rollups: RollupsConfig{
+			Enabled:  getEnv("MQTT_ROLLUP_ENABLED", "true") == "true",
+			Interval: getEnvDuration("MQTT_ROLLUP_INTERVAL", time.Minute),
+			Lag:      getEnvDuration("MQTT_ROLLUP_LAG", 2*time.Minute),
 		},
+		Alerts: AlertsConfig{
+			Enabled:       getEnv("MQTT_ALERTS_ENABLED", "true") == "true",
+			QueueSize:     getEnvInt("MQTT_ALERTS_QUEUE_SIZE", 10000),
+			NotifyTimeout: getEnvDuration("MQTT_ALERTS_NOTIFY_TIMEOUT", 10*time.Second),
+		},
+		Health: HealthConfig{
+			CheckTimeout:      getEnvDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+			CertExpiryWarning: getEnvDuration("HEALTH_CERT_EXPIRY_WARNING", 14*24*time.Hour),
+		},
+		Metrics: MetricsConfig{
+			Enabled: getEnv("METRICS_ENABLED", "true") == "true",
+			Path:    getEnv("METRICS_PATH", "/metrics"),
+		},
+		Tracing: TracingConfig{
+			Exporter:     getEnv("TRACING_EXPORTER", "none"),
+			ServiceName:  getEnv("TRACING_SERVICE_NAME", "prueba-api-http-postgresql"),
+			OTLPEndpoint: getEnv("TRACING_OTLP_ENDPOINT", ""),
+			File:         getEnv("TRACING_FILE", "data/traces.jsonl"),
+			SampleRatio:  getEnvFloat("TRACING_SAMPLE_RATIO", 1),
+		},
+		EmbeddedBroker: EmbeddedBrokerConfig{
+			Enabled: getEnv("MQTT_EMBEDDED_BROKER", "false") == "true",
+			Address: getEnv("MQTT_EMBEDDED_BROKER_ADDRESS", "127.0.0.1:1883"),
+			TLS:     getEnv("MQTT_EMBEDDED_BROKER_TLS", "false") == "true",
+			CertDir: getEnv("MQTT_EMBEDDED_BROKER_CERT_DIR", "data/embedded-broker"),
+		},
+	}, nil
+}
+
+// readConfigFile lee un fichero de configuración con líneas CLAVE=valor.
+// Se ignoran las líneas vacías y las que empiezan por #.
+func readConfigFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo abrir el fichero de configuración: %w", err)
+	}
+	defer file.Close()
+
+	values := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("%s:%d: se esperaba CLAVE=valor", path, lineNumber)
+		}
+		values[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
 	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error leyendo el fichero de configuración: %w", err)
+	}
+	return values, nil
 }
 
-func getEnv(key, defaultValue string) string {
+// lookup busca la clave en el entorno y, si no está, en el fichero de configuración
+func lookup(key string) (string, bool) {
 	if value, exists := os.LookupEnv(key); exists {
+		return value, true
+	}
+	value, exists := fileValues[key]
+	return value, exists
+}
+
+func getEnv(key, defaultValue string) string {
+	if value, exists := lookup(key); exists {
 		return value
 	}
 	return defaultValue
 }
 
+// getEnvList lee una lista separada por comas
+func getEnvList(key string, defaultValue []string) []string {
+	value, exists := lookup(key)
+	if !exists {
+		return defaultValue
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, exists := lookup(key); exists {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value, exists := lookup(key); exists {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := lookup(key); exists {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+// ServesLegacy indica si deben montarse las rutas legacy (/add, /update, ...)
+func (s *ServerConfig) ServesLegacy() bool {
+	return s.APIMode == APIModeLegacy || s.APIMode == APIModeBoth
+}
+
+// ServesV1 indica si deben montarse las rutas /api/v1
+func (s *ServerConfig) ServesV1() bool {
+	return s.APIMode == APIModeV1 || s.APIMode == APIModeBoth
+}
+
 func (d *DatabaseConfig) ConnectionString() string {
 
 	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
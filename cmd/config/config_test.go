@@ -0,0 +1,45 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadReadsConfigFileAndEnvOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.env")
+	content := `# Broker de desarrollo
+MQTT_BROKERS=ssl://broker-a:8883, ssl://broker-b:8883
+MQTT_USERNAME="sensor1"
+MQTT_KEEPALIVE=45s
+MQTT_CLEAN_SESSION=false
+`
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("MQTT_USERNAME", "from-env")
+
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ssl://broker-a:8883", "ssl://broker-b:8883"}, cfg.MQTT.Brokers)
+	assert.Equal(t, "from-env", cfg.MQTT.Username, "Environment variables take precedence over the file")
+	assert.Equal(t, 45*time.Second, cfg.MQTT.KeepAlive)
+	assert.False(t, cfg.MQTT.CleanSession)
+	assert.Equal(t, 10*time.Second, cfg.MQTT.ConnectTimeout, "Unset values keep their defaults")
+}
+
+func TestLoadRejectsMalformedConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.env")
+	assert.NoError(t, os.WriteFile(path, []byte("MQTT_BROKERS\n"), 0o600))
+	t.Setenv("CONFIG_FILE", path)
+
+	_, err := Load()
+	assert.Error(t, err)
+
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.env"))
+	_, err = Load()
+	assert.Error(t, err)
+}
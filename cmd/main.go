@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/JorgeePG/prueba-api-http-postgresql-/cmd/app"
 	"github.com/rs/zerolog"
@@ -9,27 +13,99 @@ import (
 )
 
 func main() {
+	embeddedBroker := flag.Bool("embedded-broker", false, "arranca un broker MQTT embebido en lugar de conectarse a MQTT_BROKERS (solo desarrollo)")
+	flag.Parse()
 
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
-	application := app.New()
+	application, err := app.New()
+	if err != nil {
+		log.Error().Err(err).Msg("Error al cargar la configuración")
+		os.Exit(1)
+	}
+	if *embeddedBroker {
+		application.EnableEmbeddedBroker()
+	}
+
+	// Subcomandos de mantenimiento: no arrancan el servidor
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(application, flag.Args()))
+	}
+
+	os.Exit(serve(application))
+}
+
+// serve arranca la aplicación y la detiene de forma ordenada al recibir SIGINT
+// o SIGTERM. Devuelve el código de salida.
+func serve(application *app.App) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	log.Info().Msg("Iniciando inicialización de la aplicación")
 	if err := application.Initialize(); err != nil {
 		log.Error().
 			Err(err).
 			Msg("Error al inicializar la aplicación")
-		return
+		// Se libera lo que llegara a arrancar (pipeline, broker, base de datos)
+		if err := application.Shutdown(); err != nil {
+			log.Error().Err(err).Msg("Error al detener la aplicación")
+		}
+		return 1
 	}
 	log.Info().
 		Msg("Aplicación inicializada correctamente")
-	defer application.Shutdown()
 
-	if err := application.Run(); err != nil {
+	status := 0
+	if err := application.Run(ctx); err != nil {
 		log.Error().
 			Err(err).
 			Msg("Error al ejecutar la aplicación")
+		status = 1
 	}
-	log.Info().
-		Msg("Aplicación finalizada correctamente")
+
+	// Una segunda señal durante la parada termina el proceso de inmediato
+	stop()
+	if err := application.Shutdown(); err != nil {
+		log.Error().
+			Err(err).
+			Msg("Error al detener la aplicación")
+		return 1
+	}
+	if status == 0 {
+		log.Info().
+			Msg("Aplicación finalizada correctamente")
+	}
+	return status
+}
+
+// runCommand ejecuta un subcomando de mantenimiento y devuelve el código de salida
+func runCommand(application *app.App, args []string) int {
+	defer func() {
+		if err := application.Shutdown(); err != nil {
+			log.Error().Err(err).Msg("Error al detener la aplicación")
+		}
+	}()
+
+	var err error
+	switch args[0] {
+	case "flag-plaintext-passwords":
+		err = application.FlagPlaintextPasswords(context.Background())
+	case "grant-role":
+		if len(args) != 3 {
+			log.Error().Msg("Uso: grant-role <username> <role>")
+			return 2
+		}
+		err = application.GrantRole(context.Background(), args[1], args[2])
+	case "migrate":
+		err = application.Migrate(context.Background(), args[1:])
+	default:
+		log.Error().Str("command", args[0]).Msg("Comando desconocido (disponibles: flag-plaintext-passwords, grant-role, migrate)")
+		return 2
+	}
+
+	if err != nil {
+		log.Error().Err(err).Str("command", args[0]).Msg("Error al ejecutar el comando")
+		return 1
+	}
+	return 0
 }
@@ -1,33 +1,43 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"net/http"
 
 	"github.com/JorgeePG/prueba-api-http-postgresql-/http/handler"
 	"github.com/JorgeePG/prueba-api-http-postgresql-/http/middleware"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/authz"
+	apimiddleware "github.com/JorgeePG/prueba-api-http-postgresql-/internal/interfaces/http/middleware"
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/log"
 )
 
 type Server struct {
-	router  *mux.Router
-	port    string
-	useSSL  bool
-	sslCert string
-	sslKey  string
+	router     *mux.Router
+	httpServer *http.Server
+	port       string
+	useSSL     bool
+	sslCert    string
+	sslKey     string
 }
 
 func New(port string, useSSL bool, sslCert, sslKey string) *Server {
-	return &Server{
+	s := &Server{
 		router:  mux.NewRouter(),
 		port:    port,
 		useSSL:  useSSL,
 		sslCert: sslCert,
 		sslKey:  sslKey,
 	}
-}
+	s.httpServer = &http.Server{
+		Addr:    ":" + port,
+		Handler: s.router,
+	}
+
+	// Traza de cada petición, legacy o /api/v1
+	s.router.Use(apimiddleware.Tracing)
 
-func (s *Server) SetupRoutes() {
 	// Middlewares de seguridad
 	s.router.Use(middleware.SecurityHeaders)
 	if s.useSSL {
@@ -35,26 +45,74 @@ func (s *Server) SetupRoutes() {
 	}
 	s.router.Use(middleware.CspControl)
 
-	s.router.HandleFunc("/", handler.ListV2).Methods("GET")
+	return s
+}
+
+// SetupRoutes registra las rutas legacy del servidor.
+// authenticate protege todas las rutas salvo el alta de usuarios (/add).
+func (s *Server) SetupRoutes(authenticate mux.MiddlewareFunc) {
 	s.router.HandleFunc("/add", handler.AddV2).Methods("POST")
-	s.router.HandleFunc("/update", handler.UpdateV2).Methods("POST")
-	s.router.HandleFunc("/delete", handler.DeleteV2).Methods("GET")
+
+	protected := s.router.NewRoute().Subrouter()
+	protected.Use(authenticate)
+
+	protected.Handle("/", requirePermission(authz.PermUsersRead, handler.ListV2)).Methods("GET")
+	protected.Handle("/update", apimiddleware.RequireSelfOrPermission(authz.PermUsersWrite, apimiddleware.UserIDFromQuery)(http.HandlerFunc(handler.UpdateV2))).Methods("POST")
+	protected.Handle("/delete", requirePermission(authz.PermUsersDelete, handler.DeleteV2)).Methods("GET")
 	// MQTT routes
-	s.router.HandleFunc("/mqtt/topic/add", handler.AddTopicSubscriber).Methods("GET")
-	s.router.HandleFunc("/mqtt/topic/delete", handler.DeleteTopicSubscriber).Methods("GET")
-	s.router.HandleFunc("/mqtt/messages", handler.ListMqttMessages).Methods("GET")
+	protected.Handle("/mqtt/topic/add", requirePermission(authz.PermMqttSubscriptionsManage, handler.AddTopicSubscriber)).Methods("GET")
+	protected.Handle("/mqtt/topic/delete", requirePermission(authz.PermMqttSubscriptionsManage, handler.DeleteTopicSubscriber)).Methods("GET")
+	protected.Handle("/mqtt/messages", requirePermission(authz.PermMqttMessagesRead, handler.ListMqttMessages)).Methods("GET")
 }
 
-func (s *Server) Start() error {
-	address := ":" + s.port
+// requirePermission protege un handler legacy con el permiso indicado
+func requirePermission(perm authz.Permission, h http.HandlerFunc) http.Handler {
+	return apimiddleware.RequirePermission(perm)(h)
+}
+
+// SetupHealthRoutes registra las sondas /healthz y /readyz. Son públicas y se
+// sirven en cualquier API_MODE, así que deben registrarse antes de Mount.
+func (s *Server) SetupHealthRoutes(liveness, readiness http.HandlerFunc) {
+	s.router.HandleFunc("/healthz", liveness).Methods("GET")
+	s.router.HandleFunc("/health", liveness).Methods("GET")
+	s.router.HandleFunc("/readyz", readiness).Methods("GET")
+}
+
+// SetupMetrics mide todas las peticiones con observer y sirve en path las
+// métricas de handler (formato Prometheus). Es pública, como las sondas de salud.
+func (s *Server) SetupMetrics(observer apimiddleware.HTTPObserver, path string, handler http.Handler) {
+	s.router.Use(apimiddleware.Metrics(observer))
+	s.router.Handle(path, handler).Methods("GET")
+}
+
+// Mount delega en handler todas las peticiones que no coincidan con una ruta legacy.
+// Debe llamarse después de SetupRoutes para que las rutas legacy tengan prioridad.
+func (s *Server) Mount(handler http.Handler) {
+	s.router.PathPrefix("/").Handler(apimiddleware.Mounted(handler))
+}
 
+// Start sirve las peticiones hasta que falla o se llama a Shutdown; en ese
+// caso devuelve nil
+func (s *Server) Start() error {
+	var err error
 	if s.useSSL {
 		log.Info().Msgf("🔒 Iniciando servidor HTTPS en el puerto %s", s.port)
 		log.Info().Msgf("📜 Usando certificado: %s", s.sslCert)
 		log.Info().Msgf("🔑 Usando clave privada: %s", s.sslKey)
-		return http.ListenAndServeTLS(address, s.sslCert, s.sslKey, s.router)
+		err = s.httpServer.ListenAndServeTLS(s.sslCert, s.sslKey)
 	} else {
 		log.Info().Msgf("⚠️  Iniciando servidor HTTP (no seguro) en el puerto %s", s.port)
-		return http.ListenAndServe(address, s.router)
+		err = s.httpServer.ListenAndServe()
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
 	}
+	return err
+}
+
+// Shutdown deja de aceptar conexiones y espera a que terminen las peticiones
+// en curso hasta que se cancele ctx. No espera a las conexiones WebSocket.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
 }
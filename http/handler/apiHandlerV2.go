@@ -2,12 +2,17 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/services"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/interfaces/http/middleware"
 	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/subscriber"
 	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/password"
 	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/repository"
 	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/service"
 )
@@ -15,10 +20,36 @@ import (
 // userService es una instancia global del servicio de usuarios
 var userService *service.UserService
 
-// init inicializa el servicio de usuarios
+// topicACLs aplica las reglas de acceso a topics MQTT. Sin configurar se deniega
+// el acceso a todos los topics salvo a los administradores.
+var topicACLs *services.TopicACLService
+
+// init inicializa el servicio de usuarios con los parámetros de hasheo por defecto
 func init() {
+	SetPasswordHasher(password.NewManager(
+		password.NewArgon2idHasher(password.DefaultArgon2Params),
+		password.NewBcryptHasher(0),
+	))
+}
+
+// SetPasswordHasher reconstruye el servicio de usuarios con el hasher de contraseñas indicado
+func SetPasswordHasher(hasher *password.Manager) {
 	userRepo := repository.NewSQLBoilerUserRepository()
-	userService = service.NewUserService(userRepo)
+	userService = service.NewUserService(userRepo, hasher)
+}
+
+// SetTopicACLService configura el servicio que aplica las ACLs de topics MQTT
+func SetTopicACLService(acls *services.TopicACLService) {
+	topicACLs = acls
+}
+
+// authorizeTopic comprueba que el usuario autenticado tenga el acceso indicado sobre el topic
+func authorizeTopic(r *http.Request, topic, access string) error {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok || topicACLs == nil {
+		return services.ErrTopicForbidden
+	}
+	return topicACLs.Authorize(r.Context(), principal.UserID, principal.Roles, topic, access)
 }
 
 // AddV2 handles creation of new users using the new architecture
@@ -94,13 +125,21 @@ func UpdateV2(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := userService.UpdateUser(r.Context(), id, &req)
+	// Solo quien cambia su propia contraseña debe dar la actual
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	self := !ok || principal.UserID == id
+
+	user, err := userService.UpdateUser(r.Context(), id, &req, self)
 	if err != nil {
 		response := models.Response{
 			Status:  "error",
 			Message: "Failed to update user: " + err.Error(),
 		}
-		w.WriteHeader(http.StatusInternalServerError)
+		if errors.Is(err, service.ErrOldPasswordRequired) || errors.Is(err, service.ErrInvalidOldPassword) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
 		json.NewEncoder(w).Encode(response)
 		return
 	}
@@ -226,13 +265,53 @@ func AddTopicSubscriber(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Suscribirse requiere permiso de lectura sobre el topic
+	if err := authorizeTopic(r, topic, entities.TopicAccessRead); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrTopicForbidden) {
+			status = http.StatusForbidden
+		}
+		response := models.Response{
+			Status:  "error",
+			Message: err.Error(),
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// QoS opcional (?qos=0|1|2)
+	qos := subscriber.DefaultQOS
+	if qosStr := r.URL.Query().Get("qos"); qosStr != "" {
+		q, err := strconv.Atoi(qosStr)
+		if err != nil || q < 0 || q > 2 {
+			response := models.Response{
+				Status:  "error",
+				Message: "qos must be 0, 1 or 2",
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		qos = byte(q)
+	}
+
+	var createdBy *int
+	if principal, ok := middleware.PrincipalFromContext(r.Context()); ok {
+		createdBy = &principal.UserID
+	}
+
 	// Intentar agregar el suscriptor
-	if err := subscriber.AddTopicSubscriber(topic); err != nil {
+	if err := subscriber.AddTopicSubscriber(topic, qos, createdBy); err != nil {
+		status := http.StatusConflict
+		if errors.Is(err, subscriber.ErrBrokerUnavailable) {
+			status = http.StatusServiceUnavailable
+		}
 		response := models.Response{
 			Status:  "error",
 			Message: err.Error(),
 		}
-		w.WriteHeader(http.StatusConflict)
+		w.WriteHeader(status)
 		json.NewEncoder(w).Encode(response)
 		return
 	}
@@ -309,7 +388,36 @@ func ListMqttMessages(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	messages, err := subscriber.ListMqttMessages(limit)
+	// topic, from, to, qos, retained, sort, cursor, where y jsonpath
+	query, err := repository.ParseMessageQuery(r.URL.Query())
+	if err != nil {
+		response := models.Response{
+			Status:  "error",
+			Message: "Invalid filter: " + err.Error(),
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	query.Limit = limit
+
+	// Solo se devuelven mensajes de los topics que el usuario puede leer
+	var page *repository.MessagePage
+	filters, unrestricted, err := readableTopicFilters(r)
+	if err == nil {
+		query.Restricted = !unrestricted
+		query.TopicFilters = filters
+		page, err = subscriber.FindMqttMessages(r.Context(), query)
+	}
+	if errors.Is(err, repository.ErrInvalidMessageQuery) {
+		response := models.Response{
+			Status:  "error",
+			Message: "Invalid filter: " + err.Error(),
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
 	if err != nil {
 		response := models.Response{
 			Status:  "error",
@@ -320,14 +428,28 @@ func ListMqttMessages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// El cursor de la siguiente página va en una cabecera para no cambiar el formato de la respuesta
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
+
 	response := models.Response{
 		Status:  "success",
 		Message: "MQTT messages retrieved successfully",
-		Data:    messages,
+		Data:    page.Messages,
 	}
 	json.NewEncoder(w).Encode(response)
 }
 
+// readableTopicFilters devuelve los filtros de topic que puede leer el usuario autenticado
+func readableTopicFilters(r *http.Request) ([]string, bool, error) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok || topicACLs == nil {
+		return []string{}, false, nil
+	}
+	return topicACLs.ReadableFilters(r.Context(), principal.UserID, principal.Roles)
+}
+
 // isValidMQTTTopic valida si un topic MQTT es válido
 func isValidMQTTTopic(topic string) bool {
 	if topic == "" || len(topic) > 65535 {
@@ -23,8 +23,9 @@ func Initialize(connStr string) error {
 }
 
 // Close closes the database connection
-func Close() {
-	if DB != nil {
-		DB.Close()
+func Close() error {
+	if DB == nil {
+		return nil
 	}
+	return DB.Close()
 }
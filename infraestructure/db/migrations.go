@@ -1,111 +1,459 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"embed"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
 	_ "github.com/lib/pq"
 )
 
-const (
-	dbHost     = "localhost"
-	dbPort     = 5432
-	dbUser     = "postgres"
-	dbPassword = "postgres"
-	dbName     = "api_db"
+// Las migraciones son parejas de ficheros NNN_nombre.up.sql / NNN_nombre.down.sql.
+// El número es la versión: se aplican en orden creciente y cada versión
+// aplicada se registra en schema_migrations, así que solo se ejecuta una vez.
+// Cada migración se aplica en una transacción (no admiten sentencias como
+// CREATE INDEX CONCURRENTLY) y un advisory lock impide que varias réplicas
+// migren a la vez. Las bases de datos creadas antes de registrar versiones
+// vuelven a ejecutar todas las migraciones una vez; por eso son idempotentes.
+
+// migrationsLockKey identifica el advisory lock de las migraciones
+const migrationsLockKey int64 = 7267340115
+
+var (
+	// ErrNoDownMigration se devuelve al deshacer una migración sin fichero .down.sql
+	ErrNoDownMigration = errors.New("la migración no tiene fichero down")
+	// ErrUnknownMigration se devuelve al deshacer una versión aplicada cuyo fichero ya no existe
+	ErrUnknownMigration = errors.New("versión aplicada sin fichero de migración")
 )
 
-// RunMigrations ejecuta los scripts SQL para crear o actualizar la estructura de la base de datos
-func RunMigrations() error {
-	log.Info().Msg("Iniciando proceso de migraciones") // NUEVO
+var migrationFileRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
 
-	// Construir la cadena de conexión
-	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		dbHost, dbPort, dbUser, dbPassword, dbName)
+// Migration es una versión del esquema con sus scripts para aplicarla y deshacerla
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
 
-	log.Debug().Str("connStr", connStr).Msg("Cadena de conexión construida") // NUEVO
+// MigrationStatus indica si una versión está aplicada. Missing marca las
+// versiones registradas en schema_migrations cuyo fichero ya no existe.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	Missing   bool
+}
 
-	// Abrir la conexión a la base de datos
-	db, err := sql.Open("postgres", connStr)
+// LoadMigrations lee las migraciones de fsys ordenadas por versión. Los
+// ficheros .sql que no siguen el formato NNN_nombre.(up|down).sql son un error
+// para que no se ignoren en silencio.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
 	if err != nil {
-		log.Error().Err(err).Msg("Error al abrir la conexión a la base de datos")
-		return fmt.Errorf("error al abrir la conexión a la base de datos: %v", err)
+		return nil, fmt.Errorf("error al leer la carpeta de migraciones: %w", err)
 	}
-	defer db.Close()
 
-	// Probar la conexión
-	err = db.Ping()
-	if err != nil {
-		log.Error().Err(err).Msg("Error al conectar a la base de datos")
-		return fmt.Errorf("error al conectar a la base de datos: %v", err)
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+
+		parts := migrationFileRe.FindStringSubmatch(entry.Name())
+		if parts == nil {
+			return nil, fmt.Errorf("nombre de migración no válido %s (se espera NNN_nombre.up.sql o NNN_nombre.down.sql)", entry.Name())
+		}
+		version, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("versión de migración no válida en %s: %w", entry.Name(), err)
+		}
+
+		script, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("error al leer el archivo de migración %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != parts[2] {
+			return nil, fmt.Errorf("la versión %d tiene dos nombres: %s y %s", version, migration.Name, parts[2])
+		}
+		if parts[3] == "up" {
+			migration.Up = string(script)
+		} else {
+			migration.Down = string(script)
+		}
 	}
-	log.Info().Msg("Conexión a la base de datos establecida correctamente")
 
-	// Ejecutar los scripts de migración
-	// Comprobar varias rutas relativas posibles para encontrar las migraciones
-	possiblePaths := []string{
-		"infraestructure/db/migrations",       // Si se ejecuta desde la raíz del proyecto
-		"../infraestructure/db/migrations",    // Si se ejecuta desde cmd/
-		"../../infraestructure/db/migrations", // Si se ejecuta desde otro subdirectorio
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("la migración %03d_%s no tiene fichero up", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
 	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator aplica y deshace migraciones registrándolas en schema_migrations
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator crea un migrador para las migraciones indicadas
+func NewMigrator(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
 
-	var migrationsPath string
-	var pathExists bool
+// Up aplica en orden todas las migraciones pendientes y las devuelve
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
 
-	for _, path := range possiblePaths {
-		if _, err := os.Stat(path); err == nil {
-			migrationsPath = path
-			pathExists = true
-			break
+		for _, migration := range pendingMigrations(m.migrations, versions) {
+			if err := apply(ctx, conn, migration); err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down deshace las últimas steps migraciones aplicadas, de la más reciente a la más antigua
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		migrations, err := lastApplied(m.migrations, versions, steps)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			if err := revert(ctx, conn, migration); err != nil {
+				return err
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Redo deshace y vuelve a aplicar la última migración aplicada
+func (m *Migrator) Redo(ctx context.Context) (*Migration, error) {
+	var redone *Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		migrations, err := lastApplied(m.migrations, versions, 1)
+		if err != nil || len(migrations) == 0 {
+			return err
+		}
+		if err := revert(ctx, conn, migrations[0]); err != nil {
+			return err
+		}
+		if err := apply(ctx, conn, migrations[0]); err != nil {
+			return err
+		}
+		redone = &migrations[0]
+		return nil
+	})
+	return redone, err
+}
+
+// Status devuelve el estado de cada migración conocida y de las versiones
+// aplicadas que ya no tienen fichero
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var status []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		known := make(map[int64]bool, len(m.migrations))
+		for _, migration := range m.migrations {
+			known[migration.Version] = true
+			entry := MigrationStatus{Version: migration.Version, Name: migration.Name}
+			if appliedAt, ok := versions[migration.Version]; ok {
+				entry.Applied = true
+				entry.AppliedAt = &appliedAt
+			}
+			status = append(status, entry)
+		}
+		for version, appliedAt := range versions {
+			if !known[version] {
+				appliedAt := appliedAt
+				status = append(status, MigrationStatus{Version: version, Applied: true, AppliedAt: &appliedAt, Missing: true})
+			}
+		}
+		sort.Slice(status, func(i, j int) bool { return status[i].Version < status[j].Version })
+		return nil
+	})
+	return status, err
+}
+
+// MigrationVersion resume el estado del esquema respecto a las migraciones conocidas
+type MigrationVersion struct {
+	// Current es la versión aplicada más alta; 0 si no hay ninguna
+	Current int64 `json:"current"`
+	// Latest es la versión más alta que conoce el binario
+	Latest int64 `json:"latest"`
+	// Pending son las migraciones conocidas sin aplicar
+	Pending int `json:"pending"`
+	// Unknown son las versiones aplicadas que el binario no conoce
+	Unknown int `json:"unknown"`
+}
+
+// Version consulta la versión del esquema sin tomar el bloqueo de las
+// migraciones, para poder usarse en las comprobaciones de salud
+func (m *Migrator) Version(ctx context.Context) (MigrationVersion, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return MigrationVersion{}, err
+	}
+	defer conn.Close()
+
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return MigrationVersion{}, err
+	}
+	return migrationVersion(m.migrations, applied), nil
+}
+
+// migrationVersion compara las versiones aplicadas con las migraciones conocidas
+func migrationVersion(migrations []Migration, applied map[int64]time.Time) MigrationVersion {
+	var version MigrationVersion
+	known := make(map[int64]bool, len(migrations))
+	for _, migration := range migrations {
+		known[migration.Version] = true
+		if migration.Version > version.Latest {
+			version.Latest = migration.Version
+		}
+	}
+	for v := range applied {
+		if v > version.Current {
+			version.Current = v
+		}
+		if !known[v] {
+			version.Unknown++
 		}
 	}
+	version.Pending = len(pendingMigrations(migrations, applied))
+	return version
+}
 
-	if !pathExists {
-		log.Error().Msg("No se pudo encontrar la carpeta de migraciones en las rutas relativas especificadas")
-		return fmt.Errorf("no se pudo encontrar la carpeta de migraciones en ninguna de las rutas relativas")
+// withLock ejecuta fn en una conexión que tiene el advisory lock de las
+// migraciones. Si otra réplica está migrando, espera a que termine.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("error al obtener una conexión para migrar: %w", err)
 	}
+	defer conn.Close()
 
-	log.Info().Msgf("Buscando migraciones en: %s", migrationsPath)
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationsLockKey); err != nil {
+		return fmt.Errorf("error al obtener el bloqueo de migraciones: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationsLockKey)
 
-	files, err := os.ReadDir(migrationsPath)
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`)
 	if err != nil {
-		log.Error().Err(err).Msg("Error al leer la carpeta de migraciones")
-		return fmt.Errorf("error al leer la carpeta de migraciones: %v", err)
+		return fmt.Errorf("error al crear la tabla schema_migrations: %w", err)
 	}
 
-	log.Info().Int("count", len(files)).Msg("Archivos encontrados en carpeta de migraciones") // NUEVO
+	return fn(conn)
+}
 
-	migrationCount := 0 // NUEVO: contador
-	for _, file := range files {
-		if filepath.Ext(file.Name()) == ".sql" {
-			migrationCount++ // NUEVO
-			log.Info().Msgf("Ejecutando migración: %s", file.Name())
+// appliedVersions devuelve las versiones aplicadas con su fecha
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("error al leer schema_migrations: %w", err)
+	}
+	defer rows.Close()
 
-			filePath := filepath.Join(migrationsPath, file.Name())
-			sqlScript, err := os.ReadFile(filePath)
-			if err != nil {
-				log.Fatal().Err(err).Msgf("Error al leer el archivo de migración %s", file.Name())
-				return fmt.Errorf("error al leer el archivo de migración %s: %v", file.Name(), err)
-			}
+	versions := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		versions[version] = appliedAt
+	}
+	return versions, rows.Err()
+}
 
-			_, err = db.Exec(string(sqlScript))
-			if err != nil {
-				log.Fatal().Err(err).Msgf("Error al ejecutar la migración %s", file.Name())
-				return fmt.Errorf("error al ejecutar la migración %s: %v", file.Name(), err)
-			}
+// pendingMigrations devuelve, en orden, las migraciones que no están aplicadas
+func pendingMigrations(migrations []Migration, applied map[int64]time.Time) []Migration {
+	var pending []Migration
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending
+}
 
-			log.Info().Msgf("Migración ejecutada correctamente: %s", file.Name())
-		} else {
-			log.Debug().Str("file", file.Name()).Msg("Archivo ignorado (no es .sql)") // NUEVO
+// lastApplied devuelve las últimas steps migraciones aplicadas, de la más
+// reciente a la más antigua. Falla si alguna ya no tiene fichero.
+func lastApplied(migrations []Migration, applied map[int64]time.Time, steps int) ([]Migration, error) {
+	versions := make([]int64, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+	if steps < len(versions) {
+		versions = versions[:steps]
+	}
+
+	byVersion := make(map[int64]Migration, len(migrations))
+	for _, migration := range migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	result := make([]Migration, 0, len(versions))
+	for _, version := range versions {
+		migration, ok := byVersion[version]
+		if !ok {
+			return nil, fmt.Errorf("%w: %d", ErrUnknownMigration, version)
 		}
+		result = append(result, migration)
+	}
+	return result, nil
+}
+
+// apply ejecuta el script up y registra la versión en la misma transacción
+func apply(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	log.Info().Int64("version", migration.Version).Str("name", migration.Name).Msg("Aplicando migración")
+	return inTx(ctx, conn, migration, migration.Up,
+		`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, migration.Version, migration.Name)
+}
+
+// revert ejecuta el script down y borra el registro de la versión en la misma transacción
+func revert(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	if migration.Down == "" {
+		return fmt.Errorf("%w: %03d_%s", ErrNoDownMigration, migration.Version, migration.Name)
+	}
+	log.Info().Int64("version", migration.Version).Str("name", migration.Name).Msg("Deshaciendo migración")
+	return inTx(ctx, conn, migration, migration.Down,
+		`DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+}
+
+func inTx(ctx context.Context, conn *sql.Conn, migration Migration, script, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("error al ejecutar la migración %03d_%s: %w", migration.Version, migration.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return fmt.Errorf("error al registrar la migración %03d_%s: %w", migration.Version, migration.Name, err)
+	}
+	return tx.Commit()
+}
+
+// embeddedMigrations son las migraciones incluidas en el binario
+//
+//go:embed migrations/*.sql
+var embeddedMigrations embed.FS
+
+// MigrationsFS devuelve las migraciones del directorio indicado o, si está
+// vacío, las incluidas en el binario
+func MigrationsFS(dir string) (fs.FS, error) {
+	if dir == "" {
+		return fs.Sub(embeddedMigrations, "migrations")
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo abrir la carpeta de migraciones: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s no es un directorio", dir)
+	}
+	return os.DirFS(dir), nil
+}
+
+// DefaultMigrator crea un migrador con las migraciones de dir o, si está
+// vacío, con las incluidas en el binario
+func DefaultMigrator(db *sql.DB, dir string) (*Migrator, error) {
+	fsys, err := MigrationsFS(dir)
+	if err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		log.Info().Str("dir", dir).Msg("Usando las migraciones del directorio configurado")
+	}
+
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return NewMigrator(db, migrations), nil
+}
+
+// RunMigrations aplica las migraciones pendientes sobre la base de datos
+// inicializada con Initialize. dir sustituye las migraciones incluidas en el binario.
+func RunMigrations(dir string) error {
+	log.Info().Msg("Iniciando proceso de migraciones")
+	if DB == nil {
+		return fmt.Errorf("la base de datos no está inicializada")
+	}
+
+	migrator, err := DefaultMigrator(DB, dir)
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		log.Error().Err(err).Msg("Error al aplicar las migraciones")
+		return err
 	}
 
 	log.Info().
-		Int("total_migrations", migrationCount).
-		Msg("Todas las migraciones se ejecutaron correctamente") // MEJORADO
+		Int("applied", len(applied)).
+		Msg("Migraciones aplicadas correctamente")
 	return nil
 }
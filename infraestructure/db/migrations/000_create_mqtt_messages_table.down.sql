@@ -0,0 +1,4 @@
+-- Archivo: 000_create_mqtt_messages_table.down.sql
+-- Descripción: Elimina la tabla de mensajes MQTT
+
+DROP TABLE IF EXISTS mqtt_messages;
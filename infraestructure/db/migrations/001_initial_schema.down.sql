@@ -0,0 +1,4 @@
+-- Archivo: 001_initial_schema.down.sql
+-- Descripción: Elimina el esquema inicial
+
+DROP TABLE IF EXISTS users;
@@ -1,4 +1,4 @@
--- Archivo: 001_initial_schema.sql
+-- Archivo: 001_initial_schema.up.sql
 -- Descripción: Esquema inicial para la API HTTP con PostgreSQL
 
 -- Tabla de usuarios
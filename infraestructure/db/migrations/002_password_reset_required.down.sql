@@ -0,0 +1,4 @@
+-- Archivo: 002_password_reset_required.down.sql
+-- Descripción: Elimina la marca de reseteo obligatorio de contraseña
+
+ALTER TABLE users DROP COLUMN IF EXISTS password_reset_required;
@@ -0,0 +1,6 @@
+-- Archivo: 002_password_reset_required.up.sql
+-- Descripción: Marca de reseteo obligatorio para usuarios con contraseñas heredadas en claro
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_reset_required BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMENT ON COLUMN users.password_reset_required IS 'El usuario debe cambiar la contraseña antes de poder autenticarse';
@@ -0,0 +1,4 @@
+-- Archivo: 003_refresh_tokens.down.sql
+-- Descripción: Elimina los refresh tokens
+
+DROP TABLE IF EXISTS refresh_tokens;
@@ -0,0 +1,19 @@
+-- Archivo: 003_refresh_tokens.up.sql
+-- Descripción: Refresh tokens rotatorios emitidos en el login
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    family_id VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    replaced_by INTEGER REFERENCES refresh_tokens(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+
+COMMENT ON TABLE refresh_tokens IS 'Refresh tokens emitidos (solo se guarda el hash SHA-256 del valor)';
+COMMENT ON COLUMN refresh_tokens.family_id IS 'Cadena de rotación: reutilizar un token rotado revoca toda la cadena';
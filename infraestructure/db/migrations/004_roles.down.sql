@@ -0,0 +1,5 @@
+-- Archivo: 004_roles.down.sql
+-- Descripción: Elimina los roles y sus asignaciones
+
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS roles;
@@ -0,0 +1,24 @@
+-- Archivo: 004_roles.up.sql
+-- Descripción: Roles de usuario para el control de acceso
+
+CREATE TABLE IF NOT EXISTS roles (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE,
+    description VARCHAR(255)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    granted_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, role_id)
+);
+
+INSERT INTO roles (name, description) VALUES
+    ('admin', 'Acceso total: gestión de usuarios, roles y suscripciones MQTT'),
+    ('operator', 'Consulta de usuarios, suscripciones y mensajes MQTT'),
+    ('viewer', 'Solo lectura de mensajes MQTT')
+ON CONFLICT (name) DO NOTHING;
+
+COMMENT ON TABLE roles IS 'Roles disponibles; los permisos de cada rol se definen en la aplicación';
+COMMENT ON TABLE user_roles IS 'Roles asignados a cada usuario';
@@ -0,0 +1,4 @@
+-- Archivo: 005_mqtt_topic_acls.down.sql
+-- Descripción: Elimina las ACL de topics MQTT
+
+DROP TABLE IF EXISTS mqtt_topic_acls;
@@ -0,0 +1,16 @@
+-- Archivo: 005_mqtt_topic_acls.up.sql
+-- Descripción: Reglas de acceso por usuario a topics MQTT (modelo acl_file de Mosquitto)
+
+CREATE TABLE IF NOT EXISTS mqtt_topic_acls (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    topic_filter VARCHAR(255) NOT NULL,
+    access VARCHAR(10) NOT NULL CHECK (access IN ('read', 'write', 'readwrite')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, topic_filter)
+);
+
+CREATE INDEX IF NOT EXISTS idx_mqtt_topic_acls_user_id ON mqtt_topic_acls(user_id);
+
+COMMENT ON TABLE mqtt_topic_acls IS 'Permisos de lectura/escritura de cada usuario sobre filtros de topics MQTT';
+COMMENT ON COLUMN mqtt_topic_acls.topic_filter IS 'Filtro de topic; admite los comodines + y #';
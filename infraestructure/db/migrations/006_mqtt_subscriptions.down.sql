@@ -0,0 +1,4 @@
+-- Archivo: 006_mqtt_subscriptions.down.sql
+-- Descripción: Elimina las suscripciones MQTT persistentes
+
+DROP TABLE IF EXISTS mqtt_subscriptions;
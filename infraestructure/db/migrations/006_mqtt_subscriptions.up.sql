@@ -0,0 +1,16 @@
+-- Archivo: 006_mqtt_subscriptions.up.sql
+-- Descripción: Suscripciones MQTT persistentes, restauradas al arrancar la aplicación
+
+CREATE TABLE IF NOT EXISTS mqtt_subscriptions (
+    id SERIAL PRIMARY KEY,
+    topic VARCHAR(255) NOT NULL UNIQUE,
+    qos SMALLINT NOT NULL DEFAULT 1 CHECK (qos BETWEEN 0 AND 2),
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE INDEX IF NOT EXISTS idx_mqtt_subscriptions_enabled ON mqtt_subscriptions(enabled);
+
+COMMENT ON TABLE mqtt_subscriptions IS 'Topics suscritos desde la API; al eliminar una suscripción se deshabilita';
+COMMENT ON COLUMN mqtt_subscriptions.created_by IS 'Usuario que creó la suscripción (NULL si se desconoce)';
@@ -0,0 +1,7 @@
+-- Archivo: 007_mqtt_payload_json.down.sql
+-- Descripción: Elimina el payload JSONB de los mensajes MQTT (el payload de texto se conserva)
+
+DROP INDEX IF EXISTS idx_mqtt_messages_payload_json;
+ALTER TABLE mqtt_messages DROP COLUMN IF EXISTS payload_json;
+ALTER TABLE mqtt_messages DROP COLUMN IF EXISTS payload_raw;
+DROP FUNCTION IF EXISTS try_parse_jsonb(TEXT);
@@ -0,0 +1,26 @@
+-- Archivo: 007_mqtt_payload_json.up.sql
+-- Descripción: Payload JSON de los mensajes MQTT como JSONB para poder filtrar por su contenido
+
+ALTER TABLE mqtt_messages ADD COLUMN IF NOT EXISTS payload_json JSONB;
+ALTER TABLE mqtt_messages ADD COLUMN IF NOT EXISTS payload_raw BYTEA;
+
+-- jsonb_path_ops sirve tanto a @> (where=campo:valor) como a @? (JSONPath)
+CREATE INDEX IF NOT EXISTS idx_mqtt_messages_payload_json ON mqtt_messages USING GIN (payload_json jsonb_path_ops);
+
+-- Devuelve NULL en lugar de fallar si el texto no es JSON
+CREATE OR REPLACE FUNCTION try_parse_jsonb(value TEXT) RETURNS JSONB AS $$
+BEGIN
+    RETURN value::jsonb;
+EXCEPTION WHEN others THEN
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+-- Rellenar los mensajes anteriores: JSON en payload_json y el resto en payload_raw
+UPDATE mqtt_messages
+SET payload_json = try_parse_jsonb(payload),
+    payload_raw = CASE WHEN try_parse_jsonb(payload) IS NULL THEN convert_to(payload, 'UTF8') END
+WHERE payload_json IS NULL AND payload_raw IS NULL;
+
+COMMENT ON COLUMN mqtt_messages.payload_json IS 'Payload cuando es JSON válido (NULL en otro caso)';
+COMMENT ON COLUMN mqtt_messages.payload_raw IS 'Bytes originales de los payloads que no son JSON';
@@ -0,0 +1,5 @@
+-- Archivo: 008_mqtt_messages_keyset_index.down.sql
+-- Descripción: Elimina los índices de paginación por cursor
+
+DROP INDEX IF EXISTS idx_mqtt_messages_topic_received_at_id;
+DROP INDEX IF EXISTS idx_mqtt_messages_received_at_id;
@@ -0,0 +1,5 @@
+-- Archivo: 008_mqtt_messages_keyset_index.up.sql
+-- Descripción: Índices para paginar mqtt_messages por cursor sobre (received_at, id)
+
+CREATE INDEX IF NOT EXISTS idx_mqtt_messages_received_at_id ON mqtt_messages(received_at, id);
+CREATE INDEX IF NOT EXISTS idx_mqtt_messages_topic_received_at_id ON mqtt_messages(topic, received_at, id);
@@ -0,0 +1,5 @@
+-- Archivo: 009_mqtt_messages_direction.down.sql
+-- Descripción: Elimina la dirección de los mensajes MQTT
+
+ALTER TABLE mqtt_messages DROP CONSTRAINT IF EXISTS mqtt_messages_direction_check;
+ALTER TABLE mqtt_messages DROP COLUMN IF EXISTS direction;
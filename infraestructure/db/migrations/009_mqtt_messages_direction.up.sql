@@ -0,0 +1,15 @@
+-- Archivo: 009_mqtt_messages_direction.up.sql
+-- Descripción: Distingue los mensajes recibidos de los publicados desde la API
+
+ALTER TABLE mqtt_messages ADD COLUMN IF NOT EXISTS direction VARCHAR(8) NOT NULL DEFAULT 'inbound';
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint WHERE conname = 'mqtt_messages_direction_check'
+    ) THEN
+        ALTER TABLE mqtt_messages
+            ADD CONSTRAINT mqtt_messages_direction_check CHECK (direction IN ('inbound', 'outbound'));
+    END IF;
+END
+$$;
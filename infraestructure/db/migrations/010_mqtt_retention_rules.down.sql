@@ -0,0 +1,4 @@
+-- Archivo: 010_mqtt_retention_rules.down.sql
+-- Descripción: Elimina las reglas de retención de mensajes MQTT
+
+DROP TABLE IF EXISTS mqtt_retention_rules;
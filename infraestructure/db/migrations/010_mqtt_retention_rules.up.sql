@@ -0,0 +1,17 @@
+-- Archivo: 010_mqtt_retention_rules.up.sql
+-- Descripción: Reglas de retención de mqtt_messages por antigüedad y/o número de filas
+
+CREATE TABLE IF NOT EXISTS mqtt_retention_rules (
+    id SERIAL PRIMARY KEY,
+    topic_filter VARCHAR(255) NOT NULL UNIQUE,
+    max_age_seconds BIGINT CHECK (max_age_seconds > 0),
+    max_rows BIGINT CHECK (max_rows > 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (max_age_seconds IS NOT NULL OR max_rows IS NOT NULL)
+);
+
+COMMENT ON TABLE mqtt_retention_rules IS 'Cuánto se conservan los mensajes MQTT; cada mensaje sigue la regla más específica que coincide con su topic';
+COMMENT ON COLUMN mqtt_retention_rules.topic_filter IS 'Filtro de topic; la regla de # es la retención por defecto';
+COMMENT ON COLUMN mqtt_retention_rules.max_age_seconds IS 'Antigüedad máxima de los mensajes (NULL: sin límite)';
+COMMENT ON COLUMN mqtt_retention_rules.max_rows IS 'Mensajes que se conservan como máximo, los más recientes (NULL: sin límite)';
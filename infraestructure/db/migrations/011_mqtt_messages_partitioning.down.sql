@@ -0,0 +1,46 @@
+-- Archivo: 011_mqtt_messages_partitioning.down.sql
+-- Descripción: Vuelve a convertir mqtt_messages en una tabla sin particionar conservando los mensajes
+
+DO $$
+DECLARE
+    seq TEXT;
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_partitioned_table WHERE partrelid = to_regclass('mqtt_messages')) THEN
+        RETURN;
+    END IF;
+
+    CREATE TABLE mqtt_messages_unpartitioned (
+        id INTEGER NOT NULL,
+        topic VARCHAR(255) NOT NULL,
+        payload TEXT NOT NULL,
+        received_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+        qos INTEGER DEFAULT 0,
+        retained BOOLEAN DEFAULT FALSE,
+        payload_json JSONB,
+        payload_raw BYTEA,
+        direction VARCHAR(8) NOT NULL DEFAULT 'inbound',
+        CONSTRAINT mqtt_messages_unpartitioned_pkey PRIMARY KEY (id),
+        CONSTRAINT mqtt_messages_unpartitioned_direction_check CHECK (direction IN ('inbound', 'outbound'))
+    );
+
+    seq := pg_get_serial_sequence('mqtt_messages', 'id');
+    EXECUTE format('ALTER TABLE mqtt_messages_unpartitioned ALTER COLUMN id SET DEFAULT nextval(%L)', seq);
+    EXECUTE format('ALTER SEQUENCE %s OWNED BY mqtt_messages_unpartitioned.id', seq);
+
+    INSERT INTO mqtt_messages_unpartitioned (id, topic, payload, received_at, qos, retained, payload_json, payload_raw, direction)
+    SELECT id, topic, payload, received_at, qos, retained, payload_json, payload_raw, direction
+    FROM mqtt_messages;
+
+    -- Elimina también todas las particiones
+    DROP TABLE mqtt_messages;
+    ALTER TABLE mqtt_messages_unpartitioned RENAME TO mqtt_messages;
+    ALTER TABLE mqtt_messages RENAME CONSTRAINT mqtt_messages_unpartitioned_pkey TO mqtt_messages_pkey;
+    ALTER TABLE mqtt_messages RENAME CONSTRAINT mqtt_messages_unpartitioned_direction_check TO mqtt_messages_direction_check;
+END
+$$;
+
+CREATE INDEX IF NOT EXISTS idx_mqtt_messages_topic ON mqtt_messages(topic);
+CREATE INDEX IF NOT EXISTS idx_mqtt_messages_received_at ON mqtt_messages(received_at);
+CREATE INDEX IF NOT EXISTS idx_mqtt_messages_payload_json ON mqtt_messages USING GIN (payload_json jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS idx_mqtt_messages_received_at_id ON mqtt_messages(received_at, id);
+CREATE INDEX IF NOT EXISTS idx_mqtt_messages_topic_received_at_id ON mqtt_messages(topic, received_at, id);
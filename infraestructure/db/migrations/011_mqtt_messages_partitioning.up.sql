@@ -0,0 +1,72 @@
+-- Archivo: 011_mqtt_messages_partitioning.up.sql
+-- Descripción: Particiona mqtt_messages por rangos de received_at conservando los mensajes.
+-- Los mensajes existentes se copian a particiones mensuales; después la
+-- aplicación crea por adelantado las particiones siguientes (diarias o
+-- mensuales) y elimina las que han caducado según las reglas de retención.
+
+-- Los límites de las particiones son medianoches UTC
+SET LOCAL timezone = 'UTC';
+
+DO $$
+DECLARE
+    seq TEXT;
+    period_start TIMESTAMPTZ;
+BEGIN
+    -- Ya está particionada: la migración se está volviendo a ejecutar
+    IF EXISTS (SELECT 1 FROM pg_partitioned_table WHERE partrelid = to_regclass('mqtt_messages')) THEN
+        RETURN;
+    END IF;
+
+    -- La clave primaria de una tabla particionada tiene que incluir la clave de partición
+    CREATE TABLE mqtt_messages_partitioned (
+        id INTEGER NOT NULL,
+        topic VARCHAR(255) NOT NULL,
+        payload TEXT NOT NULL,
+        received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        qos INTEGER DEFAULT 0,
+        retained BOOLEAN DEFAULT FALSE,
+        payload_json JSONB,
+        payload_raw BYTEA,
+        direction VARCHAR(8) NOT NULL DEFAULT 'inbound',
+        CONSTRAINT mqtt_messages_partitioned_pkey PRIMARY KEY (id, received_at),
+        CONSTRAINT mqtt_messages_partitioned_direction_check CHECK (direction IN ('inbound', 'outbound'))
+    ) PARTITION BY RANGE (received_at);
+
+    -- La secuencia de la tabla original sigue numerando los mensajes
+    seq := pg_get_serial_sequence('mqtt_messages', 'id');
+    EXECUTE format('ALTER TABLE mqtt_messages_partitioned ALTER COLUMN id SET DEFAULT nextval(%L)', seq);
+    EXECUTE format('ALTER SEQUENCE %s OWNED BY mqtt_messages_partitioned.id', seq);
+
+    -- Una partición por mes desde el mensaje más antiguo hasta el mes siguiente al actual
+    period_start := date_trunc('month', COALESCE((SELECT min(received_at) FROM mqtt_messages), CURRENT_TIMESTAMP));
+    WHILE period_start <= date_trunc('month', CURRENT_TIMESTAMP) + INTERVAL '1 month' LOOP
+        EXECUTE format('CREATE TABLE %I PARTITION OF mqtt_messages_partitioned FOR VALUES FROM (%L) TO (%L)',
+            'mqtt_messages_p' || to_char(period_start, 'YYYYMMDD'), period_start, period_start + INTERVAL '1 month');
+        period_start := period_start + INTERVAL '1 month';
+    END LOOP;
+
+    -- Recoge los mensajes fuera de las particiones (relojes desajustados, reintentos antiguos...)
+    CREATE TABLE mqtt_messages_default PARTITION OF mqtt_messages_partitioned DEFAULT;
+
+    -- received_at nunca debería ser NULL (tiene valor por defecto), pero la clave de partición no lo admite
+    INSERT INTO mqtt_messages_partitioned (id, topic, payload, received_at, qos, retained, payload_json, payload_raw, direction)
+    SELECT id, topic, payload, COALESCE(received_at, CURRENT_TIMESTAMP), qos, retained, payload_json, payload_raw, direction
+    FROM mqtt_messages;
+
+    DROP TABLE mqtt_messages;
+    ALTER TABLE mqtt_messages_partitioned RENAME TO mqtt_messages;
+    ALTER TABLE mqtt_messages RENAME CONSTRAINT mqtt_messages_partitioned_pkey TO mqtt_messages_pkey;
+    ALTER TABLE mqtt_messages RENAME CONSTRAINT mqtt_messages_partitioned_direction_check TO mqtt_messages_direction_check;
+END
+$$;
+
+-- Los índices de la tabla particionada se crean en todas sus particiones
+CREATE INDEX IF NOT EXISTS idx_mqtt_messages_topic ON mqtt_messages(topic);
+CREATE INDEX IF NOT EXISTS idx_mqtt_messages_received_at ON mqtt_messages(received_at);
+CREATE INDEX IF NOT EXISTS idx_mqtt_messages_payload_json ON mqtt_messages USING GIN (payload_json jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS idx_mqtt_messages_received_at_id ON mqtt_messages(received_at, id);
+CREATE INDEX IF NOT EXISTS idx_mqtt_messages_topic_received_at_id ON mqtt_messages(topic, received_at, id);
+
+COMMENT ON TABLE mqtt_messages IS 'Mensajes MQTT recibidos y publicados, particionados por received_at';
+COMMENT ON COLUMN mqtt_messages.payload_json IS 'Payload cuando es JSON válido (NULL en otro caso)';
+COMMENT ON COLUMN mqtt_messages.payload_raw IS 'Bytes originales de los payloads que no son JSON';
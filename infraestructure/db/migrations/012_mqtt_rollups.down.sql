@@ -0,0 +1,8 @@
+-- Archivo: 012_mqtt_rollups.down.sql
+-- Descripción: Elimina los agregados de mensajes MQTT y su configuración
+
+DROP TABLE IF EXISTS mqtt_rollups_1d;
+DROP TABLE IF EXISTS mqtt_rollups_1h;
+DROP TABLE IF EXISTS mqtt_rollups_1m;
+DROP TABLE IF EXISTS mqtt_rollup_watermarks;
+DROP TABLE IF EXISTS mqtt_rollup_configs;
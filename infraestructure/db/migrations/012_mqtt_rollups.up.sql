@@ -0,0 +1,41 @@
+-- Archivo: 012_mqtt_rollups.up.sql
+-- Descripción: Agregados por minuto, hora y día de campos numéricos del payload
+-- de mqtt_messages, para consultar rangos largos sin recorrer los mensajes
+
+CREATE TABLE IF NOT EXISTS mqtt_rollup_configs (
+    id SERIAL PRIMARY KEY,
+    topic_filter VARCHAR(255) NOT NULL UNIQUE,
+    fields TEXT[] NOT NULL CHECK (cardinality(fields) > 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Hasta dónde está calculada cada resolución de cada configuración
+CREATE TABLE IF NOT EXISTS mqtt_rollup_watermarks (
+    config_id INTEGER NOT NULL REFERENCES mqtt_rollup_configs(id) ON DELETE CASCADE,
+    resolution VARCHAR(4) NOT NULL,
+    watermark TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (config_id, resolution)
+);
+
+-- count, sum, min y max se pueden volver a agregar: cada resolución se calcula
+-- a partir de la anterior y la media es sum / count
+CREATE TABLE IF NOT EXISTS mqtt_rollups_1m (
+    topic VARCHAR(255) NOT NULL,
+    field TEXT NOT NULL,
+    bucket TIMESTAMP WITH TIME ZONE NOT NULL,
+    count BIGINT NOT NULL,
+    sum DOUBLE PRECISION NOT NULL,
+    min DOUBLE PRECISION NOT NULL,
+    max DOUBLE PRECISION NOT NULL,
+    PRIMARY KEY (field, topic, bucket)
+);
+
+CREATE TABLE IF NOT EXISTS mqtt_rollups_1h (LIKE mqtt_rollups_1m INCLUDING ALL);
+CREATE TABLE IF NOT EXISTS mqtt_rollups_1d (LIKE mqtt_rollups_1m INCLUDING ALL);
+
+COMMENT ON TABLE mqtt_rollup_configs IS 'Topics y campos del payload (expresiones JSONPath) de los que se calculan agregados';
+COMMENT ON TABLE mqtt_rollup_watermarks IS 'Instante hasta el que están calculados los agregados de cada resolución';
+COMMENT ON TABLE mqtt_rollups_1m IS 'Agregados por minuto calculados a partir de mqtt_messages';
+COMMENT ON TABLE mqtt_rollups_1h IS 'Agregados por hora calculados a partir de mqtt_rollups_1m';
+COMMENT ON TABLE mqtt_rollups_1d IS 'Agregados por día (UTC) calculados a partir de mqtt_rollups_1h';
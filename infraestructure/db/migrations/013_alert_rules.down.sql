@@ -0,0 +1,5 @@
+-- Archivo: 013_alert_rules.down.sql
+-- Descripción: Elimina las reglas de alerta y el estado de las alertas
+
+DROP TABLE IF EXISTS alert_states;
+DROP TABLE IF EXISTS alert_rules;
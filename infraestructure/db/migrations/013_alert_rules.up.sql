@@ -0,0 +1,38 @@
+-- Archivo: 013_alert_rules.up.sql
+-- Descripción: Reglas de alerta sobre campos numéricos del payload de los
+-- mensajes MQTT y estado de cada alerta por topic
+
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    topic_filter VARCHAR(255) NOT NULL,
+    field TEXT NOT NULL,
+    comparator VARCHAR(3) NOT NULL CHECK (comparator IN ('gt', 'gte', 'lt', 'lte', 'eq', 'ne')),
+    threshold DOUBLE PRECISION NOT NULL,
+    window_seconds BIGINT NOT NULL DEFAULT 0 CHECK (window_seconds >= 0),
+    cooldown_seconds BIGINT NOT NULL DEFAULT 0 CHECK (cooldown_seconds >= 0),
+    sinks JSONB NOT NULL DEFAULT '[]',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS alert_states (
+    rule_id INTEGER NOT NULL REFERENCES alert_rules(id) ON DELETE CASCADE,
+    topic VARCHAR(255) NOT NULL,
+    status VARCHAR(10) NOT NULL CHECK (status IN ('pending', 'firing', 'resolved')),
+    value DOUBLE PRECISION,
+    pending_since TIMESTAMP WITH TIME ZONE,
+    fired_at TIMESTAMP WITH TIME ZONE,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (rule_id, topic)
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_states_status ON alert_states(status);
+
+COMMENT ON TABLE alert_rules IS 'Condiciones sobre un campo numérico del payload que disparan alertas si se cumplen durante una ventana';
+COMMENT ON COLUMN alert_rules.field IS 'Ruta del campo en el payload JSON, p. ej. $.temperature';
+COMMENT ON COLUMN alert_rules.cooldown_seconds IS 'Tiempo mínimo entre dos disparos de la alerta de un mismo topic';
+COMMENT ON COLUMN alert_rules.sinks IS 'Destinos de las notificaciones: log, webhook o mqtt';
+COMMENT ON TABLE alert_states IS 'Estado de la alerta de cada regla para cada topic: pending, firing o resolved';
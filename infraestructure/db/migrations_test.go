@@ -0,0 +1,114 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadMigrationsSortsAndPairsFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"010_add_index.up.sql":  {Data: []byte("CREATE INDEX ...")},
+		"002_users.up.sql":      {Data: []byte("CREATE TABLE users ...")},
+		"002_users.down.sql":    {Data: []byte("DROP TABLE users")},
+		"001_messages.up.sql":   {Data: []byte("CREATE TABLE mqtt_messages ...")},
+		"001_messages.down.sql": {Data: []byte("DROP TABLE mqtt_messages")},
+		"README.md":             {Data: []byte("no es una migración")},
+		"fixtures/extra.up.sql": {Data: []byte("SELECT 1")},
+	}
+
+	migrations, err := LoadMigrations(fsys)
+	assert.NoError(t, err)
+	if assert.Len(t, migrations, 3) {
+		assert.Equal(t, int64(1), migrations[0].Version)
+		assert.Equal(t, "messages", migrations[0].Name)
+		assert.Equal(t, "DROP TABLE mqtt_messages", migrations[0].Down)
+		assert.Equal(t, int64(2), migrations[1].Version)
+		assert.Equal(t, int64(10), migrations[2].Version)
+		assert.Empty(t, migrations[2].Down, "Down files are optional")
+	}
+}
+
+func TestLoadMigrationsRejectsInvalidFiles(t *testing.T) {
+	invalid := []fstest.MapFS{
+		{"create_mqtt_messages_table.sql": {Data: []byte("CREATE TABLE ...")}},
+		{"001_users.down.sql": {Data: []byte("DROP TABLE users")}},
+		{"001_users.up.sql": {Data: []byte("...")}, "001_accounts.down.sql": {Data: []byte("...")}},
+	}
+
+	for _, fsys := range invalid {
+		_, err := LoadMigrations(fsys)
+		assert.Error(t, err)
+	}
+}
+
+func TestEmbeddedMigrationsHaveUpAndDown(t *testing.T) {
+	fsys, err := MigrationsFS("")
+	assert.NoError(t, err)
+	migrations, err := LoadMigrations(fsys)
+	assert.NoError(t, err)
+
+	onDisk, err := LoadMigrations(os.DirFS("migrations"))
+	assert.NoError(t, err)
+	assert.Equal(t, onDisk, migrations, "Embedded migrations should match the migrations directory")
+
+	for _, migration := range migrations {
+		assert.NotEmpty(t, migration.Down, "Migration %03d_%s has no down file", migration.Version, migration.Name)
+	}
+}
+
+func TestMigrationsFSOverrideDirectory(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "001_custom.up.sql"), []byte("SELECT 1"), 0o644))
+
+	fsys, err := MigrationsFS(dir)
+	assert.NoError(t, err)
+	migrations, err := LoadMigrations(fsys)
+	assert.NoError(t, err)
+	if assert.Len(t, migrations, 1) {
+		assert.Equal(t, "custom", migrations[0].Name)
+	}
+
+	_, err = MigrationsFS(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestPendingAndLastAppliedMigrations(t *testing.T) {
+	migrations := []Migration{{Version: 1}, {Version: 2}, {Version: 3}}
+	applied := map[int64]time.Time{1: time.Now(), 2: time.Now()}
+
+	pending := pendingMigrations(migrations, applied)
+	if assert.Len(t, pending, 1) {
+		assert.Equal(t, int64(3), pending[0].Version)
+	}
+
+	last, err := lastApplied(migrations, applied, 1)
+	assert.NoError(t, err)
+	if assert.Len(t, last, 1) {
+		assert.Equal(t, int64(2), last[0].Version)
+	}
+
+	last, err = lastApplied(migrations, applied, 5)
+	assert.NoError(t, err)
+	assert.Len(t, last, 2, "Steps beyond the applied versions revert everything")
+	assert.Equal(t, int64(1), last[1].Version)
+
+	_, err = lastApplied(migrations[:1], applied, 1)
+	assert.ErrorIs(t, err, ErrUnknownMigration)
+}
+
+func TestMigrationVersion(t *testing.T) {
+	migrations := []Migration{{Version: 1}, {Version: 2}, {Version: 3}}
+
+	version := migrationVersion(migrations, map[int64]time.Time{1: time.Now(), 2: time.Now()})
+	assert.Equal(t, MigrationVersion{Current: 2, Latest: 3, Pending: 1}, version)
+
+	version = migrationVersion(migrations, map[int64]time.Time{1: time.Now(), 2: time.Now(), 3: time.Now(), 4: time.Now()})
+	assert.Equal(t, MigrationVersion{Current: 4, Latest: 3, Unknown: 1}, version)
+
+	assert.Equal(t, MigrationVersion{Latest: 3, Pending: 3}, migrationVersion(migrations, nil))
+}
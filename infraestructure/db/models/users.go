@@ -24,57 +24,62 @@ import (
 
 // User is an object representing the database table.
 type User struct {
-	ID        int         `boil:"id" json:"id" toml:"id" yaml:"id"`
-	Username  string      `boil:"username" json:"username" toml:"username" yaml:"username"`
-	Email     string      `boil:"email" json:"email" toml:"email" yaml:"email"`
-	Password  string      `boil:"password" json:"password" toml:"password" yaml:"password"`
-	FullName  null.String `boil:"full_name" json:"full_name,omitempty" toml:"full_name" yaml:"full_name,omitempty"`
-	IsActive  null.Bool   `boil:"is_active" json:"is_active,omitempty" toml:"is_active" yaml:"is_active,omitempty"`
-	CreatedAt null.Time   `boil:"created_at" json:"created_at,omitempty" toml:"created_at" yaml:"created_at,omitempty"`
-	UpdatedAt null.Time   `boil:"updated_at" json:"updated_at,omitempty" toml:"updated_at" yaml:"updated_at,omitempty"`
+	ID                    int         `boil:"id" json:"id" toml:"id" yaml:"id"`
+	Username              string      `boil:"username" json:"username" toml:"username" yaml:"username"`
+	Email                 string      `boil:"email" json:"email" toml:"email" yaml:"email"`
+	Password              string      `boil:"password" json:"password" toml:"password" yaml:"password"`
+	FullName              null.String `boil:"full_name" json:"full_name,omitempty" toml:"full_name" yaml:"full_name,omitempty"`
+	IsActive              null.Bool   `boil:"is_active" json:"is_active,omitempty" toml:"is_active" yaml:"is_active,omitempty"`
+	CreatedAt             null.Time   `boil:"created_at" json:"created_at,omitempty" toml:"created_at" yaml:"created_at,omitempty"`
+	UpdatedAt             null.Time   `boil:"updated_at" json:"updated_at,omitempty" toml:"updated_at" yaml:"updated_at,omitempty"`
+	PasswordResetRequired bool        `boil:"password_reset_required" json:"password_reset_required" toml:"password_reset_required" yaml:"password_reset_required"`
 
 	R *userR `boil:"-" json:"-" toml:"-" yaml:"-"`
 	L userL  `boil:"-" json:"-" toml:"-" yaml:"-"`
 }
 
 var UserColumns = struct {
-	ID        string
-	Username  string
-	Email     string
-	Password  string
-	FullName  string
-	IsActive  string
-	CreatedAt string
-	UpdatedAt string
+	ID                    string
+	Username              string
+	Email                 string
+	Password              string
+	FullName              string
+	IsActive              string
+	CreatedAt             string
+	UpdatedAt             string
+	PasswordResetRequired string
 }{
-	ID:        "id",
-	Username:  "username",
-	Email:     "email",
-	Password:  "password",
-	FullName:  "full_name",
-	IsActive:  "is_active",
-	CreatedAt: "created_at",
-	UpdatedAt: "updated_at",
+	ID:                    "id",
+	Username:              "username",
+	Email:                 "email",
+	Password:              "password",
+	FullName:              "full_name",
+	IsActive:              "is_active",
+	CreatedAt:             "created_at",
+	UpdatedAt:             "updated_at",
+	PasswordResetRequired: "password_reset_required",
 }
 
 var UserTableColumns = struct {
-	ID        string
-	Username  string
-	Email     string
-	Password  string
-	FullName  string
-	IsActive  string
-	CreatedAt string
-	UpdatedAt string
+	ID                    string
+	Username              string
+	Email                 string
+	Password              string
+	FullName              string
+	IsActive              string
+	CreatedAt             string
+	UpdatedAt             string
+	PasswordResetRequired string
 }{
-	ID:        "users.id",
-	Username:  "users.username",
-	Email:     "users.email",
-	Password:  "users.password",
-	FullName:  "users.full_name",
-	IsActive:  "users.is_active",
-	CreatedAt: "users.created_at",
-	UpdatedAt: "users.updated_at",
+	ID:                    "users.id",
+	Username:              "users.username",
+	Email:                 "users.email",
+	Password:              "users.password",
+	FullName:              "users.full_name",
+	IsActive:              "users.is_active",
+	CreatedAt:             "users.created_at",
+	UpdatedAt:             "users.updated_at",
+	PasswordResetRequired: "users.password_reset_required",
 }
 
 // Generated where
@@ -237,24 +242,35 @@ func (w whereHelpernull_Time) GTE(x null.Time) qm.QueryMod {
 func (w whereHelpernull_Time) IsNull() qm.QueryMod    { return qmhelper.WhereIsNull(w.field) }
 func (w whereHelpernull_Time) IsNotNull() qm.QueryMod { return qmhelper.WhereIsNotNull(w.field) }
 
+type whereHelperbool struct{ field string }
+
+func (w whereHelperbool) EQ(x bool) qm.QueryMod  { return qmhelper.Where(w.field, qmhelper.EQ, x) }
+func (w whereHelperbool) NEQ(x bool) qm.QueryMod { return qmhelper.Where(w.field, qmhelper.NEQ, x) }
+func (w whereHelperbool) LT(x bool) qm.QueryMod  { return qmhelper.Where(w.field, qmhelper.LT, x) }
+func (w whereHelperbool) LTE(x bool) qm.QueryMod { return qmhelper.Where(w.field, qmhelper.LTE, x) }
+func (w whereHelperbool) GT(x bool) qm.QueryMod  { return qmhelper.Where(w.field, qmhelper.GT, x) }
+func (w whereHelperbool) GTE(x bool) qm.QueryMod { return qmhelper.Where(w.field, qmhelper.GTE, x) }
+
 var UserWhere = struct {
-	ID        whereHelperint
-	Username  whereHelperstring
-	Email     whereHelperstring
-	Password  whereHelperstring
-	FullName  whereHelpernull_String
-	IsActive  whereHelpernull_Bool
-	CreatedAt whereHelpernull_Time
-	UpdatedAt whereHelpernull_Time
+	ID                    whereHelperint
+	Username              whereHelperstring
+	Email                 whereHelperstring
+	Password              whereHelperstring
+	FullName              whereHelpernull_String
+	IsActive              whereHelpernull_Bool
+	CreatedAt             whereHelpernull_Time
+	UpdatedAt             whereHelpernull_Time
+	PasswordResetRequired whereHelperbool
 }{
-	ID:        whereHelperint{field: "\"users\".\"id\""},
-	Username:  whereHelperstring{field: "\"users\".\"username\""},
-	Email:     whereHelperstring{field: "\"users\".\"email\""},
-	Password:  whereHelperstring{field: "\"users\".\"password\""},
-	FullName:  whereHelpernull_String{field: "\"users\".\"full_name\""},
-	IsActive:  whereHelpernull_Bool{field: "\"users\".\"is_active\""},
-	CreatedAt: whereHelpernull_Time{field: "\"users\".\"created_at\""},
-	UpdatedAt: whereHelpernull_Time{field: "\"users\".\"updated_at\""},
+	ID:                    whereHelperint{field: "\"users\".\"id\""},
+	Username:              whereHelperstring{field: "\"users\".\"username\""},
+	Email:                 whereHelperstring{field: "\"users\".\"email\""},
+	Password:              whereHelperstring{field: "\"users\".\"password\""},
+	FullName:              whereHelpernull_String{field: "\"users\".\"full_name\""},
+	IsActive:              whereHelpernull_Bool{field: "\"users\".\"is_active\""},
+	CreatedAt:             whereHelpernull_Time{field: "\"users\".\"created_at\""},
+	UpdatedAt:             whereHelpernull_Time{field: "\"users\".\"updated_at\""},
+	PasswordResetRequired: whereHelperbool{field: "\"users\".\"password_reset_required\""},
 }
 
 // UserRels is where relationship names are stored.
@@ -274,9 +290,9 @@ func (*userR) NewStruct() *userR {
 type userL struct{}
 
 var (
-	userAllColumns            = []string{"id", "username", "email", "password", "full_name", "is_active", "created_at", "updated_at"}
+	userAllColumns            = []string{"id", "username", "email", "password", "full_name", "is_active", "created_at", "updated_at", "password_reset_required"}
 	userColumnsWithoutDefault = []string{"username", "email", "password"}
-	userColumnsWithDefault    = []string{"id", "full_name", "is_active", "created_at", "updated_at"}
+	userColumnsWithDefault    = []string{"id", "full_name", "is_active", "created_at", "updated_at", "password_reset_required"}
 	userPrimaryKeyColumns     = []string{"id"}
 	userGeneratedColumns      = []string{}
 )
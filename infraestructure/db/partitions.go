@@ -0,0 +1,266 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// Intervalos de las particiones de mqtt_messages
+const (
+	PartitionDaily   = "day"
+	PartitionMonthly = "month"
+)
+
+// messagesTable es la tabla particionada por received_at
+const messagesTable = "mqtt_messages"
+
+// ErrInvalidPartitionConfig se devuelve cuando la configuración de particiones no es válida
+var ErrInvalidPartitionConfig = errors.New("configuración de particiones no válida")
+
+// Partition es una partición de mqtt_messages con los mensajes recibidos en [From, To)
+type Partition struct {
+	Name string    `json:"name"`
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// PartitionManager mantiene las particiones de mqtt_messages: crea por
+// adelantado las de los próximos periodos y elimina las que ya solo contienen
+// mensajes caducados. Los periodos empiezan a medianoche UTC.
+//
+// Los mensajes que no caen en ninguna partición van a mqtt_messages_default.
+// PostgreSQL no permite crear una partición si la partición por defecto tiene
+// filas de su rango, por eso las particiones se crean antes de que empiece su periodo.
+type PartitionManager struct {
+	db       *sql.DB
+	interval string
+	ahead    int
+}
+
+// NewPartitionManager crea el gestor de particiones. interval es day o month y
+// ahead el número de periodos futuros que deben tener ya su partición.
+func NewPartitionManager(db *sql.DB, interval string, ahead int) (*PartitionManager, error) {
+	if interval != PartitionDaily && interval != PartitionMonthly {
+		return nil, fmt.Errorf("%w: intervalo %q (day, month)", ErrInvalidPartitionConfig, interval)
+	}
+	if ahead < 1 {
+		return nil, fmt.Errorf("%w: hay que crear al menos una partición por adelantado", ErrInvalidPartitionConfig)
+	}
+	return &PartitionManager{db: db, interval: interval, ahead: ahead}, nil
+}
+
+// Partitioned indica si mqtt_messages es una tabla particionada (la migración
+// de particionado está aplicada)
+func (m *PartitionManager) Partitioned(ctx context.Context) (bool, error) {
+	var partitioned bool
+	err := m.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM pg_partitioned_table WHERE partrelid = to_regclass($1))`, messagesTable).
+		Scan(&partitioned)
+	return partitioned, err
+}
+
+// Partitions devuelve las particiones por rango ordenadas por fecha, sin la
+// partición por defecto
+func (m *PartitionManager) Partitions(ctx context.Context) ([]Partition, error) {
+	// Los límites se leen de la expresión FOR VALUES FROM (...) TO (...) y los
+	// convierte de nuevo PostgreSQL, en la misma zona horaria en que los escribió
+	rows, err := m.db.QueryContext(ctx, `
+        SELECT c.relname, bounds[1]::timestamptz, bounds[2]::timestamptz
+        FROM pg_inherits i
+        JOIN pg_class c ON c.oid = i.inhrelid
+        CROSS JOIN LATERAL regexp_match(pg_get_expr(c.relpartbound, c.oid),
+            'FROM \(''([^'']+)''\) TO \(''([^'']+)''\)') AS bounds
+        WHERE i.inhparent = to_regclass($1) AND bounds IS NOT NULL
+        ORDER BY 2
+    `, messagesTable)
+	if err != nil {
+		return nil, fmt.Errorf("error listando las particiones: %w", err)
+	}
+	defer rows.Close()
+
+	var partitions []Partition
+	for rows.Next() {
+		var p Partition
+		if err := rows.Scan(&p.Name, &p.From, &p.To); err != nil {
+			return nil, err
+		}
+		p.From, p.To = p.From.UTC(), p.To.UTC()
+		partitions = append(partitions, p)
+	}
+	return partitions, rows.Err()
+}
+
+// Ensure crea las particiones que falten desde el periodo de now hasta ahead
+// periodos después. Devuelve las creadas.
+func (m *PartitionManager) Ensure(ctx context.Context, now time.Time) ([]Partition, error) {
+	existing, err := m.Partitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var created []Partition
+	var errs []error
+	for _, p := range missingPartitions(existing, now, m.interval, m.ahead) {
+		query := fmt.Sprintf(`CREATE TABLE %s PARTITION OF %s FOR VALUES FROM (%s) TO (%s)`,
+			pq.QuoteIdentifier(p.Name), messagesTable,
+			pq.QuoteLiteral(p.From.Format(time.RFC3339)), pq.QuoteLiteral(p.To.Format(time.RFC3339)))
+		if _, err := m.db.ExecContext(ctx, query); err != nil {
+			errs = append(errs, fmt.Errorf("error creando la partición %s: %w", p.Name, err))
+			continue
+		}
+		created = append(created, p)
+	}
+	return created, errors.Join(errs...)
+}
+
+// DropBefore separa y elimina las particiones que terminan antes de cutoff.
+// Devuelve las eliminadas.
+func (m *PartitionManager) DropBefore(ctx context.Context, cutoff time.Time) ([]Partition, error) {
+	existing, err := m.Partitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var dropped []Partition
+	for _, p := range existing {
+		if p.To.After(cutoff) {
+			break
+		}
+		if err := m.drop(ctx, p); err != nil {
+			return dropped, fmt.Errorf("error eliminando la partición %s: %w", p.Name, err)
+		}
+		dropped = append(dropped, p)
+	}
+	return dropped, nil
+}
+
+// drop separa la partición de la tabla y la elimina en una transacción
+func (m *PartitionManager) drop(ctx context.Context, p Partition) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s DETACH PARTITION %s`,
+		messagesTable, pq.QuoteIdentifier(p.Name))); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DROP TABLE `+pq.QuoteIdentifier(p.Name)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Maintain crea las particiones de los próximos periodos y, si cutoff devuelve
+// un instante, elimina las que terminan antes. cutoff puede ser nil.
+func (m *PartitionManager) Maintain(ctx context.Context, cutoff func(context.Context) (*time.Time, error)) error {
+	partitioned, err := m.Partitioned(ctx)
+	if err != nil {
+		return err
+	}
+	if !partitioned {
+		log.Warn().Msg("La tabla mqtt_messages no está particionada, no se gestionan sus particiones")
+		return nil
+	}
+
+	created, err := m.Ensure(ctx, time.Now())
+	for _, p := range created {
+		log.Info().Str("partition", p.Name).Time("from", p.From).Time("to", p.To).Msg("Partición de mensajes MQTT creada")
+	}
+	if err != nil || cutoff == nil {
+		return err
+	}
+
+	before, err := cutoff(ctx)
+	if err != nil || before == nil {
+		return err
+	}
+	dropped, err := m.DropBefore(ctx, *before)
+	for _, p := range dropped {
+		log.Info().Str("partition", p.Name).Time("from", p.From).Time("to", p.To).Msg("Partición de mensajes MQTT caducada eliminada")
+	}
+	return err
+}
+
+// RunMaintenance ejecuta Maintain cada interval hasta que se cancele ctx
+func (m *PartitionManager) RunMaintenance(ctx context.Context, interval time.Duration, cutoff func(context.Context) (*time.Time, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := m.Maintain(ctx, cutoff); err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msg("Error manteniendo las particiones de mensajes MQTT")
+		}
+	}
+}
+
+// periodStart devuelve el inicio del periodo (día o mes UTC) que contiene t
+func periodStart(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	if interval == PartitionMonthly {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// nextPeriod devuelve el inicio del periodo siguiente al que empieza en start
+func nextPeriod(start time.Time, interval string) time.Time {
+	if interval == PartitionMonthly {
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// partitionName nombra la partición por el día en que empieza
+func partitionName(from time.Time) string {
+	return messagesTable + "_p" + from.UTC().Format("20060102")
+}
+
+// missingPartitions devuelve las particiones que faltan para cubrir desde el
+// periodo de now hasta ahead periodos después. Los huecos se calculan contra las
+// particiones existentes, que pueden ser de otro intervalo (la migración crea
+// particiones mensuales aunque se use el intervalo diario).
+func missingPartitions(existing []Partition, now time.Time, interval string, ahead int) []Partition {
+	sorted := append([]Partition(nil), existing...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].From.Before(sorted[j].From) })
+
+	var missing []Partition
+	start := periodStart(now, interval)
+	for i := 0; i <= ahead; i++ {
+		end := nextPeriod(start, interval)
+
+		// Partes de [start, end) que no cubre ninguna partición
+		cursor := start
+		for _, p := range sorted {
+			if !p.To.After(cursor) || !p.From.Before(end) {
+				continue
+			}
+			if p.From.After(cursor) {
+				missing = append(missing, Partition{Name: partitionName(cursor), From: cursor, To: p.From})
+			}
+			if p.To.After(cursor) {
+				cursor = p.To
+			}
+		}
+		if cursor.Before(end) {
+			missing = append(missing, Partition{Name: partitionName(cursor), From: cursor, To: end})
+		}
+
+		start = end
+	}
+	return missing
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func day(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestPeriodStart(t *testing.T) {
+	now := time.Date(2025, 3, 31, 23, 30, 0, 0, time.FixedZone("CEST", 2*3600))
+
+	assert.Equal(t, day(2025, 3, 31), periodStart(now, PartitionDaily), "Periods start at UTC midnight")
+	assert.Equal(t, day(2025, 3, 1), periodStart(now, PartitionMonthly))
+	assert.Equal(t, day(2025, 4, 1), nextPeriod(day(2025, 3, 31), PartitionDaily))
+	assert.Equal(t, day(2025, 2, 1), nextPeriod(day(2025, 1, 1), PartitionMonthly))
+	assert.Equal(t, "mqtt_messages_p20250331", partitionName(day(2025, 3, 31)))
+}
+
+func TestMissingPartitionsCreatesUpcomingPeriods(t *testing.T) {
+	now := time.Date(2025, 1, 30, 10, 0, 0, 0, time.UTC)
+
+	missing := missingPartitions(nil, now, PartitionDaily, 2)
+	assert.Equal(t, []Partition{
+		{Name: "mqtt_messages_p20250130", From: day(2025, 1, 30), To: day(2025, 1, 31)},
+		{Name: "mqtt_messages_p20250131", From: day(2025, 1, 31), To: day(2025, 2, 1)},
+		{Name: "mqtt_messages_p20250201", From: day(2025, 2, 1), To: day(2025, 2, 2)},
+	}, missing)
+
+	existing := []Partition{{From: day(2025, 1, 30), To: day(2025, 1, 31)}}
+	assert.Len(t, missingPartitions(existing, now, PartitionDaily, 2), 2, "Existing partitions are kept")
+	assert.Empty(t, missingPartitions(missing, now, PartitionDaily, 2))
+}
+
+func TestMissingPartitionsFillsGapsAroundOtherIntervals(t *testing.T) {
+	// La migración crea particiones mensuales hasta el mes siguiente
+	existing := []Partition{
+		{From: day(2025, 1, 1), To: day(2025, 2, 1)},
+		{From: day(2025, 2, 1), To: day(2025, 3, 1)},
+	}
+
+	assert.Empty(t, missingPartitions(existing, day(2025, 1, 30), PartitionDaily, 3))
+
+	missing := missingPartitions(existing, day(2025, 2, 27), PartitionDaily, 3)
+	assert.Equal(t, []Partition{
+		{Name: "mqtt_messages_p20250301", From: day(2025, 3, 1), To: day(2025, 3, 2)},
+		{Name: "mqtt_messages_p20250302", From: day(2025, 3, 2), To: day(2025, 3, 3)},
+	}, missing)
+
+	// Una partición diaria suelta deja huecos a los dos lados dentro del mes
+	existing = []Partition{{From: day(2025, 4, 10), To: day(2025, 4, 11)}}
+	missing = missingPartitions(existing, day(2025, 4, 5), PartitionMonthly, 0)
+	assert.Equal(t, []Partition{
+		{Name: "mqtt_messages_p20250401", From: day(2025, 4, 1), To: day(2025, 4, 10)},
+		{Name: "mqtt_messages_p20250411", From: day(2025, 4, 11), To: day(2025, 5, 1)},
+	}, missing)
+}
@@ -0,0 +1,16 @@
+package dto
+
+import "github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+
+// AlertRuleRequest representa la petición para crear o modificar una regla de alerta
+type AlertRuleRequest struct {
+	Name            string                     `json:"name"`
+	TopicFilter     string                     `json:"topic_filter"`
+	Field           string                     `json:"field"`      // Ruta del campo, p. ej. $.temperature
+	Comparator      string                     `json:"comparator"` // gt, gte, lt, lte, eq o ne
+	Threshold       *float64                   `json:"threshold"`
+	WindowSeconds   int64                      `json:"window_seconds"`   // Opcional, 0 por defecto
+	CooldownSeconds int64                      `json:"cooldown_seconds"` // Opcional, 0 por defecto
+	Sinks           []entities.AlertSinkConfig `json:"sinks"`            // Opcional, log por defecto
+	Enabled         *bool                      `json:"enabled"`          // Opcional, true por defecto
+}
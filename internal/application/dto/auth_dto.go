@@ -0,0 +1,31 @@
+package dto
+
+// LoginRequest representa la solicitud de inicio de sesión
+type LoginRequest struct {
+	// Login admite el username o el email del usuario
+	Login    string `json:"login" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// RefreshRequest representa la solicitud de rotación o revocación de un refresh token
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// PasswordChangeRequest representa la solicitud de cambio de contraseña sin sesión,
+// necesaria para los usuarios obligados a cambiarla antes de autenticarse
+type PasswordChangeRequest struct {
+	// Login admite el username o el email del usuario
+	Login       string `json:"login" validate:"required"`
+	OldPassword string `json:"old_password" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=6"`
+}
+
+// TokenResponse representa los tokens emitidos tras autenticarse
+type TokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int    `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresAt string `json:"refresh_expires_at"`
+}
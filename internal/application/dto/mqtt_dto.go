@@ -0,0 +1,67 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/repository"
+)
+
+// SubscribeRequest representa la solicitud para suscribirse a un topic MQTT
+type SubscribeRequest struct {
+	Topic string `json:"topic" validate:"required"`
+	QOS   *byte  `json:"qos,omitempty"` // Por defecto 1
+}
+
+// SubscriptionsResponse representa la lista de topics con suscriptor activo
+type SubscriptionsResponse struct {
+	Topics []string `json:"topics"`
+	Total  int      `json:"total"`
+}
+
+// MessagesListResponse es una página de mensajes MQTT. NextCursor se pasa como
+// ?cursor= para obtener la siguiente y no aparece en la última página.
+type MessagesListResponse struct {
+	Messages   []models.MqttMessage `json:"messages"`
+	Count      int                  `json:"count"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+// AggregateResponse son las series temporales de /mqtt/aggregate, una por
+// topic. Todas tienen un punto por intervalo entre From y To salvo con fill=none.
+type AggregateResponse struct {
+	Field     string    `json:"field"`
+	Bucket    string    `json:"bucket"`
+	Functions []string  `json:"functions"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Fill      string    `json:"fill"`
+	// Resolution es la resolución más gruesa de los agregados precalculados
+	// que se usaron (1m, 1h, 1d) o raw si se calculó sobre los mensajes
+	Resolution string                       `json:"resolution"`
+	Series     []repository.AggregateSeries `json:"series"`
+}
+
+// PublishRequest representa la solicitud para publicar un mensaje MQTT. El
+// payload se indica con Payload (cualquier valor JSON; las cadenas se publican
+// como texto) o con PayloadBase64 para datos binarios, pero no con ambos.
+type PublishRequest struct {
+	Topic         string          `json:"topic" validate:"required"`
+	Payload       json.RawMessage `json:"payload,omitempty"`
+	PayloadBase64 *string         `json:"payload_base64,omitempty"`
+	QOS           *byte           `json:"qos,omitempty"` // Por defecto 1
+	Retain        bool            `json:"retain"`
+	// Record guarda el mensaje en base de datos como saliente (outbound)
+	Record bool `json:"record"`
+}
+
+// PublishResponse describe un mensaje publicado. MessageID solo aparece si se guardó.
+type PublishResponse struct {
+	Topic       string    `json:"topic"`
+	QOS         byte      `json:"qos"`
+	Retain      bool      `json:"retain"`
+	Bytes       int       `json:"bytes"`
+	PublishedAt time.Time `json:"published_at"`
+	MessageID   *int      `json:"message_id,omitempty"`
+}
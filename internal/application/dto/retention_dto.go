@@ -0,0 +1,8 @@
+package dto
+
+// RetentionRuleRequest representa la petición para crear o modificar una regla de retención
+type RetentionRuleRequest struct {
+	TopicFilter   string `json:"topic_filter"`    // # para la regla por defecto
+	MaxAgeSeconds *int64 `json:"max_age_seconds"` // Opcional si se indica max_rows
+	MaxRows       *int64 `json:"max_rows"`        // Opcional si se indica max_age_seconds
+}
@@ -0,0 +1,7 @@
+package dto
+
+// RollupConfigRequest representa la petición para crear o modificar una configuración de agregados
+type RollupConfigRequest struct {
+	TopicFilter string   `json:"topic_filter"`
+	Fields      []string `json:"fields"` // Expresiones JSONPath, p. ej. $.value
+}
@@ -0,0 +1,8 @@
+package dto
+
+// CreateTopicACLRequest representa la petición para crear una regla ACL de topics
+type CreateTopicACLRequest struct {
+	Username    string `json:"username"`
+	TopicFilter string `json:"topic_filter"`
+	Access      string `json:"access"` // read, write o readwrite
+}
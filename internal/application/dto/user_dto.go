@@ -25,13 +25,19 @@ type ChangePasswordRequest struct {
 
 // UserResponse representa la respuesta con datos del usuario
 type UserResponse struct {
-	ID        int     `json:"id"`
-	Username  string  `json:"username"`
-	Email     string  `json:"email"`
-	FullName  *string `json:"full_name,omitempty"`
-	IsActive  *bool   `json:"is_active,omitempty"`
-	CreatedAt string  `json:"created_at"`
-	UpdatedAt string  `json:"updated_at"`
+	ID        int      `json:"id"`
+	Username  string   `json:"username"`
+	Email     string   `json:"email"`
+	FullName  *string  `json:"full_name,omitempty"`
+	IsActive  *bool    `json:"is_active,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	CreatedAt string   `json:"created_at"`
+	UpdatedAt string   `json:"updated_at"`
+}
+
+// AssignRoleRequest representa la petición para asignar un rol a un usuario
+type AssignRoleRequest struct {
+	Role string `json:"role"`
 }
 
 // UsersListResponse representa la respuesta para lista de usuarios
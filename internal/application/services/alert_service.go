@@ -0,0 +1,479 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/dto"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/repositories"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/mqtttopic"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrAlertRuleNotFound se devuelve cuando la regla de alerta no existe
+	ErrAlertRuleNotFound = errors.New("alert rule not found")
+	// ErrAlertRuleExists se devuelve cuando ya hay una regla con el mismo nombre
+	ErrAlertRuleExists = errors.New("an alert rule with this name already exists")
+	// ErrInvalidAlertRule se devuelve cuando algún campo de la regla no es válido
+	ErrInvalidAlertRule = errors.New("invalid alert rule")
+	// ErrInvalidAlertStatus se devuelve al filtrar las alertas por un estado que no existe
+	ErrInvalidAlertStatus = errors.New("alert status must be pending, firing or resolved")
+)
+
+// AlertSink entrega las notificaciones de las alertas a un tipo de destino
+type AlertSink interface {
+	Notify(ctx context.Context, sink entities.AlertSinkConfig, notification entities.AlertNotification) error
+}
+
+// AlertOptions controla la evaluación de las reglas de alerta
+type AlertOptions struct {
+	// QueueSize son los mensajes pendientes de evaluar; con la cola llena los
+	// nuevos se descartan para no frenar la recepción
+	QueueSize int
+	// NotifyTimeout limita cada notificación a un destino
+	NotifyTimeout time.Duration
+}
+
+// alertKey identifica la alerta de una regla para un topic
+type alertKey struct {
+	ruleID int
+	topic  string
+}
+
+// AlertService gestiona las reglas de alerta y las evalúa con cada mensaje
+// MQTT recibido. Las reglas y el estado de las alertas se guardan en la base de
+// datos y se mantienen en memoria para no consultarla con cada mensaje.
+type AlertService struct {
+	ruleRepo  repositories.AlertRuleRepository
+	stateRepo repositories.AlertStateRepository
+	sinks     map[string]AlertSink
+	opts      AlertOptions
+	queue     chan models.MqttMessage
+
+	// rules es una copia inmutable de las reglas que se sustituye entera con cada
+	// cambio, para leerla sin bloqueos desde la recepción de mensajes
+	rules atomic.Pointer[[]*entities.AlertRule]
+	// mu protege states y serializa los cambios de rules
+	mu     sync.Mutex
+	states map[alertKey]*entities.AlertState
+
+	dropped       atomic.Uint64
+	notifications sync.WaitGroup
+}
+
+// NewAlertService crea una nueva instancia del servicio de alertas
+func NewAlertService(ruleRepo repositories.AlertRuleRepository, stateRepo repositories.AlertStateRepository, opts AlertOptions) *AlertService {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1000
+	}
+	if opts.NotifyTimeout <= 0 {
+		opts.NotifyTimeout = 10 * time.Second
+	}
+	s := &AlertService{
+		ruleRepo:  ruleRepo,
+		stateRepo: stateRepo,
+		sinks:     map[string]AlertSink{},
+		opts:      opts,
+		queue:     make(chan models.MqttMessage, opts.QueueSize),
+		states:    map[alertKey]*entities.AlertState{},
+	}
+	s.rules.Store(&[]*entities.AlertRule{})
+	return s
+}
+
+// RegisterSink añade un tipo de destino para las notificaciones. Debe llamarse
+// antes de Run; las reglas solo pueden usar los tipos registrados.
+func (s *AlertService) RegisterSink(sinkType string, sink AlertSink) {
+	s.sinks[sinkType] = sink
+}
+
+// ListRules obtiene todas las reglas de alerta
+func (s *AlertService) ListRules(ctx context.Context) ([]*entities.AlertRule, error) {
+	return s.ruleRepo.List(ctx)
+}
+
+// ListAlerts obtiene el estado de las alertas, opcionalmente solo las de un
+// estado. Si restricted, solo las de los topics que coinciden con filters.
+func (s *AlertService) ListAlerts(ctx context.Context, status string, restricted bool, filters []string) ([]*entities.AlertState, error) {
+	switch entities.AlertStatus(status) {
+	case "", entities.AlertPending, entities.AlertFiring, entities.AlertResolved:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidAlertStatus, status)
+	}
+
+	states, err := s.stateRepo.List(ctx, entities.AlertStatus(status))
+	if err != nil {
+		return nil, err
+	}
+	if !restricted {
+		return states, nil
+	}
+
+	readable := make([]*entities.AlertState, 0, len(states))
+	for _, state := range states {
+		for _, filter := range filters {
+			if mqtttopic.Match(filter, state.Topic) {
+				readable = append(readable, state)
+				break
+			}
+		}
+	}
+	return readable, nil
+}
+
+// CreateRule crea una regla de alerta con un nombre que no tenga otra
+func (s *AlertService) CreateRule(ctx context.Context, req dto.AlertRuleRequest) (*entities.AlertRule, error) {
+	rule, err := s.newRule(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.checkUnique(ctx, rule); err != nil {
+		return nil, err
+	}
+	if err := s.ruleRepo.Create(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	s.cacheRule(rule)
+	return rule, nil
+}
+
+// UpdateRule sustituye una regla. El estado de sus alertas se descarta y se
+// vuelve a calcular con los mensajes siguientes.
+func (s *AlertService) UpdateRule(ctx context.Context, id int, req dto.AlertRuleRequest) (*entities.AlertRule, error) {
+	rule, err := s.newRule(req)
+	if err != nil {
+		return nil, err
+	}
+	rule.ID = id
+
+	current, err := s.checkUnique(ctx, rule)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, ErrAlertRuleNotFound
+	}
+
+	updated, err := s.ruleRepo.Update(ctx, rule)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, ErrAlertRuleNotFound
+	}
+	if err := s.stateRepo.DeleteByRule(ctx, id); err != nil {
+		return nil, err
+	}
+
+	s.cacheRule(rule)
+	return rule, nil
+}
+
+// DeleteRule elimina una regla y el estado de sus alertas
+func (s *AlertService) DeleteRule(ctx context.Context, id int) error {
+	deleted, err := s.ruleRepo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrAlertRuleNotFound
+	}
+
+	s.uncacheRule(id)
+	return nil
+}
+
+// newRule crea la regla de la petición comprobando que sus destinos estén disponibles
+func (s *AlertService) newRule(req dto.AlertRuleRequest) (*entities.AlertRule, error) {
+	if req.Threshold == nil {
+		return nil, fmt.Errorf("%w: threshold is required", ErrInvalidAlertRule)
+	}
+	enabled := req.Enabled == nil || *req.Enabled
+
+	rule, err := entities.NewAlertRule(strings.TrimSpace(req.Name), strings.TrimSpace(req.TopicFilter), strings.TrimSpace(req.Field),
+		strings.TrimSpace(req.Comparator), *req.Threshold, req.WindowSeconds, req.CooldownSeconds, req.Sinks, enabled)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAlertRule, err)
+	}
+
+	for _, sink := range rule.Sinks {
+		if _, ok := s.sinks[sink.Type]; !ok {
+			return nil, fmt.Errorf("%w: sink type %q is not available", ErrInvalidAlertRule, sink.Type)
+		}
+	}
+	return rule, nil
+}
+
+// checkUnique comprueba que ninguna otra regla use el mismo nombre y devuelve
+// la que tiene el id de rule, si existe
+func (s *AlertService) checkUnique(ctx context.Context, rule *entities.AlertRule) (*entities.AlertRule, error) {
+	rules, err := s.ruleRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var current *entities.AlertRule
+	for _, other := range rules {
+		if rule.ID != 0 && other.ID == rule.ID {
+			current = other
+		} else if other.Name == rule.Name {
+			return nil, fmt.Errorf("%w: %s", ErrAlertRuleExists, rule.Name)
+		}
+	}
+	return current, nil
+}
+
+// cacheRule añade o sustituye la regla en memoria y descarta el estado de sus alertas
+func (s *AlertService) cacheRule(rule *entities.AlertRule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dropStates(rule.ID)
+	rules := append([]*entities.AlertRule(nil), *s.rules.Load()...)
+	for i, cached := range rules {
+		if cached.ID == rule.ID {
+			rules[i] = rule
+			s.rules.Store(&rules)
+			return
+		}
+	}
+	rules = append(rules, rule)
+	s.rules.Store(&rules)
+}
+
+// uncacheRule quita la regla y el estado de sus alertas de memoria
+func (s *AlertService) uncacheRule(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dropStates(id)
+	current := *s.rules.Load()
+	rules := make([]*entities.AlertRule, 0, len(current))
+	for _, cached := range current {
+		if cached.ID != id {
+			rules = append(rules, cached)
+		}
+	}
+	s.rules.Store(&rules)
+}
+
+// dropStates descarta de memoria el estado de las alertas de la regla. Debe
+// llamarse con mu bloqueado.
+func (s *AlertService) dropStates(ruleID int) {
+	for key := range s.states {
+		if key.ruleID == ruleID {
+			delete(s.states, key)
+		}
+	}
+}
+
+// Load carga en memoria las reglas y el estado de las alertas. Las reglas
+// guardadas que ya no son válidas se ignoran.
+func (s *AlertService) Load(ctx context.Context) error {
+	rules, err := s.ruleRepo.List(ctx)
+	if err != nil {
+		return err
+	}
+	states, err := s.stateRepo.List(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	valid := make([]*entities.AlertRule, 0, len(rules))
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			log.Warn().Err(err).Int("rule_id", rule.ID).Str("rule", rule.Name).Msg("Regla de alerta no válida, se ignora")
+			continue
+		}
+		valid = append(valid, rule)
+	}
+
+	byKey := make(map[alertKey]*entities.AlertState, len(states))
+	for _, state := range states {
+		byKey[alertKey{ruleID: state.RuleID, topic: state.Topic}] = state
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules.Store(&valid)
+	s.states = byKey
+	return nil
+}
+
+// Observe encola un mensaje recibido para evaluarlo con las reglas. No se
+// bloquea ni toma locks: se llama desde la recepción de mensajes MQTT.
+func (s *AlertService) Observe(msg models.MqttMessage) {
+	if !s.matchesAny(msg.Topic) {
+		return
+	}
+
+	select {
+	case s.queue <- msg:
+	default:
+		if dropped := s.dropped.Add(1); dropped == 1 || dropped%1000 == 0 {
+			log.Warn().Uint64("dropped", dropped).Msg("Cola de evaluación de alertas llena, se descartan mensajes")
+		}
+	}
+}
+
+// matchesAny indica si alguna regla activa se aplica a los mensajes del topic
+func (s *AlertService) matchesAny(topic string) bool {
+	for _, rule := range *s.rules.Load() {
+		if rule.Matches(topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run evalúa los mensajes encolados hasta que se cancele ctx. Al terminar
+// espera a que se entreguen las notificaciones pendientes.
+func (s *AlertService) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			s.notifications.Wait()
+			return
+		case msg := <-s.queue:
+			s.evaluate(ctx, msg)
+		}
+	}
+}
+
+// alertChange es un cambio de estado calculado por evaluate pendiente de guardar
+type alertChange struct {
+	rule       *entities.AlertRule
+	key        alertKey
+	previous   *entities.AlertState
+	next       *entities.AlertState
+	transition entities.AlertStatus
+}
+
+// evaluate aplica al mensaje las reglas de su topic, guarda los cambios de
+// estado y notifica los disparos y resoluciones. Los cambios se calculan con mu
+// bloqueado, pero se guardan y notifican después para no retener el lock
+// durante las consultas. Si un estado no se puede guardar no se notifica y se
+// restaura el anterior; el siguiente mensaje lo vuelve a intentar.
+func (s *AlertService) evaluate(ctx context.Context, msg models.MqttMessage) {
+	for _, change := range s.transitions(msg) {
+		if err := s.persist(ctx, change.next); err != nil {
+			log.Error().Err(err).Int("rule_id", change.rule.ID).Str("topic", msg.Topic).Msg("Error guardando el estado de la alerta")
+			s.restoreState(change)
+			continue
+		}
+
+		if change.transition != "" {
+			s.notify(change.rule, change.rule.Notification(change.next))
+		}
+	}
+}
+
+// transitions calcula los cambios de estado que provoca el mensaje y los
+// aplica en memoria
+func (s *AlertService) transitions(msg models.MqttMessage) []alertChange {
+	var payload interface{}
+	decoded := false
+	var changes []alertChange
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, rule := range *s.rules.Load() {
+		if !rule.Matches(msg.Topic) {
+			continue
+		}
+		if !decoded {
+			if len(msg.PayloadJSON) == 0 || json.Unmarshal(msg.PayloadJSON, &payload) != nil {
+				return nil
+			}
+			decoded = true
+		}
+
+		value, ok := rule.Value(payload)
+		if !ok {
+			continue
+		}
+
+		key := alertKey{ruleID: rule.ID, topic: msg.Topic}
+		previous := s.states[key]
+		next, transition := rule.Evaluate(previous, msg.Topic, value, msg.ReceivedAt)
+		if next == nil {
+			continue
+		}
+		if next.Cleared() {
+			delete(s.states, key)
+		} else {
+			s.states[key] = next
+		}
+		changes = append(changes, alertChange{rule: rule, key: key, previous: previous, next: next, transition: transition})
+	}
+	return changes
+}
+
+// persist guarda el estado de la alerta, o lo elimina si ya no tiene
+func (s *AlertService) persist(ctx context.Context, state *entities.AlertState) error {
+	if state.Cleared() {
+		return s.stateRepo.Delete(ctx, state.RuleID, state.Topic)
+	}
+	return s.stateRepo.Save(ctx, state)
+}
+
+// restoreState deshace en memoria un cambio que no se pudo guardar, salvo que
+// el estado ya se haya sustituido o descartado después
+func (s *AlertService) restoreState(change alertChange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	applied := change.next
+	if applied.Cleared() {
+		applied = nil
+	}
+	if s.states[change.key] != applied {
+		return
+	}
+	if change.previous == nil {
+		delete(s.states, change.key)
+		return
+	}
+	s.states[change.key] = change.previous
+}
+
+// notify entrega la notificación a cada destino de la regla en segundo plano,
+// para que un destino lento no retrase la evaluación de los mensajes
+func (s *AlertService) notify(rule *entities.AlertRule, notification entities.AlertNotification) {
+	for _, config := range rule.Sinks {
+		sink, ok := s.sinks[config.Type]
+		if !ok {
+			log.Warn().Str("sink", config.Type).Int("rule_id", rule.ID).Msg("Destino de alertas no disponible")
+			continue
+		}
+
+		s.notifications.Add(1)
+		go func(config entities.AlertSinkConfig) {
+			defer s.notifications.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), s.opts.NotifyTimeout)
+			defer cancel()
+			if err := sink.Notify(ctx, config, notification); err != nil {
+				log.Error().
+					Err(err).
+					Str("sink", config.Type).
+					Int("rule_id", notification.RuleID).
+					Str("topic", notification.Topic).
+					Str("status", string(notification.Status)).
+					Msg("Error notificando la alerta")
+			}
+		}(config)
+	}
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/dto"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeAlertRuleRepo struct {
+	rules []*entities.AlertRule
+}
+
+func (r *fakeAlertRuleRepo) Create(ctx context.Context, rule *entities.AlertRule) error {
+	rule.ID = len(r.rules) + 1
+	r.rules = append(r.rules, rule)
+	return nil
+}
+
+func (r *fakeAlertRuleRepo) Update(ctx context.Context, rule *entities.AlertRule) (bool, error) {
+	for i, existing := range r.rules {
+		if existing.ID == rule.ID {
+			r.rules[i] = rule
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeAlertRuleRepo) Delete(ctx context.Context, id int) (bool, error) {
+	return false, nil
+}
+
+func (r *fakeAlertRuleRepo) List(ctx context.Context) ([]*entities.AlertRule, error) {
+	return r.rules, nil
+}
+
+type fakeAlertStateRepo struct {
+	states map[alertKey]*entities.AlertState
+	// saveErr hace fallar los guardados mientras no sea nil
+	saveErr error
+}
+
+func (r *fakeAlertStateRepo) List(ctx context.Context, status entities.AlertStatus) ([]*entities.AlertState, error) {
+	states := []*entities.AlertState{}
+	for _, state := range r.states {
+		if status == "" || state.Status == status {
+			states = append(states, state)
+		}
+	}
+	return states, nil
+}
+
+func (r *fakeAlertStateRepo) Save(ctx context.Context, state *entities.AlertState) error {
+	if r.saveErr != nil {
+		return r.saveErr
+	}
+	r.states[alertKey{ruleID: state.RuleID, topic: state.Topic}] = state
+	return nil
+}
+
+func (r *fakeAlertStateRepo) Delete(ctx context.Context, ruleID int, topic string) error {
+	delete(r.states, alertKey{ruleID: ruleID, topic: topic})
+	return nil
+}
+
+func (r *fakeAlertStateRepo) DeleteByRule(ctx context.Context, ruleID int) error {
+	for key := range r.states {
+		if key.ruleID == ruleID {
+			delete(r.states, key)
+		}
+	}
+	return nil
+}
+
+// fakeAlertSink anota las notificaciones recibidas
+type fakeAlertSink struct {
+	mu            sync.Mutex
+	notifications []entities.AlertNotification
+}
+
+func (s *fakeAlertSink) Notify(ctx context.Context, sink entities.AlertSinkConfig, notification entities.AlertNotification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifications = append(s.notifications, notification)
+	return nil
+}
+
+func float64p(v float64) *float64 { return &v }
+
+func newTestAlertService() (*AlertService, *fakeAlertStateRepo, *fakeAlertSink) {
+	states := &fakeAlertStateRepo{states: map[alertKey]*entities.AlertState{}}
+	sink := &fakeAlertSink{}
+	service := NewAlertService(&fakeAlertRuleRepo{}, states, AlertOptions{})
+	service.RegisterSink(entities.AlertSinkLog, sink)
+	return service, states, sink
+}
+
+func TestAlertCreateRule(t *testing.T) {
+	service, _, _ := newTestAlertService()
+
+	_, err := service.CreateRule(context.Background(), dto.AlertRuleRequest{Name: "calor", TopicFilter: "sensores/#", Field: "$.temperatura", Comparator: "gt"})
+	assert.ErrorIs(t, err, ErrInvalidAlertRule, "Threshold is required")
+
+	_, err = service.CreateRule(context.Background(), dto.AlertRuleRequest{Name: "calor", TopicFilter: "sensores/#", Field: "$.temperatura", Comparator: "gt",
+		Threshold: float64p(30), Sinks: []entities.AlertSinkConfig{{Type: entities.AlertSinkWebhook, URL: "http://example.com"}}})
+	assert.ErrorIs(t, err, ErrInvalidAlertRule, "Webhook sink not registered")
+
+	rule, err := service.CreateRule(context.Background(), dto.AlertRuleRequest{Name: "calor", TopicFilter: "sensores/#", Field: "$.temperatura", Comparator: "gt", Threshold: float64p(30)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, rule.Enabled, "Rules are enabled by default")
+
+	_, err = service.CreateRule(context.Background(), dto.AlertRuleRequest{Name: "calor", TopicFilter: "otros/#", Field: "$.valor", Comparator: "lt", Threshold: float64p(0)})
+	assert.ErrorIs(t, err, ErrAlertRuleExists)
+
+	_, err = service.UpdateRule(context.Background(), 42, dto.AlertRuleRequest{Name: "frio", TopicFilter: "sensores/#", Field: "$.temperatura", Comparator: "lt", Threshold: float64p(5)})
+	assert.ErrorIs(t, err, ErrAlertRuleNotFound)
+}
+
+func TestAlertEvaluateFiresAndResolves(t *testing.T) {
+	service, states, sink := newTestAlertService()
+	rule, err := service.CreateRule(context.Background(), dto.AlertRuleRequest{Name: "calor", TopicFilter: "sensores/+/temperatura", Field: "$.valor",
+		Comparator: "gt", Threshold: float64p(30), WindowSeconds: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	message := func(topic, payload string, offset time.Duration) models.MqttMessage {
+		return models.NewMqttMessage(topic, []byte(payload), 1, false, start.Add(offset))
+	}
+
+	for _, msg := range []models.MqttMessage{
+		message("sensores/sala1/temperatura", `{"valor": 31}`, 0),
+		message("sensores/sala2/temperatura", `{"valor": 35}`, 0),
+		message("sensores/sala1/temperatura", `no es json`, 30*time.Second),
+		message("sensores/sala1/temperatura", `{"valor": 32}`, time.Minute),
+		message("sensores/sala2/temperatura", `{"valor": 20}`, time.Minute),
+		message("sensores/sala1/temperatura", `{"valor": 25}`, 2*time.Minute),
+	} {
+		service.evaluate(context.Background(), msg)
+	}
+	service.notifications.Wait()
+
+	// Las notificaciones se entregan en paralelo, sin orden garantizado
+	values := map[entities.AlertStatus]float64{}
+	for _, notification := range sink.notifications {
+		assert.Equal(t, "sensores/sala1/temperatura", notification.Topic)
+		values[notification.Status] = notification.Value
+	}
+	assert.Equal(t, map[entities.AlertStatus]float64{entities.AlertFiring: 32, entities.AlertResolved: 25}, values)
+
+	assert.Equal(t, entities.AlertResolved, states.states[alertKey{ruleID: rule.ID, topic: "sensores/sala1/temperatura"}].Status)
+	assert.NotContains(t, states.states, alertKey{ruleID: rule.ID, topic: "sensores/sala2/temperatura"},
+		"A pending alert that clears without ever firing leaves no state")
+	assert.NotContains(t, service.states, alertKey{ruleID: rule.ID, topic: "sensores/sala2/temperatura"})
+
+	resolved, err := service.ListAlerts(context.Background(), string(entities.AlertResolved), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, resolved, 1, "Only alerts that fired are listed as resolved")
+}
+
+func TestAlertEvaluateRetriesFailedSaves(t *testing.T) {
+	service, states, sink := newTestAlertService()
+	rule, err := service.CreateRule(context.Background(), dto.AlertRuleRequest{Name: "calor", TopicFilter: "sensores/#", Field: "$.valor",
+		Comparator: "gt", Threshold: float64p(30)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := alertKey{ruleID: rule.ID, topic: "sensores/sala1"}
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	states.saveErr = errors.New("connection refused")
+	service.evaluate(context.Background(), models.NewMqttMessage("sensores/sala1", []byte(`{"valor": 31}`), 1, false, start))
+	service.notifications.Wait()
+	assert.Empty(t, sink.notifications, "Unsaved transitions are not notified")
+	assert.NotContains(t, service.states, key, "The state is restored so the next message retries")
+
+	states.saveErr = nil
+	service.evaluate(context.Background(), models.NewMqttMessage("sensores/sala1", []byte(`{"valor": 32}`), 1, false, start.Add(time.Second)))
+	service.notifications.Wait()
+	if assert.Len(t, sink.notifications, 1) {
+		assert.Equal(t, entities.AlertFiring, sink.notifications[0].Status)
+	}
+	assert.Equal(t, entities.AlertFiring, states.states[key].Status)
+}
+
+func TestAlertObserveSkipsUnmatchedTopics(t *testing.T) {
+	service, _, _ := newTestAlertService()
+	if _, err := service.CreateRule(context.Background(), dto.AlertRuleRequest{Name: "calor", TopicFilter: "sensores/#", Field: "$.valor",
+		Comparator: "gt", Threshold: float64p(30)}); err != nil {
+		t.Fatal(err)
+	}
+
+	service.Observe(models.NewMqttMessage("actuadores/puerta", []byte(`{"valor": 40}`), 0, false, time.Now()))
+	assert.Len(t, service.queue, 0)
+	service.Observe(models.NewMqttMessage("sensores/sala1", []byte(`{"valor": 40}`), 0, false, time.Now()))
+	assert.Len(t, service.queue, 1)
+}
+
+func TestAlertListAlertsRestrictsTopics(t *testing.T) {
+	service, states, _ := newTestAlertService()
+	states.states[alertKey{ruleID: 1, topic: "sensores/sala1"}] = &entities.AlertState{RuleID: 1, Topic: "sensores/sala1", Status: entities.AlertFiring}
+	states.states[alertKey{ruleID: 1, topic: "privado/sala2"}] = &entities.AlertState{RuleID: 1, Topic: "privado/sala2", Status: entities.AlertFiring}
+
+	alerts, err := service.ListAlerts(context.Background(), "firing", true, []string{"sensores/#"})
+	assert.NoError(t, err)
+	if assert.Len(t, alerts, 1) {
+		assert.Equal(t, "sensores/sala1", alerts[0].Topic)
+	}
+
+	alerts, err = service.ListAlerts(context.Background(), "", false, nil)
+	assert.NoError(t, err)
+	assert.Len(t, alerts, 2)
+
+	_, err = service.ListAlerts(context.Background(), "open", false, nil)
+	assert.ErrorIs(t, err, ErrInvalidAlertStatus)
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/dto"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/repositories"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrInvalidRefreshToken se devuelve cuando el refresh token no existe, caducó o fue revocado
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused se devuelve cuando se presenta un refresh token ya rotado
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+// AccessTokenIssuer emite access tokens firmados para un usuario
+type AccessTokenIssuer interface {
+	IssueAccessToken(user *entities.User) (string, time.Time, error)
+}
+
+// AuthService encapsula el inicio de sesión y la rotación de refresh tokens
+type AuthService struct {
+	userService *UserService
+	userRepo    repositories.UserRepository
+	refreshRepo repositories.RefreshTokenRepository
+	tokens      AccessTokenIssuer
+	refreshTTL  time.Duration
+}
+
+// NewAuthService crea una nueva instancia del servicio de autenticación
+func NewAuthService(
+	userService *UserService,
+	userRepo repositories.UserRepository,
+	refreshRepo repositories.RefreshTokenRepository,
+	tokens AccessTokenIssuer,
+	refreshTTL time.Duration,
+) *AuthService {
+	return &AuthService{
+		userService: userService,
+		userRepo:    userRepo,
+		refreshRepo: refreshRepo,
+		tokens:      tokens,
+		refreshTTL:  refreshTTL,
+	}
+}
+
+// Login verifica las credenciales e inicia una nueva cadena de refresh tokens
+func (s *AuthService) Login(ctx context.Context, req dto.LoginRequest) (*dto.TokenResponse, error) {
+	if req.Login == "" || req.Password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	user, err := s.userService.Authenticate(ctx, req.Login, req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, _, err := s.issueTokens(ctx, user, randomToken(16))
+	return tokens, err
+}
+
+// ChangePassword cambia la contraseña a partir de las credenciales actuales, sin emitir tokens.
+// El usuario debe iniciar sesión con la nueva contraseña.
+func (s *AuthService) ChangePassword(ctx context.Context, req dto.PasswordChangeRequest) error {
+	if req.Login == "" || req.OldPassword == "" {
+		return ErrInvalidCredentials
+	}
+
+	return s.userService.ChangePasswordWithCredentials(ctx, req.Login, req.OldPassword, req.NewPassword)
+}
+
+// Refresh rota el refresh token: el presentado queda revocado y se emite uno nuevo de
+// la misma cadena. Presentar un token ya rotado revoca la cadena completa.
+func (s *AuthService) Refresh(ctx context.Context, req dto.RefreshRequest) (*dto.TokenResponse, error) {
+	current, err := s.findRefreshToken(ctx, req.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if current.IsRevoked() {
+		s.revokeReusedFamily(ctx, current)
+		return nil, ErrRefreshTokenReused
+	}
+	if current.IsExpired(time.Now()) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.GetByID(ctx, current.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil || !user.Active() || user.PasswordResetRequired {
+		if err := s.refreshRepo.RevokeFamily(ctx, current.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	tokens, next, err := s.issueTokens(ctx, user, current.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+
+	rotated, err := s.refreshRepo.Rotate(ctx, current.ID, next.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		// Otra petición rotó el mismo token a la vez: se trata como reutilización
+		s.revokeReusedFamily(ctx, current)
+		return nil, ErrRefreshTokenReused
+	}
+
+	return tokens, nil
+}
+
+// Logout revoca la cadena de refresh tokens a la que pertenece el token presentado
+func (s *AuthService) Logout(ctx context.Context, req dto.RefreshRequest) error {
+	current, err := s.findRefreshToken(ctx, req.RefreshToken)
+	if err != nil {
+		return err
+	}
+
+	return s.refreshRepo.RevokeFamily(ctx, current.FamilyID)
+}
+
+// findRefreshToken busca el refresh token a partir de su valor en claro
+func (s *AuthService) findRefreshToken(ctx context.Context, value string) (*entities.RefreshToken, error) {
+	if value == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	token, err := s.refreshRepo.GetByHash(ctx, hashRefreshToken(value))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	if token == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return token, nil
+}
+
+// revokeReusedFamily revoca la cadena de un token reutilizado, que se considera comprometida
+func (s *AuthService) revokeReusedFamily(ctx context.Context, token *entities.RefreshToken) {
+	log.Warn().
+		Int("user_id", token.UserID).
+		Str("family_id", token.FamilyID).
+		Msg("Reutilización de refresh token detectada, revocando la cadena")
+
+	if err := s.refreshRepo.RevokeFamily(ctx, token.FamilyID); err != nil {
+		log.Error().Err(err).Str("family_id", token.FamilyID).Msg("Error revocando la cadena de refresh tokens")
+	}
+}
+
+// issueTokens emite un access token y un refresh token de la cadena indicada.
+// Devuelve también el refresh token persistido para poder enlazar la rotación.
+func (s *AuthService) issueTokens(ctx context.Context, user *entities.User, familyID string) (*dto.TokenResponse, *entities.RefreshToken, error) {
+	// Los roles viajan en el access token; los cambios se aplican al renovarlo
+	roles, err := s.userService.UserRoles(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	user.Roles = roles
+
+	accessToken, accessExpiresAt, err := s.tokens.IssueAccessToken(user)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refreshValue := randomToken(32)
+	refresh := &entities.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: hashRefreshToken(refreshValue),
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	}
+	if err := s.refreshRepo.Create(ctx, refresh); err != nil {
+		return nil, nil, err
+	}
+
+	return &dto.TokenResponse{
+		AccessToken:      accessToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int(time.Until(accessExpiresAt).Seconds()),
+		RefreshToken:     refreshValue,
+		RefreshExpiresAt: refresh.ExpiresAt.Format("2006-01-02T15:04:05Z07:00"),
+	}, refresh, nil
+}
+
+// randomToken genera un valor aleatorio codificado en base64url
+func randomToken(size int) string {
+	b := make([]byte, size)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// hashRefreshToken calcula el hash con el que se persiste un refresh token
+func hashRefreshToken(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
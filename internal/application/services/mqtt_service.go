@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/dto"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/pipeline"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/subscriber"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/mqtttopic"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrPipelineDisabled se devuelve cuando los mensajes no se guardan por lotes
+var ErrPipelineDisabled = errors.New("MQTT message pipeline is not enabled")
+
+// ErrInvalidTopic se devuelve cuando el topic MQTT no tiene un formato válido
+var ErrInvalidTopic = errors.New("invalid MQTT topic")
+
+// ErrInvalidMessageFilter se devuelve cuando un filtro de mensajes no es válido
+var ErrInvalidMessageFilter = errors.New("invalid MQTT message filter")
+
+// ErrInvalidPayload se devuelve cuando el payload a publicar falta o no es válido
+var ErrInvalidPayload = errors.New("invalid MQTT payload")
+
+// AggregatePlanner decide qué tramos de una consulta de agregación se leen de
+// las tablas de agregados en lugar de los mensajes
+type AggregatePlanner interface {
+	PlanAggregate(ctx context.Context, query *repository.AggregateQuery) error
+}
+
+// MqttService encapsula la gestión de suscripciones y mensajes MQTT
+type MqttService struct {
+	manager *subscriber.SubscriberManager
+	rollups AggregatePlanner
+}
+
+// NewMqttService crea una nueva instancia del servicio MQTT
+func NewMqttService(manager *subscriber.SubscriberManager) *MqttService {
+	return &MqttService{
+		manager: manager,
+	}
+}
+
+// Subscribe crea un suscriptor para el topic indicado y lo persiste a nombre de createdBy
+func (s *MqttService) Subscribe(topic string, qos byte, createdBy int) error {
+	topic = strings.TrimSpace(topic)
+	if err := validateTopic(topic); err != nil {
+		return err
+	}
+
+	return subscriber.AddTopicSubscriber(topic, qos, &createdBy)
+}
+
+// Unsubscribe elimina el suscriptor del topic indicado
+func (s *MqttService) Unsubscribe(topic string) error {
+	topic = strings.TrimSpace(topic)
+	if topic == "" {
+		return subscriber.ErrEmptyTopic
+	}
+
+	return subscriber.DeleteTopicSubscriber(topic)
+}
+
+// SetRollups hace que las agregaciones usen los agregados precalculados cuando
+// los hay
+func (s *MqttService) SetRollups(planner AggregatePlanner) {
+	s.rollups = planner
+}
+
+// ActiveTopics devuelve los topics con un suscriptor activo
+func (s *MqttService) ActiveTopics() []string {
+	return s.manager.GetActiveSubscribers()
+}
+
+// ListMessages obtiene los últimos mensajes MQTT almacenados
+func (s *MqttService) ListMessages(ctx context.Context, limit int) ([]models.MqttMessage, error) {
+	messages, err := s.manager.ListMqttMessages(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list MQTT messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+// FindMessages obtiene una página de los mensajes que cumplen la consulta
+func (s *MqttService) FindMessages(ctx context.Context, query repository.MessageQuery) (*repository.MessagePage, error) {
+	page, err := s.manager.FindMqttMessages(ctx, query)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidMessageQuery) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidMessageFilter, err)
+		}
+		return nil, fmt.Errorf("failed to list MQTT messages: %w", err)
+	}
+
+	return page, nil
+}
+
+// AggregateMessages calcula por intervalos las funciones pedidas sobre un campo
+// numérico del payload, con una serie por topic. Devuelve también la
+// resolución más gruesa de los agregados usados, o raw si no se usó ninguno.
+func (s *MqttService) AggregateMessages(ctx context.Context, query repository.AggregateQuery) ([]repository.AggregateSeries, string, error) {
+	if s.rollups != nil {
+		if err := s.rollups.PlanAggregate(ctx, &query); err != nil {
+			// Sin agregados el resultado es el mismo, solo más lento
+			log.Warn().Err(err).Msg("No se pudieron consultar los agregados, se usan los mensajes")
+			query.Segments = nil
+		}
+	}
+
+	series, err := s.manager.AggregateMqttMessages(ctx, query)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidMessageQuery) {
+			return nil, "", fmt.Errorf("%w: %v", ErrInvalidMessageFilter, err)
+		}
+		return nil, "", fmt.Errorf("failed to aggregate MQTT messages: %w", err)
+	}
+
+	return series, query.Resolution(), nil
+}
+
+// Publish publica un mensaje por la conexión compartida con el broker y espera
+// su confirmación según el QoS. Si se pide, lo guarda como saliente.
+func (s *MqttService) Publish(ctx context.Context, req dto.PublishRequest) (*dto.PublishResponse, error) {
+	topic := strings.TrimSpace(req.Topic)
+	if err := mqtttopic.ValidateTopic(topic); err != nil {
+		if errors.Is(err, mqtttopic.ErrEmpty) {
+			return nil, subscriber.ErrEmptyTopic
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTopic, err)
+	}
+
+	payload, err := publishPayload(req)
+	if err != nil {
+		return nil, err
+	}
+
+	qos := subscriber.DefaultQOS
+	if req.QOS != nil {
+		qos = *req.QOS
+	}
+
+	msg, err := s.manager.Publish(ctx, topic, payload, qos, req.Retain, req.Record)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dto.PublishResponse{
+		Topic:       msg.Topic,
+		QOS:         qos,
+		Retain:      msg.Retained,
+		Bytes:       len(payload),
+		PublishedAt: msg.ReceivedAt,
+	}
+	if msg.ID > 0 {
+		resp.MessageID = &msg.ID
+	}
+	return resp, nil
+}
+
+// publishPayload obtiene los bytes a publicar: los de payload_base64 o los del
+// valor JSON de payload, salvo las cadenas, que se publican sin comillas
+func publishPayload(req dto.PublishRequest) ([]byte, error) {
+	hasJSON := len(req.Payload) > 0 && string(req.Payload) != "null"
+
+	switch {
+	case hasJSON && req.PayloadBase64 != nil:
+		return nil, fmt.Errorf("%w: use either payload or payload_base64", ErrInvalidPayload)
+	case req.PayloadBase64 != nil:
+		payload, err := base64.StdEncoding.DecodeString(*req.PayloadBase64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: payload_base64 is not valid base64", ErrInvalidPayload)
+		}
+		return payload, nil
+	case hasJSON:
+		var text string
+		if err := json.Unmarshal(req.Payload, &text); err == nil {
+			return []byte(text), nil
+		}
+		return req.Payload, nil
+	default:
+		return nil, fmt.Errorf("%w: payload or payload_base64 is required", ErrInvalidPayload)
+	}
+}
+
+// PipelineStats devuelve las métricas del pipeline que guarda los mensajes recibidos
+func (s *MqttService) PipelineStats() (pipeline.Stats, error) {
+	stats, ok := s.manager.PipelineStats()
+	if !ok {
+		return pipeline.Stats{}, ErrPipelineDisabled
+	}
+	return stats, nil
+}
+
+// validateTopic valida el formato de un topic o filtro de suscripción MQTT
+func validateTopic(topic string) error {
+	if err := mqtttopic.ValidateFilter(topic); err != nil {
+		if errors.Is(err, mqtttopic.ErrEmpty) {
+			return subscriber.ErrEmptyTopic
+		}
+		return fmt.Errorf("%w: %v", ErrInvalidTopic, err)
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/dto"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/repositories"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrRetentionRuleNotFound se devuelve cuando la regla de retención no existe
+	ErrRetentionRuleNotFound = errors.New("retention rule not found")
+	// ErrRetentionRuleExists se devuelve cuando ya hay una regla para el filtro
+	ErrRetentionRuleExists = errors.New("a retention rule already exists for this topic filter")
+	// ErrInvalidRetentionRule se devuelve cuando el filtro o los límites no son válidos
+	ErrInvalidRetentionRule = errors.New("invalid retention rule")
+)
+
+// RetentionOptions controla cómo se borran los mensajes caducados
+type RetentionOptions struct {
+	// BatchSize son los mensajes que se borran en cada sentencia
+	BatchSize int
+	// BatchPause es la espera entre lotes, para dejar paso al resto de escrituras
+	BatchPause time.Duration
+}
+
+// RetentionService gestiona las reglas de retención de mensajes MQTT y purga
+// los mensajes que han caducado según ellas
+type RetentionService struct {
+	ruleRepo    repositories.RetentionRuleRepository
+	messageRepo repositories.MessageRetentionRepository
+	opts        RetentionOptions
+	now         func() time.Time
+}
+
+// NewRetentionService crea una nueva instancia del servicio de retención
+func NewRetentionService(ruleRepo repositories.RetentionRuleRepository, messageRepo repositories.MessageRetentionRepository, opts RetentionOptions) *RetentionService {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	return &RetentionService{
+		ruleRepo:    ruleRepo,
+		messageRepo: messageRepo,
+		opts:        opts,
+		now:         time.Now,
+	}
+}
+
+// List obtiene todas las reglas de retención
+func (s *RetentionService) List(ctx context.Context) ([]*entities.RetentionRule, error) {
+	return s.ruleRepo.List(ctx)
+}
+
+// Create crea una regla de retención para un filtro que no tenga ya una
+func (s *RetentionService) Create(ctx context.Context, req dto.RetentionRuleRequest) (*entities.RetentionRule, error) {
+	rule, err := entities.NewRetentionRule(strings.TrimSpace(req.TopicFilter), req.MaxAgeSeconds, req.MaxRows)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRetentionRule, err)
+	}
+
+	if err := s.checkUnique(ctx, rule); err != nil {
+		return nil, err
+	}
+	if err := s.ruleRepo.Create(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+// Update sustituye el filtro y los límites de una regla
+func (s *RetentionService) Update(ctx context.Context, id int, req dto.RetentionRuleRequest) (*entities.RetentionRule, error) {
+	rule := &entities.RetentionRule{
+		ID:            id,
+		TopicFilter:   strings.TrimSpace(req.TopicFilter),
+		MaxAgeSeconds: req.MaxAgeSeconds,
+		MaxRows:       req.MaxRows,
+	}
+	if err := rule.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRetentionRule, err)
+	}
+
+	if err := s.checkUnique(ctx, rule); err != nil {
+		return nil, err
+	}
+	updated, err := s.ruleRepo.Update(ctx, rule)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, ErrRetentionRuleNotFound
+	}
+
+	return rule, nil
+}
+
+// Delete elimina una regla de retención
+func (s *RetentionService) Delete(ctx context.Context, id int) error {
+	deleted, err := s.ruleRepo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrRetentionRuleNotFound
+	}
+	return nil
+}
+
+// checkUnique comprueba que ninguna otra regla use el mismo filtro
+func (s *RetentionService) checkUnique(ctx context.Context, rule *entities.RetentionRule) error {
+	rules, err := s.ruleRepo.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, other := range rules {
+		if other.TopicFilter == rule.TopicFilter && other.ID != rule.ID {
+			return fmt.Errorf("%w: %s", ErrRetentionRuleExists, rule.TopicFilter)
+		}
+	}
+	return nil
+}
+
+// DryRun informa de cuántos mensajes eliminaría cada regla sin eliminarlos
+func (s *RetentionService) DryRun(ctx context.Context) ([]*entities.RetentionReport, error) {
+	plans, err := s.plan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]*entities.RetentionReport, 0, len(plans))
+	for _, p := range plans {
+		if p.report.Before != nil {
+			if p.report.Rows, err = s.messageRepo.CountBefore(ctx, p.scope, *p.report.Before); err != nil {
+				return nil, err
+			}
+		}
+		reports = append(reports, p.report)
+	}
+	return reports, nil
+}
+
+// Purge elimina los mensajes caducados según cada regla, en lotes de
+// BatchSize. Si falla una regla sigue con las demás y devuelve los errores juntos.
+func (s *RetentionService) Purge(ctx context.Context) ([]*entities.RetentionReport, error) {
+	plans, err := s.plan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]*entities.RetentionReport, 0, len(plans))
+	var errs []error
+	for _, p := range plans {
+		if p.report.Before != nil {
+			if err := s.purgeScope(ctx, p.scope, *p.report.Before, p.report); err != nil {
+				if ctx.Err() != nil {
+					return reports, ctx.Err()
+				}
+				errs = append(errs, fmt.Errorf("%s: %w", p.report.TopicFilter, err))
+			}
+		}
+		reports = append(reports, p.report)
+	}
+	return reports, errors.Join(errs...)
+}
+
+// purgeScope borra lote a lote hasta que no queden mensajes anteriores a before
+func (s *RetentionService) purgeScope(ctx context.Context, scope entities.RetentionScope, before entities.MessagePosition, report *entities.RetentionReport) error {
+	for {
+		deleted, err := s.messageRepo.DeleteBefore(ctx, scope, before, s.opts.BatchSize)
+		if err != nil {
+			return err
+		}
+		report.Rows += deleted
+		if deleted < int64(s.opts.BatchSize) {
+			return nil
+		}
+
+		if s.opts.BatchPause > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(s.opts.BatchPause):
+			}
+		}
+	}
+}
+
+// retentionPlan es una regla con los mensajes que gobierna y desde dónde se conservan
+type retentionPlan struct {
+	scope  entities.RetentionScope
+	report *entities.RetentionReport
+}
+
+// plan calcula para cada regla su ámbito y la posición desde la que se
+// conservan sus mensajes
+func (s *RetentionService) plan(ctx context.Context) ([]retentionPlan, error) {
+	rules, err := s.ruleRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	plans := make([]retentionPlan, 0, len(rules))
+	for _, rule := range rules {
+		scope := rule.Scope(rules)
+
+		var oldestKept *entities.MessagePosition
+		if rule.MaxRows != nil {
+			if oldestKept, err = s.messageRepo.OldestKept(ctx, scope, *rule.MaxRows); err != nil {
+				return nil, err
+			}
+		}
+
+		plans = append(plans, retentionPlan{
+			scope: scope,
+			report: &entities.RetentionReport{
+				RuleID:      rule.ID,
+				TopicFilter: rule.TopicFilter,
+				Overridden:  scope.Exclude,
+				Before:      rule.Boundary(now, oldestKept),
+			},
+		})
+	}
+	return plans, nil
+}
+
+// PartitionCutoff devuelve el instante antes del cual han caducado los
+// mensajes de todos los topics, o nil si alguna regla no limita la antigüedad.
+// Las particiones de mqtt_messages que terminan antes se pueden eliminar enteras.
+func (s *RetentionService) PartitionCutoff(ctx context.Context) (*time.Time, error) {
+	rules, err := s.ruleRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return entities.RetentionCutoff(rules, s.now()), nil
+}
+
+// RunPurges purga los mensajes caducados cada interval hasta que se cancele ctx
+func (s *RetentionService) RunPurges(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		reports, err := s.Purge(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		var deleted int64
+		for _, report := range reports {
+			deleted += report.Rows
+		}
+		if err != nil {
+			log.Error().Err(err).Int64("deleted", deleted).Msg("Error purgando mensajes MQTT caducados")
+		} else if deleted > 0 {
+			log.Info().Int64("deleted", deleted).Int("rules", len(reports)).Dur("duration", time.Since(start)).Msg("Mensajes MQTT caducados purgados")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/dto"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeRuleRepo struct {
+	rules []*entities.RetentionRule
+}
+
+func (r *fakeRuleRepo) Create(ctx context.Context, rule *entities.RetentionRule) error {
+	rule.ID = len(r.rules) + 1
+	r.rules = append(r.rules, rule)
+	return nil
+}
+
+func (r *fakeRuleRepo) Update(ctx context.Context, rule *entities.RetentionRule) (bool, error) {
+	for i, existing := range r.rules {
+		if existing.ID == rule.ID {
+			r.rules[i] = rule
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeRuleRepo) Delete(ctx context.Context, id int) (bool, error) {
+	return false, nil
+}
+
+func (r *fakeRuleRepo) List(ctx context.Context) ([]*entities.RetentionRule, error) {
+	return r.rules, nil
+}
+
+// fakeMessages guarda las posiciones de los mensajes por filtro de regla
+type fakeMessages struct {
+	byFilter map[string][]entities.MessagePosition
+	deletes  int
+}
+
+func (m *fakeMessages) OldestKept(ctx context.Context, scope entities.RetentionScope, keep int64) (*entities.MessagePosition, error) {
+	positions := m.byFilter[scope.TopicFilter]
+	if int64(len(positions)) < keep {
+		return nil, nil
+	}
+	// Las posiciones están en orden ascendente
+	kept := positions[int64(len(positions))-keep]
+	return &kept, nil
+}
+
+func (m *fakeMessages) CountBefore(ctx context.Context, scope entities.RetentionScope, before entities.MessagePosition) (int64, error) {
+	var count int64
+	for _, p := range m.byFilter[scope.TopicFilter] {
+		if p.Before(before) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *fakeMessages) DeleteBefore(ctx context.Context, scope entities.RetentionScope, before entities.MessagePosition, limit int) (int64, error) {
+	m.deletes++
+	positions := m.byFilter[scope.TopicFilter]
+	n := 0
+	for n < len(positions) && n < limit && positions[n].Before(before) {
+		n++
+	}
+	m.byFilter[scope.TopicFilter] = positions[n:]
+	return int64(n), nil
+}
+
+func positionsEvery(start time.Time, step time.Duration, n int) []entities.MessagePosition {
+	positions := make([]entities.MessagePosition, n)
+	for i := range positions {
+		positions[i] = entities.MessagePosition{ReceivedAt: start.Add(time.Duration(i) * step), ID: i + 1}
+	}
+	return positions
+}
+
+func TestRetentionDryRunAndPurge(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	maxAge, maxRows := int64(10*3600), int64(3)
+	rules := &fakeRuleRepo{rules: []*entities.RetentionRule{
+		{ID: 1, TopicFilter: "#", MaxAgeSeconds: &maxAge},
+		{ID: 2, TopicFilter: "sensores/#", MaxRows: &maxRows},
+	}}
+	messages := &fakeMessages{byFilter: map[string][]entities.MessagePosition{
+		// Un mensaje por hora durante las últimas 24 horas
+		"#":          positionsEvery(now.Add(-24*time.Hour), time.Hour, 24),
+		"sensores/#": positionsEvery(now.Add(-24*time.Hour), time.Hour, 10),
+	}}
+	service := NewRetentionService(rules, messages, RetentionOptions{BatchSize: 4})
+	service.now = func() time.Time { return now }
+
+	reports, err := service.DryRun(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(14), reports[0].Rows, "Messages older than 10 hours")
+	assert.Equal(t, []string{"sensores/#"}, reports[0].Overridden)
+	assert.Equal(t, int64(7), reports[1].Rows, "All but the 3 newest")
+	assert.Len(t, messages.byFilter["#"], 24, "A dry run must not delete anything")
+
+	reports, err = service.Purge(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(14), reports[0].Rows)
+	assert.Equal(t, int64(7), reports[1].Rows)
+	assert.Len(t, messages.byFilter["#"], 10)
+	assert.Len(t, messages.byFilter["sensores/#"], 3)
+	assert.Equal(t, 4+2, messages.deletes, "Rows are deleted in batches of BatchSize")
+}
+
+func TestRetentionRulesAreUniquePerFilter(t *testing.T) {
+	maxAge := int64(3600)
+	service := NewRetentionService(&fakeRuleRepo{}, &fakeMessages{}, RetentionOptions{})
+
+	rule, err := service.Create(context.Background(), dto.RetentionRuleRequest{TopicFilter: " sensores/# ", MaxAgeSeconds: &maxAge})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "sensores/#", rule.TopicFilter)
+
+	_, err = service.Create(context.Background(), dto.RetentionRuleRequest{TopicFilter: "sensores/#", MaxAgeSeconds: &maxAge})
+	assert.ErrorIs(t, err, ErrRetentionRuleExists)
+
+	_, err = service.Create(context.Background(), dto.RetentionRuleRequest{TopicFilter: "actuadores/#"})
+	assert.ErrorIs(t, err, ErrInvalidRetentionRule)
+
+	_, err = service.Update(context.Background(), rule.ID, dto.RetentionRuleRequest{TopicFilter: "sensores/#", MaxAgeSeconds: &maxAge})
+	assert.NoError(t, err, "A rule keeps its own filter")
+
+	_, err = service.Update(context.Background(), 99, dto.RetentionRuleRequest{TopicFilter: "otros/#", MaxAgeSeconds: &maxAge})
+	assert.ErrorIs(t, err, ErrRetentionRuleNotFound)
+}
@@ -0,0 +1,331 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/dto"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/repositories"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/repository"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrRollupConfigNotFound se devuelve cuando la configuración de agregados no existe
+	ErrRollupConfigNotFound = errors.New("rollup config not found")
+	// ErrRollupConfigExists se devuelve cuando ya hay una configuración para el filtro
+	ErrRollupConfigExists = errors.New("a rollup config already exists for this topic filter")
+	// ErrInvalidRollupConfig se devuelve cuando el filtro o los campos no son válidos
+	ErrInvalidRollupConfig = errors.New("invalid rollup config")
+)
+
+// rollupStepBuckets son los intervalos que se calculan como mucho en cada
+// sentencia: un día de agregados por minuto, 60 días de agregados por hora...
+const rollupStepBuckets = 1440
+
+// RollupOptions controla el cálculo de los agregados
+type RollupOptions struct {
+	// Lag es el margen que se deja a los mensajes recientes antes de
+	// agregarlos, para que el pipeline los haya guardado. Los mensajes que se
+	// guardan más tarde con un received_at anterior al watermark no se agregan.
+	Lag time.Duration
+}
+
+// RollupService gestiona las configuraciones de agregados y calcula en segundo
+// plano los agregados por minuto, hora y día de los mensajes MQTT
+type RollupService struct {
+	configRepo repositories.RollupConfigRepository
+	rollupRepo repositories.RollupRepository
+	opts       RollupOptions
+	now        func() time.Time
+}
+
+// NewRollupService crea una nueva instancia del servicio de agregados
+func NewRollupService(configRepo repositories.RollupConfigRepository, rollupRepo repositories.RollupRepository, opts RollupOptions) *RollupService {
+	return &RollupService{
+		configRepo: configRepo,
+		rollupRepo: rollupRepo,
+		opts:       opts,
+		now:        time.Now,
+	}
+}
+
+// List obtiene todas las configuraciones con el punto hasta el que están calculadas
+func (s *RollupService) List(ctx context.Context) ([]*entities.RollupConfig, error) {
+	return s.configRepo.List(ctx)
+}
+
+// Create crea una configuración para un filtro que no tenga ya una. Sus
+// agregados se calculan desde el mensaje más antiguo en las siguientes pasadas.
+func (s *RollupService) Create(ctx context.Context, req dto.RollupConfigRequest) (*entities.RollupConfig, error) {
+	config, err := entities.NewRollupConfig(strings.TrimSpace(req.TopicFilter), req.Fields)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRollupConfig, err)
+	}
+
+	if _, err := s.checkUnique(ctx, config); err != nil {
+		return nil, err
+	}
+	if err := s.configRepo.Create(ctx, config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Update sustituye el filtro y los campos de una configuración. Si cambian, sus
+// agregados se vuelven a calcular desde el principio.
+func (s *RollupService) Update(ctx context.Context, id int, req dto.RollupConfigRequest) (*entities.RollupConfig, error) {
+	config, err := entities.NewRollupConfig(strings.TrimSpace(req.TopicFilter), req.Fields)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRollupConfig, err)
+	}
+	config.ID = id
+
+	current, err := s.checkUnique(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, ErrRollupConfigNotFound
+	}
+
+	updated, err := s.configRepo.Update(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, ErrRollupConfigNotFound
+	}
+
+	if config.TopicFilter != current.TopicFilter || !sameFields(config.Fields, current.Fields) {
+		if err := s.rollupRepo.ResetWatermarks(ctx, id); err != nil {
+			return nil, err
+		}
+	} else {
+		config.Watermarks = current.Watermarks
+	}
+
+	return config, nil
+}
+
+// Delete elimina una configuración. Los agregados ya calculados se conservan.
+func (s *RollupService) Delete(ctx context.Context, id int) error {
+	deleted, err := s.configRepo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrRollupConfigNotFound
+	}
+	return nil
+}
+
+// checkUnique comprueba que ninguna otra configuración use el mismo filtro y
+// devuelve la que tiene el id de config, si existe
+func (s *RollupService) checkUnique(ctx context.Context, config *entities.RollupConfig) (*entities.RollupConfig, error) {
+	configs, err := s.configRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var current *entities.RollupConfig
+	for _, other := range configs {
+		if config.ID != 0 && other.ID == config.ID {
+			current = other
+		} else if other.TopicFilter == config.TopicFilter {
+			return nil, fmt.Errorf("%w: %s", ErrRollupConfigExists, config.TopicFilter)
+		}
+	}
+	return current, nil
+}
+
+// sameFields indica si las dos listas tienen los mismos campos, en cualquier orden
+func sameFields(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]bool, len(a))
+	for _, field := range a {
+		seen[field] = true
+	}
+	for _, field := range b {
+		if !seen[field] {
+			return false
+		}
+	}
+	return true
+}
+
+// Run calcula los agregados pendientes de todas las configuraciones. Cada paso
+// guarda su watermark, así que si se interrumpe continúa donde lo dejó.
+func (s *RollupService) Run(ctx context.Context) error {
+	configs, err := s.configRepo.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, config := range configs {
+		if err := s.rollup(ctx, config); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", config.TopicFilter, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// rollup calcula cada resolución de la configuración desde su watermark: la
+// primera hasta now - Lag y las siguientes hasta donde llega la anterior,
+// siempre en intervalos completos
+func (s *RollupService) rollup(ctx context.Context, config *entities.RollupConfig) error {
+	limit := s.now().Add(-s.opts.Lag)
+	watermarks, err := s.startWatermarks(ctx, config, limit)
+	if err != nil {
+		return err
+	}
+
+	for i, resolution := range repository.RollupResolutions {
+		upto := limit.Truncate(resolution.Duration)
+		if i > 0 {
+			upto = watermarks[repository.RollupResolutions[i-1].Name].Truncate(resolution.Duration)
+		}
+
+		for from := watermarks[resolution.Name]; from.Before(upto); from = watermarks[resolution.Name] {
+			to := from.Add(rollupStepBuckets * resolution.Duration)
+			if to.After(upto) {
+				to = upto
+			}
+			if err := s.rollupRepo.Rollup(ctx, config, resolution, from, to); err != nil {
+				return err
+			}
+			watermarks[resolution.Name] = to
+		}
+	}
+	return nil
+}
+
+// startWatermarks devuelve los watermarks de la configuración. Las
+// resoluciones que aún no se han calculado empiezan el día (UTC) del mensaje
+// más antiguo, o el de limit si todavía no hay mensajes.
+func (s *RollupService) startWatermarks(ctx context.Context, config *entities.RollupConfig, limit time.Time) (map[string]time.Time, error) {
+	watermarks := make(map[string]time.Time, len(repository.RollupResolutions))
+	missing := false
+	for _, resolution := range repository.RollupResolutions {
+		watermark, ok := config.Watermarks[resolution.Name]
+		if ok {
+			watermarks[resolution.Name] = watermark
+		}
+		missing = missing || !ok
+	}
+	if !missing {
+		return watermarks, nil
+	}
+
+	start := limit
+	oldest, err := s.rollupRepo.OldestMessage(ctx, config.TopicFilter)
+	if err != nil {
+		return nil, err
+	}
+	if oldest != nil && oldest.Before(start) {
+		start = *oldest
+	}
+	start = start.Truncate(24 * time.Hour)
+
+	for _, resolution := range repository.RollupResolutions {
+		if _, ok := watermarks[resolution.Name]; !ok {
+			watermarks[resolution.Name] = start
+		}
+	}
+	return watermarks, nil
+}
+
+// RunRollups calcula los agregados pendientes cada interval hasta que se cancele ctx
+func (s *RollupService) RunRollups(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		err := s.Run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("Error calculando los agregados de mensajes MQTT")
+		} else {
+			log.Debug().Dur("duration", time.Since(start)).Msg("Agregados de mensajes MQTT actualizados")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PlanAggregate reparte la consulta entre las tablas de agregados y los
+// mensajes: usa los agregados si todos los topics pedidos y el campo tienen
+// configuración, con la resolución más gruesa que permite el intervalo
+func (s *RollupService) PlanAggregate(ctx context.Context, query *repository.AggregateQuery) error {
+	configs, err := s.configRepo.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	query.Segments = nil
+	watermarks := rollupWatermarks(configs, query.Topics, query.Field)
+	if len(watermarks) > 0 {
+		query.Segments = repository.PlanSegments(query.From, query.To, query.Bucket, watermarks)
+	}
+	return nil
+}
+
+// rollupWatermarks devuelve, por resolución, hasta dónde están calculados los
+// agregados del campo para todos los topics. Cada topic usa la configuración
+// que lo abarca y está más avanzada; si alguno no tiene, no hay agregados.
+func rollupWatermarks(configs []*entities.RollupConfig, topics []string, field string) map[string]time.Time {
+	if len(topics) == 0 {
+		return nil
+	}
+
+	var watermarks map[string]time.Time
+	for _, topic := range topics {
+		covered := map[string]time.Time{}
+		for _, config := range configs {
+			if !config.Covers(topic, field) {
+				continue
+			}
+			for resolution, watermark := range config.Watermarks {
+				if watermark.After(covered[resolution]) {
+					covered[resolution] = watermark
+				}
+			}
+		}
+		if len(covered) == 0 {
+			return nil
+		}
+
+		if watermarks == nil {
+			watermarks = covered
+			continue
+		}
+		for resolution, watermark := range watermarks {
+			other, ok := covered[resolution]
+			switch {
+			case !ok:
+				delete(watermarks, resolution)
+			case other.Before(watermark):
+				watermarks[resolution] = other
+			}
+		}
+	}
+	return watermarks
+}
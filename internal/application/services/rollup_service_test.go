@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/dto"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeRollupConfigRepo struct {
+	configs []*entities.RollupConfig
+}
+
+func (r *fakeRollupConfigRepo) Create(ctx context.Context, config *entities.RollupConfig) error {
+	config.ID = len(r.configs) + 1
+	r.configs = append(r.configs, config)
+	return nil
+}
+
+func (r *fakeRollupConfigRepo) Update(ctx context.Context, config *entities.RollupConfig) (bool, error) {
+	for i, existing := range r.configs {
+		if existing.ID == config.ID {
+			r.configs[i] = config
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeRollupConfigRepo) Delete(ctx context.Context, id int) (bool, error) {
+	return false, nil
+}
+
+func (r *fakeRollupConfigRepo) List(ctx context.Context) ([]*entities.RollupConfig, error) {
+	return r.configs, nil
+}
+
+// rollupCall es una llamada a Rollup: resolución y rango
+type rollupCall struct {
+	resolution string
+	from, to   time.Time
+}
+
+// fakeRollups anota los rangos calculados y guarda los watermarks en la configuración
+type fakeRollups struct {
+	oldest *time.Time
+	calls  []rollupCall
+	resets int
+}
+
+func (r *fakeRollups) OldestMessage(ctx context.Context, topicFilter string) (*time.Time, error) {
+	return r.oldest, nil
+}
+
+func (r *fakeRollups) Rollup(ctx context.Context, config *entities.RollupConfig, resolution repository.RollupResolution, from, to time.Time) error {
+	r.calls = append(r.calls, rollupCall{resolution: resolution.Name, from: from, to: to})
+	config.Watermarks[resolution.Name] = to
+	return nil
+}
+
+func (r *fakeRollups) ResetWatermarks(ctx context.Context, configID int) error {
+	r.resets++
+	return nil
+}
+
+func TestRollupCreateAndUpdate(t *testing.T) {
+	configs := &fakeRollupConfigRepo{}
+	rollups := &fakeRollups{}
+	service := NewRollupService(configs, rollups, RollupOptions{})
+
+	config, err := service.Create(context.Background(), dto.RollupConfigRequest{TopicFilter: "sensors/#", Fields: []string{" $.value", "$.value"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"$.value"}, config.Fields, "Fields are trimmed and deduplicated")
+
+	_, err = service.Create(context.Background(), dto.RollupConfigRequest{TopicFilter: "sensors/#", Fields: []string{"$.humidity"}})
+	assert.ErrorIs(t, err, ErrRollupConfigExists)
+	_, err = service.Create(context.Background(), dto.RollupConfigRequest{TopicFilter: "alarms/#", Fields: []string{"value"}})
+	assert.ErrorIs(t, err, ErrInvalidRollupConfig)
+
+	config.Watermarks["1m"] = time.Now()
+	_, err = service.Update(context.Background(), config.ID, dto.RollupConfigRequest{TopicFilter: "sensors/#", Fields: []string{"$.value"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, rollups.resets, "Unchanged configs keep their rollups")
+
+	_, err = service.Update(context.Background(), config.ID, dto.RollupConfigRequest{TopicFilter: "sensors/#", Fields: []string{"$.value", "$.humidity"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rollups.resets, "New fields are rolled up from the start")
+
+	_, err = service.Update(context.Background(), 42, dto.RollupConfigRequest{TopicFilter: "other/#", Fields: []string{"$.value"}})
+	assert.ErrorIs(t, err, ErrRollupConfigNotFound)
+}
+
+func TestRollupRunResumesFromWatermarks(t *testing.T) {
+	oldest := time.Date(2025, 6, 1, 22, 30, 0, 0, time.UTC)
+	configs := &fakeRollupConfigRepo{configs: []*entities.RollupConfig{
+		{ID: 1, TopicFilter: "sensors/#", Fields: []string{"$.value"}, Watermarks: map[string]time.Time{}},
+	}}
+	rollups := &fakeRollups{oldest: &oldest}
+	service := NewRollupService(configs, rollups, RollupOptions{Lag: 2 * time.Minute})
+	service.now = func() time.Time { return time.Date(2025, 6, 3, 1, 30, 30, 0, time.UTC) }
+
+	if err := service.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	day := func(d, h, m int) time.Time { return time.Date(2025, 6, d, h, m, 0, 0, time.UTC) }
+	assert.Equal(t, []rollupCall{
+		{resolution: "1m", from: day(1, 0, 0), to: day(2, 0, 0)},
+		{resolution: "1m", from: day(2, 0, 0), to: day(3, 0, 0)},
+		{resolution: "1m", from: day(3, 0, 0), to: day(3, 1, 28)},
+		{resolution: "1h", from: day(1, 0, 0), to: day(3, 1, 0)},
+		{resolution: "1d", from: day(1, 0, 0), to: day(3, 0, 0)},
+	}, rollups.calls, "Rollups start on the day of the oldest message and each resolution stops at the previous one")
+
+	// La siguiente pasada continúa desde los watermarks guardados
+	rollups.calls = nil
+	service.now = func() time.Time { return time.Date(2025, 6, 3, 2, 5, 0, 0, time.UTC) }
+	if err := service.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []rollupCall{
+		{resolution: "1m", from: day(3, 1, 28), to: day(3, 2, 3)},
+		{resolution: "1h", from: day(3, 1, 0), to: day(3, 2, 0)},
+	}, rollups.calls)
+}
+
+func TestRollupWatermarks(t *testing.T) {
+	t1 := time.Date(2025, 6, 3, 10, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	configs := []*entities.RollupConfig{
+		{TopicFilter: "sensors/#", Fields: []string{"$.value"}, Watermarks: map[string]time.Time{"1m": t1, "1h": t1, "1d": t1}},
+		{TopicFilter: "sensors/kitchen/+", Fields: []string{"$.value"}, Watermarks: map[string]time.Time{"1m": t2}},
+	}
+
+	assert.Equal(t, map[string]time.Time{"1m": t2, "1h": t1, "1d": t1},
+		rollupWatermarks(configs, []string{"sensors/kitchen/temperature"}, "$.value"), "The most advanced covering config wins")
+	assert.Equal(t, map[string]time.Time{"1m": t1, "1h": t1, "1d": t1},
+		rollupWatermarks(configs, []string{"sensors/kitchen/temperature", "sensors/hall/temperature"}, "$.value"),
+		"Several topics are covered up to the least advanced one")
+	assert.Nil(t, rollupWatermarks(configs, []string{"sensors/kitchen/temperature"}, "$.humidity"))
+	assert.Nil(t, rollupWatermarks(configs, []string{"sensors/#", "alarms/fire"}, "$.value"),
+		"Every topic must be covered")
+}
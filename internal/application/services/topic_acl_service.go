@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/dto"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/authz"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/repositories"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/mqtttopic"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrTopicForbidden se devuelve cuando el usuario no tiene acceso al topic
+	ErrTopicForbidden = errors.New("access to MQTT topic denied")
+	// ErrTopicACLNotFound se devuelve cuando la regla ACL no existe
+	ErrTopicACLNotFound = errors.New("topic ACL not found")
+)
+
+// TopicACLService gestiona las reglas de acceso por usuario a topics MQTT.
+// Sigue el modelo del acl_file de Mosquitto: sin una regla que lo permita, el
+// acceso se deniega. Los administradores no están sujetos a las reglas.
+type TopicACLService struct {
+	aclRepo  repositories.TopicACLRepository
+	userRepo repositories.UserRepository
+}
+
+// NewTopicACLService crea una nueva instancia del servicio de ACLs
+func NewTopicACLService(aclRepo repositories.TopicACLRepository, userRepo repositories.UserRepository) *TopicACLService {
+	return &TopicACLService{
+		aclRepo:  aclRepo,
+		userRepo: userRepo,
+	}
+}
+
+// Create crea una regla ACL para el usuario indicado
+func (s *TopicACLService) Create(ctx context.Context, req dto.CreateTopicACLRequest) (*entities.TopicACL, error) {
+	user, err := s.userRepo.GetByUsername(ctx, req.Username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+
+	acl, err := entities.NewTopicACL(user.ID, strings.TrimSpace(req.TopicFilter), req.Access)
+	if err != nil {
+		return nil, err
+	}
+	acl.Username = user.Username
+
+	if err := s.aclRepo.Create(ctx, acl); err != nil {
+		return nil, err
+	}
+
+	return acl, nil
+}
+
+// Delete elimina una regla ACL
+func (s *TopicACLService) Delete(ctx context.Context, id int) error {
+	deleted, err := s.aclRepo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrTopicACLNotFound
+	}
+	return nil
+}
+
+// List obtiene todas las reglas ACL
+func (s *TopicACLService) List(ctx context.Context) ([]*entities.TopicACL, error) {
+	return s.aclRepo.List(ctx)
+}
+
+// ListByUser obtiene las reglas ACL de un usuario
+func (s *TopicACLService) ListByUser(ctx context.Context, userID int) ([]*entities.TopicACL, error) {
+	return s.aclRepo.ListByUser(ctx, userID)
+}
+
+// ReadableFilters devuelve los filtros de topic que el usuario puede leer.
+// unrestricted es true si el usuario puede leer cualquier topic.
+func (s *TopicACLService) ReadableFilters(ctx context.Context, userID int, roles []string) (filters []string, unrestricted bool, err error) {
+	if authz.HasRole(roles, authz.RoleAdmin) {
+		return nil, true, nil
+	}
+
+	acls, err := s.aclRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	filters = []string{}
+	for _, acl := range acls {
+		if acl.Allows(entities.TopicAccessRead) {
+			filters = append(filters, acl.TopicFilter)
+		}
+	}
+	return filters, false, nil
+}
+
+// Authorize comprueba que el usuario tenga el acceso indicado (read o write)
+// sobre todos los topics que abarca topicFilter.
+func (s *TopicACLService) Authorize(ctx context.Context, userID int, roles []string, topicFilter, access string) error {
+	if authz.HasRole(roles, authz.RoleAdmin) {
+		return nil
+	}
+
+	acls, err := s.aclRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, acl := range acls {
+		if acl.Allows(access) && mqtttopic.Covers(acl.TopicFilter, topicFilter) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s %s", ErrTopicForbidden, access, topicFilter)
+}
+
+// ExportMosquitto genera las reglas en el formato del acl_file de Mosquitto
+func (s *TopicACLService) ExportMosquitto(ctx context.Context) (string, error) {
+	acls, err := s.aclRepo.List(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	byUser := map[string][]*entities.TopicACL{}
+	usernames := []string{}
+	for _, acl := range acls {
+		if _, ok := byUser[acl.Username]; !ok {
+			usernames = append(usernames, acl.Username)
+		}
+		byUser[acl.Username] = append(byUser[acl.Username], acl)
+	}
+	sort.Strings(usernames)
+
+	var b strings.Builder
+	b.WriteString("# acl_file generado a partir de la tabla mqtt_topic_acls\n")
+	for _, username := range usernames {
+		// Un salto de línea en el nombre o en el filtro añadiría reglas al fichero
+		if strings.ContainsAny(username, "\r\n") {
+			log.Warn().Str("username", username).Msg("Usuario con saltos de línea, sus reglas no se exportan")
+			continue
+		}
+		fmt.Fprintf(&b, "\nuser %s\n", username)
+		for _, acl := range byUser[username] {
+			if strings.ContainsAny(acl.TopicFilter, "\r\n") {
+				log.Warn().Int("acl_id", acl.ID).Msg("Filtro con saltos de línea, la regla no se exporta")
+				continue
+			}
+			fmt.Fprintf(&b, "topic %s %s\n", acl.Access, acl.TopicFilter)
+		}
+	}
+
+	return b.String(), nil
+}
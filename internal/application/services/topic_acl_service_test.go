@@ -0,0 +1,44 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeTopicACLRepo struct {
+	acls []*entities.TopicACL
+}
+
+func (r *fakeTopicACLRepo) Create(ctx context.Context, acl *entities.TopicACL) error {
+	r.acls = append(r.acls, acl)
+	return nil
+}
+
+func (r *fakeTopicACLRepo) Delete(ctx context.Context, id int) (bool, error) {
+	return false, nil
+}
+
+func (r *fakeTopicACLRepo) List(ctx context.Context) ([]*entities.TopicACL, error) {
+	return r.acls, nil
+}
+
+func (r *fakeTopicACLRepo) ListByUser(ctx context.Context, userID int) ([]*entities.TopicACL, error) {
+	return nil, nil
+}
+
+func TestExportMosquittoSkipsLineInjection(t *testing.T) {
+	repo := &fakeTopicACLRepo{acls: []*entities.TopicACL{
+		{ID: 1, UserID: 1, Username: "ana", TopicFilter: "sensores/#", Access: entities.TopicAccessRead},
+		{ID: 2, UserID: 1, Username: "ana", TopicFilter: "sensores/#\ntopic readwrite #", Access: entities.TopicAccessRead},
+		{ID: 3, UserID: 2, Username: "x\ntopic readwrite #", TopicFilter: "otros/+", Access: entities.TopicAccessRead},
+	}}
+	service := NewTopicACLService(repo, nil)
+
+	exported, err := service.ExportMosquitto(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "# acl_file generado a partir de la tabla mqtt_topic_acls\n\nuser ana\ntopic read sensores/#\n", exported)
+	assert.NotContains(t, exported, "readwrite #")
+}
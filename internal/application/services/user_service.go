@@ -7,24 +7,53 @@ import (
 	"math"
 
 	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/dto"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/authz"
 	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
 	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/repositories"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/password"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/tracing"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrInvalidCredentials se devuelve cuando el usuario o la contraseña no son correctos
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrUserInactive se devuelve cuando el usuario está desactivado
+	ErrUserInactive = errors.New("user is inactive")
+	// ErrPasswordResetRequired se devuelve cuando el usuario debe cambiar la contraseña antes de autenticarse
+	ErrPasswordResetRequired = errors.New("password reset required")
+	// ErrInvalidNewPassword se devuelve cuando la nueva contraseña está vacía o coincide con la actual
+	ErrInvalidNewPassword = errors.New("invalid new password")
+	// ErrInvalidRole se devuelve al asignar o retirar un rol desconocido
+	ErrInvalidRole = errors.New("invalid role")
 )
 
 // UserService encapsula la lógica de negocio para usuarios
 type UserService struct {
 	userRepo repositories.UserRepository
+	roleRepo repositories.RoleRepository
+	hasher   *password.Manager
+	// dummyHash se verifica cuando el usuario no existe para no revelarlo por tiempo de respuesta
+	dummyHash string
 }
 
 // NewUserService crea una nueva instancia del servicio de usuarios
-func NewUserService(userRepo repositories.UserRepository) *UserService {
+func NewUserService(userRepo repositories.UserRepository, roleRepo repositories.RoleRepository, hasher *password.Manager) *UserService {
+	dummyHash, _ := hasher.Hash("dummy-password")
+
 	return &UserService{
-		userRepo: userRepo,
+		userRepo:  userRepo,
+		roleRepo:  roleRepo,
+		hasher:    hasher,
+		dummyHash: dummyHash,
 	}
 }
 
 // CreateUser crea un nuevo usuario
-func (s *UserService) CreateUser(ctx context.Context, req dto.CreateUserRequest) (*dto.UserResponse, error) {
+func (s *UserService) CreateUser(ctx context.Context, req dto.CreateUserRequest) (_ *dto.UserResponse, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.CreateUser")
+	defer tracing.End(span, &err)
+
 	// Verificar si el usuario ya existe
 	existingUser, _ := s.userRepo.GetByUsername(ctx, req.Username)
 	if existingUser != nil {
@@ -36,8 +65,13 @@ func (s *UserService) CreateUser(ctx context.Context, req dto.CreateUserRequest)
 		return nil, errors.New("email already exists")
 	}
 
+	passwordHash, err := s.hasher.Hash(req.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user entity: %w", err)
+	}
+
 	// Crear entidad de usuario
-	user, err := entities.NewUser(req.Username, req.Email, req.Password)
+	user, err := entities.NewUser(req.Username, req.Email, passwordHash)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user entity: %w", err)
 	}
@@ -59,7 +93,10 @@ func (s *UserService) CreateUser(ctx context.Context, req dto.CreateUserRequest)
 }
 
 // GetUser obtiene un usuario por ID
-func (s *UserService) GetUser(ctx context.Context, id int) (*dto.UserResponse, error) {
+func (s *UserService) GetUser(ctx context.Context, id int) (_ *dto.UserResponse, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUser")
+	defer tracing.End(span, &err)
+
 	user, err := s.userRepo.GetByID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
@@ -68,11 +105,18 @@ func (s *UserService) GetUser(ctx context.Context, id int) (*dto.UserResponse, e
 		return nil, errors.New("user not found")
 	}
 
+	if user.Roles, err = s.UserRoles(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
 	return s.toUserResponse(user), nil
 }
 
 // UpdateUser actualiza un usuario existente
-func (s *UserService) UpdateUser(ctx context.Context, id int, req dto.UpdateUserRequest) (*dto.UserResponse, error) {
+func (s *UserService) UpdateUser(ctx context.Context, id int, req dto.UpdateUserRequest) (_ *dto.UserResponse, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.UpdateUser")
+	defer tracing.End(span, &err)
+
 	user, err := s.userRepo.GetByID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
@@ -110,7 +154,10 @@ func (s *UserService) UpdateUser(ctx context.Context, id int, req dto.UpdateUser
 }
 
 // DeleteUser elimina un usuario
-func (s *UserService) DeleteUser(ctx context.Context, id int) error {
+func (s *UserService) DeleteUser(ctx context.Context, id int) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.DeleteUser")
+	defer tracing.End(span, &err)
+
 	user, err := s.userRepo.GetByID(ctx, id)
 	if err != nil {
 		return fmt.Errorf("failed to get user: %w", err)
@@ -127,7 +174,10 @@ func (s *UserService) DeleteUser(ctx context.Context, id int) error {
 }
 
 // ListUsers obtiene una lista paginada de usuarios
-func (s *UserService) ListUsers(ctx context.Context, page, perPage int) (*dto.UsersListResponse, error) {
+func (s *UserService) ListUsers(ctx context.Context, page, perPage int) (_ *dto.UsersListResponse, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.ListUsers")
+	defer tracing.End(span, &err)
+
 	if page < 1 {
 		page = 1
 	}
@@ -164,7 +214,10 @@ func (s *UserService) ListUsers(ctx context.Context, page, perPage int) (*dto.Us
 }
 
 // ChangePassword cambia la contraseña de un usuario
-func (s *UserService) ChangePassword(ctx context.Context, id int, req dto.ChangePasswordRequest) error {
+func (s *UserService) ChangePassword(ctx context.Context, id int, req dto.ChangePasswordRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.ChangePassword")
+	defer tracing.End(span, &err)
+
 	user, err := s.userRepo.GetByID(ctx, id)
 	if err != nil {
 		return fmt.Errorf("failed to get user: %w", err)
@@ -173,13 +226,77 @@ func (s *UserService) ChangePassword(ctx context.Context, id int, req dto.Change
 		return errors.New("user not found")
 	}
 
-	// Aquí deberías verificar la contraseña anterior con hash
-	// Por simplicidad, asumimos que la verificación es correcta
-	if user.Password != req.OldPassword {
+	ok, _, err := s.hasher.Verify(req.OldPassword, user.Password)
+	if err != nil && !errors.Is(err, password.ErrUnknownHashFormat) {
+		return fmt.Errorf("failed to verify password: %w", err)
+	}
+	if !ok {
 		return errors.New("invalid old password")
 	}
 
-	if err := user.ChangePassword(req.NewPassword); err != nil {
+	newHash, err := s.hasher.Hash(req.NewPassword)
+	if err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+
+	if err := user.ChangePassword(newHash); err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return fmt.Errorf("failed to update user password: %w", err)
+	}
+
+	return nil
+}
+
+// Authenticate verifica las credenciales de un usuario por username o email.
+// Si el hash se generó con otro algoritmo o parámetros, se regenera de forma transparente.
+func (s *UserService) Authenticate(ctx context.Context, login, plainPassword string) (_ *entities.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Authenticate")
+	defer tracing.End(span, &err)
+
+	user, needsRehash, err := s.verifyCredentials(ctx, login, plainPassword)
+	if err != nil {
+		return nil, err
+	}
+	if user.PasswordResetRequired {
+		return nil, ErrPasswordResetRequired
+	}
+
+	if needsRehash {
+		if newHash, err := s.hasher.Hash(plainPassword); err == nil {
+			user.RehashPassword(newHash)
+			if err := s.userRepo.Update(ctx, user); err != nil {
+				log.Warn().Err(err).Int("user_id", user.ID).Msg("No se pudo regenerar el hash de la contraseña")
+			}
+		}
+	}
+
+	return user, nil
+}
+
+// ChangePasswordWithCredentials cambia la contraseña de un usuario identificado por
+// username o email y su contraseña actual. Es la única vía para los usuarios marcados
+// con PasswordResetRequired, que no pueden obtener un token hasta cambiarla.
+func (s *UserService) ChangePasswordWithCredentials(ctx context.Context, login, oldPassword, newPassword string) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.ChangePasswordWithCredentials")
+	defer tracing.End(span, &err)
+
+	user, _, err := s.verifyCredentials(ctx, login, oldPassword)
+	if err != nil {
+		return err
+	}
+	if newPassword == "" || newPassword == oldPassword {
+		return ErrInvalidNewPassword
+	}
+
+	newHash, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+
+	if err := user.ChangePassword(newHash); err != nil {
 		return fmt.Errorf("failed to change password: %w", err)
 	}
 
@@ -190,6 +307,155 @@ func (s *UserService) ChangePassword(ctx context.Context, id int, req dto.Change
 	return nil
 }
 
+// verifyCredentials busca al usuario por username o email y comprueba su contraseña
+// y que esté activo. Indica además si el hash debe regenerarse.
+func (s *UserService) verifyCredentials(ctx context.Context, login, plainPassword string) (*entities.User, bool, error) {
+	user, err := s.userRepo.GetByUsername(ctx, login)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		user, err = s.userRepo.GetByEmail(ctx, login)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get user: %w", err)
+		}
+	}
+	if user == nil {
+		// Igualar el coste de la respuesta con el de un usuario existente
+		s.hasher.Verify(plainPassword, s.dummyHash)
+		return nil, false, ErrInvalidCredentials
+	}
+
+	ok, needsRehash, err := s.hasher.Verify(plainPassword, user.Password)
+	if err != nil && !errors.Is(err, password.ErrUnknownHashFormat) {
+		return nil, false, fmt.Errorf("failed to verify password: %w", err)
+	}
+	if !ok {
+		return nil, false, ErrInvalidCredentials
+	}
+	if !user.Active() {
+		return nil, false, ErrUserInactive
+	}
+
+	return user, needsRehash, nil
+}
+
+// FlagPlaintextPasswords hashea las contraseñas guardadas en claro y marca a sus
+// usuarios para que las cambien. Devuelve el número de usuarios marcados.
+func (s *UserService) FlagPlaintextPasswords(ctx context.Context) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.FlagPlaintextPasswords")
+	defer tracing.End(span, &err)
+
+	const batchSize = 100
+	flagged := 0
+
+	// Se recorre por id: con offsets, los empates en el orden o las altas
+	// concurrentes harían saltarse usuarios o visitarlos dos veces
+	for lastID := 0; ; {
+		users, err := s.userRepo.ListAfterID(ctx, lastID, batchSize)
+		if err != nil {
+			return flagged, fmt.Errorf("failed to list users: %w", err)
+		}
+
+		for _, user := range users {
+			lastID = user.ID
+			if s.hasher.IsHashed(user.Password) {
+				continue
+			}
+
+			if user.PasswordResetRequired {
+				continue
+			}
+
+			// Una contraseña vacía no se puede hashear: se conserva y el usuario queda bloqueado
+			passwordHash := user.Password
+			if passwordHash != "" {
+				passwordHash, err = s.hasher.Hash(user.Password)
+				if err != nil {
+					return flagged, fmt.Errorf("failed to hash password of user %d: %w", user.ID, err)
+				}
+			}
+
+			user.RequirePasswordReset(passwordHash)
+			if err := s.userRepo.Update(ctx, user); err != nil {
+				return flagged, fmt.Errorf("failed to flag user %d: %w", user.ID, err)
+			}
+			flagged++
+		}
+
+		if len(users) < batchSize {
+			return flagged, nil
+		}
+	}
+}
+
+// UserRoles obtiene los roles de un usuario. Un usuario sin roles asignados
+// recibe el rol por defecto.
+func (s *UserService) UserRoles(ctx context.Context, userID int) (_ []string, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.UserRoles")
+	defer tracing.End(span, &err)
+
+	roles, err := s.roleRepo.GetUserRoles(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user roles: %w", err)
+	}
+	if len(roles) == 0 {
+		roles = []string{authz.DefaultRole}
+	}
+	return roles, nil
+}
+
+// AssignRole asigna un rol a un usuario
+func (s *UserService) AssignRole(ctx context.Context, userID int, role string) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.AssignRole")
+	defer tracing.End(span, &err)
+
+	if !authz.IsValidRole(role) {
+		return fmt.Errorf("%w: %s", ErrInvalidRole, role)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return errors.New("user not found")
+	}
+
+	if err := s.roleRepo.AssignRole(ctx, userID, role); err != nil {
+		if errors.Is(err, repositories.ErrRoleNotFound) {
+			return fmt.Errorf("%w: %s", ErrInvalidRole, role)
+		}
+		return fmt.Errorf("failed to assign role: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeRole retira un rol a un usuario
+func (s *UserService) RevokeRole(ctx context.Context, userID int, role string) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.RevokeRole")
+	defer tracing.End(span, &err)
+
+	if !authz.IsValidRole(role) {
+		return fmt.Errorf("%w: %s", ErrInvalidRole, role)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return errors.New("user not found")
+	}
+
+	if err := s.roleRepo.RevokeRole(ctx, userID, role); err != nil {
+		return fmt.Errorf("failed to revoke role: %w", err)
+	}
+
+	return nil
+}
+
 // toUserResponse convierte una entidad User a UserResponse
 func (s *UserService) toUserResponse(user *entities.User) *dto.UserResponse {
 	return &dto.UserResponse{
@@ -198,6 +464,7 @@ func (s *UserService) toUserResponse(user *entities.User) *dto.UserResponse {
 		Email:     user.Email,
 		FullName:  user.FullName,
 		IsActive:  user.IsActive,
+		Roles:     user.Roles,
 		CreatedAt: user.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 		UpdatedAt: user.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
 	}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/password"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeUserRepo struct {
+	users []*entities.User
+}
+
+func (r *fakeUserRepo) Create(ctx context.Context, user *entities.User) error {
+	user.ID = len(r.users) + 1
+	r.users = append(r.users, user)
+	return nil
+}
+
+func (r *fakeUserRepo) GetByID(ctx context.Context, id int) (*entities.User, error) {
+	for _, user := range r.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUserRepo) GetByUsername(ctx context.Context, username string) (*entities.User, error) {
+	for _, user := range r.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUserRepo) GetByEmail(ctx context.Context, email string) (*entities.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUserRepo) Update(ctx context.Context, user *entities.User) error {
+	return nil
+}
+
+func (r *fakeUserRepo) Delete(ctx context.Context, id int) error {
+	return nil
+}
+
+func (r *fakeUserRepo) List(ctx context.Context, limit, offset int) ([]*entities.User, error) {
+	if offset >= len(r.users) {
+		return nil, nil
+	}
+	return r.users[offset:min(offset+limit, len(r.users))], nil
+}
+
+func (r *fakeUserRepo) ListAfterID(ctx context.Context, afterID, limit int) ([]*entities.User, error) {
+	users := []*entities.User{}
+	for _, user := range r.users {
+		if user.ID > afterID && len(users) < limit {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (r *fakeUserRepo) Count(ctx context.Context) (int, error) {
+	return len(r.users), nil
+}
+
+func TestFlaggedUserChangesPasswordAndLogsIn(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeUserRepo{users: []*entities.User{
+		{ID: 1, Username: "ana", Email: "ana@example.com", Password: "en-claro"},
+	}}
+	hasher := password.NewManager(password.NewArgon2idHasher(password.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1}))
+	service := NewUserService(repo, nil, hasher)
+
+	flagged, err := service.FlagPlaintextPasswords(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, flagged)
+
+	_, err = service.Authenticate(ctx, "ana", "en-claro")
+	assert.ErrorIs(t, err, ErrPasswordResetRequired, "Flagged users get no token")
+
+	err = service.ChangePasswordWithCredentials(ctx, "ana", "otra", "nueva-clave")
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "The old password is checked")
+	err = service.ChangePasswordWithCredentials(ctx, "ana", "en-claro", "en-claro")
+	assert.ErrorIs(t, err, ErrInvalidNewPassword, "The password must actually change")
+
+	if err := service.ChangePasswordWithCredentials(ctx, "ana@example.com", "en-claro", "nueva-clave"); err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, repo.users[0].PasswordResetRequired, "Changing the password clears the flag")
+
+	user, err := service.Authenticate(ctx, "ana", "nueva-clave")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, user.ID)
+
+	_, err = service.Authenticate(ctx, "ana", "en-claro")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestFlagPlaintextPasswordsVisitsEveryUserOnce(t *testing.T) {
+	repo := &fakeUserRepo{}
+	for id := 1; id <= 250; id++ {
+		repo.users = append(repo.users, &entities.User{ID: id, Username: fmt.Sprintf("user%d", id), Password: "en-claro"})
+	}
+	hasher := password.NewManager(password.NewArgon2idHasher(password.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1}))
+	service := NewUserService(repo, nil, hasher)
+
+	flagged, err := service.FlagPlaintextPasswords(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 250, flagged)
+	for _, user := range repo.users {
+		assert.True(t, user.PasswordResetRequired, user.Username)
+	}
+}
@@ -2,39 +2,222 @@ package container
 
 import (
 	"database/sql"
+	"fmt"
+	"net/http"
 
+	"github.com/JorgeePG/prueba-api-http-postgresql-/cmd/config"
+	appdb "github.com/JorgeePG/prueba-api-http-postgresql-/infraestructure/db"
 	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/services"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
 	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/repositories"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/infrastructure/auth"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/infrastructure/health"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/infrastructure/metrics"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/infrastructure/notify"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/infrastructure/persistence/postgres"
 	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/infrastructure/persistence/sqlboiler"
+	apihttp "github.com/JorgeePG/prueba-api-http-postgresql-/internal/interfaces/http"
 	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/interfaces/http/handlers"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/interfaces/http/middleware"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/mqttconn"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/subscriber"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/password"
+	"github.com/rs/zerolog/log"
 )
 
 // Container contiene todas las dependencias de la aplicación
 type Container struct {
+	// Security
+	PasswordHasher *password.Manager
+	TokenManager   *auth.TokenManager
+	Authenticate   func(http.Handler) http.Handler
+
 	// Repositories
-	UserRepository repositories.UserRepository
+	UserRepository          repositories.UserRepository
+	RefreshTokenRepository  repositories.RefreshTokenRepository
+	RoleRepository          repositories.RoleRepository
+	TopicACLRepository      repositories.TopicACLRepository
+	RetentionRuleRepository repositories.RetentionRuleRepository
+	RollupConfigRepository  repositories.RollupConfigRepository
+	AlertRuleRepository     repositories.AlertRuleRepository
 
 	// Services
-	UserService *services.UserService
+	UserService      *services.UserService
+	AuthService      *services.AuthService
+	MqttService      *services.MqttService
+	TopicACLService  *services.TopicACLService
+	RetentionService *services.RetentionService
+	RollupService    *services.RollupService
+	AlertService     *services.AlertService
 
 	// Handlers
-	UserHandler *handlers.UserHandler
+	UserHandler      *handlers.UserHandler
+	AuthHandler      *handlers.AuthHandler
+	MqttHandler      *handlers.MqttHandler
+	TopicACLHandler  *handlers.TopicACLHandler
+	RetentionHandler *handlers.RetentionHandler
+	RollupHandler    *handlers.RollupHandler
+	AlertHandler     *handlers.AlertHandler
+	StreamHandler    *handlers.MqttStreamHandler
+	HealthHandler    *handlers.HealthHandler
+
+	// Health
+	HealthChecker *health.Checker
+
+	// Metrics es nil si METRICS_ENABLED=false
+	Metrics *metrics.Metrics
+
+	// Router
+	Router *apihttp.Router
 }
 
 // NewContainer crea un nuevo contenedor con todas las dependencias
-func NewContainer(db *sql.DB) *Container {
+func NewContainer(db *sql.DB, cfg *config.Config) (*Container, error) {
+	// Security
+	hasher, err := password.NewManagerFromConfig(password.Config{
+		Algorithm: cfg.Auth.PasswordAlgorithm,
+		Argon2: password.Argon2Params{
+			Memory:      uint32(cfg.Auth.Argon2Memory),
+			Iterations:  uint32(cfg.Auth.Argon2Iterations),
+			Parallelism: uint8(cfg.Auth.Argon2Parallelism),
+		},
+		BcryptCost: cfg.Auth.BcryptCost,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure password hashing: %w", err)
+	}
+
+	secret := []byte(cfg.Auth.JWTSecret)
+	if len(secret) == 0 {
+		log.Warn().Msg("JWT_SECRET no configurado: se usa una clave aleatoria y los tokens no sobrevivirán a un reinicio")
+		if secret, err = auth.GenerateSecret(); err != nil {
+			return nil, fmt.Errorf("failed to generate JWT secret: %w", err)
+		}
+	}
+	tokenManager := auth.NewTokenManager(secret, cfg.Auth.JWTIssuer, cfg.Auth.AccessTokenTTL)
+	authenticate := middleware.Authenticate(tokenManager)
+
 	// Repositories
 	userRepo := sqlboiler.NewUserRepository(db)
+	refreshTokenRepo := postgres.NewRefreshTokenRepository(db)
+	roleRepo := postgres.NewRoleRepository(db)
+	topicACLRepo := postgres.NewTopicACLRepository(db)
+	retentionRuleRepo := postgres.NewRetentionRuleRepository(db)
+	rollupConfigRepo := postgres.NewRollupConfigRepository(db)
+	alertRuleRepo := postgres.NewAlertRuleRepository(db)
 
 	// Services
-	userService := services.NewUserService(userRepo)
+	userService := services.NewUserService(userRepo, roleRepo, hasher)
+	authService := services.NewAuthService(userService, userRepo, refreshTokenRepo, tokenManager, cfg.Auth.RefreshTokenTTL)
+	subscriberManager := subscriber.GetSubscriberManager()
+	mqttService := services.NewMqttService(subscriberManager)
+	topicACLService := services.NewTopicACLService(topicACLRepo, userRepo)
+	retentionService := services.NewRetentionService(retentionRuleRepo, postgres.NewMessageRetentionRepository(db), services.RetentionOptions{
+		BatchSize:  cfg.Retention.BatchSize,
+		BatchPause: cfg.Retention.BatchPause,
+	})
+	rollupService := services.NewRollupService(rollupConfigRepo, postgres.NewRollupRepository(db), services.RollupOptions{
+		Lag: cfg.Rollups.Lag,
+	})
+	mqttService.SetRollups(rollupService)
+	alertService := services.NewAlertService(alertRuleRepo, postgres.NewAlertStateRepository(db), services.AlertOptions{
+		QueueSize:     cfg.Alerts.QueueSize,
+		NotifyTimeout: cfg.Alerts.NotifyTimeout,
+	})
+	alertService.RegisterSink(entities.AlertSinkLog, notify.NewLogSink())
+	alertService.RegisterSink(entities.AlertSinkWebhook, notify.NewWebhookSink(cfg.Alerts.NotifyTimeout))
+	alertService.RegisterSink(entities.AlertSinkMqtt, notify.NewMqttSink(subscriberManager))
 
 	// Handlers
 	userHandler := handlers.NewUserHandler(userService)
+	authHandler := handlers.NewAuthHandler(authService)
+	mqttHandler := handlers.NewMqttHandler(mqttService, topicACLService)
+	topicACLHandler := handlers.NewTopicACLHandler(topicACLService)
+	retentionHandler := handlers.NewRetentionHandler(retentionService)
+	rollupHandler := handlers.NewRollupHandler(rollupService)
+	alertHandler := handlers.NewAlertHandler(alertService, topicACLService)
+	streamHandler := handlers.NewMqttStreamHandler(subscriberManager.Stream(), mqttService, topicACLService, handlers.StreamOptions{
+		Heartbeat:    cfg.Stream.Heartbeat,
+		ClientBuffer: cfg.Stream.ClientBuffer,
+		ReplayLimit:  cfg.Stream.ReplayLimit,
+		WriteTimeout: cfg.Stream.WriteTimeout,
+	})
+
+	// Health checks (/readyz)
+	migrator, err := appdb.DefaultMigrator(db, cfg.Database.MigrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	healthChecker := health.NewChecker(cfg.Health.CheckTimeout,
+		health.Database(db),
+		health.Migrations(migrator),
+		health.MQTT(subscriberManager),
+		health.Certificates(certificateFiles(cfg), cfg.Health.CertExpiryWarning),
+	)
+	healthHandler := handlers.NewHealthHandler(healthChecker)
+
+	var appMetrics *metrics.Metrics
+	if cfg.Metrics.Enabled {
+		appMetrics = metrics.New(db, subscriberManager)
+	}
+
+	router := apihttp.NewRouter(apihttp.Handlers{
+		User:      userHandler,
+		Mqtt:      mqttHandler,
+		Auth:      authHandler,
+		ACL:       topicACLHandler,
+		Stream:    streamHandler,
+		Retention: retentionHandler,
+		Rollups:   rollupHandler,
+		Alerts:    alertHandler,
+	}, authenticate)
 
 	return &Container{
-		UserRepository: userRepo,
-		UserService:    userService,
-		UserHandler:    userHandler,
+		PasswordHasher:          hasher,
+		TokenManager:            tokenManager,
+		Authenticate:            authenticate,
+		UserRepository:          userRepo,
+		RefreshTokenRepository:  refreshTokenRepo,
+		RoleRepository:          roleRepo,
+		TopicACLRepository:      topicACLRepo,
+		RetentionRuleRepository: retentionRuleRepo,
+		RollupConfigRepository:  rollupConfigRepo,
+		AlertRuleRepository:     alertRuleRepo,
+		UserService:             userService,
+		AuthService:             authService,
+		MqttService:             mqttService,
+		TopicACLService:         topicACLService,
+		RetentionService:        retentionService,
+		RollupService:           rollupService,
+		AlertService:            alertService,
+		UserHandler:             userHandler,
+		AuthHandler:             authHandler,
+		MqttHandler:             mqttHandler,
+		TopicACLHandler:         topicACLHandler,
+		RetentionHandler:        retentionHandler,
+		RollupHandler:           rollupHandler,
+		AlertHandler:            alertHandler,
+		StreamHandler:           streamHandler,
+		HealthHandler:           healthHandler,
+		HealthChecker:           healthChecker,
+		Metrics:                 appMetrics,
+		Router:                  router,
+	}, nil
+}
+
+// certificateFiles devuelve los certificados TLS en uso cuya caducidad se vigila
+func certificateFiles(cfg *config.Config) []health.CertificateFile {
+	var files []health.CertificateFile
+	if cfg.Server.UseSSL {
+		files = append(files, health.CertificateFile{Name: "server", Path: cfg.Server.SSLCert})
+	}
+	if mqttconn.UsesTLS(cfg.MQTT) {
+		if cfg.MQTT.CACert != "" {
+			files = append(files, health.CertificateFile{Name: "mqtt_ca", Path: cfg.MQTT.CACert})
+		}
+		if cfg.MQTT.ClientCert != "" {
+			files = append(files, health.CertificateFile{Name: "mqtt_client", Path: cfg.MQTT.ClientCert})
+		}
 	}
+	return files
 }
@@ -0,0 +1,85 @@
+// Package authz define los roles de la aplicación y los permisos que concede cada uno.
+package authz
+
+// Roles disponibles
+const (
+	RoleAdmin    = "admin"
+	RoleOperator = "operator"
+	RoleViewer   = "viewer"
+)
+
+// DefaultRole es el rol efectivo de los usuarios que no tienen ninguno asignado
+const DefaultRole = RoleViewer
+
+// Permission identifica una acción protegida de la API
+type Permission string
+
+// Permisos de la API
+const (
+	PermUsersRead   Permission = "users:read"
+	PermUsersWrite  Permission = "users:write"
+	PermUsersDelete Permission = "users:delete"
+	PermRolesManage Permission = "roles:manage"
+
+	PermMqttMessagesRead        Permission = "mqtt:messages:read"
+	PermMqttSubscriptionsRead   Permission = "mqtt:subscriptions:read"
+	PermMqttSubscriptionsManage Permission = "mqtt:subscriptions:manage"
+	PermMqttACLsManage          Permission = "mqtt:acls:manage"
+	PermMqttRetentionManage     Permission = "mqtt:retention:manage"
+	PermMqttRollupsManage       Permission = "mqtt:rollups:manage"
+	PermMqttAlertsManage        Permission = "mqtt:alerts:manage"
+	// PermMqttPublish permite publicar desde la API en los topics con ACL de escritura
+	PermMqttPublish Permission = "mqtt:publish"
+)
+
+// rolePermissions asigna a cada rol sus permisos. El rol admin los tiene todos.
+var rolePermissions = map[string][]Permission{
+	RoleOperator: {
+		PermUsersRead,
+		PermMqttMessagesRead,
+		PermMqttSubscriptionsRead,
+		PermMqttPublish,
+	},
+	RoleViewer: {
+		PermMqttMessagesRead,
+	},
+}
+
+// IsValidRole indica si el rol existe
+func IsValidRole(role string) bool {
+	if role == RoleAdmin {
+		return true
+	}
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Allowed indica si alguno de los roles concede el permiso.
+// Sin roles se aplican los permisos de DefaultRole.
+func Allowed(roles []string, perm Permission) bool {
+	if len(roles) == 0 {
+		roles = []string{DefaultRole}
+	}
+
+	for _, role := range roles {
+		if role == RoleAdmin {
+			return true
+		}
+		for _, p := range rolePermissions[role] {
+			if p == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasRole indica si la lista de roles contiene el rol indicado
+func HasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package authz
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminIsAllowedEverything(t *testing.T) {
+	roles := []string{RoleAdmin}
+
+	assert.True(t, Allowed(roles, PermUsersDelete))
+	assert.True(t, Allowed(roles, PermMqttSubscriptionsManage))
+	assert.True(t, Allowed(roles, PermRolesManage))
+}
+
+func TestViewerCanOnlyReadMessages(t *testing.T) {
+	roles := []string{RoleViewer}
+
+	assert.True(t, Allowed(roles, PermMqttMessagesRead))
+	assert.False(t, Allowed(roles, PermUsersRead))
+	assert.False(t, Allowed(roles, PermUsersDelete))
+	assert.False(t, Allowed(roles, PermMqttSubscriptionsManage))
+	assert.False(t, Allowed(roles, PermMqttPublish))
+}
+
+func TestUsersWithoutRolesGetDefaultRole(t *testing.T) {
+	assert.True(t, Allowed(nil, PermMqttMessagesRead))
+	assert.False(t, Allowed(nil, PermUsersDelete))
+}
+
+func TestOperatorCannotManageSubscriptions(t *testing.T) {
+	roles := []string{RoleOperator}
+
+	assert.True(t, Allowed(roles, PermMqttSubscriptionsRead))
+	assert.False(t, Allowed(roles, PermMqttSubscriptionsManage))
+	assert.True(t, Allowed(roles, PermMqttPublish))
+	assert.True(t, Allowed([]string{RoleOperator, RoleAdmin}, PermMqttSubscriptionsManage))
+}
+
+func TestIsValidRole(t *testing.T) {
+	assert.True(t, IsValidRole(RoleAdmin))
+	assert.True(t, IsValidRole(RoleViewer))
+	assert.False(t, IsValidRole("root"))
+}
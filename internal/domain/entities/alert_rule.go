@@ -0,0 +1,390 @@
+package entities
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/mqtttopic"
+)
+
+// Comparadores de las reglas de alerta
+const (
+	AlertGreater        = "gt"
+	AlertGreaterOrEqual = "gte"
+	AlertLess           = "lt"
+	AlertLessOrEqual    = "lte"
+	AlertEqual          = "eq"
+	AlertNotEqual       = "ne"
+)
+
+// Tipos de destino de las notificaciones de alerta
+const (
+	AlertSinkLog     = "log"
+	AlertSinkWebhook = "webhook"
+	AlertSinkMqtt    = "mqtt"
+)
+
+// AlertStatus es el estado de una alerta para un topic
+type AlertStatus string
+
+const (
+	// AlertPending indica que la condición se cumple pero aún no durante toda la ventana
+	AlertPending AlertStatus = "pending"
+	// AlertFiring indica que la condición se ha cumplido durante toda la ventana
+	AlertFiring AlertStatus = "firing"
+	// AlertResolved indica que la condición ha dejado de cumplirse
+	AlertResolved AlertStatus = "resolved"
+)
+
+// AlertRule dispara una alerta cuando el campo numérico Field del payload de
+// los mensajes de un topic que coincide con TopicFilter cumple la comparación
+// con Threshold durante WindowSeconds. Cada topic tiene su propio estado.
+type AlertRule struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	TopicFilter string `json:"topic_filter"`
+	// Field es la ruta del campo en el payload JSON, p. ej. $.temperature o $.sensors[0].value
+	Field      string  `json:"field"`
+	Comparator string  `json:"comparator"`
+	Threshold  float64 `json:"threshold"`
+	// WindowSeconds es cuánto debe cumplirse la condición antes de disparar; 0 dispara al primer mensaje
+	WindowSeconds int64 `json:"window_seconds"`
+	// CooldownSeconds es el tiempo mínimo entre dos disparos de la alerta de
+	// un mismo topic; mientras dura, la alerta sigue pendiente
+	CooldownSeconds int64             `json:"cooldown_seconds"`
+	Sinks           []AlertSinkConfig `json:"sinks"`
+	Enabled         bool              `json:"enabled"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+
+	// path es Field ya interpretado; lo rellena Validate
+	path []alertPathStep
+}
+
+// AlertSinkConfig indica dónde se notifican los cambios de una alerta
+type AlertSinkConfig struct {
+	Type string `json:"type"`
+	// URL es la dirección a la que el destino webhook envía un POST
+	URL string `json:"url,omitempty"`
+	// Topic, QOS y Retain configuran la publicación del destino mqtt
+	Topic  string `json:"topic,omitempty"`
+	QOS    byte   `json:"qos,omitempty"`
+	Retain bool   `json:"retain,omitempty"`
+}
+
+// AlertState es el estado de la alerta de una regla para un topic concreto
+type AlertState struct {
+	RuleID int         `json:"rule_id"`
+	Topic  string      `json:"topic"`
+	Status AlertStatus `json:"status"`
+	// Value es el valor que provocó el último disparo o resolución
+	Value *float64 `json:"value"`
+	// PendingSince es desde cuándo se cumple la condición sin interrupción
+	PendingSince *time.Time `json:"pending_since"`
+	FiredAt      *time.Time `json:"fired_at"`
+	ResolvedAt   *time.Time `json:"resolved_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// Cleared indica que la alerta vuelve a no tener estado: estuvo pendiente sin
+// llegar a dispararse nunca, así que no hay nada que conservar
+func (s *AlertState) Cleared() bool {
+	return s.Status == ""
+}
+
+// AlertNotification es lo que reciben los destinos cuando una alerta se
+// dispara o se resuelve
+type AlertNotification struct {
+	RuleID     int         `json:"rule_id"`
+	RuleName   string      `json:"rule_name"`
+	Topic      string      `json:"topic"`
+	Status     AlertStatus `json:"status"`
+	Field      string      `json:"field"`
+	Comparator string      `json:"comparator"`
+	Threshold  float64     `json:"threshold"`
+	Value      float64     `json:"value"`
+	At         time.Time   `json:"at"`
+}
+
+// alertPathStep es un paso de la ruta de un campo: una clave o un índice
+type alertPathStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// NewAlertRule crea una regla validando sus campos. Sin destinos, los cambios
+// de la alerta se notifican en el log.
+func NewAlertRule(name, topicFilter, field, comparator string, threshold float64, windowSeconds, cooldownSeconds int64, sinks []AlertSinkConfig, enabled bool) (*AlertRule, error) {
+	if len(sinks) == 0 {
+		sinks = []AlertSinkConfig{{Type: AlertSinkLog}}
+	}
+
+	rule := &AlertRule{
+		Name:            name,
+		TopicFilter:     topicFilter,
+		Field:           field,
+		Comparator:      comparator,
+		Threshold:       threshold,
+		WindowSeconds:   windowSeconds,
+		CooldownSeconds: cooldownSeconds,
+		Sinks:           sinks,
+		Enabled:         enabled,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
+	}
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// Validate comprueba los campos de la regla y prepara la ruta del campo
+func (r *AlertRule) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("name is required")
+	}
+	if err := mqtttopic.ValidateFilter(r.TopicFilter); err != nil {
+		return err
+	}
+
+	path, err := parseAlertField(r.Field)
+	if err != nil {
+		return err
+	}
+
+	switch r.Comparator {
+	case AlertGreater, AlertGreaterOrEqual, AlertLess, AlertLessOrEqual, AlertEqual, AlertNotEqual:
+	default:
+		return fmt.Errorf("comparator must be one of gt, gte, lt, lte, eq, ne: %q", r.Comparator)
+	}
+	if r.WindowSeconds < 0 {
+		return errors.New("window_seconds must not be negative")
+	}
+	if r.CooldownSeconds < 0 {
+		return errors.New("cooldown_seconds must not be negative")
+	}
+
+	for _, sink := range r.Sinks {
+		if err := r.validateSink(sink); err != nil {
+			return err
+		}
+	}
+
+	r.path = path
+	return nil
+}
+
+// validateSink comprueba la configuración de un destino
+func (r *AlertRule) validateSink(sink AlertSinkConfig) error {
+	switch sink.Type {
+	case AlertSinkLog:
+		return nil
+	case AlertSinkWebhook:
+		u, err := url.Parse(sink.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook sinks require an http or https url: %q", sink.URL)
+		}
+		return nil
+	case AlertSinkMqtt:
+		if sink.Topic == "" || mqtttopic.HasWildcards(sink.Topic) {
+			return fmt.Errorf("mqtt sinks require a topic without wildcards: %q", sink.Topic)
+		}
+		if sink.QOS > 2 {
+			return fmt.Errorf("mqtt sink qos must be 0, 1 or 2: %d", sink.QOS)
+		}
+		// La notificación volvería a evaluarse con esta misma regla
+		if mqtttopic.Match(r.TopicFilter, sink.Topic) {
+			return fmt.Errorf("mqtt sink topic %q must not match the rule topic filter", sink.Topic)
+		}
+		return nil
+	default:
+		return fmt.Errorf("sink type must be one of log, webhook, mqtt: %q", sink.Type)
+	}
+}
+
+// parseAlertField interpreta la ruta de un campo: claves separadas por puntos
+// e índices entre corchetes, con o sin $ inicial ($.a.b[0] o a.b[0])
+func parseAlertField(field string) ([]alertPathStep, error) {
+	rest := strings.TrimSpace(field)
+	if strings.HasPrefix(rest, "$") {
+		rest = rest[1:]
+	} else {
+		rest = "." + rest
+	}
+
+	var path []alertPathStep
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty key in field %q", field)
+			}
+			path = append(path, alertPathStep{key: rest[:end]})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed index in field %q", field)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid index in field %q", field)
+			}
+			path = append(path, alertPathStep{index: index, isIndex: true})
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid field %q", field)
+		}
+	}
+
+	if len(path) == 0 {
+		return nil, errors.New("field is required")
+	}
+	return path, nil
+}
+
+// Matches indica si la regla está activa y se aplica a los mensajes del topic
+func (r *AlertRule) Matches(topic string) bool {
+	return r.Enabled && mqtttopic.Match(r.TopicFilter, topic)
+}
+
+// Value extrae el campo de un payload JSON ya decodificado. Devuelve false si
+// no existe o no es un número. La regla debe haberse validado.
+func (r *AlertRule) Value(payload interface{}) (float64, bool) {
+	current := payload
+	for _, step := range r.path {
+		if step.isIndex {
+			items, ok := current.([]interface{})
+			if !ok || step.index >= len(items) {
+				return 0, false
+			}
+			current = items[step.index]
+			continue
+		}
+
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return 0, false
+		}
+		if current, ok = object[step.key]; !ok {
+			return 0, false
+		}
+	}
+
+	value, ok := current.(float64)
+	return value, ok
+}
+
+// Holds indica si el valor cumple la condición de la regla
+func (r *AlertRule) Holds(value float64) bool {
+	switch r.Comparator {
+	case AlertGreater:
+		return value > r.Threshold
+	case AlertGreaterOrEqual:
+		return value >= r.Threshold
+	case AlertLess:
+		return value < r.Threshold
+	case AlertLessOrEqual:
+		return value <= r.Threshold
+	case AlertEqual:
+		return value == r.Threshold
+	case AlertNotEqual:
+		return value != r.Threshold
+	}
+	return false
+}
+
+// Evaluate calcula el estado de la alerta del topic tras recibir value en at.
+// state es el estado actual, nil si la alerta nunca ha estado pendiente.
+// Devuelve el nuevo estado, o nil si no cambia, y AlertFiring o AlertResolved
+// si hay que notificarlo. Una alerta pendiente que deja de cumplir la condición
+// vuelve al estado que tenía antes: resuelta si ya se disparó alguna vez, o sin
+// estado (ver Cleared) si no.
+//
+// La ventana solo se comprueba al llegar mensajes: si la condición se cumple
+// pero el topic deja de publicar, la alerta se dispara con el siguiente mensaje.
+func (r *AlertRule) Evaluate(state *AlertState, topic string, value float64, at time.Time) (*AlertState, AlertStatus) {
+	if !r.Holds(value) {
+		if state == nil || state.Status == AlertResolved {
+			return nil, ""
+		}
+
+		next := *state
+		next.PendingSince = nil
+		next.UpdatedAt = at
+		if state.Status != AlertFiring {
+			// Estaba pendiente: vuelve a su estado anterior sin notificar
+			if state.FiredAt == nil {
+				next.Status = ""
+			} else {
+				next.Status = AlertResolved
+			}
+			return &next, ""
+		}
+		next.Status = AlertResolved
+		next.Value = &value
+		next.ResolvedAt = &at
+		return &next, AlertResolved
+	}
+
+	var next AlertState
+	switch {
+	case state == nil:
+		next = AlertState{RuleID: r.ID, Topic: topic}
+	case state.Status == AlertFiring:
+		return nil, ""
+	default:
+		next = *state
+	}
+
+	changed := false
+	if next.Status != AlertPending {
+		next.Status = AlertPending
+		next.PendingSince = &at
+		changed = true
+	}
+
+	window := time.Duration(r.WindowSeconds) * time.Second
+	cooldown := time.Duration(r.CooldownSeconds) * time.Second
+	if at.Sub(*next.PendingSince) >= window && (next.FiredAt == nil || at.Sub(*next.FiredAt) >= cooldown) {
+		next.Status = AlertFiring
+		next.Value = &value
+		next.FiredAt = &at
+		next.UpdatedAt = at
+		return &next, AlertFiring
+	}
+
+	if !changed {
+		return nil, ""
+	}
+	next.UpdatedAt = at
+	return &next, ""
+}
+
+// Notification crea la notificación de un cambio del estado de la alerta
+func (r *AlertRule) Notification(state *AlertState) AlertNotification {
+	notification := AlertNotification{
+		RuleID:     r.ID,
+		RuleName:   r.Name,
+		Topic:      state.Topic,
+		Status:     state.Status,
+		Field:      r.Field,
+		Comparator: r.Comparator,
+		Threshold:  r.Threshold,
+		At:         state.UpdatedAt,
+	}
+	if state.Value != nil {
+		notification.Value = *state.Value
+	}
+	return notification
+}
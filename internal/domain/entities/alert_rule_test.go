@@ -0,0 +1,149 @@
+package entities
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAlertRuleValidates(t *testing.T) {
+	_, err := NewAlertRule("", "sensores/#", "$.temperatura", AlertGreater, 30, 0, 0, nil, true)
+	assert.Error(t, err, "Name is required")
+
+	_, err = NewAlertRule("calor", "sensores/#", "$.temperatura[x]", AlertGreater, 30, 0, 0, nil, true)
+	assert.Error(t, err, "Invalid index")
+
+	_, err = NewAlertRule("calor", "sensores/#", "$.temperatura", "above", 30, 0, 0, nil, true)
+	assert.Error(t, err, "Unknown comparator")
+
+	_, err = NewAlertRule("calor", "sensores/#", "$.temperatura", AlertGreater, 30, -1, 0, nil, true)
+	assert.Error(t, err)
+
+	_, err = NewAlertRule("calor", "sensores/#", "$.temperatura", AlertGreater, 30, 0, 0,
+		[]AlertSinkConfig{{Type: AlertSinkWebhook, URL: "ftp://example.com"}}, true)
+	assert.Error(t, err, "Webhooks must be http or https")
+
+	_, err = NewAlertRule("calor", "sensores/#", "$.temperatura", AlertGreater, 30, 0, 0,
+		[]AlertSinkConfig{{Type: AlertSinkMqtt, Topic: "sensores/alertas"}}, true)
+	assert.Error(t, err, "Republishing into the rule's own filter would loop")
+
+	rule, err := NewAlertRule("calor", "sensores/#", "$.temperatura", AlertGreater, 30, 0, 0, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []AlertSinkConfig{{Type: AlertSinkLog}}, rule.Sinks, "Without sinks alerts are logged")
+}
+
+func TestAlertRuleValue(t *testing.T) {
+	var payload interface{}
+	if err := json.Unmarshal([]byte(`{"temperatura": 31.5, "sensores": [{"valor": 7}], "estado": "ok"}`), &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		value float64
+		ok    bool
+	}{
+		"$.temperatura":       {31.5, true},
+		"temperatura":         {31.5, true},
+		"$.sensores[0].valor": {7, true},
+		"$.sensores[1].valor": {0, false},
+		"$.estado":            {0, false},
+		"$.humedad":           {0, false},
+	}
+	for field, expected := range cases {
+		rule := &AlertRule{Name: "r", TopicFilter: "#", Field: field, Comparator: AlertGreater}
+		if err := rule.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		value, ok := rule.Value(payload)
+		assert.Equal(t, expected.ok, ok, field)
+		assert.Equal(t, expected.value, value, field)
+	}
+}
+
+func TestAlertRuleEvaluateWindow(t *testing.T) {
+	rule := &AlertRule{ID: 1, Comparator: AlertGreater, Threshold: 30, WindowSeconds: 300}
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	state, transition := rule.Evaluate(nil, "sensores/sala1", 25, start)
+	assert.Nil(t, state, "Nothing to record while the condition does not hold")
+	assert.Empty(t, transition)
+
+	state, transition = rule.Evaluate(nil, "sensores/sala1", 31, start)
+	if assert.NotNil(t, state) {
+		assert.Equal(t, AlertPending, state.Status)
+		assert.Equal(t, start, *state.PendingSince)
+	}
+	assert.Empty(t, transition)
+
+	next, transition := rule.Evaluate(state, "sensores/sala1", 32, start.Add(4*time.Minute))
+	assert.Nil(t, next, "Still inside the window")
+	assert.Empty(t, transition)
+
+	state, transition = rule.Evaluate(state, "sensores/sala1", 33, start.Add(5*time.Minute))
+	assert.Equal(t, AlertFiring, transition)
+	assert.Equal(t, 33.0, *state.Value)
+
+	next, transition = rule.Evaluate(state, "sensores/sala1", 34, start.Add(6*time.Minute))
+	assert.Nil(t, next, "Firing alerts are notified once")
+	assert.Empty(t, transition)
+
+	state, transition = rule.Evaluate(state, "sensores/sala1", 29, start.Add(7*time.Minute))
+	assert.Equal(t, AlertResolved, transition)
+	assert.Equal(t, AlertResolved, state.Status)
+	assert.Nil(t, state.PendingSince)
+	assert.Equal(t, 29.0, *state.Value)
+}
+
+func TestAlertRuleEvaluatePendingResets(t *testing.T) {
+	rule := &AlertRule{ID: 1, Comparator: AlertGreater, Threshold: 30, WindowSeconds: 300}
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	state, _ := rule.Evaluate(nil, "sensores/sala1", 31, start)
+	state, transition := rule.Evaluate(state, "sensores/sala1", 29, start.Add(time.Minute))
+	assert.Empty(t, transition, "A pending alert that clears is not notified")
+	assert.True(t, state.Cleared(), "An alert that never fired goes back to having no state")
+	state = nil
+
+	state, _ = rule.Evaluate(state, "sensores/sala1", 31, start.Add(2*time.Minute))
+	next, transition := rule.Evaluate(state, "sensores/sala1", 31, start.Add(6*time.Minute))
+	assert.Nil(t, next, "The window starts again")
+	assert.Empty(t, transition)
+}
+
+func TestAlertRuleEvaluatePendingAfterFiringResets(t *testing.T) {
+	rule := &AlertRule{ID: 1, Comparator: AlertGreater, Threshold: 30, WindowSeconds: 300}
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	state, _ := rule.Evaluate(nil, "sensores/sala1", 31, start)
+	state, _ = rule.Evaluate(state, "sensores/sala1", 31, start.Add(5*time.Minute))
+	resolved, _ := rule.Evaluate(state, "sensores/sala1", 29, start.Add(6*time.Minute))
+
+	state, _ = rule.Evaluate(resolved, "sensores/sala1", 31, start.Add(7*time.Minute))
+	assert.Equal(t, AlertPending, state.Status)
+	state, transition := rule.Evaluate(state, "sensores/sala1", 29, start.Add(8*time.Minute))
+	assert.Empty(t, transition)
+	assert.Equal(t, AlertResolved, state.Status, "An alert that fired before goes back to resolved")
+	assert.Equal(t, resolved.ResolvedAt, state.ResolvedAt)
+	assert.Equal(t, resolved.Value, state.Value)
+	assert.Nil(t, state.PendingSince)
+}
+
+func TestAlertRuleEvaluateCooldown(t *testing.T) {
+	rule := &AlertRule{ID: 1, Comparator: AlertGreaterOrEqual, Threshold: 30, CooldownSeconds: 600}
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	state, transition := rule.Evaluate(nil, "sensores/sala1", 30, start)
+	assert.Equal(t, AlertFiring, transition, "Without window the first message fires")
+	state, _ = rule.Evaluate(state, "sensores/sala1", 20, start.Add(time.Minute))
+
+	state, transition = rule.Evaluate(state, "sensores/sala1", 35, start.Add(2*time.Minute))
+	assert.Empty(t, transition, "Fires are suppressed during the cooldown")
+	assert.Equal(t, AlertPending, state.Status)
+
+	_, transition = rule.Evaluate(state, "sensores/sala1", 35, start.Add(10*time.Minute))
+	assert.Equal(t, AlertFiring, transition)
+}
@@ -0,0 +1,26 @@
+package entities
+
+import "time"
+
+// RefreshToken representa un refresh token emitido a un usuario.
+// Solo se persiste el hash del token; el valor en claro únicamente lo conoce el cliente.
+type RefreshToken struct {
+	ID         int
+	UserID     int
+	TokenHash  string
+	FamilyID   string // Cadena de rotación a la que pertenece el token
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+	ReplacedBy *int
+	CreatedAt  time.Time
+}
+
+// IsExpired indica si el token ha caducado
+func (t *RefreshToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+// IsRevoked indica si el token ha sido revocado o ya se rotó
+func (t *RefreshToken) IsRevoked() bool {
+	return t.RevokedAt != nil
+}
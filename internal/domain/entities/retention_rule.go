@@ -0,0 +1,152 @@
+package entities
+
+import (
+	"errors"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/mqtttopic"
+)
+
+// DefaultRetentionFilter es el filtro de la regla de retención por defecto: se
+// aplica a los mensajes que no coinciden con ninguna regla más específica
+const DefaultRetentionFilter = mqtttopic.MultiLevel
+
+// RetentionRule limita cuánto se conservan los mensajes MQTT cuyo topic
+// coincide con TopicFilter: por antigüedad, por número de mensajes o por
+// ambos. Cada mensaje sigue solo la regla más específica que coincide con su
+// topic, igual que el reparto de mensajes entre suscripciones.
+type RetentionRule struct {
+	ID          int    `json:"id"`
+	TopicFilter string `json:"topic_filter"`
+	// MaxAgeSeconds es la antigüedad máxima de los mensajes; nil es sin límite
+	MaxAgeSeconds *int64 `json:"max_age_seconds"`
+	// MaxRows son los mensajes más recientes que se conservan; nil es sin límite
+	MaxRows   *int64    `json:"max_rows"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NewRetentionRule crea una regla validando el filtro y los límites
+func NewRetentionRule(topicFilter string, maxAgeSeconds, maxRows *int64) (*RetentionRule, error) {
+	rule := &RetentionRule{
+		TopicFilter:   topicFilter,
+		MaxAgeSeconds: maxAgeSeconds,
+		MaxRows:       maxRows,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// Validate comprueba que el filtro sea válido y que haya al menos un límite positivo
+func (r *RetentionRule) Validate() error {
+	if err := mqtttopic.ValidateFilter(r.TopicFilter); err != nil {
+		return err
+	}
+	if r.MaxAgeSeconds == nil && r.MaxRows == nil {
+		return errors.New("max_age_seconds or max_rows is required")
+	}
+	if r.MaxAgeSeconds != nil && *r.MaxAgeSeconds <= 0 {
+		return errors.New("max_age_seconds must be positive")
+	}
+	if r.MaxRows != nil && *r.MaxRows <= 0 {
+		return errors.New("max_rows must be positive")
+	}
+	return nil
+}
+
+// IsDefault indica si es la regla por defecto
+func (r *RetentionRule) IsDefault() bool {
+	return r.TopicFilter == DefaultRetentionFilter
+}
+
+// Scope devuelve los mensajes que gobierna la regla dentro del conjunto de
+// reglas: los de su filtro salvo los que corresponden a reglas más específicas
+// con las que comparte topics
+func (r *RetentionRule) Scope(rules []*RetentionRule) RetentionScope {
+	scope := RetentionScope{TopicFilter: r.TopicFilter, Exclude: []string{}}
+	for _, other := range rules {
+		if other.TopicFilter != r.TopicFilter && mqtttopic.Overlaps(other.TopicFilter, r.TopicFilter) &&
+			mqtttopic.MoreSpecific(other.TopicFilter, r.TopicFilter) {
+			scope.Exclude = append(scope.Exclude, other.TopicFilter)
+		}
+	}
+	return scope
+}
+
+// Boundary devuelve la posición a partir de la cual los mensajes se conservan:
+// los anteriores han caducado por antigüedad o quedan fuera de los MaxRows más
+// recientes. oldestKept es la posición del mensaje número MaxRows empezando
+// por el más reciente, o nil si no hay tantos. Devuelve nil si no sobra nada.
+func (r *RetentionRule) Boundary(now time.Time, oldestKept *MessagePosition) *MessagePosition {
+	var boundary *MessagePosition
+	if r.MaxAgeSeconds != nil {
+		boundary = &MessagePosition{ReceivedAt: now.Add(-time.Duration(*r.MaxAgeSeconds) * time.Second)}
+	}
+	if r.MaxRows != nil && oldestKept != nil && (boundary == nil || boundary.Before(*oldestKept)) {
+		boundary = oldestKept
+	}
+	return boundary
+}
+
+// RetentionCutoff devuelve el instante antes del cual todos los mensajes han
+// caducado, sea cual sea su topic: now menos la mayor antigüedad permitida.
+// Solo existe si hay regla por defecto y todas las reglas limitan la
+// antigüedad; si no, algún mensaje podría conservarse indefinidamente y
+// devuelve nil.
+func RetentionCutoff(rules []*RetentionRule, now time.Time) *time.Time {
+	var hasDefault bool
+	var maxAge int64
+	for _, rule := range rules {
+		if rule.MaxAgeSeconds == nil {
+			return nil
+		}
+		if rule.IsDefault() {
+			hasDefault = true
+		}
+		maxAge = max(maxAge, *rule.MaxAgeSeconds)
+	}
+	if !hasDefault {
+		return nil
+	}
+
+	cutoff := now.Add(-time.Duration(maxAge) * time.Second)
+	return &cutoff
+}
+
+// RetentionScope son los mensajes que gobierna una regla: los que coinciden
+// con TopicFilter y con ninguno de los filtros de Exclude
+type RetentionScope struct {
+	TopicFilter string
+	Exclude     []string
+}
+
+// MessagePosition es la posición de un mensaje en el orden (received_at, id)
+// en el que se pagina y se purga mqtt_messages
+type MessagePosition struct {
+	ReceivedAt time.Time `json:"received_at"`
+	ID         int       `json:"id"`
+}
+
+// Before indica si p va antes que other
+func (p MessagePosition) Before(other MessagePosition) bool {
+	if !p.ReceivedAt.Equal(other.ReceivedAt) {
+		return p.ReceivedAt.Before(other.ReceivedAt)
+	}
+	return p.ID < other.ID
+}
+
+// RetentionReport es el resultado de aplicar una regla: los mensajes que
+// eliminaría (simulación) o que eliminó
+type RetentionReport struct {
+	RuleID      int    `json:"rule_id"`
+	TopicFilter string `json:"topic_filter"`
+	// Overridden son los filtros de reglas más específicas que no se tocan
+	Overridden []string `json:"overridden_by"`
+	// Before es la posición desde la que se conservan los mensajes; nil si no sobra ninguno
+	Before *MessagePosition `json:"keep_from,omitempty"`
+	Rows   int64            `json:"rows"`
+}
@@ -0,0 +1,98 @@
+package entities
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func int64p(v int64) *int64 { return &v }
+
+func TestNewRetentionRuleValidates(t *testing.T) {
+	_, err := NewRetentionRule("sensores/#", nil, nil)
+	assert.Error(t, err, "At least one limit is required")
+
+	_, err = NewRetentionRule("sensores/#", int64p(0), nil)
+	assert.Error(t, err)
+
+	_, err = NewRetentionRule("sensores/#", nil, int64p(-1))
+	assert.Error(t, err)
+
+	_, err = NewRetentionRule("sensores/#/sala1", int64p(3600), nil)
+	assert.Error(t, err, "Invalid topic filter")
+
+	rule, err := NewRetentionRule(DefaultRetentionFilter, int64p(3600), int64p(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, rule.IsDefault())
+}
+
+func TestRetentionScopeExcludesMoreSpecificRules(t *testing.T) {
+	rules := []*RetentionRule{
+		{TopicFilter: "#"},
+		{TopicFilter: "sensores/#"},
+		{TopicFilter: "sensores/sala1/temperatura"},
+		{TopicFilter: "actuadores/+"},
+	}
+
+	assert.ElementsMatch(t, []string{"sensores/#", "sensores/sala1/temperatura", "actuadores/+"}, rules[0].Scope(rules).Exclude)
+	assert.ElementsMatch(t, []string{"sensores/sala1/temperatura"}, rules[1].Scope(rules).Exclude,
+		"Filters that never match the same topics are not excluded")
+	assert.Empty(t, rules[2].Scope(rules).Exclude)
+	assert.Equal(t, "actuadores/+", rules[3].Scope(rules).TopicFilter)
+}
+
+func TestRetentionScopeExactFilterBeatsParentWildcard(t *testing.T) {
+	rules := []*RetentionRule{
+		{TopicFilter: "a", MaxAgeSeconds: int64p(24 * 3600)},
+		{TopicFilter: "a/#", MaxAgeSeconds: int64p(30 * 24 * 3600)},
+	}
+
+	assert.Empty(t, rules[0].Scope(rules).Exclude, "Topic a is governed by rule a")
+	assert.Equal(t, []string{"a"}, rules[1].Scope(rules).Exclude, "a/# also matches a, which belongs to the exact rule")
+}
+
+func TestRetentionBoundary(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	dayAgo := now.Add(-24 * time.Hour)
+
+	byAge := &RetentionRule{MaxAgeSeconds: int64p(24 * 3600)}
+	assert.Equal(t, &MessagePosition{ReceivedAt: dayAgo}, byAge.Boundary(now, nil))
+
+	byRows := &RetentionRule{MaxRows: int64p(100)}
+	assert.Nil(t, byRows.Boundary(now, nil), "Nothing expires while there are fewer rows than the limit")
+	kept := &MessagePosition{ReceivedAt: now.Add(-time.Hour), ID: 42}
+	assert.Equal(t, kept, byRows.Boundary(now, kept))
+
+	both := &RetentionRule{MaxAgeSeconds: int64p(24 * 3600), MaxRows: int64p(100)}
+	assert.Equal(t, kept, both.Boundary(now, kept), "The row limit removes more than the age limit")
+	old := &MessagePosition{ReceivedAt: now.Add(-48 * time.Hour), ID: 7}
+	assert.Equal(t, &MessagePosition{ReceivedAt: dayAgo}, both.Boundary(now, old), "The age limit removes more than the row limit")
+}
+
+func TestMessagePositionBefore(t *testing.T) {
+	now := time.Now()
+	assert.True(t, MessagePosition{ReceivedAt: now.Add(-time.Second), ID: 9}.Before(MessagePosition{ReceivedAt: now, ID: 1}))
+	assert.True(t, MessagePosition{ReceivedAt: now, ID: 1}.Before(MessagePosition{ReceivedAt: now, ID: 2}))
+	assert.False(t, MessagePosition{ReceivedAt: now, ID: 2}.Before(MessagePosition{ReceivedAt: now, ID: 2}))
+}
+
+func TestRetentionCutoff(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	rules := []*RetentionRule{
+		{TopicFilter: "#", MaxAgeSeconds: int64p(24 * 3600)},
+		{TopicFilter: "sensores/#", MaxAgeSeconds: int64p(7 * 24 * 3600), MaxRows: int64p(100)},
+	}
+
+	cutoff := RetentionCutoff(rules, now)
+	if assert.NotNil(t, cutoff) {
+		assert.Equal(t, now.AddDate(0, 0, -7), *cutoff, "The longest age limit applies")
+	}
+
+	assert.Nil(t, RetentionCutoff(rules[1:], now), "Without a default rule some topics are kept forever")
+	assert.Nil(t, RetentionCutoff(append(rules, &RetentionRule{TopicFilter: "alarmas/#", MaxRows: int64p(10)}), now),
+		"A rule limited only by rows has no age cutoff")
+	assert.Nil(t, RetentionCutoff(nil, now))
+}
@@ -0,0 +1,73 @@
+package entities
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/mqtttopic"
+)
+
+// RollupConfig indica de qué campos numéricos del payload de los topics que
+// coinciden con TopicFilter se calculan agregados por minuto, hora y día
+type RollupConfig struct {
+	ID          int    `json:"id"`
+	TopicFilter string `json:"topic_filter"`
+	// Fields son expresiones JSONPath de los campos, p. ej. $.value
+	Fields    []string  `json:"fields"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Watermarks es, por resolución, el instante hasta el que están calculados
+	Watermarks map[string]time.Time `json:"watermarks"`
+}
+
+// NewRollupConfig crea una configuración validando el filtro y los campos
+func NewRollupConfig(topicFilter string, fields []string) (*RollupConfig, error) {
+	config := &RollupConfig{
+		TopicFilter: topicFilter,
+		Fields:      normalizeRollupFields(fields),
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+		Watermarks:  map[string]time.Time{},
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// normalizeRollupFields quita espacios y campos vacíos o repetidos
+func normalizeRollupFields(fields []string) []string {
+	normalized := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if field = strings.TrimSpace(field); field != "" && !slices.Contains(normalized, field) {
+			normalized = append(normalized, field)
+		}
+	}
+	return normalized
+}
+
+// Validate comprueba el filtro y que haya al menos un campo con forma de
+// expresión JSONPath ($..., lax $... o strict $...)
+func (c *RollupConfig) Validate() error {
+	if err := mqtttopic.ValidateFilter(c.TopicFilter); err != nil {
+		return err
+	}
+	if len(c.Fields) == 0 {
+		return errors.New("at least one field is required")
+	}
+	for _, field := range c.Fields {
+		path := strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(field, "strict "), "lax "))
+		if !strings.HasPrefix(path, "$") {
+			return errors.New("fields must be JSONPath expressions starting with $: " + field)
+		}
+	}
+	return nil
+}
+
+// Covers indica si la configuración calcula agregados del campo para todos los
+// topics que abarca el filtro
+func (c *RollupConfig) Covers(topicFilter, field string) bool {
+	return slices.Contains(c.Fields, field) && mqtttopic.Covers(c.TopicFilter, topicFilter)
+}
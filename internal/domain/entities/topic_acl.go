@@ -0,0 +1,57 @@
+package entities
+
+import (
+	"errors"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/mqtttopic"
+)
+
+// Tipos de acceso de una regla ACL, con los mismos nombres que en el acl_file de Mosquitto
+const (
+	TopicAccessRead      = "read"
+	TopicAccessWrite     = "write"
+	TopicAccessReadWrite = "readwrite"
+)
+
+// TopicACL es una regla que permite a un usuario leer y/o escribir en los
+// topics que coinciden con un filtro MQTT
+type TopicACL struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"user_id"`
+	Username    string    `json:"username"` // Se carga junto a la regla para exportarla
+	TopicFilter string    `json:"topic_filter"`
+	Access      string    `json:"access"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// NewTopicACL crea una regla validando el filtro y el tipo de acceso
+func NewTopicACL(userID int, topicFilter, access string) (*TopicACL, error) {
+	if err := mqtttopic.ValidateFilter(topicFilter); err != nil {
+		return nil, err
+	}
+	if !IsValidTopicAccess(access) {
+		return nil, errors.New("access must be read, write or readwrite")
+	}
+
+	return &TopicACL{
+		UserID:      userID,
+		TopicFilter: topicFilter,
+		Access:      access,
+		CreatedAt:   time.Now(),
+	}, nil
+}
+
+// IsValidTopicAccess indica si access es un tipo de acceso conocido
+func IsValidTopicAccess(access string) bool {
+	switch access {
+	case TopicAccessRead, TopicAccessWrite, TopicAccessReadWrite:
+		return true
+	}
+	return false
+}
+
+// Allows indica si la regla concede el tipo de acceso indicado (read o write)
+func (a *TopicACL) Allows(access string) bool {
+	return a.Access == TopicAccessReadWrite || a.Access == access
+}
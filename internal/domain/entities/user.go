@@ -2,7 +2,9 @@ package entities
 
 import (
 	"errors"
+	"strings"
 	"time"
+	"unicode"
 )
 
 // User representa la entidad de usuario en el dominio
@@ -10,16 +12,21 @@ type User struct {
 	ID        int       `json:"id"`
 	Username  string    `json:"username"`
 	Email     string    `json:"email"`
-	Password  string    `json:"-"` // No se expone en JSON por seguridad
+	Password  string    `json:"-"` // Hash de la contraseña, no se expone en JSON por seguridad
 	FullName  *string   `json:"full_name,omitempty"`
 	IsActive  *bool     `json:"is_active,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// PasswordResetRequired obliga al usuario a cambiar la contraseña antes de poder autenticarse
+	PasswordResetRequired bool `json:"-"`
+	// Roles asignados al usuario (se cargan aparte del resto de campos)
+	Roles []string `json:"roles,omitempty"`
 }
 
-// NewUser crea una nueva instancia de User con validaciones básicas
-func NewUser(username, email, password string) (*User, error) {
-	if err := validateUserData(username, email, password); err != nil {
+// NewUser crea una nueva instancia de User con validaciones básicas.
+// passwordHash debe ser el hash de la contraseña, nunca la contraseña en claro.
+func NewUser(username, email, passwordHash string) (*User, error) {
+	if err := validateUserData(username, email, passwordHash); err != nil {
 		return nil, err
 	}
 
@@ -29,7 +36,7 @@ func NewUser(username, email, password string) (*User, error) {
 	return &User{
 		Username:  username,
 		Email:     email,
-		Password:  password,
+		Password:  passwordHash,
 		IsActive:  &isActive,
 		CreatedAt: now,
 		UpdatedAt: now,
@@ -39,6 +46,9 @@ func NewUser(username, email, password string) (*User, error) {
 // Update actualiza los campos modificables del usuario
 func (u *User) Update(username, email string, fullName *string, isActive *bool) error {
 	if username != "" {
+		if err := validateUsername(username); err != nil {
+			return err
+		}
 		u.Username = username
 	}
 	if email != "" {
@@ -54,20 +64,40 @@ func (u *User) Update(username, email string, fullName *string, isActive *bool)
 	return nil
 }
 
-// ChangePassword cambia la contraseña del usuario
-func (u *User) ChangePassword(newPassword string) error {
-	if newPassword == "" {
+// ChangePassword sustituye el hash de la contraseña del usuario y levanta
+// la obligación de resetearla si existía
+func (u *User) ChangePassword(newPasswordHash string) error {
+	if newPasswordHash == "" {
 		return errors.New("password cannot be empty")
 	}
-	u.Password = newPassword
+	u.Password = newPasswordHash
+	u.PasswordResetRequired = false
 	u.UpdatedAt = time.Now()
 	return nil
 }
 
+// RehashPassword sustituye el hash de la contraseña sin cambiar la contraseña en sí
+func (u *User) RehashPassword(passwordHash string) {
+	u.Password = passwordHash
+	u.UpdatedAt = time.Now()
+}
+
+// RequirePasswordReset marca al usuario para que cambie la contraseña en el próximo acceso
+func (u *User) RequirePasswordReset(passwordHash string) {
+	u.Password = passwordHash
+	u.PasswordResetRequired = true
+	u.UpdatedAt = time.Now()
+}
+
+// Active indica si el usuario está activo (por defecto lo está)
+func (u *User) Active() bool {
+	return u.IsActive == nil || *u.IsActive
+}
+
 // validateUserData valida los datos básicos del usuario
 func validateUserData(username, email, password string) error {
-	if username == "" {
-		return errors.New("username is required")
+	if err := validateUsername(username); err != nil {
+		return err
 	}
 	if email == "" {
 		return errors.New("email is required")
@@ -78,3 +108,16 @@ func validateUserData(username, email, password string) error {
 	// Aquí podrías agregar más validaciones como formato de email, etc.
 	return nil
 }
+
+// validateUsername exige un nombre de usuario sin espacios ni caracteres de
+// control: se exporta tal cual a ficheros de configuración como el acl_file de
+// Mosquitto, donde un salto de línea permitiría añadir reglas
+func validateUsername(username string) error {
+	if username == "" {
+		return errors.New("username is required")
+	}
+	if strings.IndexFunc(username, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		return errors.New("username must not contain whitespace or control characters")
+	}
+	return nil
+}
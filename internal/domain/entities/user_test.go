@@ -0,0 +1,21 @@
+package entities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewUserRejectsUnsafeUsernames(t *testing.T) {
+	for _, username := range []string{"x\ntopic readwrite #", "ana lopez", "tab\tuser", "nul\x00"} {
+		_, err := NewUser(username, "a@example.com", "hash")
+		assert.Error(t, err, "%q", username)
+	}
+
+	user, err := NewUser("ana.lopez", "a@example.com", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Error(t, user.Update("x\ntopic readwrite #", "", nil, nil), "Renaming is validated too")
+	assert.Equal(t, "ana.lopez", user.Username)
+}
@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+)
+
+// AlertRuleRepository define las operaciones de persistencia de las reglas de alerta
+type AlertRuleRepository interface {
+	// Create guarda una regla nueva
+	Create(ctx context.Context, rule *entities.AlertRule) error
+
+	// Update guarda los cambios de una regla; devuelve false si no existía
+	Update(ctx context.Context, rule *entities.AlertRule) (bool, error)
+
+	// Delete elimina una regla y el estado de sus alertas; devuelve false si no existía
+	Delete(ctx context.Context, id int) (bool, error)
+
+	// List obtiene todas las reglas ordenadas por nombre
+	List(ctx context.Context) ([]*entities.AlertRule, error)
+}
+
+// AlertStateRepository define las operaciones de persistencia del estado de las alertas
+type AlertStateRepository interface {
+	// List obtiene el estado de las alertas; con status solo las que están en ese estado
+	List(ctx context.Context, status entities.AlertStatus) ([]*entities.AlertState, error)
+
+	// Save guarda el estado de la alerta de una regla para un topic
+	Save(ctx context.Context, state *entities.AlertState) error
+
+	// Delete elimina el estado de la alerta de una regla para un topic
+	Delete(ctx context.Context, ruleID int, topic string) error
+
+	// DeleteByRule elimina el estado de todas las alertas de una regla
+	DeleteByRule(ctx context.Context, ruleID int) error
+}
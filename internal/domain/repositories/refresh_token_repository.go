@@ -0,0 +1,22 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+)
+
+// RefreshTokenRepository define las operaciones de persistencia para RefreshToken
+type RefreshTokenRepository interface {
+	// Create guarda un nuevo refresh token
+	Create(ctx context.Context, token *entities.RefreshToken) error
+
+	// GetByHash obtiene un refresh token por el hash de su valor
+	GetByHash(ctx context.Context, tokenHash string) (*entities.RefreshToken, error)
+
+	// Rotate revoca el token indicando cuál lo sustituye. Devuelve false si ya estaba revocado.
+	Rotate(ctx context.Context, id, replacedBy int) (bool, error)
+
+	// RevokeFamily revoca todos los tokens activos de una cadena de rotación
+	RevokeFamily(ctx context.Context, familyID string) error
+}
@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+)
+
+// RetentionRuleRepository define las operaciones de persistencia de las reglas de retención de mensajes MQTT
+type RetentionRuleRepository interface {
+	// Create guarda una regla nueva
+	Create(ctx context.Context, rule *entities.RetentionRule) error
+
+	// Update guarda los cambios de una regla; devuelve false si no existía
+	Update(ctx context.Context, rule *entities.RetentionRule) (bool, error)
+
+	// Delete elimina una regla; devuelve false si no existía
+	Delete(ctx context.Context, id int) (bool, error)
+
+	// List obtiene todas las reglas ordenadas por filtro
+	List(ctx context.Context) ([]*entities.RetentionRule, error)
+}
+
+// MessageRetentionRepository consulta y elimina los mensajes MQTT que gobierna
+// una regla de retención
+type MessageRetentionRepository interface {
+	// OldestKept devuelve la posición del mensaje número keep empezando por el
+	// más reciente, o nil si el ámbito tiene menos mensajes
+	OldestKept(ctx context.Context, scope entities.RetentionScope, keep int64) (*entities.MessagePosition, error)
+
+	// CountBefore cuenta los mensajes del ámbito anteriores a la posición
+	CountBefore(ctx context.Context, scope entities.RetentionScope, before entities.MessagePosition) (int64, error)
+
+	// DeleteBefore elimina como mucho limit mensajes del ámbito anteriores a la
+	// posición, empezando por los más antiguos, y devuelve cuántos eliminó
+	DeleteBefore(ctx context.Context, scope entities.RetentionScope, before entities.MessagePosition, limit int) (int64, error)
+}
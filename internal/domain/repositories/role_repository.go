@@ -0,0 +1,21 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrRoleNotFound se devuelve al asignar un rol que no existe
+var ErrRoleNotFound = errors.New("role not found")
+
+// RoleRepository define las operaciones de persistencia de los roles de usuario
+type RoleRepository interface {
+	// GetUserRoles obtiene los nombres de los roles asignados a un usuario
+	GetUserRoles(ctx context.Context, userID int) ([]string, error)
+
+	// AssignRole asigna un rol a un usuario (no falla si ya lo tenía)
+	AssignRole(ctx context.Context, userID int, role string) error
+
+	// RevokeRole retira un rol a un usuario
+	RevokeRole(ctx context.Context, userID int, role string) error
+}
@@ -0,0 +1,40 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/repository"
+)
+
+// RollupConfigRepository define las operaciones de persistencia de las configuraciones de agregados
+type RollupConfigRepository interface {
+	// Create guarda una configuración nueva
+	Create(ctx context.Context, config *entities.RollupConfig) error
+
+	// Update guarda los cambios de una configuración; devuelve false si no existía
+	Update(ctx context.Context, config *entities.RollupConfig) (bool, error)
+
+	// Delete elimina una configuración y sus watermarks; devuelve false si no existía
+	Delete(ctx context.Context, id int) (bool, error)
+
+	// List obtiene todas las configuraciones ordenadas por filtro, con sus watermarks
+	List(ctx context.Context) ([]*entities.RollupConfig, error)
+}
+
+// RollupRepository calcula y guarda los agregados de los mensajes MQTT
+type RollupRepository interface {
+	// OldestMessage devuelve cuándo se recibió el mensaje más antiguo de los
+	// topics del filtro, o nil si no hay ninguno
+	OldestMessage(ctx context.Context, topicFilter string) (*time.Time, error)
+
+	// Rollup calcula los agregados de la resolución en [from, to) a partir de
+	// la resolución anterior (o de los mensajes si es la primera) y deja el
+	// watermark de la configuración en to, todo en una transacción
+	Rollup(ctx context.Context, config *entities.RollupConfig, resolution repository.RollupResolution, from, to time.Time) error
+
+	// ResetWatermarks borra los watermarks de la configuración para que sus
+	// agregados se vuelvan a calcular desde el principio
+	ResetWatermarks(ctx context.Context, configID int) error
+}
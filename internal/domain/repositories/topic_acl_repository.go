@@ -0,0 +1,22 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+)
+
+// TopicACLRepository define las operaciones de persistencia de las reglas ACL de topics MQTT
+type TopicACLRepository interface {
+	// Create guarda una regla nueva
+	Create(ctx context.Context, acl *entities.TopicACL) error
+
+	// Delete elimina una regla; devuelve false si no existía
+	Delete(ctx context.Context, id int) (bool, error)
+
+	// List obtiene todas las reglas ordenadas por usuario y filtro
+	List(ctx context.Context) ([]*entities.TopicACL, error)
+
+	// ListByUser obtiene las reglas de un usuario
+	ListByUser(ctx context.Context, userID int) ([]*entities.TopicACL, error)
+}
@@ -29,6 +29,10 @@ type UserRepository interface {
 	// List obtiene una lista paginada de usuarios
 	List(ctx context.Context, limit, offset int) ([]*entities.User, error)
 
+	// ListAfterID obtiene hasta limit usuarios con id mayor que afterID, ordenados por id.
+	// Permite recorrer todos los usuarios sin saltos aunque se creen otros a la vez.
+	ListAfterID(ctx context.Context, afterID, limit int) ([]*entities.User, error)
+
 	// Count obtiene el total de usuarios
 	Count(ctx context.Context) (int, error)
 }
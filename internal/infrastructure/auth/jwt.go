@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidToken se devuelve cuando el access token no es válido o ha caducado
+var ErrInvalidToken = errors.New("invalid access token")
+
+// Principal identifica al usuario autenticado de una petición
+type Principal struct {
+	UserID   int
+	Username string
+	Roles    []string
+}
+
+// Claims son los claims de los access tokens emitidos por la API
+type Claims struct {
+	Username string   `json:"username"`
+	Roles    []string `json:"roles,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Principal devuelve el usuario autenticado representado por los claims
+func (c *Claims) Principal() (*Principal, error) {
+	id, err := strconv.Atoi(c.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid subject", ErrInvalidToken)
+	}
+	return &Principal{UserID: id, Username: c.Username, Roles: c.Roles}, nil
+}
+
+// TokenManager emite y valida access tokens JWT firmados con HMAC-SHA256
+type TokenManager struct {
+	secret    []byte
+	issuer    string
+	accessTTL time.Duration
+}
+
+// NewTokenManager crea un TokenManager con la clave y duración indicadas
+func NewTokenManager(secret []byte, issuer string, accessTTL time.Duration) *TokenManager {
+	return &TokenManager{
+		secret:    secret,
+		issuer:    issuer,
+		accessTTL: accessTTL,
+	}
+}
+
+// IssueAccessToken genera un access token de vida corta para el usuario
+func (m *TokenManager) IssueAccessToken(user *entities.User) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(m.accessTTL)
+
+	claims := Claims{
+		Username: user.Username,
+		Roles:    user.Roles,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.issuer,
+			Subject:   strconv.Itoa(user.ID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			ID:        randomID(),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign access token: %w", err)
+	}
+
+	return token, expiresAt, nil
+}
+
+// ParseAccessToken valida la firma, el emisor y la caducidad del token y devuelve sus claims
+func (m *TokenManager) ParseAccessToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+
+	_, err := jwt.ParseWithClaims(tokenString, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			return m.secret, nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	return claims, nil
+}
+
+// GenerateSecret genera una clave aleatoria para firmar tokens
+func GenerateSecret() ([]byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// randomID genera un identificador aleatorio para el claim jti
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
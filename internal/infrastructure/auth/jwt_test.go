@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIssueAndParseAccessToken(t *testing.T) {
+	manager := NewTokenManager([]byte("test-secret"), "test-issuer", time.Minute)
+
+	token, expiresAt, err := manager.IssueAccessToken(&entities.User{ID: 42, Username: "alice"})
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), expiresAt, 2*time.Second)
+
+	claims, err := manager.ParseAccessToken(token)
+	assert.NoError(t, err)
+
+	principal, err := claims.Principal()
+	assert.NoError(t, err)
+	assert.Equal(t, 42, principal.UserID)
+	assert.Equal(t, "alice", principal.Username)
+}
+
+func TestParseAccessTokenRejectsInvalidTokens(t *testing.T) {
+	manager := NewTokenManager([]byte("test-secret"), "test-issuer", time.Minute)
+	user := &entities.User{ID: 1, Username: "bob"}
+
+	token, _, err := manager.IssueAccessToken(user)
+	assert.NoError(t, err)
+
+	other := NewTokenManager([]byte("other-secret"), "test-issuer", time.Minute)
+	_, err = other.ParseAccessToken(token)
+	assert.ErrorIs(t, err, ErrInvalidToken, "Should reject tokens signed with another key")
+
+	otherIssuer := NewTokenManager([]byte("test-secret"), "other-issuer", time.Minute)
+	_, err = otherIssuer.ParseAccessToken(token)
+	assert.ErrorIs(t, err, ErrInvalidToken, "Should reject tokens from another issuer")
+
+	expired := NewTokenManager([]byte("test-secret"), "test-issuer", -time.Minute)
+	expiredToken, _, err := expired.IssueAccessToken(user)
+	assert.NoError(t, err)
+	_, err = manager.ParseAccessToken(expiredToken)
+	assert.ErrorIs(t, err, ErrInvalidToken, "Should reject expired tokens")
+
+	parts := strings.Split(token, ".")
+	_, err = manager.ParseAccessToken(parts[0] + "." + parts[1] + ".")
+	assert.ErrorIs(t, err, ErrInvalidToken, "Should reject unsigned tokens")
+}
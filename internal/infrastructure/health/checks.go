@@ -0,0 +1,171 @@
+package health
+
+import (
+	"context"
+	"crypto/x509"
+	"database/sql"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/infraestructure/db"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/subscriber"
+)
+
+// Database comprueba que la base de datos responde a un ping
+func Database(conn *sql.DB) Check {
+	return Check{
+		Name:     "database",
+		Critical: true,
+		Run: func(ctx context.Context) (interface{}, error) {
+			if err := conn.PingContext(ctx); err != nil {
+				return nil, err
+			}
+			stats := conn.Stats()
+			return map[string]int{
+				"open_connections": stats.OpenConnections,
+				"in_use":           stats.InUse,
+				"idle":             stats.Idle,
+			}, nil
+		},
+	}
+}
+
+// Migrations comprueba que el esquema está en la versión que espera el binario
+func Migrations(migrator *db.Migrator) Check {
+	return Check{
+		Name:     "migrations",
+		Critical: true,
+		Run: func(ctx context.Context) (interface{}, error) {
+			version, err := migrator.Version(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return version, checkMigrationVersion(version)
+		},
+	}
+}
+
+func checkMigrationVersion(version db.MigrationVersion) error {
+	if version.Pending > 0 {
+		return fmt.Errorf("hay %d migraciones pendientes", version.Pending)
+	}
+	if version.Unknown > 0 {
+		return Warnf("el esquema tiene %d migraciones que este binario no conoce", version.Unknown)
+	}
+	return nil
+}
+
+// MQTT comprueba la conexión con el broker de la que dependen las suscripciones.
+// Sin suscripciones no se abre la conexión, así que no se considera caída.
+func MQTT(manager *subscriber.SubscriberManager) Check {
+	return Check{
+		Name:     "mqtt",
+		Critical: true,
+		Run: func(ctx context.Context) (interface{}, error) {
+			status := manager.ConnectionStatus()
+			return status, checkConnectionStatus(status)
+		},
+	}
+}
+
+func checkConnectionStatus(status subscriber.ConnectionStatus) error {
+	if len(status.Subscriptions) == 0 {
+		return nil
+	}
+	if !status.Configured {
+		return fmt.Errorf("la conexión MQTT no está configurada")
+	}
+	if !status.Connected {
+		return fmt.Errorf("sin conexión con el broker: %d suscripciones sin recibir mensajes", len(status.Subscriptions))
+	}
+	return nil
+}
+
+// CertificateFile es un certificado PEM que se vigila
+type CertificateFile struct {
+	Name string
+	Path string
+}
+
+// CertificateStatus es la caducidad de un certificado
+type CertificateStatus struct {
+	Path      string    `json:"path"`
+	Subject   string    `json:"subject,omitempty"`
+	NotAfter  time.Time `json:"not_after,omitempty"`
+	ExpiresIn string    `json:"expires_in,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Certificates comprueba la caducidad de los certificados TLS. Uno caducado
+// o ilegible deja el componente caído; uno que caduca antes de warnBefore
+// solo genera un aviso.
+func Certificates(files []CertificateFile, warnBefore time.Duration) Check {
+	return Check{
+		Name:     "certificates",
+		Critical: true,
+		Run: func(ctx context.Context) (interface{}, error) {
+			return checkCertificates(files, warnBefore, time.Now())
+		},
+	}
+}
+
+func checkCertificates(files []CertificateFile, warnBefore time.Duration, now time.Time) (map[string]CertificateStatus, error) {
+	statuses := make(map[string]CertificateStatus, len(files))
+	var failed, expiring []string
+
+	for _, file := range files {
+		status := CertificateStatus{Path: file.Path}
+		cert, err := readCertificate(file.Path)
+		if err != nil {
+			status.Error = err.Error()
+			statuses[file.Name] = status
+			failed = append(failed, file.Name)
+			continue
+		}
+
+		status.Subject = cert.Subject.String()
+		status.NotAfter = cert.NotAfter.UTC()
+		remaining := cert.NotAfter.Sub(now)
+		status.ExpiresIn = remaining.Truncate(time.Second).String()
+		switch {
+		case remaining <= 0:
+			status.Error = "certificado caducado"
+			failed = append(failed, file.Name)
+		case now.Before(cert.NotBefore):
+			status.Error = "certificado todavía no válido"
+			failed = append(failed, file.Name)
+		case remaining < warnBefore:
+			expiring = append(expiring, file.Name)
+		}
+		statuses[file.Name] = status
+	}
+
+	if len(failed) > 0 {
+		return statuses, fmt.Errorf("certificados no válidos: %v", failed)
+	}
+	if len(expiring) > 0 {
+		return statuses, Warnf("certificados a punto de caducar: %v", expiring)
+	}
+	return statuses, nil
+}
+
+// readCertificate lee el primer certificado de un fichero PEM (el de la hoja
+// en las cadenas completas)
+func readCertificate(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("%s no contiene ningún certificado PEM", path)
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
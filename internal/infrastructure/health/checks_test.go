@@ -0,0 +1,89 @@
+package health
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/infraestructure/db"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/subscriber"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCertificate genera un certificado autofirmado válido hasta notAfter
+func writeCertificate(t *testing.T, dir, name string, notAfter time.Time) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, name+".crt")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCheckCertificates(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	valid := CertificateFile{Name: "server", Path: writeCertificate(t, dir, "server", now.Add(90*24*time.Hour))}
+	expiring := CertificateFile{Name: "mqtt_ca", Path: writeCertificate(t, dir, "ca", now.Add(3*24*time.Hour))}
+	expired := CertificateFile{Name: "mqtt_client", Path: writeCertificate(t, dir, "client", now.Add(-time.Hour))}
+	missing := CertificateFile{Name: "missing", Path: filepath.Join(dir, "missing.crt")}
+	warnBefore := 14 * 24 * time.Hour
+
+	statuses, err := checkCertificates([]CertificateFile{valid}, warnBefore, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "CN=server", statuses["server"].Subject)
+	assert.Empty(t, statuses["server"].Error)
+
+	statuses, err = checkCertificates([]CertificateFile{valid, expiring}, warnBefore, now)
+	assert.ErrorIs(t, err, ErrWarning)
+	assert.Contains(t, err.Error(), "mqtt_ca")
+	assert.Empty(t, statuses["mqtt_ca"].Error)
+
+	statuses, err = checkCertificates([]CertificateFile{valid, expiring, expired, missing}, warnBefore, now)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrWarning), "Expired certificates are not a warning")
+	assert.Equal(t, "certificado caducado", statuses["mqtt_client"].Error)
+	assert.NotEmpty(t, statuses["missing"].Error)
+
+	_, err = checkCertificates(nil, warnBefore, now)
+	assert.NoError(t, err)
+}
+
+func TestCheckMigrationVersion(t *testing.T) {
+	assert.NoError(t, checkMigrationVersion(db.MigrationVersion{Current: 9, Latest: 9}))
+	assert.Error(t, checkMigrationVersion(db.MigrationVersion{Current: 8, Latest: 9, Pending: 1}))
+	assert.ErrorIs(t, checkMigrationVersion(db.MigrationVersion{Current: 10, Latest: 9, Unknown: 1}), ErrWarning)
+}
+
+func TestCheckConnectionStatus(t *testing.T) {
+	subscriptions := []subscriber.SubscriptionStatus{{Topic: "sensores/#"}}
+
+	assert.NoError(t, checkConnectionStatus(subscriber.ConnectionStatus{}), "Without subscriptions there is no connection to check")
+	assert.NoError(t, checkConnectionStatus(subscriber.ConnectionStatus{Configured: true, Connected: true, Subscriptions: subscriptions}))
+	assert.Error(t, checkConnectionStatus(subscriber.ConnectionStatus{Configured: true, Subscriptions: subscriptions}))
+	assert.Error(t, checkConnectionStatus(subscriber.ConnectionStatus{Subscriptions: subscriptions}))
+}
@@ -0,0 +1,156 @@
+// Package health comprueba las dependencias de la aplicación (base de datos,
+// migraciones, broker MQTT, certificados) para las sondas de readiness.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Status es el estado de un componente
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusWarn Status = "warn"
+	StatusDown Status = "down"
+)
+
+// Estados globales del informe
+const (
+	ReportOK          = "ok"
+	ReportDegraded    = "degraded"
+	ReportUnavailable = "unavailable"
+)
+
+// ErrWarning marca los errores que no impiden dar servicio (p. ej. un
+// certificado a punto de caducar)
+var ErrWarning = errors.New("aviso")
+
+// Warnf crea un error de aviso: el componente queda en estado "warn"
+func Warnf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrWarning, fmt.Sprintf(format, args...))
+}
+
+// CheckFunc comprueba un componente. Devuelve detalles para el informe y un
+// error si el componente no está disponible.
+type CheckFunc func(ctx context.Context) (interface{}, error)
+
+// Check es una comprobación con nombre. Si es crítica y falla, la aplicación
+// no está lista.
+type Check struct {
+	Name     string
+	Critical bool
+	Run      CheckFunc
+}
+
+// Result es el resultado de una comprobación
+type Result struct {
+	Status     Status      `json:"status"`
+	Critical   bool        `json:"critical"`
+	DurationMs int64       `json:"duration_ms"`
+	Error      string      `json:"error,omitempty"`
+	Details    interface{} `json:"details,omitempty"`
+}
+
+// Report agrupa los resultados de todas las comprobaciones
+type Report struct {
+	Status     string            `json:"status"`
+	CheckedAt  time.Time         `json:"checked_at"`
+	Components map[string]Result `json:"components"`
+}
+
+// Ready indica si ningún componente crítico está caído
+func (r Report) Ready() bool {
+	return r.Status != ReportUnavailable
+}
+
+// Checker ejecuta las comprobaciones en paralelo, cada una con su límite de tiempo
+type Checker struct {
+	checks  []Check
+	timeout time.Duration
+}
+
+// NewChecker crea un checker; timeout limita cada comprobación
+func NewChecker(timeout time.Duration, checks ...Check) *Checker {
+	return &Checker{checks: checks, timeout: timeout}
+}
+
+// Check ejecuta todas las comprobaciones y devuelve el informe
+func (c *Checker) Check(ctx context.Context) Report {
+	report := Report{
+		Status:     ReportOK,
+		CheckedAt:  time.Now().UTC(),
+		Components: make(map[string]Result, len(c.checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range c.checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			result := c.run(ctx, check)
+			mu.Lock()
+			report.Components[check.Name] = result
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	for _, result := range report.Components {
+		switch {
+		case result.Status == StatusDown && result.Critical:
+			report.Status = ReportUnavailable
+		case result.Status != StatusUp && report.Status == ReportOK:
+			report.Status = ReportDegraded
+		}
+	}
+	return report
+}
+
+// run ejecuta una comprobación. Si no termina a tiempo se da por caída,
+// aunque ignore la cancelación del contexto.
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	type outcome struct {
+		details interface{}
+		err     error
+	}
+	done := make(chan outcome, 1)
+	start := time.Now()
+	go func() {
+		details, err := check.Run(ctx)
+		done <- outcome{details, err}
+	}()
+
+	var out outcome
+	select {
+	case out = <-done:
+	case <-ctx.Done():
+		out.err = fmt.Errorf("la comprobación no terminó a tiempo: %w", ctx.Err())
+	}
+
+	result := Result{
+		Status:     StatusUp,
+		Critical:   check.Critical,
+		DurationMs: time.Since(start).Milliseconds(),
+		Details:    out.details,
+	}
+	if out.err != nil {
+		result.Error = out.err.Error()
+		result.Status = StatusDown
+		if errors.Is(out.err, ErrWarning) {
+			result.Status = StatusWarn
+		}
+	}
+	return result
+}
@@ -0,0 +1,58 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func check(name string, critical bool, err error) Check {
+	return Check{Name: name, Critical: critical, Run: func(ctx context.Context) (interface{}, error) {
+		return map[string]string{"name": name}, err
+	}}
+}
+
+func TestCheckerReportStatus(t *testing.T) {
+	ctx := context.Background()
+
+	report := NewChecker(time.Second, check("database", true, nil), check("mqtt", true, nil)).Check(ctx)
+	assert.Equal(t, ReportOK, report.Status)
+	assert.True(t, report.Ready())
+	assert.Equal(t, StatusUp, report.Components["database"].Status)
+	assert.Equal(t, map[string]string{"name": "database"}, report.Components["database"].Details)
+
+	report = NewChecker(time.Second, check("database", true, nil), check("certificates", true, Warnf("caduca pronto"))).Check(ctx)
+	assert.Equal(t, ReportDegraded, report.Status)
+	assert.True(t, report.Ready(), "Warnings do not make the application unavailable")
+	assert.Equal(t, StatusWarn, report.Components["certificates"].Status)
+
+	report = NewChecker(time.Second, check("database", true, nil), check("cache", false, errors.New("caída"))).Check(ctx)
+	assert.Equal(t, ReportDegraded, report.Status)
+	assert.True(t, report.Ready())
+
+	report = NewChecker(time.Second, check("database", true, errors.New("connection refused")), check("cache", false, nil)).Check(ctx)
+	assert.Equal(t, ReportUnavailable, report.Status)
+	assert.False(t, report.Ready())
+	assert.Equal(t, StatusDown, report.Components["database"].Status)
+	assert.Equal(t, "connection refused", report.Components["database"].Error)
+	assert.True(t, report.Components["database"].Critical)
+}
+
+func TestCheckerTimesOutSlowChecks(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	slow := Check{Name: "database", Critical: true, Run: func(ctx context.Context) (interface{}, error) {
+		// Ignora el contexto a propósito
+		<-release
+		return nil, nil
+	}}
+
+	start := time.Now()
+	report := NewChecker(50*time.Millisecond, slow).Check(context.Background())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, ReportUnavailable, report.Status)
+	assert.Contains(t, report.Components["database"].Error, "deadline exceeded")
+}
@@ -0,0 +1,71 @@
+// Package metrics expone las métricas de la aplicación en formato Prometheus:
+// peticiones HTTP, pool de conexiones a la base de datos y actividad MQTT.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/subscriber"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "api"
+
+// Metrics agrupa el registro de métricas y los contadores HTTP
+type Metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+}
+
+// New crea el registro con las métricas del proceso, del pool de conexiones
+// de db y de la actividad MQTT de manager
+func New(db *sql.DB, manager *subscriber.SubscriberManager) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Peticiones HTTP atendidas por ruta, método y código de estado.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Duración de las peticiones HTTP por ruta, método y código de estado.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+	)
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, "postgres"))
+	}
+	if manager != nil {
+		m.registry.MustRegister(newMqttCollector(manager))
+	}
+	return m
+}
+
+// ObserveHTTP registra una petición HTTP atendida
+func (m *Metrics) ObserveHTTP(route, method string, status int, duration time.Duration) {
+	code := strconv.Itoa(status)
+	m.requests.WithLabelValues(route, method, code).Inc()
+	m.requestDuration.WithLabelValues(route, method, code).Observe(duration.Seconds())
+}
+
+// Handler sirve las métricas en el formato de texto de Prometheus
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/subscriber"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestMetricsExposition(t *testing.T) {
+	m := New(nil, subscriber.GetSubscriberManager())
+	m.ObserveHTTP("/api/v1/users/{id:[0-9]+}", http.MethodGet, http.StatusOK, 20*time.Millisecond)
+	m.ObserveHTTP("/api/v1/users/{id:[0-9]+}", http.MethodGet, http.StatusOK, 30*time.Millisecond)
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `api_http_requests_total{method="GET",route="/api/v1/users/{id:[0-9]+}",status="200"} 2`)
+	assert.Contains(t, body, `api_http_request_duration_seconds_bucket{method="GET",route="/api/v1/users/{id:[0-9]+}",status="200",le="0.05"} 2`)
+	assert.Contains(t, body, "api_mqtt_active_subscriptions 0")
+	assert.Contains(t, body, "api_mqtt_connected 0")
+	assert.Contains(t, body, "api_mqtt_stream_clients 0")
+	assert.NotContains(t, body, "api_mqtt_pipeline_queue_depth", "Pipeline metrics are only exposed when it is configured")
+	assert.Contains(t, body, "go_goroutines")
+}
+
+func TestMetricsIncludeDatabasePool(t *testing.T) {
+	// sql.Open no conecta: basta para leer las estadísticas del pool
+	db, err := sql.Open("postgres", "host=localhost dbname=metrics_test sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	body := scrape(t, New(db, nil))
+	assert.Contains(t, body, `go_sql_open_connections{db_name="postgres"} 0`)
+	assert.Contains(t, body, `go_sql_max_open_connections{db_name="postgres"}`)
+	assert.NotContains(t, body, "api_mqtt_")
+}
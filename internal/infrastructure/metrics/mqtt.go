@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/subscriber"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// mqttCollector lee en cada scrape los contadores del SubscriberManager, del
+// pipeline de persistencia y del stream en vivo, que ya se mantienen sin
+// bloqueos en sus paquetes
+type mqttCollector struct {
+	manager *subscriber.SubscriberManager
+
+	received            *prometheus.Desc
+	persisted           *prometheus.Desc
+	failed              *prometheus.Desc
+	connected           *prometheus.Desc
+	connectionLost      *prometheus.Desc
+	reconnects          *prometheus.Desc
+	activeSubscriptions *prometheus.Desc
+
+	queueDepth    *prometheus.Desc
+	queueCapacity *prometheus.Desc
+	pipeline      *prometheus.Desc
+	flushes       *prometheus.Desc
+	flushErrors   *prometheus.Desc
+
+	streamClients   *prometheus.Desc
+	streamDelivered *prometheus.Desc
+	streamEvicted   *prometheus.Desc
+}
+
+func newMqttCollector(manager *subscriber.SubscriberManager) *mqttCollector {
+	desc := func(subsystem, name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, name), help, labels, nil)
+	}
+
+	return &mqttCollector{
+		manager: manager,
+
+		received:            desc("mqtt", "messages_received_total", "Mensajes MQTT recibidos por suscripción.", "topic"),
+		persisted:           desc("mqtt", "messages_persisted_total", "Mensajes MQTT guardados en base de datos por suscripción.", "topic"),
+		failed:              desc("mqtt", "messages_failed_total", "Mensajes MQTT perdidos sin guardarse por suscripción.", "topic"),
+		connected:           desc("mqtt", "connected", "1 si la conexión con el broker está abierta."),
+		connectionLost:      desc("mqtt", "connection_lost_total", "Veces que se perdió la conexión con el broker."),
+		reconnects:          desc("mqtt", "reconnects_total", "Reconexiones con el broker que renovaron las suscripciones."),
+		activeSubscriptions: desc("mqtt", "active_subscriptions", "Suscripciones MQTT activas."),
+
+		queueDepth:    desc("mqtt_pipeline", "queue_depth", "Mensajes en la cola del pipeline pendientes de guardar."),
+		queueCapacity: desc("mqtt_pipeline", "queue_capacity", "Capacidad de la cola del pipeline."),
+		pipeline:      desc("mqtt_pipeline", "messages_total", "Mensajes procesados por el pipeline según su resultado.", "result"),
+		flushes:       desc("mqtt_pipeline", "flushes_total", "Escrituras de lotes en base de datos."),
+		flushErrors:   desc("mqtt_pipeline", "flush_errors_total", "Escrituras de lotes que fallaron."),
+
+		streamClients:   desc("mqtt_stream", "clients", "Clientes conectados al stream en vivo (SSE y WebSocket)."),
+		streamDelivered: desc("mqtt_stream", "delivered_total", "Mensajes entregados a los clientes del stream."),
+		streamEvicted:   desc("mqtt_stream", "evicted_total", "Clientes del stream expulsados por no consumir a tiempo."),
+	}
+}
+
+// Describe implementa prometheus.Collector
+func (c *mqttCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		c.received, c.persisted, c.failed, c.connected, c.connectionLost, c.reconnects, c.activeSubscriptions,
+		c.queueDepth, c.queueCapacity, c.pipeline, c.flushes, c.flushErrors,
+		c.streamClients, c.streamDelivered, c.streamEvicted,
+	} {
+		ch <- d
+	}
+}
+
+// Collect implementa prometheus.Collector
+func (c *mqttCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.manager.Stats()
+	for _, topic := range stats.Topics {
+		ch <- counter(c.received, topic.Received, topic.Topic)
+		ch <- counter(c.persisted, topic.Persisted, topic.Topic)
+		ch <- counter(c.failed, topic.Failed, topic.Topic)
+	}
+	connected := 0.0
+	if stats.Connected {
+		connected = 1
+	}
+	ch <- prometheus.MustNewConstMetric(c.connected, prometheus.GaugeValue, connected)
+	ch <- counter(c.connectionLost, stats.ConnectionLost)
+	ch <- counter(c.reconnects, stats.Reconnects)
+	ch <- prometheus.MustNewConstMetric(c.activeSubscriptions, prometheus.GaugeValue, float64(stats.ActiveSubscriptions))
+
+	if pipeline, ok := c.manager.PipelineStats(); ok {
+		ch <- prometheus.MustNewConstMetric(c.queueDepth, prometheus.GaugeValue, float64(pipeline.QueueDepth))
+		ch <- prometheus.MustNewConstMetric(c.queueCapacity, prometheus.GaugeValue, float64(pipeline.QueueCapacity))
+		ch <- counter(c.pipeline, pipeline.Enqueued, "enqueued")
+		ch <- counter(c.pipeline, pipeline.Persisted, "persisted")
+		ch <- counter(c.pipeline, pipeline.Dropped, "dropped")
+		ch <- counter(c.pipeline, pipeline.Spilled, "spilled")
+		ch <- counter(c.pipeline, pipeline.Replayed, "replayed")
+		ch <- counter(c.pipeline, pipeline.Failed, "failed")
+		ch <- counter(c.flushes, pipeline.Flushes)
+		ch <- counter(c.flushErrors, pipeline.FlushErrors)
+	}
+
+	stream := c.manager.Stream().Stats()
+	ch <- prometheus.MustNewConstMetric(c.streamClients, prometheus.GaugeValue, float64(stream.Clients))
+	ch <- counter(c.streamDelivered, stream.Delivered)
+	ch <- counter(c.streamEvicted, stream.Evicted)
+}
+
+func counter(desc *prometheus.Desc, value uint64, labels ...string) prometheus.Metric {
+	return prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(value), labels...)
+}
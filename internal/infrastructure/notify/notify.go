@@ -0,0 +1,112 @@
+// Package notify entrega las notificaciones de las alertas MQTT a sus
+// destinos: el log, un webhook HTTP o un topic MQTT.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
+	"github.com/rs/zerolog/log"
+)
+
+// LogSink escribe las notificaciones en el log de la aplicación
+type LogSink struct{}
+
+// NewLogSink crea el destino de log
+func NewLogSink() *LogSink {
+	return &LogSink{}
+}
+
+// Notify escribe la notificación: como error si la alerta se dispara y como
+// información si se resuelve
+func (s *LogSink) Notify(ctx context.Context, sink entities.AlertSinkConfig, n entities.AlertNotification) error {
+	event := log.Info()
+	message := "✅ Alerta MQTT resuelta"
+	if n.Status == entities.AlertFiring {
+		event = log.Error()
+		message = "🚨 Alerta MQTT disparada"
+	}
+
+	event.
+		Int("rule_id", n.RuleID).
+		Str("rule", n.RuleName).
+		Str("topic", n.Topic).
+		Str("field", n.Field).
+		Str("comparator", n.Comparator).
+		Float64("threshold", n.Threshold).
+		Float64("value", n.Value).
+		Time("at", n.At).
+		Msg(message)
+	return nil
+}
+
+// WebhookSink envía las notificaciones como JSON en un POST a la URL del destino
+type WebhookSink struct {
+	client *http.Client
+}
+
+// NewWebhookSink crea el destino webhook; timeout limita cada petición
+func NewWebhookSink(timeout time.Duration) *WebhookSink {
+	return &WebhookSink{client: &http.Client{Timeout: timeout}}
+}
+
+// Notify envía la notificación; cualquier respuesta que no sea 2xx es un error
+func (s *WebhookSink) Notify(ctx context.Context, sink entities.AlertSinkConfig, n entities.AlertNotification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sink.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("petición al webhook no válida: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error llamando al webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	// Se consume el cuerpo para poder reutilizar la conexión
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("el webhook respondió %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Publisher publica mensajes en el broker MQTT; lo implementa el SubscriberManager
+type Publisher interface {
+	Publish(ctx context.Context, topic string, payload []byte, qos byte, retain, record bool) (models.MqttMessage, error)
+}
+
+// MqttSink publica las notificaciones como JSON en el topic del destino
+type MqttSink struct {
+	publisher Publisher
+}
+
+// NewMqttSink crea el destino MQTT
+func NewMqttSink(publisher Publisher) *MqttSink {
+	return &MqttSink{publisher: publisher}
+}
+
+// Notify publica la notificación con el QoS y retain del destino. No se guarda
+// en el historial de mensajes.
+func (s *MqttSink) Notify(ctx context.Context, sink entities.AlertSinkConfig, n entities.AlertNotification) error {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.publisher.Publish(ctx, sink.Topic, payload, sink.QOS, sink.Retain, false)
+	return err
+}
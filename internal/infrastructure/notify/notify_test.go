@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookSinkPostsNotification(t *testing.T) {
+	var received entities.AlertNotification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(time.Second)
+	notification := entities.AlertNotification{RuleID: 1, RuleName: "calor", Topic: "sensores/sala1", Status: entities.AlertFiring, Value: 31}
+	err := sink.Notify(context.Background(), entities.AlertSinkConfig{Type: entities.AlertSinkWebhook, URL: server.URL}, notification)
+
+	assert.NoError(t, err)
+	assert.Equal(t, notification, received)
+}
+
+func TestWebhookSinkFailsOnErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	err := NewWebhookSink(time.Second).Notify(context.Background(),
+		entities.AlertSinkConfig{Type: entities.AlertSinkWebhook, URL: server.URL}, entities.AlertNotification{})
+	assert.Error(t, err)
+}
+
+// fakePublisher anota los mensajes publicados
+type fakePublisher struct {
+	topic   string
+	payload []byte
+	qos     byte
+	retain  bool
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, topic string, payload []byte, qos byte, retain, record bool) (models.MqttMessage, error) {
+	p.topic, p.payload, p.qos, p.retain = topic, payload, qos, retain
+	return models.MqttMessage{}, nil
+}
+
+func TestMqttSinkPublishesNotification(t *testing.T) {
+	publisher := &fakePublisher{}
+	notification := entities.AlertNotification{RuleID: 1, Topic: "sensores/sala1", Status: entities.AlertResolved}
+	err := NewMqttSink(publisher).Notify(context.Background(),
+		entities.AlertSinkConfig{Type: entities.AlertSinkMqtt, Topic: "alertas/calor", QOS: 1, Retain: true}, notification)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "alertas/calor", publisher.topic)
+	assert.Equal(t, byte(1), publisher.qos)
+	assert.True(t, publisher.retain)
+
+	var published entities.AlertNotification
+	assert.NoError(t, json.Unmarshal(publisher.payload, &published))
+	assert.Equal(t, notification, published)
+}
@@ -0,0 +1,197 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+)
+
+// AlertRuleRepository implementa el repositorio de reglas de alerta con SQL directo
+type AlertRuleRepository struct {
+	db *sql.DB
+}
+
+// NewAlertRuleRepository crea una nueva instancia del repositorio
+func NewAlertRuleRepository(db *sql.DB) *AlertRuleRepository {
+	return &AlertRuleRepository{db: db}
+}
+
+// Create guarda una regla nueva
+func (r *AlertRuleRepository) Create(ctx context.Context, rule *entities.AlertRule) error {
+	sinks, err := json.Marshal(rule.Sinks)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert sinks: %w", err)
+	}
+
+	query := `
+        INSERT INTO alert_rules (name, topic_filter, field, comparator, threshold, window_seconds, cooldown_seconds, sinks, enabled)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        RETURNING id, created_at, updated_at
+    `
+
+	err = r.db.QueryRowContext(ctx, query, rule.Name, rule.TopicFilter, rule.Field, rule.Comparator, rule.Threshold,
+		rule.WindowSeconds, rule.CooldownSeconds, sinks, rule.Enabled).
+		Scan(&rule.ID, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create alert rule: %w", err)
+	}
+
+	return nil
+}
+
+// Update guarda los cambios de una regla; devuelve false si no existía
+func (r *AlertRuleRepository) Update(ctx context.Context, rule *entities.AlertRule) (bool, error) {
+	sinks, err := json.Marshal(rule.Sinks)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode alert sinks: %w", err)
+	}
+
+	query := `
+        UPDATE alert_rules
+        SET name = $2, topic_filter = $3, field = $4, comparator = $5, threshold = $6,
+            window_seconds = $7, cooldown_seconds = $8, sinks = $9, enabled = $10, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1
+        RETURNING created_at, updated_at
+    `
+
+	err = r.db.QueryRowContext(ctx, query, rule.ID, rule.Name, rule.TopicFilter, rule.Field, rule.Comparator, rule.Threshold,
+		rule.WindowSeconds, rule.CooldownSeconds, sinks, rule.Enabled).
+		Scan(&rule.CreatedAt, &rule.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update alert rule: %w", err)
+	}
+
+	return true, nil
+}
+
+// Delete elimina una regla; el estado de sus alertas se borra en cascada
+func (r *AlertRuleRepository) Delete(ctx context.Context, id int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM alert_rules WHERE id = $1`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete alert rule: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete alert rule: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// List obtiene todas las reglas ordenadas por nombre
+func (r *AlertRuleRepository) List(ctx context.Context) ([]*entities.AlertRule, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, name, topic_filter, field, comparator, threshold, window_seconds, cooldown_seconds, sinks, enabled, created_at, updated_at
+        FROM alert_rules
+        ORDER BY name
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alert rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []*entities.AlertRule{}
+	for rows.Next() {
+		rule := &entities.AlertRule{}
+		var sinks []byte
+		if err := rows.Scan(&rule.ID, &rule.Name, &rule.TopicFilter, &rule.Field, &rule.Comparator, &rule.Threshold,
+			&rule.WindowSeconds, &rule.CooldownSeconds, &sinks, &rule.Enabled, &rule.CreatedAt, &rule.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan alert rule: %w", err)
+		}
+		if err := json.Unmarshal(sinks, &rule.Sinks); err != nil {
+			return nil, fmt.Errorf("failed to decode sinks of alert rule %d: %w", rule.ID, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// AlertStateRepository implementa el repositorio del estado de las alertas con SQL directo
+type AlertStateRepository struct {
+	db *sql.DB
+}
+
+// NewAlertStateRepository crea una nueva instancia del repositorio
+func NewAlertStateRepository(db *sql.DB) *AlertStateRepository {
+	return &AlertStateRepository{db: db}
+}
+
+// List obtiene el estado de las alertas; con status solo las que están en ese estado
+func (r *AlertStateRepository) List(ctx context.Context, status entities.AlertStatus) ([]*entities.AlertState, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT rule_id, topic, status, value, pending_since, fired_at, resolved_at, updated_at
+        FROM alert_states
+        WHERE $1 = '' OR status = $1
+        ORDER BY updated_at DESC
+    `, string(status))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alert states: %w", err)
+	}
+	defer rows.Close()
+
+	states := []*entities.AlertState{}
+	for rows.Next() {
+		state := &entities.AlertState{}
+		var value sql.NullFloat64
+		var pendingSince, firedAt, resolvedAt sql.NullTime
+		if err := rows.Scan(&state.RuleID, &state.Topic, &state.Status, &value, &pendingSince, &firedAt, &resolvedAt, &state.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan alert state: %w", err)
+		}
+		if value.Valid {
+			state.Value = &value.Float64
+		}
+		if pendingSince.Valid {
+			state.PendingSince = &pendingSince.Time
+		}
+		if firedAt.Valid {
+			state.FiredAt = &firedAt.Time
+		}
+		if resolvedAt.Valid {
+			state.ResolvedAt = &resolvedAt.Time
+		}
+		states = append(states, state)
+	}
+
+	return states, rows.Err()
+}
+
+// Save guarda el estado de la alerta de una regla para un topic
+func (r *AlertStateRepository) Save(ctx context.Context, state *entities.AlertState) error {
+	query := `
+        INSERT INTO alert_states (rule_id, topic, status, value, pending_since, fired_at, resolved_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        ON CONFLICT (rule_id, topic) DO UPDATE
+        SET status = EXCLUDED.status, value = EXCLUDED.value, pending_since = EXCLUDED.pending_since,
+            fired_at = EXCLUDED.fired_at, resolved_at = EXCLUDED.resolved_at, updated_at = EXCLUDED.updated_at
+    `
+
+	if _, err := r.db.ExecContext(ctx, query, state.RuleID, state.Topic, string(state.Status), state.Value,
+		state.PendingSince, state.FiredAt, state.ResolvedAt, state.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to save alert state: %w", err)
+	}
+	return nil
+}
+
+// Delete elimina el estado de la alerta de una regla para un topic
+func (r *AlertStateRepository) Delete(ctx context.Context, ruleID int, topic string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM alert_states WHERE rule_id = $1 AND topic = $2`, ruleID, topic); err != nil {
+		return fmt.Errorf("failed to delete alert state: %w", err)
+	}
+	return nil
+}
+
+// DeleteByRule elimina el estado de todas las alertas de una regla
+func (r *AlertStateRepository) DeleteByRule(ctx context.Context, ruleID int) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM alert_states WHERE rule_id = $1`, ruleID); err != nil {
+		return fmt.Errorf("failed to delete alert states: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/mqtttopic"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/tracing"
+	"github.com/lib/pq"
+)
+
+// MessageRetentionRepository consulta y elimina mensajes de mqtt_messages según
+// las reglas de retención. Recorre los mensajes en el orden (received_at, id),
+// que tiene índice, así que cada lote de borrado lee solo las filas que borra.
+type MessageRetentionRepository struct {
+	db *sql.DB
+}
+
+// NewMessageRetentionRepository crea una nueva instancia del repositorio
+func NewMessageRetentionRepository(db *sql.DB) *MessageRetentionRepository {
+	return &MessageRetentionRepository{db: db}
+}
+
+// OldestKept devuelve la posición del mensaje número keep empezando por el
+// más reciente, o nil si el ámbito tiene menos mensajes
+func (r *MessageRetentionRepository) OldestKept(ctx context.Context, scope entities.RetentionScope, keep int64) (_ *entities.MessagePosition, err error) {
+	ctx, span := tracing.StartDB(ctx, "MessageRetentionRepository.OldestKept", "SELECT", "mqtt_messages")
+	defer tracing.End(span, &err)
+
+	var args []interface{}
+	where := scopeCondition(scope, &args)
+	args = append(args, keep-1)
+	query := fmt.Sprintf(`SELECT received_at, id FROM mqtt_messages WHERE %s
+        ORDER BY received_at DESC, id DESC OFFSET $%d LIMIT 1`, where, len(args))
+
+	position := &entities.MessagePosition{}
+	err = r.db.QueryRowContext(ctx, query, args...).Scan(&position.ReceivedAt, &position.ID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find retention boundary: %w", err)
+	}
+	return position, nil
+}
+
+// CountBefore cuenta los mensajes del ámbito anteriores a la posición
+func (r *MessageRetentionRepository) CountBefore(ctx context.Context, scope entities.RetentionScope, before entities.MessagePosition) (_ int64, err error) {
+	ctx, span := tracing.StartDB(ctx, "MessageRetentionRepository.CountBefore", "SELECT", "mqtt_messages")
+	defer tracing.End(span, &err)
+
+	var args []interface{}
+	where := scopeCondition(scope, &args) + " AND " + beforeCondition(before, &args)
+
+	var count int64
+	if err := r.db.QueryRowContext(ctx, `SELECT count(*) FROM mqtt_messages WHERE `+where, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count expired messages: %w", err)
+	}
+	return count, nil
+}
+
+// DeleteBefore elimina como mucho limit mensajes del ámbito anteriores a la
+// posición, empezando por los más antiguos. Cada llamada es una transacción
+// corta, para no bloquear la tabla mientras se purga.
+func (r *MessageRetentionRepository) DeleteBefore(ctx context.Context, scope entities.RetentionScope, before entities.MessagePosition, limit int) (_ int64, err error) {
+	ctx, span := tracing.StartDB(ctx, "MessageRetentionRepository.DeleteBefore", "DELETE", "mqtt_messages")
+	defer tracing.End(span, &err)
+
+	var args []interface{}
+	where := scopeCondition(scope, &args) + " AND " + beforeCondition(before, &args)
+	args = append(args, limit)
+	// Con la tabla particionada, comparar también received_at deja borrar en
+	// las particiones del lote sin recorrer el resto
+	query := fmt.Sprintf(`DELETE FROM mqtt_messages WHERE (id, received_at) IN (
+        SELECT id, received_at FROM mqtt_messages WHERE %s ORDER BY received_at, id LIMIT $%d)`, where, len(args))
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired messages: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired messages: %w", err)
+	}
+	return deleted, nil
+}
+
+// scopeCondition filtra los topics que coinciden con el filtro de la regla y
+// con ninguno de los excluidos
+func scopeCondition(scope entities.RetentionScope, args *[]interface{}) string {
+	*args = append(*args, mqtttopic.Regexp(scope.TopicFilter))
+	condition := fmt.Sprintf("topic ~ $%d", len(*args))
+
+	if len(scope.Exclude) > 0 {
+		patterns := make([]string, len(scope.Exclude))
+		for i, filter := range scope.Exclude {
+			patterns[i] = mqtttopic.Regexp(filter)
+		}
+		*args = append(*args, pq.Array(patterns))
+		condition += fmt.Sprintf(" AND NOT topic ~ ANY($%d)", len(*args))
+	}
+	return condition
+}
+
+// beforeCondition filtra los mensajes anteriores a la posición
+func beforeCondition(before entities.MessagePosition, args *[]interface{}) string {
+	*args = append(*args, before.ReceivedAt, before.ID)
+	return fmt.Sprintf("(received_at, id) < ($%d, $%d)", len(*args)-1, len(*args))
+}
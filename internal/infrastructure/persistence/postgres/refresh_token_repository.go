@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+)
+
+// RefreshTokenRepository implementa el repositorio de refresh tokens con SQL directo
+type RefreshTokenRepository struct {
+	db *sql.DB
+}
+
+// NewRefreshTokenRepository crea una nueva instancia del repositorio
+func NewRefreshTokenRepository(db *sql.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{db: db}
+}
+
+// Create guarda un nuevo refresh token
+func (r *RefreshTokenRepository) Create(ctx context.Context, token *entities.RefreshToken) error {
+	query := `
+        INSERT INTO refresh_tokens (user_id, token_hash, family_id, expires_at)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, created_at
+    `
+
+	err := r.db.QueryRowContext(ctx, query,
+		token.UserID,
+		token.TokenHash,
+		token.FamilyID,
+		token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// GetByHash obtiene un refresh token por el hash de su valor
+func (r *RefreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*entities.RefreshToken, error) {
+	query := `
+        SELECT id, user_id, token_hash, family_id, expires_at, revoked_at, replaced_by, created_at
+        FROM refresh_tokens
+        WHERE token_hash = $1
+    `
+
+	var token entities.RefreshToken
+	var revokedAt sql.NullTime
+	var replacedBy sql.NullInt64
+
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.FamilyID,
+		&token.ExpiresAt,
+		&revokedAt,
+		&replacedBy,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // No encontrado
+		}
+		return nil, fmt.Errorf("failed to find refresh token: %w", err)
+	}
+
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	if replacedBy.Valid {
+		id := int(replacedBy.Int64)
+		token.ReplacedBy = &id
+	}
+
+	return &token, nil
+}
+
+// Rotate revoca el token indicando cuál lo sustituye. Devuelve false si ya estaba revocado,
+// lo que permite detectar dos rotaciones concurrentes del mismo token.
+func (r *RefreshTokenRepository) Rotate(ctx context.Context, id, replacedBy int) (bool, error) {
+	query := `
+        UPDATE refresh_tokens
+        SET revoked_at = CURRENT_TIMESTAMP, replaced_by = $2
+        WHERE id = $1 AND revoked_at IS NULL
+    `
+
+	result, err := r.db.ExecContext(ctx, query, id, replacedBy)
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	return affected == 1, nil
+}
+
+// RevokeFamily revoca todos los tokens activos de una cadena de rotación
+func (r *RefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	query := `
+        UPDATE refresh_tokens
+        SET revoked_at = CURRENT_TIMESTAMP
+        WHERE family_id = $1 AND revoked_at IS NULL
+    `
+
+	if _, err := r.db.ExecContext(ctx, query, familyID); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+
+	return nil
+}
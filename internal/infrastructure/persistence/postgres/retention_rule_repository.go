@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+)
+
+// RetentionRuleRepository implementa el repositorio de reglas de retención con SQL directo
+type RetentionRuleRepository struct {
+	db *sql.DB
+}
+
+// NewRetentionRuleRepository crea una nueva instancia del repositorio
+func NewRetentionRuleRepository(db *sql.DB) *RetentionRuleRepository {
+	return &RetentionRuleRepository{db: db}
+}
+
+// Create guarda una regla nueva
+func (r *RetentionRuleRepository) Create(ctx context.Context, rule *entities.RetentionRule) error {
+	query := `
+        INSERT INTO mqtt_retention_rules (topic_filter, max_age_seconds, max_rows)
+        VALUES ($1, $2, $3)
+        RETURNING id, created_at, updated_at
+    `
+
+	err := r.db.QueryRowContext(ctx, query, rule.TopicFilter, rule.MaxAgeSeconds, rule.MaxRows).
+		Scan(&rule.ID, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create retention rule: %w", err)
+	}
+
+	return nil
+}
+
+// Update guarda los cambios de una regla; devuelve false si no existía
+func (r *RetentionRuleRepository) Update(ctx context.Context, rule *entities.RetentionRule) (bool, error) {
+	query := `
+        UPDATE mqtt_retention_rules
+        SET topic_filter = $2, max_age_seconds = $3, max_rows = $4, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1
+        RETURNING created_at, updated_at
+    `
+
+	err := r.db.QueryRowContext(ctx, query, rule.ID, rule.TopicFilter, rule.MaxAgeSeconds, rule.MaxRows).
+		Scan(&rule.CreatedAt, &rule.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update retention rule: %w", err)
+	}
+
+	return true, nil
+}
+
+// Delete elimina una regla; devuelve false si no existía
+func (r *RetentionRuleRepository) Delete(ctx context.Context, id int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM mqtt_retention_rules WHERE id = $1`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete retention rule: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete retention rule: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// List obtiene todas las reglas ordenadas por filtro
+func (r *RetentionRuleRepository) List(ctx context.Context) ([]*entities.RetentionRule, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, topic_filter, max_age_seconds, max_rows, created_at, updated_at
+        FROM mqtt_retention_rules
+        ORDER BY topic_filter
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list retention rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []*entities.RetentionRule{}
+	for rows.Next() {
+		rule := &entities.RetentionRule{}
+		var maxAge, maxRows sql.NullInt64
+		if err := rows.Scan(&rule.ID, &rule.TopicFilter, &maxAge, &maxRows, &rule.CreatedAt, &rule.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan retention rule: %w", err)
+		}
+		if maxAge.Valid {
+			rule.MaxAgeSeconds = &maxAge.Int64
+		}
+		if maxRows.Valid {
+			rule.MaxRows = &maxRows.Int64
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
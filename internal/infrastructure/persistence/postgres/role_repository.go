@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/repositories"
+)
+
+// RoleRepository implementa el repositorio de roles con SQL directo
+type RoleRepository struct {
+	db *sql.DB
+}
+
+// NewRoleRepository crea una nueva instancia del repositorio
+func NewRoleRepository(db *sql.DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+// GetUserRoles obtiene los nombres de los roles asignados a un usuario
+func (r *RoleRepository) GetUserRoles(ctx context.Context, userID int) ([]string, error) {
+	query := `
+        SELECT r.name
+        FROM user_roles ur
+        JOIN roles r ON r.id = ur.role_id
+        WHERE ur.user_id = $1
+        ORDER BY r.name
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user roles: %w", err)
+	}
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, fmt.Errorf("failed to scan user role: %w", err)
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+// AssignRole asigna un rol a un usuario (no falla si ya lo tenía)
+func (r *RoleRepository) AssignRole(ctx context.Context, userID int, role string) error {
+	query := `
+        INSERT INTO user_roles (user_id, role_id)
+        SELECT $1, id FROM roles WHERE name = $2
+        ON CONFLICT (user_id, role_id) DO NOTHING
+    `
+
+	result, err := r.db.ExecContext(ctx, query, userID, role)
+	if err != nil {
+		return fmt.Errorf("failed to assign role: %w", err)
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		// Puede que el rol no exista o que el usuario ya lo tuviera
+		var exists bool
+		if err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM roles WHERE name = $1)`, role).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check role: %w", err)
+		}
+		if !exists {
+			return repositories.ErrRoleNotFound
+		}
+	}
+
+	return nil
+}
+
+// RevokeRole retira un rol a un usuario
+func (r *RoleRepository) RevokeRole(ctx context.Context, userID int, role string) error {
+	query := `
+        DELETE FROM user_roles
+        WHERE user_id = $1 AND role_id = (SELECT id FROM roles WHERE name = $2)
+    `
+
+	if _, err := r.db.ExecContext(ctx, query, userID, role); err != nil {
+		return fmt.Errorf("failed to revoke role: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+	"github.com/lib/pq"
+)
+
+// RollupConfigRepository implementa el repositorio de configuraciones de agregados con SQL directo
+type RollupConfigRepository struct {
+	db *sql.DB
+}
+
+// NewRollupConfigRepository crea una nueva instancia del repositorio
+func NewRollupConfigRepository(db *sql.DB) *RollupConfigRepository {
+	return &RollupConfigRepository{db: db}
+}
+
+// Create guarda una configuración nueva
+func (r *RollupConfigRepository) Create(ctx context.Context, config *entities.RollupConfig) error {
+	query := `
+        INSERT INTO mqtt_rollup_configs (topic_filter, fields)
+        VALUES ($1, $2)
+        RETURNING id, created_at, updated_at
+    `
+
+	err := r.db.QueryRowContext(ctx, query, config.TopicFilter, pq.Array(config.Fields)).
+		Scan(&config.ID, &config.CreatedAt, &config.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create rollup config: %w", err)
+	}
+
+	return nil
+}
+
+// Update guarda los cambios de una configuración; devuelve false si no existía
+func (r *RollupConfigRepository) Update(ctx context.Context, config *entities.RollupConfig) (bool, error) {
+	query := `
+        UPDATE mqtt_rollup_configs
+        SET topic_filter = $2, fields = $3, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1
+        RETURNING created_at, updated_at
+    `
+
+	err := r.db.QueryRowContext(ctx, query, config.ID, config.TopicFilter, pq.Array(config.Fields)).
+		Scan(&config.CreatedAt, &config.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update rollup config: %w", err)
+	}
+
+	return true, nil
+}
+
+// Delete elimina una configuración; sus watermarks se borran en cascada. Los
+// agregados ya calculados se conservan.
+func (r *RollupConfigRepository) Delete(ctx context.Context, id int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM mqtt_rollup_configs WHERE id = $1`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete rollup config: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete rollup config: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// List obtiene todas las configuraciones ordenadas por filtro, con sus watermarks
+func (r *RollupConfigRepository) List(ctx context.Context) ([]*entities.RollupConfig, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, topic_filter, fields, created_at, updated_at
+        FROM mqtt_rollup_configs
+        ORDER BY topic_filter
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rollup configs: %w", err)
+	}
+	defer rows.Close()
+
+	configs := []*entities.RollupConfig{}
+	byID := map[int]*entities.RollupConfig{}
+	for rows.Next() {
+		config := &entities.RollupConfig{Watermarks: map[string]time.Time{}}
+		if err := rows.Scan(&config.ID, &config.TopicFilter, pq.Array(&config.Fields), &config.CreatedAt, &config.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan rollup config: %w", err)
+		}
+		configs = append(configs, config)
+		byID[config.ID] = config
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadWatermarks(ctx, byID); err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// loadWatermarks completa los watermarks de las configuraciones
+func (r *RollupConfigRepository) loadWatermarks(ctx context.Context, byID map[int]*entities.RollupConfig) error {
+	rows, err := r.db.QueryContext(ctx, `SELECT config_id, resolution, watermark FROM mqtt_rollup_watermarks`)
+	if err != nil {
+		return fmt.Errorf("failed to list rollup watermarks: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var configID int
+		var resolution string
+		var watermark time.Time
+		if err := rows.Scan(&configID, &resolution, &watermark); err != nil {
+			return fmt.Errorf("failed to scan rollup watermark: %w", err)
+		}
+		if config, ok := byID[configID]; ok {
+			config.Watermarks[resolution] = watermark
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,138 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/mqtttopic"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/repository"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/tracing"
+	"github.com/lib/pq"
+)
+
+// RollupRepository calcula los agregados de mqtt_messages en las tablas
+// mqtt_rollups_*. Cada intervalo se calcula entero y sustituye al que hubiera,
+// así que volver a calcular un rango (p. ej. tras un reinicio a mitad de un
+// lote) no duplica valores.
+type RollupRepository struct {
+	db *sql.DB
+}
+
+// NewRollupRepository crea una nueva instancia del repositorio
+func NewRollupRepository(db *sql.DB) *RollupRepository {
+	return &RollupRepository{db: db}
+}
+
+// OldestMessage devuelve cuándo se recibió el mensaje más antiguo de los
+// topics del filtro, o nil si no hay ninguno
+func (r *RollupRepository) OldestMessage(ctx context.Context, topicFilter string) (_ *time.Time, err error) {
+	ctx, span := tracing.StartDB(ctx, "RollupRepository.OldestMessage", "SELECT", "mqtt_messages")
+	defer tracing.End(span, &err)
+
+	var oldest sql.NullTime
+	err = r.db.QueryRowContext(ctx, `SELECT min(received_at) FROM mqtt_messages WHERE topic ~ $1`,
+		mqtttopic.Regexp(topicFilter)).Scan(&oldest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find oldest message: %w", err)
+	}
+	if !oldest.Valid {
+		return nil, nil
+	}
+	return &oldest.Time, nil
+}
+
+// Rollup calcula los agregados de la resolución en [from, to) a partir de la
+// resolución anterior (o de los mensajes si es la primera) y deja el watermark
+// de la configuración en to, todo en una transacción
+func (r *RollupRepository) Rollup(ctx context.Context, config *entities.RollupConfig, resolution repository.RollupResolution, from, to time.Time) (err error) {
+	ctx, span := tracing.StartDB(ctx, "RollupRepository.Rollup", "INSERT", resolution.Table)
+	defer tracing.End(span, &err)
+
+	query, err := rollupQuery(resolution)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, query, mqtttopic.Regexp(config.TopicFilter), pq.Array(config.Fields),
+		from, to, int64(resolution.Duration/time.Second)); err != nil {
+		return fmt.Errorf("failed to roll up %s: %w", resolution.Table, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+        INSERT INTO mqtt_rollup_watermarks (config_id, resolution, watermark)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (config_id, resolution) DO UPDATE SET watermark = EXCLUDED.watermark
+    `, config.ID, resolution.Name, to); err != nil {
+		return fmt.Errorf("failed to save rollup watermark: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// ResetWatermarks borra los watermarks de la configuración para que sus
+// agregados se vuelvan a calcular desde el principio
+func (r *RollupRepository) ResetWatermarks(ctx context.Context, configID int) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM mqtt_rollup_watermarks WHERE config_id = $1`, configID); err != nil {
+		return fmt.Errorf("failed to reset rollup watermarks: %w", err)
+	}
+	return nil
+}
+
+// rollupQuery devuelve la sentencia que calcula una resolución. Sus parámetros
+// son la expresión regular de los topics, los campos, from, to y la duración
+// del intervalo en segundos.
+func rollupQuery(resolution repository.RollupResolution) (string, error) {
+	index := -1
+	for i, known := range repository.RollupResolutions {
+		if known.Name == resolution.Name {
+			index = i
+		}
+	}
+	if index < 0 {
+		return "", fmt.Errorf("unknown rollup resolution: %s", resolution.Name)
+	}
+
+	upsert := `
+        ON CONFLICT (field, topic, bucket) DO UPDATE
+        SET count = EXCLUDED.count, sum = EXCLUDED.sum, min = EXCLUDED.min, max = EXCLUDED.max
+    `
+	target := pq.QuoteIdentifier(resolution.Table)
+
+	// La primera resolución se calcula a partir de los mensajes
+	if index == 0 {
+		return `
+            INSERT INTO ` + target + ` (topic, field, bucket, count, sum, min, max)
+            SELECT topic, path, bucket, count(value), sum(value), min(value), max(value)
+            FROM (
+                SELECT m.topic, f.path,
+                    date_bin(make_interval(secs => $5), m.received_at, TIMESTAMPTZ '1970-01-01 00:00:00+00') AS bucket,
+                    CASE WHEN jsonb_typeof(e.extracted) = 'number' THEN (e.extracted #>> '{}')::double precision END AS value
+                FROM mqtt_messages m
+                CROSS JOIN unnest($2::text[]) AS f(path)
+                CROSS JOIN LATERAL jsonb_path_query_first(m.payload_json, f.path::jsonpath) AS e(extracted)
+                WHERE m.topic ~ $1 AND m.received_at >= $3 AND m.received_at < $4 AND m.payload_json IS NOT NULL
+            ) AS numeric_values
+            WHERE value IS NOT NULL
+            GROUP BY topic, path, bucket
+        ` + upsert, nil
+	}
+
+	source := pq.QuoteIdentifier(repository.RollupResolutions[index-1].Table)
+	return `
+        INSERT INTO ` + target + ` (topic, field, bucket, count, sum, min, max)
+        SELECT topic, field, date_bin(make_interval(secs => $5), bucket, TIMESTAMPTZ '1970-01-01 00:00:00+00') AS target_bucket,
+            sum(count), sum(sum), min(min), max(max)
+        FROM ` + source + `
+        WHERE topic ~ $1 AND field = ANY($2::text[]) AND bucket >= $3 AND bucket < $4
+        GROUP BY topic, field, target_bucket
+    ` + upsert, nil
+}
@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+)
+
+// TopicACLRepository implementa el repositorio de reglas ACL de topics con SQL directo
+type TopicACLRepository struct {
+	db *sql.DB
+}
+
+// NewTopicACLRepository crea una nueva instancia del repositorio
+func NewTopicACLRepository(db *sql.DB) *TopicACLRepository {
+	return &TopicACLRepository{db: db}
+}
+
+const topicACLSelect = `
+        SELECT a.id, a.user_id, u.username, a.topic_filter, a.access, a.created_at
+        FROM mqtt_topic_acls a
+        JOIN users u ON u.id = a.user_id
+    `
+
+// Create guarda una regla nueva
+func (r *TopicACLRepository) Create(ctx context.Context, acl *entities.TopicACL) error {
+	query := `
+        INSERT INTO mqtt_topic_acls (user_id, topic_filter, access)
+        VALUES ($1, $2, $3)
+        RETURNING id, created_at
+    `
+
+	err := r.db.QueryRowContext(ctx, query, acl.UserID, acl.TopicFilter, acl.Access).
+		Scan(&acl.ID, &acl.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create topic ACL: %w", err)
+	}
+
+	return nil
+}
+
+// Delete elimina una regla; devuelve false si no existía
+func (r *TopicACLRepository) Delete(ctx context.Context, id int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM mqtt_topic_acls WHERE id = $1`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete topic ACL: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete topic ACL: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// List obtiene todas las reglas ordenadas por usuario y filtro
+func (r *TopicACLRepository) List(ctx context.Context) ([]*entities.TopicACL, error) {
+	return r.query(ctx, topicACLSelect+` ORDER BY u.username, a.topic_filter`)
+}
+
+// ListByUser obtiene las reglas de un usuario
+func (r *TopicACLRepository) ListByUser(ctx context.Context, userID int) ([]*entities.TopicACL, error) {
+	return r.query(ctx, topicACLSelect+` WHERE a.user_id = $1 ORDER BY a.topic_filter`, userID)
+}
+
+func (r *TopicACLRepository) query(ctx context.Context, query string, args ...interface{}) ([]*entities.TopicACL, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list topic ACLs: %w", err)
+	}
+	defer rows.Close()
+
+	acls := []*entities.TopicACL{}
+	for rows.Next() {
+		acl := &entities.TopicACL{}
+		if err := rows.Scan(&acl.ID, &acl.UserID, &acl.Username, &acl.TopicFilter, &acl.Access, &acl.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan topic ACL: %w", err)
+		}
+		acls = append(acls, acl)
+	}
+
+	return acls, rows.Err()
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/JorgeePG/prueba-api-http-postgresql-/infraestructure/db/models"
 	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/tracing"
 	"github.com/volatiletech/null/v8"
 	"github.com/volatiletech/sqlboiler/v4/boil"
 	"github.com/volatiletech/sqlboiler/v4/queries/qm"
@@ -23,7 +24,10 @@ func NewUserRepository(db boil.ContextExecutor) *UserRepository {
 }
 
 // Create crea un nuevo usuario en la base de datos
-func (r *UserRepository) Create(ctx context.Context, user *entities.User) error {
+func (r *UserRepository) Create(ctx context.Context, user *entities.User) (err error) {
+	ctx, span := tracing.StartDB(ctx, "UserRepository.Create", "INSERT", models.TableNames.Users)
+	defer tracing.End(span, &err)
+
 	dbUser := r.toSQLBoilerUser(user)
 
 	if err := dbUser.Insert(ctx, r.db, boil.Infer()); err != nil {
@@ -36,7 +40,10 @@ func (r *UserRepository) Create(ctx context.Context, user *entities.User) error
 }
 
 // GetByID obtiene un usuario por su ID
-func (r *UserRepository) GetByID(ctx context.Context, id int) (*entities.User, error) {
+func (r *UserRepository) GetByID(ctx context.Context, id int) (_ *entities.User, err error) {
+	ctx, span := tracing.StartDB(ctx, "UserRepository.GetByID", "SELECT", models.TableNames.Users)
+	defer tracing.End(span, &err)
+
 	dbUser, err := models.FindUser(ctx, r.db, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -49,7 +56,10 @@ func (r *UserRepository) GetByID(ctx context.Context, id int) (*entities.User, e
 }
 
 // GetByUsername obtiene un usuario por su username
-func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*entities.User, error) {
+func (r *UserRepository) GetByUsername(ctx context.Context, username string) (_ *entities.User, err error) {
+	ctx, span := tracing.StartDB(ctx, "UserRepository.GetByUsername", "SELECT", models.TableNames.Users)
+	defer tracing.End(span, &err)
+
 	dbUser, err := models.Users(
 		models.UserWhere.Username.EQ(username),
 	).One(ctx, r.db)
@@ -64,7 +74,10 @@ func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*e
 }
 
 // GetByEmail obtiene un usuario por su email
-func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*entities.User, error) {
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (_ *entities.User, err error) {
+	ctx, span := tracing.StartDB(ctx, "UserRepository.GetByEmail", "SELECT", models.TableNames.Users)
+	defer tracing.End(span, &err)
+
 	dbUser, err := models.Users(
 		models.UserWhere.Email.EQ(email),
 	).One(ctx, r.db)
@@ -79,7 +92,10 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*entitie
 }
 
 // Update actualiza un usuario existente
-func (r *UserRepository) Update(ctx context.Context, user *entities.User) error {
+func (r *UserRepository) Update(ctx context.Context, user *entities.User) (err error) {
+	ctx, span := tracing.StartDB(ctx, "UserRepository.Update", "UPDATE", models.TableNames.Users)
+	defer tracing.End(span, &err)
+
 	dbUser := r.toSQLBoilerUser(user)
 
 	if _, err := dbUser.Update(ctx, r.db, boil.Infer()); err != nil {
@@ -90,7 +106,10 @@ func (r *UserRepository) Update(ctx context.Context, user *entities.User) error
 }
 
 // Delete elimina un usuario por su ID
-func (r *UserRepository) Delete(ctx context.Context, id int) error {
+func (r *UserRepository) Delete(ctx context.Context, id int) (err error) {
+	ctx, span := tracing.StartDB(ctx, "UserRepository.Delete", "DELETE", models.TableNames.Users)
+	defer tracing.End(span, &err)
+
 	dbUser := &models.User{ID: id}
 
 	if _, err := dbUser.Delete(ctx, r.db); err != nil {
@@ -101,7 +120,10 @@ func (r *UserRepository) Delete(ctx context.Context, id int) error {
 }
 
 // List obtiene una lista paginada de usuarios
-func (r *UserRepository) List(ctx context.Context, limit, offset int) ([]*entities.User, error) {
+func (r *UserRepository) List(ctx context.Context, limit, offset int) (_ []*entities.User, err error) {
+	ctx, span := tracing.StartDB(ctx, "UserRepository.List", "SELECT", models.TableNames.Users)
+	defer tracing.End(span, &err)
+
 	dbUsers, err := models.Users(
 		qm.OrderBy(models.UserColumns.CreatedAt+" DESC"),
 		qm.Limit(limit),
@@ -119,8 +141,33 @@ func (r *UserRepository) List(ctx context.Context, limit, offset int) ([]*entiti
 	return users, nil
 }
 
+// ListAfterID obtiene hasta limit usuarios con id mayor que afterID, ordenados por id
+func (r *UserRepository) ListAfterID(ctx context.Context, afterID, limit int) (_ []*entities.User, err error) {
+	ctx, span := tracing.StartDB(ctx, "UserRepository.ListAfterID", "SELECT", models.TableNames.Users)
+	defer tracing.End(span, &err)
+
+	dbUsers, err := models.Users(
+		models.UserWhere.ID.GT(afterID),
+		qm.OrderBy(models.UserColumns.ID),
+		qm.Limit(limit),
+	).All(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	users := make([]*entities.User, len(dbUsers))
+	for i, dbUser := range dbUsers {
+		users[i] = r.toEntity(dbUser)
+	}
+
+	return users, nil
+}
+
 // Count obtiene el total de usuarios
-func (r *UserRepository) Count(ctx context.Context) (int, error) {
+func (r *UserRepository) Count(ctx context.Context) (_ int, err error) {
+	ctx, span := tracing.StartDB(ctx, "UserRepository.Count", "SELECT", models.TableNames.Users)
+	defer tracing.End(span, &err)
+
 	count, err := models.Users().Count(ctx, r.db)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count users: %w", err)
@@ -138,6 +185,8 @@ func (r *UserRepository) toSQLBoilerUser(user *entities.User) *models.User {
 		Password:  user.Password,
 		CreatedAt: null.TimeFrom(user.CreatedAt),
 		UpdatedAt: null.TimeFrom(user.UpdatedAt),
+
+		PasswordResetRequired: user.PasswordResetRequired,
 	}
 
 	if user.FullName != nil {
@@ -160,6 +209,8 @@ func (r *UserRepository) toEntity(dbUser *models.User) *entities.User {
 		Password:  dbUser.Password,
 		CreatedAt: dbUser.CreatedAt.Time,
 		UpdatedAt: dbUser.UpdatedAt.Time,
+
+		PasswordResetRequired: dbUser.PasswordResetRequired,
 	}
 
 	if dbUser.FullName.Valid {
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/dto"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/services"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/interfaces/http/middleware"
+	"github.com/gorilla/mux"
+)
+
+// AlertHandler maneja las peticiones HTTP de las reglas de alerta sobre mensajes MQTT y de su estado
+type AlertHandler struct {
+	alertService *services.AlertService
+	aclService   *services.TopicACLService
+}
+
+// NewAlertHandler crea una nueva instancia del handler de alertas
+func NewAlertHandler(alertService *services.AlertService, aclService *services.TopicACLService) *AlertHandler {
+	return &AlertHandler{
+		alertService: alertService,
+		aclService:   aclService,
+	}
+}
+
+// ListAlerts maneja la obtención del estado de las alertas de los topics que
+// el usuario puede leer. Admite ?status=pending|firing|resolved.
+func (h *AlertHandler) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	filters, unrestricted, err := h.aclService.ReadableFilters(r.Context(), principal.UserID, principal.Roles)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "LIST_FAILED", "Failed to list alerts", err.Error())
+		return
+	}
+
+	alerts, err := h.alertService.ListAlerts(r.Context(), r.URL.Query().Get("status"), !unrestricted, filters)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAlertStatus) {
+			sendErrorResponse(w, http.StatusBadRequest, "INVALID_STATUS", "Invalid alert status", err.Error())
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, "LIST_FAILED", "Failed to list alerts", err.Error())
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, "Alerts retrieved successfully", alerts)
+}
+
+// ListRules maneja la obtención de las reglas de alerta
+func (h *AlertHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.alertService.ListRules(r.Context())
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "LIST_FAILED", "Failed to list alert rules", err.Error())
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, "Alert rules retrieved successfully", rules)
+}
+
+// CreateRule maneja la creación de una regla de alerta
+func (h *AlertHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	var req dto.AlertRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", err.Error())
+		return
+	}
+
+	rule, err := h.alertService.CreateRule(r.Context(), req)
+	if err != nil {
+		sendAlertError(w, err, "CREATE_FAILED", "Failed to create alert rule")
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusCreated, "Alert rule created successfully", rule)
+}
+
+// UpdateRule maneja la modificación de una regla de alerta
+func (h *AlertHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_ID", "Invalid alert rule ID", err.Error())
+		return
+	}
+
+	var req dto.AlertRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", err.Error())
+		return
+	}
+
+	rule, err := h.alertService.UpdateRule(r.Context(), id, req)
+	if err != nil {
+		sendAlertError(w, err, "UPDATE_FAILED", "Failed to update alert rule")
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, "Alert rule updated successfully", rule)
+}
+
+// DeleteRule maneja la eliminación de una regla de alerta
+func (h *AlertHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_ID", "Invalid alert rule ID", err.Error())
+		return
+	}
+
+	if err := h.alertService.DeleteRule(r.Context(), id); err != nil {
+		sendAlertError(w, err, "DELETE_FAILED", "Failed to delete alert rule")
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, "Alert rule deleted successfully", map[string]int{"id": id})
+}
+
+// sendAlertError traduce los errores del servicio de alertas a respuestas HTTP
+func sendAlertError(w http.ResponseWriter, err error, code, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidAlertRule):
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_RULE", "Invalid alert rule", err.Error())
+	case errors.Is(err, services.ErrAlertRuleExists):
+		sendErrorResponse(w, http.StatusConflict, "RULE_EXISTS", "Alert rule already exists", err.Error())
+	case errors.Is(err, services.ErrAlertRuleNotFound):
+		sendErrorResponse(w, http.StatusNotFound, "RULE_NOT_FOUND", "Alert rule not found", "")
+	default:
+		sendErrorResponse(w, http.StatusInternalServerError, code, message, err.Error())
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/dto"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/services"
+)
+
+// AuthHandler maneja las peticiones HTTP de autenticación
+type AuthHandler struct {
+	authService *services.AuthService
+}
+
+// NewAuthHandler crea una nueva instancia del handler de autenticación
+func NewAuthHandler(authService *services.AuthService) *AuthHandler {
+	return &AuthHandler{
+		authService: authService,
+	}
+}
+
+// Login maneja el inicio de sesión
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req dto.LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", err.Error())
+		return
+	}
+
+	tokens, err := h.authService.Login(r.Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidCredentials):
+			sendErrorResponse(w, http.StatusUnauthorized, "INVALID_CREDENTIALS", "Invalid username or password", "")
+		case errors.Is(err, services.ErrUserInactive):
+			sendErrorResponse(w, http.StatusForbidden, "USER_INACTIVE", "User is inactive", "")
+		case errors.Is(err, services.ErrPasswordResetRequired):
+			sendErrorResponse(w, http.StatusForbidden, "PASSWORD_RESET_REQUIRED", "Password must be changed before logging in", "POST /api/v1/auth/password")
+		default:
+			sendErrorResponse(w, http.StatusInternalServerError, "LOGIN_FAILED", "Failed to log in", err.Error())
+		}
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, "Login successful", tokens)
+}
+
+// ChangePassword maneja el cambio de contraseña sin sesión, con las credenciales actuales
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var req dto.PasswordChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", err.Error())
+		return
+	}
+
+	if err := h.authService.ChangePassword(r.Context(), req); err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidCredentials):
+			sendErrorResponse(w, http.StatusUnauthorized, "INVALID_CREDENTIALS", "Invalid username or password", "")
+		case errors.Is(err, services.ErrUserInactive):
+			sendErrorResponse(w, http.StatusForbidden, "USER_INACTIVE", "User is inactive", "")
+		case errors.Is(err, services.ErrInvalidNewPassword):
+			sendErrorResponse(w, http.StatusBadRequest, "INVALID_NEW_PASSWORD", "New password must be set and differ from the current one", "")
+		default:
+			sendErrorResponse(w, http.StatusInternalServerError, "PASSWORD_CHANGE_FAILED", "Failed to change password", err.Error())
+		}
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, "Password changed successfully", nil)
+}
+
+// Refresh maneja la rotación de refresh tokens
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req dto.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", err.Error())
+		return
+	}
+
+	tokens, err := h.authService.Refresh(r.Context(), req)
+	if err != nil {
+		h.sendRefreshError(w, err, "REFRESH_FAILED", "Failed to refresh tokens")
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, "Tokens refreshed successfully", tokens)
+}
+
+// Logout maneja la revocación de la sesión asociada a un refresh token
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req dto.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", err.Error())
+		return
+	}
+
+	if err := h.authService.Logout(r.Context(), req); err != nil {
+		h.sendRefreshError(w, err, "LOGOUT_FAILED", "Failed to log out")
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, "Logged out successfully", nil)
+}
+
+// sendRefreshError traduce los errores de refresh tokens a respuestas HTTP
+func (h *AuthHandler) sendRefreshError(w http.ResponseWriter, err error, code, message string) {
+	switch {
+	case errors.Is(err, services.ErrRefreshTokenReused):
+		sendErrorResponse(w, http.StatusUnauthorized, "REFRESH_TOKEN_REUSED", "Refresh token reuse detected, session revoked", "")
+	case errors.Is(err, services.ErrInvalidRefreshToken):
+		sendErrorResponse(w, http.StatusUnauthorized, "INVALID_REFRESH_TOKEN", "Invalid or expired refresh token", "")
+	default:
+		sendErrorResponse(w, http.StatusInternalServerError, code, message, err.Error())
+	}
+}
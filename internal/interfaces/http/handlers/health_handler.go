@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/infrastructure/health"
+)
+
+// HealthHandler sirve las sondas de liveness y readiness. Las respuestas no
+// usan el formato APIResponse para que los orquestadores puedan leerlas sin más.
+type HealthHandler struct {
+	checker *health.Checker
+}
+
+// NewHealthHandler crea una nueva instancia del handler de salud
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{
+		checker: checker,
+	}
+}
+
+// Liveness indica que el proceso está en marcha; no comprueba dependencias
+func (h *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeHealthJSON(w, http.StatusOK, map[string]string{"status": health.ReportOK})
+}
+
+// Readiness comprueba las dependencias y responde 503 si alguna crítica está caída
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := h.checker.Check(r.Context())
+
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	writeHealthJSON(w, status, report)
+}
+
+func writeHealthJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(body)
+}
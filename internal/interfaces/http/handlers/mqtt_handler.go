@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/dto"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/services"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/interfaces/http/middleware"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/subscriber"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/repository"
+)
+
+// MqttHandler maneja las peticiones HTTP relacionadas con MQTT
+type MqttHandler struct {
+	mqttService *services.MqttService
+	aclService  *services.TopicACLService
+}
+
+// NewMqttHandler crea una nueva instancia del handler MQTT
+func NewMqttHandler(mqttService *services.MqttService, aclService *services.TopicACLService) *MqttHandler {
+	return &MqttHandler{
+		mqttService: mqttService,
+		aclService:  aclService,
+	}
+}
+
+// ListSubscriptions maneja la obtención de los topics suscritos
+func (h *MqttHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	topics := h.mqttService.ActiveTopics()
+
+	sendSuccessResponse(w, http.StatusOK, "Subscriptions retrieved successfully", dto.SubscriptionsResponse{
+		Topics: topics,
+		Total:  len(topics),
+	})
+}
+
+// Subscribe maneja la creación de un suscriptor para un topic
+func (h *MqttHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	var req dto.SubscribeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", err.Error())
+		return
+	}
+
+	// Suscribirse equivale a leer todos los topics que abarca el filtro
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	if err := h.aclService.Authorize(r.Context(), principal.UserID, principal.Roles, strings.TrimSpace(req.Topic), entities.TopicAccessRead); err != nil {
+		if errors.Is(err, services.ErrTopicForbidden) {
+			sendErrorResponse(w, http.StatusForbidden, "TOPIC_FORBIDDEN", "Access to MQTT topic denied", err.Error())
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, "SUBSCRIBE_FAILED", "Failed to subscribe to topic", err.Error())
+		return
+	}
+
+	qos := subscriber.DefaultQOS
+	if req.QOS != nil {
+		qos = *req.QOS
+	}
+
+	if err := h.mqttService.Subscribe(req.Topic, qos, principal.UserID); err != nil {
+		switch {
+		case errors.Is(err, subscriber.ErrEmptyTopic), errors.Is(err, services.ErrInvalidTopic):
+			sendErrorResponse(w, http.StatusBadRequest, "INVALID_TOPIC", "Invalid MQTT topic", err.Error())
+		case errors.Is(err, subscriber.ErrInvalidQOS):
+			sendErrorResponse(w, http.StatusBadRequest, "INVALID_QOS", "Invalid QoS", err.Error())
+		case errors.Is(err, subscriber.ErrAlreadySubscribed):
+			sendErrorResponse(w, http.StatusConflict, "ALREADY_SUBSCRIBED", "Topic already subscribed", err.Error())
+		case errors.Is(err, subscriber.ErrBrokerUnavailable):
+			sendErrorResponse(w, http.StatusServiceUnavailable, "BROKER_UNAVAILABLE", "MQTT broker unavailable", err.Error())
+		default:
+			sendErrorResponse(w, http.StatusInternalServerError, "SUBSCRIBE_FAILED", "Failed to subscribe to topic", err.Error())
+		}
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusCreated, "Subscriber added successfully", req)
+}
+
+// Unsubscribe maneja la eliminación del suscriptor de un topic
+func (h *MqttHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	topic := r.URL.Query().Get("topic")
+
+	if err := h.mqttService.Unsubscribe(topic); err != nil {
+		switch {
+		case errors.Is(err, subscriber.ErrEmptyTopic):
+			sendErrorResponse(w, http.StatusBadRequest, "MISSING_TOPIC", "Topic is required", "")
+		case errors.Is(err, subscriber.ErrNotSubscribed):
+			sendErrorResponse(w, http.StatusNotFound, "SUBSCRIPTION_NOT_FOUND", "Subscription not found", err.Error())
+		default:
+			sendErrorResponse(w, http.StatusInternalServerError, "UNSUBSCRIBE_FAILED", "Failed to remove subscriber", err.Error())
+		}
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, "Subscriber removed successfully", dto.SubscribeRequest{Topic: topic})
+}
+
+// Publish maneja la publicación de un mensaje MQTT desde la API. Responde
+// cuando el broker lo ha confirmado según el QoS pedido.
+func (h *MqttHandler) Publish(w http.ResponseWriter, r *http.Request) {
+	var req dto.PublishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", err.Error())
+		return
+	}
+
+	// Publicar exige una ACL de escritura sobre el topic
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	if err := h.aclService.Authorize(r.Context(), principal.UserID, principal.Roles, strings.TrimSpace(req.Topic), entities.TopicAccessWrite); err != nil {
+		if errors.Is(err, services.ErrTopicForbidden) {
+			sendErrorResponse(w, http.StatusForbidden, "TOPIC_FORBIDDEN", "Access to MQTT topic denied", err.Error())
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, "PUBLISH_FAILED", "Failed to publish message", err.Error())
+		return
+	}
+
+	resp, err := h.mqttService.Publish(r.Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, subscriber.ErrEmptyTopic), errors.Is(err, services.ErrInvalidTopic):
+			sendErrorResponse(w, http.StatusBadRequest, "INVALID_TOPIC", "Invalid MQTT topic", err.Error())
+		case errors.Is(err, subscriber.ErrInvalidQOS):
+			sendErrorResponse(w, http.StatusBadRequest, "INVALID_QOS", "Invalid QoS", err.Error())
+		case errors.Is(err, services.ErrInvalidPayload):
+			sendErrorResponse(w, http.StatusBadRequest, "INVALID_PAYLOAD", "Invalid MQTT payload", err.Error())
+		case errors.Is(err, subscriber.ErrNoDatabase):
+			sendErrorResponse(w, http.StatusServiceUnavailable, "RECORD_UNAVAILABLE", "Database not configured, cannot record message", err.Error())
+		case errors.Is(err, subscriber.ErrBrokerUnavailable), errors.Is(err, subscriber.ErrNotConfigured):
+			sendErrorResponse(w, http.StatusServiceUnavailable, "BROKER_UNAVAILABLE", "MQTT broker unavailable", err.Error())
+		case errors.Is(err, subscriber.ErrPublishTimeout):
+			sendErrorResponse(w, http.StatusGatewayTimeout, "PUBLISH_TIMEOUT", "MQTT broker did not acknowledge the message", err.Error())
+		case errors.Is(err, subscriber.ErrNotRecorded):
+			sendErrorResponse(w, http.StatusInternalServerError, "RECORD_FAILED", "Message published but not recorded", err.Error())
+		default:
+			sendErrorResponse(w, http.StatusInternalServerError, "PUBLISH_FAILED", "Failed to publish message", err.Error())
+		}
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, "Message published successfully", resp)
+}
+
+// ListMessages maneja la obtención de los mensajes MQTT almacenados
+func (h *MqttHandler) ListMessages(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 {
+			sendErrorResponse(w, http.StatusBadRequest, "INVALID_LIMIT", "limit must be a positive number", limitStr)
+			return
+		}
+		limit = l
+	}
+
+	// topic, from, to, qos, retained, direction, sort, cursor, where y jsonpath
+	query, err := repository.ParseMessageQuery(r.URL.Query())
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_FILTER", "Invalid message filter", err.Error())
+		return
+	}
+	query.Limit = limit
+
+	// Solo se devuelven mensajes de los topics que el usuario puede leer
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	filters, unrestricted, err := h.aclService.ReadableFilters(r.Context(), principal.UserID, principal.Roles)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "LIST_FAILED", "Failed to list MQTT messages", err.Error())
+		return
+	}
+	query.Restricted = !unrestricted
+	query.TopicFilters = filters
+
+	page, err := h.mqttService.FindMessages(r.Context(), query)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidMessageFilter) {
+			sendErrorResponse(w, http.StatusBadRequest, "INVALID_FILTER", "Invalid message filter", err.Error())
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, "LIST_FAILED", "Failed to list MQTT messages", err.Error())
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, "MQTT messages retrieved successfully", dto.MessagesListResponse{
+		Messages:   page.Messages,
+		Count:      len(page.Messages),
+		NextCursor: page.NextCursor,
+	})
+}
+
+// Aggregate maneja el cálculo de series temporales sobre un campo numérico del
+// payload: topic, field, bucket, fn, from, to y fill
+func (h *MqttHandler) Aggregate(w http.ResponseWriter, r *http.Request) {
+	query, err := repository.ParseAggregateQuery(r.URL.Query(), time.Now())
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_FILTER", "Invalid aggregation query", err.Error())
+		return
+	}
+
+	// Solo se agregan mensajes de los topics que el usuario puede leer
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	filters, unrestricted, err := h.aclService.ReadableFilters(r.Context(), principal.UserID, principal.Roles)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "AGGREGATE_FAILED", "Failed to aggregate MQTT messages", err.Error())
+		return
+	}
+	query.Restricted = !unrestricted
+	query.TopicFilters = filters
+
+	series, resolution, err := h.mqttService.AggregateMessages(r.Context(), query)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidMessageFilter) {
+			sendErrorResponse(w, http.StatusBadRequest, "INVALID_FILTER", "Invalid aggregation query", err.Error())
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, "AGGREGATE_FAILED", "Failed to aggregate MQTT messages", err.Error())
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, "MQTT messages aggregated successfully", dto.AggregateResponse{
+		Field:      query.Field,
+		Bucket:     r.URL.Query().Get("bucket"),
+		Functions:  query.Functions,
+		From:       query.From,
+		To:         query.To,
+		Fill:       query.Fill,
+		Resolution: resolution,
+		Series:     series,
+	})
+}
+
+// PipelineStats maneja la consulta de las métricas del pipeline de persistencia
+func (h *MqttHandler) PipelineStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.mqttService.PipelineStats()
+	if err != nil {
+		sendErrorResponse(w, http.StatusServiceUnavailable, "PIPELINE_DISABLED", "MQTT message pipeline is not enabled", err.Error())
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, "MQTT pipeline stats retrieved successfully", stats)
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/services"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/interfaces/http/middleware"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/stream"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/repository"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+// StreamOptions configura el stream en vivo de mensajes MQTT
+type StreamOptions struct {
+	// Heartbeat es cada cuánto se envía un latido (comentario SSE o ping WebSocket)
+	Heartbeat time.Duration
+	// ClientBuffer son los mensajes pendientes que admite un cliente antes de ser expulsado
+	ClientBuffer int
+	// ReplayLimit son los mensajes reenviados como máximo al reanudar desde Last-Event-ID
+	ReplayLimit  int
+	WriteTimeout time.Duration
+}
+
+// MqttStreamHandler envía en vivo los mensajes MQTT recibidos por Server-Sent
+// Events o WebSocket. Cada cliente indica sus topics con ?topic= (repetible) y
+// solo recibe los que puede leer según sus ACLs.
+type MqttStreamHandler struct {
+	hub         *stream.Hub
+	mqttService *services.MqttService
+	aclService  *services.TopicACLService
+	opts        StreamOptions
+	upgrader    websocket.Upgrader
+}
+
+// NewMqttStreamHandler crea una nueva instancia del handler del stream MQTT
+func NewMqttStreamHandler(hub *stream.Hub, mqttService *services.MqttService, aclService *services.TopicACLService, opts StreamOptions) *MqttStreamHandler {
+	if opts.Heartbeat <= 0 {
+		opts.Heartbeat = 15 * time.Second
+	}
+	if opts.ReplayLimit <= 0 {
+		opts.ReplayLimit = 1000
+	}
+
+	return &MqttStreamHandler{
+		hub:         hub,
+		mqttService: mqttService,
+		aclService:  aclService,
+		opts:        opts,
+		upgrader: websocket.Upgrader{
+			// La API se autentica con tokens y no con cookies, igual que su CORS abierto
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// SSE maneja el stream de mensajes como Server-Sent Events. Al reconectar, el
+// navegador envía Last-Event-ID y se reenvían los mensajes guardados desde ese id.
+func (h *MqttStreamHandler) SSE(w http.ResponseWriter, r *http.Request) {
+	session, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	defer h.hub.Unregister(session.client)
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	write := func(format string, args ...interface{}) error {
+		h.setWriteDeadline(rc)
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	if err := write("retry: %d\n\n", (3 * time.Second).Milliseconds()); err != nil {
+		log.Warn().Err(err).Msg("⚠️ El cliente no admite streaming SSE")
+		return
+	}
+
+	send := func(msg models.MqttMessage) error {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		if msg.ID > 0 {
+			return write("id: %d\nevent: message\ndata: %s\n\n", msg.ID, data)
+		}
+		return write("event: message\ndata: %s\n\n", data)
+	}
+
+	err := session.run(r, h.opts.Heartbeat, send,
+		func() error { return write(": heartbeat\n\n") },
+		func() { write("event: evicted\ndata: {\"reason\":\"slow consumer\"}\n\n") })
+	if err != nil {
+		log.Debug().Err(err).Msg("Cliente SSE desconectado")
+	}
+}
+
+// WebSocket maneja el stream de mensajes por WebSocket. Cada mensaje MQTT se
+// envía como un mensaje de texto JSON; para reanudar se usa ?last_event_id=.
+func (h *MqttStreamHandler) WebSocket(w http.ResponseWriter, r *http.Request) {
+	session, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	defer h.hub.Unregister(session.client)
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade ya ha respondido con el error
+		log.Warn().Err(err).Msg("⚠️ Error actualizando la conexión a WebSocket")
+		return
+	}
+	defer conn.Close()
+
+	// El cliente no envía datos, pero hay que leer para procesar pongs y cierres
+	pongWait := 2 * h.opts.Heartbeat
+	conn.SetReadLimit(512)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	session.closed = closed
+
+	deadline := func() time.Time { return time.Now().Add(h.opts.WriteTimeout) }
+	send := func(msg models.MqttMessage) error {
+		conn.SetWriteDeadline(deadline())
+		return conn.WriteJSON(msg)
+	}
+	ping := func() error {
+		return conn.WriteControl(websocket.PingMessage, nil, deadline())
+	}
+	evicted := func() {
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "slow consumer"), deadline())
+	}
+
+	if err := session.run(r, h.opts.Heartbeat, send, ping, evicted); err != nil {
+		log.Debug().Err(err).Msg("Cliente WebSocket desconectado")
+	}
+}
+
+// streamSession es un cliente del stream junto con los mensajes a reenviar
+type streamSession struct {
+	client *stream.Client
+	replay []models.MqttMessage
+	// closed se cierra cuando el cliente se desconecta (solo WebSocket)
+	closed <-chan struct{}
+}
+
+// open registra el cliente en el hub con sus filtros y, si se reanuda el
+// stream, obtiene los mensajes guardados desde el último id recibido. El
+// registro se hace antes de leer de la base de datos para no perder nada.
+func (h *MqttStreamHandler) open(w http.ResponseWriter, r *http.Request) (*streamSession, bool) {
+	topics, err := repository.ParseTopicFilters(r.URL.Query()["topic"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_TOPIC", "Invalid MQTT topic", err.Error())
+		return nil, false
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	var afterID int
+	if lastEventID != "" {
+		afterID, err = strconv.Atoi(lastEventID)
+		if err != nil || afterID < 0 {
+			sendErrorResponse(w, http.StatusBadRequest, "INVALID_LAST_EVENT_ID", "Last-Event-ID must be a message id", lastEventID)
+			return nil, false
+		}
+	}
+
+	// Solo se envían mensajes de los topics que el usuario puede leer
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	filters, unrestricted, err := h.aclService.ReadableFilters(r.Context(), principal.UserID, principal.Roles)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "STREAM_FAILED", "Failed to open MQTT stream", err.Error())
+		return nil, false
+	}
+
+	filter := stream.Filter{Topics: topics, Restricted: !unrestricted, Allowed: filters}
+	session := &streamSession{client: h.hub.Register(filter, h.opts.ClientBuffer)}
+
+	if afterID > 0 {
+		page, err := h.mqttService.FindMessages(r.Context(), repository.MessageQuery{
+			Restricted:   filter.Restricted,
+			TopicFilters: filter.Allowed,
+			Topics:       filter.Topics,
+			Direction:    models.DirectionInbound,
+			AfterID:      afterID,
+			Limit:        h.opts.ReplayLimit,
+		})
+		if err != nil {
+			h.hub.Unregister(session.client)
+			status := http.StatusInternalServerError
+			if errors.Is(err, services.ErrInvalidMessageFilter) {
+				status = http.StatusBadRequest
+			}
+			sendErrorResponse(w, status, "STREAM_FAILED", "Failed to resume MQTT stream", err.Error())
+			return nil, false
+		}
+		session.replay = page.Messages
+	}
+
+	return session, true
+}
+
+// run envía los mensajes pendientes y después los que lleguen al hub hasta
+// que el cliente se desconecte o sea expulsado. Los mensajes en vivo que ya
+// se enviaron al reanudar se descartan.
+func (s *streamSession) run(r *http.Request, heartbeat time.Duration, send func(models.MqttMessage) error, ping func() error, evicted func()) error {
+	replayed := make(map[int]struct{}, len(s.replay))
+	for _, msg := range s.replay {
+		if err := send(msg); err != nil {
+			return err
+		}
+		replayed[msg.ID] = struct{}{}
+	}
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-s.closed:
+			return nil
+		case <-s.client.Done():
+			if s.client.Evicted() {
+				log.Warn().Msg("⚠️ Cliente del stream MQTT expulsado por no consumir a tiempo")
+				evicted()
+			}
+			return nil
+		case msg := <-s.client.Messages():
+			if _, ok := replayed[msg.ID]; ok {
+				delete(replayed, msg.ID)
+				continue
+			}
+			if err := send(msg); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			if err := ping(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// setWriteDeadline limita cada escritura para no quedarse bloqueado con un cliente que no lee
+func (h *MqttStreamHandler) setWriteDeadline(rc *http.ResponseController) {
+	if h.opts.WriteTimeout > 0 {
+		rc.SetWriteDeadline(time.Now().Add(h.opts.WriteTimeout))
+	}
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/dto"
+)
+
+// sendSuccessResponse envía una respuesta exitosa
+func sendSuccessResponse(w http.ResponseWriter, statusCode int, message string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	response := dto.APIResponse{
+		Status:  "success",
+		Message: message,
+		Data:    data,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// sendErrorResponse envía una respuesta de error
+func sendErrorResponse(w http.ResponseWriter, statusCode int, code, message, details string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	response := dto.APIResponse{
+		Status:  "error",
+		Message: message,
+		Error: &dto.APIError{
+			Code:    code,
+			Message: message,
+			Details: details,
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
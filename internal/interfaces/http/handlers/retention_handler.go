@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/dto"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/services"
+	"github.com/gorilla/mux"
+)
+
+// RetentionHandler maneja las peticiones HTTP de gestión de las reglas de retención de mensajes MQTT
+type RetentionHandler struct {
+	retentionService *services.RetentionService
+}
+
+// NewRetentionHandler crea una nueva instancia del handler de retención
+func NewRetentionHandler(retentionService *services.RetentionService) *RetentionHandler {
+	return &RetentionHandler{
+		retentionService: retentionService,
+	}
+}
+
+// ListRules maneja la obtención de las reglas de retención
+func (h *RetentionHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.retentionService.List(r.Context())
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "LIST_FAILED", "Failed to list retention rules", err.Error())
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, "Retention rules retrieved successfully", rules)
+}
+
+// CreateRule maneja la creación de una regla de retención
+func (h *RetentionHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	var req dto.RetentionRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", err.Error())
+		return
+	}
+
+	rule, err := h.retentionService.Create(r.Context(), req)
+	if err != nil {
+		sendRetentionError(w, err, "CREATE_FAILED", "Failed to create retention rule")
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusCreated, "Retention rule created successfully", rule)
+}
+
+// UpdateRule maneja la modificación de una regla de retención
+func (h *RetentionHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_ID", "Invalid retention rule ID", err.Error())
+		return
+	}
+
+	var req dto.RetentionRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", err.Error())
+		return
+	}
+
+	rule, err := h.retentionService.Update(r.Context(), id, req)
+	if err != nil {
+		sendRetentionError(w, err, "UPDATE_FAILED", "Failed to update retention rule")
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, "Retention rule updated successfully", rule)
+}
+
+// DeleteRule maneja la eliminación de una regla de retención
+func (h *RetentionHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_ID", "Invalid retention rule ID", err.Error())
+		return
+	}
+
+	if err := h.retentionService.Delete(r.Context(), id); err != nil {
+		sendRetentionError(w, err, "DELETE_FAILED", "Failed to delete retention rule")
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, "Retention rule deleted successfully", map[string]int{"id": id})
+}
+
+// DryRun informa de cuántos mensajes eliminaría cada regla en la próxima purga
+func (h *RetentionHandler) DryRun(w http.ResponseWriter, r *http.Request) {
+	reports, err := h.retentionService.DryRun(r.Context())
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "DRY_RUN_FAILED", "Failed to evaluate retention rules", err.Error())
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, "Retention dry run completed successfully", reports)
+}
+
+// sendRetentionError traduce los errores del servicio de retención a respuestas HTTP
+func sendRetentionError(w http.ResponseWriter, err error, code, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidRetentionRule):
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_RULE", "Invalid retention rule", err.Error())
+	case errors.Is(err, services.ErrRetentionRuleExists):
+		sendErrorResponse(w, http.StatusConflict, "RULE_EXISTS", "Retention rule already exists", err.Error())
+	case errors.Is(err, services.ErrRetentionRuleNotFound):
+		sendErrorResponse(w, http.StatusNotFound, "RULE_NOT_FOUND", "Retention rule not found", "")
+	default:
+		sendErrorResponse(w, http.StatusInternalServerError, code, message, err.Error())
+	}
+}
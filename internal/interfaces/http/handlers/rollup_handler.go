@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/dto"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/services"
+	"github.com/gorilla/mux"
+)
+
+// RollupHandler maneja las peticiones HTTP de gestión de la configuración de agregados de mensajes MQTT
+type RollupHandler struct {
+	rollupService *services.RollupService
+}
+
+// NewRollupHandler crea una nueva instancia del handler de agregados
+func NewRollupHandler(rollupService *services.RollupService) *RollupHandler {
+	return &RollupHandler{
+		rollupService: rollupService,
+	}
+}
+
+// ListConfigs maneja la obtención de las configuraciones de agregados
+func (h *RollupHandler) ListConfigs(w http.ResponseWriter, r *http.Request) {
+	configs, err := h.rollupService.List(r.Context())
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "LIST_FAILED", "Failed to list rollup configs", err.Error())
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, "Rollup configs retrieved successfully", configs)
+}
+
+// CreateConfig maneja la creación de una configuración de agregados
+func (h *RollupHandler) CreateConfig(w http.ResponseWriter, r *http.Request) {
+	var req dto.RollupConfigRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", err.Error())
+		return
+	}
+
+	config, err := h.rollupService.Create(r.Context(), req)
+	if err != nil {
+		sendRollupError(w, err, "CREATE_FAILED", "Failed to create rollup config")
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusCreated, "Rollup config created successfully", config)
+}
+
+// UpdateConfig maneja la modificación de una configuración de agregados
+func (h *RollupHandler) UpdateConfig(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_ID", "Invalid rollup config ID", err.Error())
+		return
+	}
+
+	var req dto.RollupConfigRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", err.Error())
+		return
+	}
+
+	config, err := h.rollupService.Update(r.Context(), id, req)
+	if err != nil {
+		sendRollupError(w, err, "UPDATE_FAILED", "Failed to update rollup config")
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, "Rollup config updated successfully", config)
+}
+
+// DeleteConfig maneja la eliminación de una configuración de agregados
+func (h *RollupHandler) DeleteConfig(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_ID", "Invalid rollup config ID", err.Error())
+		return
+	}
+
+	if err := h.rollupService.Delete(r.Context(), id); err != nil {
+		sendRollupError(w, err, "DELETE_FAILED", "Failed to delete rollup config")
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, "Rollup config deleted successfully", map[string]int{"id": id})
+}
+
+// sendRollupError traduce los errores del servicio de agregados a respuestas HTTP
+func sendRollupError(w http.ResponseWriter, err error, code, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidRollupConfig):
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_CONFIG", "Invalid rollup config", err.Error())
+	case errors.Is(err, services.ErrRollupConfigExists):
+		sendErrorResponse(w, http.StatusConflict, "CONFIG_EXISTS", "Rollup config already exists", err.Error())
+	case errors.Is(err, services.ErrRollupConfigNotFound):
+		sendErrorResponse(w, http.StatusNotFound, "CONFIG_NOT_FOUND", "Rollup config not found", "")
+	default:
+		sendErrorResponse(w, http.StatusInternalServerError, code, message, err.Error())
+	}
+}
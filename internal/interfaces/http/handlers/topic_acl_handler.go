@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/dto"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/services"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/entities"
+	"github.com/gorilla/mux"
+)
+
+// TopicACLHandler maneja las peticiones HTTP de gestión de reglas ACL de topics MQTT
+type TopicACLHandler struct {
+	aclService *services.TopicACLService
+}
+
+// NewTopicACLHandler crea una nueva instancia del handler de ACLs
+func NewTopicACLHandler(aclService *services.TopicACLService) *TopicACLHandler {
+	return &TopicACLHandler{
+		aclService: aclService,
+	}
+}
+
+// ListACLs maneja la obtención de las reglas ACL, opcionalmente de un único usuario (?user_id=)
+func (h *TopicACLHandler) ListACLs(w http.ResponseWriter, r *http.Request) {
+	var (
+		acls []*entities.TopicACL
+		err  error
+	)
+
+	if userIDStr := r.URL.Query().Get("user_id"); userIDStr != "" {
+		userID, convErr := strconv.Atoi(userIDStr)
+		if convErr != nil {
+			sendErrorResponse(w, http.StatusBadRequest, "INVALID_ID", "Invalid user ID", convErr.Error())
+			return
+		}
+		acls, err = h.aclService.ListByUser(r.Context(), userID)
+	} else {
+		acls, err = h.aclService.List(r.Context())
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "LIST_FAILED", "Failed to list topic ACLs", err.Error())
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, "Topic ACLs retrieved successfully", acls)
+}
+
+// CreateACL maneja la creación de una regla ACL
+func (h *TopicACLHandler) CreateACL(w http.ResponseWriter, r *http.Request) {
+	var req dto.CreateTopicACLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", err.Error())
+		return
+	}
+
+	acl, err := h.aclService.Create(r.Context(), req)
+	if err != nil {
+		if err.Error() == "user not found" {
+			sendErrorResponse(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found", "")
+			return
+		}
+		sendErrorResponse(w, http.StatusBadRequest, "CREATE_FAILED", "Failed to create topic ACL", err.Error())
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusCreated, "Topic ACL created successfully", acl)
+}
+
+// DeleteACL maneja la eliminación de una regla ACL
+func (h *TopicACLHandler) DeleteACL(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_ID", "Invalid topic ACL ID", err.Error())
+		return
+	}
+
+	if err := h.aclService.Delete(r.Context(), id); err != nil {
+		if errors.Is(err, services.ErrTopicACLNotFound) {
+			sendErrorResponse(w, http.StatusNotFound, "ACL_NOT_FOUND", "Topic ACL not found", "")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, "DELETE_FAILED", "Failed to delete topic ACL", err.Error())
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, "Topic ACL deleted successfully", map[string]int{"id": id})
+}
+
+// ExportACLs devuelve las reglas como un acl_file de Mosquitto en texto plano
+func (h *TopicACLHandler) ExportACLs(w http.ResponseWriter, r *http.Request) {
+	aclFile, err := h.aclService.ExportMosquitto(r.Context())
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "EXPORT_FAILED", "Failed to export topic ACLs", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="acl"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(aclFile))
+}
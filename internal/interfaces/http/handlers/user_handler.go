@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -26,17 +27,17 @@ func NewUserHandler(userService *services.UserService) *UserHandler {
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var req dto.CreateUserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", err.Error())
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", err.Error())
 		return
 	}
 
 	user, err := h.userService.CreateUser(r.Context(), req)
 	if err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "CREATE_FAILED", "Failed to create user", err.Error())
+		sendErrorResponse(w, http.StatusBadRequest, "CREATE_FAILED", "Failed to create user", err.Error())
 		return
 	}
 
-	h.sendSuccessResponse(w, http.StatusCreated, "User created successfully", user)
+	sendSuccessResponse(w, http.StatusCreated, "User created successfully", user)
 }
 
 // GetUser maneja la obtención de un usuario por ID
@@ -44,27 +45,27 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	idStr, exists := vars["id"]
 	if !exists {
-		h.sendErrorResponse(w, http.StatusBadRequest, "MISSING_ID", "User ID is required", "")
+		sendErrorResponse(w, http.StatusBadRequest, "MISSING_ID", "User ID is required", "")
 		return
 	}
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "INVALID_ID", "Invalid user ID", err.Error())
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_ID", "Invalid user ID", err.Error())
 		return
 	}
 
 	user, err := h.userService.GetUser(r.Context(), id)
 	if err != nil {
 		if err.Error() == "user not found" {
-			h.sendErrorResponse(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found", "")
+			sendErrorResponse(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found", "")
 			return
 		}
-		h.sendErrorResponse(w, http.StatusInternalServerError, "GET_FAILED", "Failed to get user", err.Error())
+		sendErrorResponse(w, http.StatusInternalServerError, "GET_FAILED", "Failed to get user", err.Error())
 		return
 	}
 
-	h.sendSuccessResponse(w, http.StatusOK, "User retrieved successfully", user)
+	sendSuccessResponse(w, http.StatusOK, "User retrieved successfully", user)
 }
 
 // UpdateUser maneja la actualización de usuarios
@@ -72,33 +73,33 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	idStr, exists := vars["id"]
 	if !exists {
-		h.sendErrorResponse(w, http.StatusBadRequest, "MISSING_ID", "User ID is required", "")
+		sendErrorResponse(w, http.StatusBadRequest, "MISSING_ID", "User ID is required", "")
 		return
 	}
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "INVALID_ID", "Invalid user ID", err.Error())
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_ID", "Invalid user ID", err.Error())
 		return
 	}
 
 	var req dto.UpdateUserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", err.Error())
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", err.Error())
 		return
 	}
 
 	user, err := h.userService.UpdateUser(r.Context(), id, req)
 	if err != nil {
 		if err.Error() == "user not found" {
-			h.sendErrorResponse(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found", "")
+			sendErrorResponse(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found", "")
 			return
 		}
-		h.sendErrorResponse(w, http.StatusBadRequest, "UPDATE_FAILED", "Failed to update user", err.Error())
+		sendErrorResponse(w, http.StatusBadRequest, "UPDATE_FAILED", "Failed to update user", err.Error())
 		return
 	}
 
-	h.sendSuccessResponse(w, http.StatusOK, "User updated successfully", user)
+	sendSuccessResponse(w, http.StatusOK, "User updated successfully", user)
 }
 
 // DeleteUser maneja la eliminación de usuarios
@@ -106,27 +107,27 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	idStr, exists := vars["id"]
 	if !exists {
-		h.sendErrorResponse(w, http.StatusBadRequest, "MISSING_ID", "User ID is required", "")
+		sendErrorResponse(w, http.StatusBadRequest, "MISSING_ID", "User ID is required", "")
 		return
 	}
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "INVALID_ID", "Invalid user ID", err.Error())
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_ID", "Invalid user ID", err.Error())
 		return
 	}
 
 	err = h.userService.DeleteUser(r.Context(), id)
 	if err != nil {
 		if err.Error() == "user not found" {
-			h.sendErrorResponse(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found", "")
+			sendErrorResponse(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found", "")
 			return
 		}
-		h.sendErrorResponse(w, http.StatusInternalServerError, "DELETE_FAILED", "Failed to delete user", err.Error())
+		sendErrorResponse(w, http.StatusInternalServerError, "DELETE_FAILED", "Failed to delete user", err.Error())
 		return
 	}
 
-	h.sendSuccessResponse(w, http.StatusOK, "User deleted successfully", nil)
+	sendSuccessResponse(w, http.StatusOK, "User deleted successfully", nil)
 }
 
 // ListUsers maneja la obtención de lista de usuarios
@@ -148,11 +149,11 @@ func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 
 	users, err := h.userService.ListUsers(r.Context(), page, perPage)
 	if err != nil {
-		h.sendErrorResponse(w, http.StatusInternalServerError, "LIST_FAILED", "Failed to list users", err.Error())
+		sendErrorResponse(w, http.StatusInternalServerError, "LIST_FAILED", "Failed to list users", err.Error())
 		return
 	}
 
-	h.sendSuccessResponse(w, http.StatusOK, "Users retrieved successfully", users)
+	sendSuccessResponse(w, http.StatusOK, "Users retrieved successfully", users)
 }
 
 // ChangePassword maneja el cambio de contraseña
@@ -160,67 +161,92 @@ func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	idStr, exists := vars["id"]
 	if !exists {
-		h.sendErrorResponse(w, http.StatusBadRequest, "MISSING_ID", "User ID is required", "")
+		sendErrorResponse(w, http.StatusBadRequest, "MISSING_ID", "User ID is required", "")
 		return
 	}
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "INVALID_ID", "Invalid user ID", err.Error())
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_ID", "Invalid user ID", err.Error())
 		return
 	}
 
 	var req dto.ChangePasswordRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", err.Error())
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", err.Error())
 		return
 	}
 
 	err = h.userService.ChangePassword(r.Context(), id, req)
 	if err != nil {
 		if err.Error() == "user not found" {
-			h.sendErrorResponse(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found", "")
+			sendErrorResponse(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found", "")
 			return
 		}
 		if err.Error() == "invalid old password" {
-			h.sendErrorResponse(w, http.StatusBadRequest, "INVALID_PASSWORD", "Invalid old password", "")
+			sendErrorResponse(w, http.StatusBadRequest, "INVALID_PASSWORD", "Invalid old password", "")
 			return
 		}
-		h.sendErrorResponse(w, http.StatusInternalServerError, "PASSWORD_CHANGE_FAILED", "Failed to change password", err.Error())
+		sendErrorResponse(w, http.StatusInternalServerError, "PASSWORD_CHANGE_FAILED", "Failed to change password", err.Error())
 		return
 	}
 
-	h.sendSuccessResponse(w, http.StatusOK, "Password changed successfully", nil)
+	sendSuccessResponse(w, http.StatusOK, "Password changed successfully", nil)
 }
 
-// sendSuccessResponse envía una respuesta exitosa
-func (h *UserHandler) sendSuccessResponse(w http.ResponseWriter, statusCode int, message string, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
+// AssignRole maneja la asignación de un rol a un usuario
+func (h *UserHandler) AssignRole(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_ID", "Invalid user ID", err.Error())
+		return
+	}
+
+	var req dto.AssignRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", err.Error())
+		return
+	}
 
-	response := dto.APIResponse{
-		Status:  "success",
-		Message: message,
-		Data:    data,
+	if err := h.userService.AssignRole(r.Context(), id, req.Role); err != nil {
+		h.sendRoleError(w, err, "ROLE_ASSIGN_FAILED", "Failed to assign role")
+		return
 	}
 
-	json.NewEncoder(w).Encode(response)
+	sendSuccessResponse(w, http.StatusOK, "Role assigned successfully", map[string]interface{}{
+		"id":   id,
+		"role": req.Role,
+	})
 }
 
-// sendErrorResponse envía una respuesta de error
-func (h *UserHandler) sendErrorResponse(w http.ResponseWriter, statusCode int, code, message, details string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
+// RevokeRole maneja la retirada de un rol a un usuario
+func (h *UserHandler) RevokeRole(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_ID", "Invalid user ID", err.Error())
+		return
+	}
 
-	response := dto.APIResponse{
-		Status:  "error",
-		Message: message,
-		Error: &dto.APIError{
-			Code:    code,
-			Message: message,
-			Details: details,
-		},
+	if err := h.userService.RevokeRole(r.Context(), id, vars["role"]); err != nil {
+		h.sendRoleError(w, err, "ROLE_REVOKE_FAILED", "Failed to revoke role")
+		return
 	}
 
-	json.NewEncoder(w).Encode(response)
+	sendSuccessResponse(w, http.StatusOK, "Role revoked successfully", map[string]interface{}{
+		"id":   id,
+		"role": vars["role"],
+	})
+}
+
+// sendRoleError traduce los errores de gestión de roles a respuestas HTTP
+func (h *UserHandler) sendRoleError(w http.ResponseWriter, err error, code, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidRole):
+		sendErrorResponse(w, http.StatusBadRequest, "INVALID_ROLE", "Invalid role", err.Error())
+	case err.Error() == "user not found":
+		sendErrorResponse(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found", "")
+	default:
+		sendErrorResponse(w, http.StatusInternalServerError, code, message, err.Error())
+	}
 }
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/application/dto"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/infrastructure/auth"
+)
+
+// contextKey evita colisiones con otras claves del contexto
+type contextKey string
+
+const principalKey contextKey = "principal"
+
+// AccessTokenParser valida un access token y devuelve sus claims
+type AccessTokenParser interface {
+	ParseAccessToken(token string) (*auth.Claims, error)
+}
+
+// Authenticate exige un access token válido en la cabecera Authorization
+// y guarda el usuario autenticado en el contexto de la petición
+func Authenticate(parser AccessTokenParser) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := bearerToken(r)
+			if !ok {
+				writeAuthError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Authentication required")
+				return
+			}
+
+			claims, err := parser.ParseAccessToken(token)
+			if err != nil {
+				writeAuthError(w, http.StatusUnauthorized, "INVALID_TOKEN", "Invalid or expired access token")
+				return
+			}
+
+			principal, err := claims.Principal()
+			if err != nil {
+				writeAuthError(w, http.StatusUnauthorized, "INVALID_TOKEN", "Invalid or expired access token")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// TokenFromQuery acepta el access token en el parámetro ?access_token= cuando
+// no viene la cabecera Authorization. Los navegadores no permiten cabeceras
+// propias en EventSource ni en WebSocket, así que solo se usa en el stream.
+func TokenFromQuery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("access_token"); token != "" && r.Header.Get("Authorization") == "" {
+			r = r.Clone(r.Context())
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WithPrincipal devuelve un contexto con el usuario autenticado
+func WithPrincipal(ctx context.Context, principal *auth.Principal) context.Context {
+	return context.WithValue(ctx, principalKey, principal)
+}
+
+// PrincipalFromContext obtiene el usuario autenticado de la petición
+func PrincipalFromContext(ctx context.Context) (*auth.Principal, bool) {
+	principal, ok := ctx.Value(principalKey).(*auth.Principal)
+	return principal, ok && principal != nil
+}
+
+// bearerToken extrae el token de la cabecera "Authorization: Bearer <token>"
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// writeAuthError envía una respuesta de error de autenticación en el formato de la API
+func writeAuthError(w http.ResponseWriter, statusCode int, code, message string) {
+	if statusCode == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	json.NewEncoder(w).Encode(dto.APIResponse{
+		Status:  "error",
+		Message: message,
+		Error: &dto.APIError{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
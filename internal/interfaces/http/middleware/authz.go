@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/authz"
+	"github.com/gorilla/mux"
+)
+
+// UserIDExtractor obtiene de la petición el ID del usuario sobre el que se actúa
+type UserIDExtractor func(r *http.Request) (int, bool)
+
+// RequirePermission exige que el usuario autenticado tenga el permiso indicado.
+// Debe usarse detrás de Authenticate.
+func RequirePermission(perm authz.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				writeAuthError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Authentication required")
+				return
+			}
+
+			if !authz.Allowed(principal.Roles, perm) {
+				writeAuthError(w, http.StatusForbidden, "FORBIDDEN", "Insufficient permissions")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSelfOrPermission permite la petición si actúa sobre el propio usuario
+// autenticado o si este tiene el permiso indicado.
+func RequireSelfOrPermission(perm authz.Permission, userID UserIDExtractor) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				writeAuthError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Authentication required")
+				return
+			}
+
+			if id, ok := userID(r); ok && id == principal.UserID {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !authz.Allowed(principal.Roles, perm) {
+				writeAuthError(w, http.StatusForbidden, "FORBIDDEN", "Insufficient permissions")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// UserIDFromPath obtiene el ID de usuario de la variable de ruta {id}
+func UserIDFromPath(r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	return id, err == nil
+}
+
+// UserIDFromQuery obtiene el ID de usuario del parámetro ?id= (rutas legacy)
+func UserIDFromQuery(r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	return id, err == nil
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/authz"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/infrastructure/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func serveAs(principal *auth.Principal, h http.Handler, target string) int {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if principal != nil {
+		req = req.WithContext(WithPrincipal(req.Context(), principal))
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestRequirePermission(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := RequirePermission(authz.PermUsersDelete)(ok)
+
+	assert.Equal(t, http.StatusUnauthorized, serveAs(nil, h, "/"))
+	assert.Equal(t, http.StatusForbidden, serveAs(&auth.Principal{UserID: 1}, h, "/"))
+	assert.Equal(t, http.StatusForbidden, serveAs(&auth.Principal{UserID: 1, Roles: []string{authz.RoleOperator}}, h, "/"))
+	assert.Equal(t, http.StatusOK, serveAs(&auth.Principal{UserID: 1, Roles: []string{authz.RoleAdmin}}, h, "/"))
+}
+
+func TestRequireSelfOrPermission(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := RequireSelfOrPermission(authz.PermUsersWrite, UserIDFromQuery)(ok)
+
+	viewer := &auth.Principal{UserID: 7, Roles: []string{authz.RoleViewer}}
+	assert.Equal(t, http.StatusOK, serveAs(viewer, h, "/update?id=7"), "Should allow acting on oneself")
+	assert.Equal(t, http.StatusForbidden, serveAs(viewer, h, "/update?id=8"))
+	assert.Equal(t, http.StatusForbidden, serveAs(viewer, h, "/update"))
+
+	admin := &auth.Principal{UserID: 1, Roles: []string{authz.RoleAdmin}}
+	assert.Equal(t, http.StatusOK, serveAs(admin, h, "/update?id=8"))
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// UnmatchedRoute es la ruta con la que se registran las peticiones que no
+// coinciden con ninguna ruta (404, 405)
+const UnmatchedRoute = "unmatched"
+
+// HTTPObserver recibe cada petición atendida, p. ej. para exportarla a Prometheus
+type HTTPObserver interface {
+	ObserveHTTP(route, method string, status int, duration time.Duration)
+}
+
+type routeKey struct{}
+
+// routeLabel es la ruta de la petición; los routers montados la sustituyen por
+// la suya con RecordRoute
+type routeLabel struct {
+	template string
+}
+
+// trackRoute guarda la ruta de la petición en su contexto para que Metrics y
+// Tracing la lean al terminar. Si ya la tiene (otro middleware) la reutiliza.
+func trackRoute(r *http.Request) (*http.Request, *routeLabel) {
+	if route, ok := r.Context().Value(routeKey{}).(*routeLabel); ok {
+		return r, route
+	}
+	route := &routeLabel{template: routeTemplate(r)}
+	return r.WithContext(context.WithValue(r.Context(), routeKey{}, route)), route
+}
+
+// Metrics mide el número y la duración de las peticiones por ruta y código de
+// estado. La ruta es la plantilla de gorilla/mux (/api/v1/users/{id:[0-9]+})
+// y no la URL, para que el número de series esté acotado.
+func Metrics(observer HTTPObserver) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			r, route := trackRoute(r)
+			wrapper := &responseWrapper{ResponseWriter: w, statusCode: http.StatusOK}
+
+			next.ServeHTTP(wrapper, r)
+
+			observer.ObserveHTTP(route.template, r.Method, wrapper.statusCode, time.Since(start))
+		})
+	}
+}
+
+// Mounted marca las peticiones delegadas en otro router como no encontradas
+// hasta que ese router las asocie a una de sus rutas con RecordRoute
+func Mounted(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(routeKey{}).(*routeLabel); ok {
+			route.template = UnmatchedRoute
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RecordRoute comunica a Metrics la ruta de un router montado con Mounted
+func RecordRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(routeKey{}).(*routeLabel); ok {
+			route.template = routeTemplate(r)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return UnmatchedRoute
+}
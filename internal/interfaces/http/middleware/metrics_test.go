@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+type observation struct {
+	route  string
+	method string
+	status int
+}
+
+type fakeObserver struct {
+	observed []observation
+}
+
+func (o *fakeObserver) ObserveHTTP(route, method string, status int, duration time.Duration) {
+	o.observed = append(o.observed, observation{route, method, status})
+}
+
+func TestMetricsUsesRouteTemplates(t *testing.T) {
+	observer := &fakeObserver{}
+
+	// Mismo montaje que cmd/server: rutas propias y un router /api/v1 montado
+	api := mux.NewRouter()
+	api.Use(RecordRoute)
+	api.HandleFunc("/api/v1/users/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods("GET")
+
+	root := mux.NewRouter()
+	root.Use(Metrics(observer))
+	root.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+	root.PathPrefix("/").Handler(Mounted(api))
+
+	for _, target := range []string{"/healthz", "/api/v1/users/7", "/api/v1/users/8", "/unknown"} {
+		root.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	assert.Equal(t, []observation{
+		{"/healthz", http.MethodGet, http.StatusOK},
+		{"/api/v1/users/{id:[0-9]+}", http.MethodGet, http.StatusNotFound},
+		{"/api/v1/users/{id:[0-9]+}", http.MethodGet, http.StatusNotFound},
+		{UnmatchedRoute, http.MethodGet, http.StatusNotFound},
+	}, observer.observed)
+}
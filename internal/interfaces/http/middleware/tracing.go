@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/tracing"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing abre un span por petición, hijo del contexto W3C (traceparent) que
+// traiga el cliente. El nombre del span usa la plantilla de la ruta, que en los
+// routers montados se conoce al terminar la petición.
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, route := trackRoute(r)
+		ctx := tracing.ExtractHTTP(r.Context(), r.Header)
+		ctx, span := tracing.Start(ctx, r.Method+" "+route.template,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				semconv.ServerAddress(r.Host),
+				semconv.ClientAddress(r.RemoteAddr),
+			),
+		)
+		defer span.End()
+
+		wrapper := &responseWrapper{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(wrapper, r.WithContext(ctx))
+
+		span.SetName(r.Method + " " + route.template)
+		span.SetAttributes(
+			semconv.HTTPRoute(route.template),
+			semconv.HTTPResponseStatusCode(wrapper.statusCode),
+		)
+		if wrapper.statusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(wrapper.statusCode))
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/tracing"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracingContinuesTraceparent(t *testing.T) {
+	if _, err := tracing.Setup(context.Background(), tracing.Config{Exporter: tracing.ExporterNone}); err != nil {
+		t.Fatal(err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(sdktrace.NewTracerProvider()) })
+
+	api := mux.NewRouter()
+	api.Use(RecordRoute)
+	api.HandleFunc("/api/v1/users/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		// Los servicios abren sus spans como hijos del de la petición
+		_, span := tracing.Start(r.Context(), "UserService.GetUser")
+		span.End()
+		w.WriteHeader(http.StatusInternalServerError)
+	}).Methods("GET")
+
+	root := mux.NewRouter()
+	root.Use(Tracing)
+	root.PathPrefix("/").Handler(Mounted(api))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users/7", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	root.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("se esperaban 2 spans, hay %d", len(spans))
+	}
+	service, server := spans[0], spans[1]
+
+	assert.Equal(t, "GET /api/v1/users/{id:[0-9]+}", server.Name())
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+	assert.True(t, server.Parent().IsRemote())
+	assert.Contains(t, server.Attributes(), semconv.HTTPRoute("/api/v1/users/{id:[0-9]+}"))
+	assert.Contains(t, server.Attributes(), semconv.HTTPResponseStatusCode(http.StatusInternalServerError))
+	assert.Equal(t, codes.Error, server.Status().Code)
+
+	assert.Equal(t, server.SpanContext().SpanID(), service.Parent().SpanID())
+}
@@ -3,20 +3,50 @@ package http
 import (
 	"net/http"
 
+	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/domain/authz"
 	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/interfaces/http/handlers"
 	"github.com/JorgeePG/prueba-api-http-postgresql-/internal/interfaces/http/middleware"
 	"github.com/gorilla/mux"
 )
 
+// Handlers agrupa los handlers HTTP de la API
+type Handlers struct {
+	User      *handlers.UserHandler
+	Mqtt      *handlers.MqttHandler
+	Auth      *handlers.AuthHandler
+	ACL       *handlers.TopicACLHandler
+	Stream    *handlers.MqttStreamHandler
+	Retention *handlers.RetentionHandler
+	Rollups   *handlers.RollupHandler
+	Alerts    *handlers.AlertHandler
+}
+
 // Router configura las rutas de la aplicación
 type Router struct {
-	userHandler *handlers.UserHandler
+	userHandler      *handlers.UserHandler
+	mqttHandler      *handlers.MqttHandler
+	authHandler      *handlers.AuthHandler
+	aclHandler       *handlers.TopicACLHandler
+	streamHandler    *handlers.MqttStreamHandler
+	retentionHandler *handlers.RetentionHandler
+	rollupHandler    *handlers.RollupHandler
+	alertHandler     *handlers.AlertHandler
+	authenticate     mux.MiddlewareFunc
 }
 
-// NewRouter crea una nueva instancia del router
-func NewRouter(userHandler *handlers.UserHandler) *Router {
+// NewRouter crea una nueva instancia del router.
+// authenticate protege todas las rutas salvo el login y el registro de usuarios.
+func NewRouter(h Handlers, authenticate mux.MiddlewareFunc) *Router {
 	return &Router{
-		userHandler: userHandler,
+		userHandler:      h.User,
+		mqttHandler:      h.Mqtt,
+		authHandler:      h.Auth,
+		aclHandler:       h.ACL,
+		streamHandler:    h.Stream,
+		retentionHandler: h.Retention,
+		rollupHandler:    h.Rollups,
+		alertHandler:     h.Alerts,
+		authenticate:     authenticate,
 	}
 }
 
@@ -25,6 +55,7 @@ func (router *Router) Setup() *mux.Router {
 	r := mux.NewRouter()
 
 	// Middleware global
+	r.Use(middleware.RecordRoute)
 	r.Use(middleware.CORS)
 	r.Use(middleware.JSONMiddleware)
 	r.Use(middleware.LoggingMiddleware)
@@ -32,21 +63,74 @@ func (router *Router) Setup() *mux.Router {
 	// API v1 routes
 	api := r.PathPrefix("/api/v1").Subrouter()
 
+	// Auth routes (públicas)
+	auth := api.PathPrefix("/auth").Subrouter()
+	auth.HandleFunc("/login", router.authHandler.Login).Methods("POST")
+	auth.HandleFunc("/refresh", router.authHandler.Refresh).Methods("POST")
+	auth.HandleFunc("/logout", router.authHandler.Logout).Methods("POST")
+	auth.HandleFunc("/password", router.authHandler.ChangePassword).Methods("POST")
+
+	// Registro de usuarios (público)
+	api.HandleFunc("/users", router.userHandler.CreateUser).Methods("POST")
+
+	// Stream en vivo de mensajes MQTT: admite el token en ?access_token= porque
+	// EventSource y WebSocket no permiten cabeceras propias en el navegador
+	mqttStream := api.PathPrefix("/mqtt/stream").Subrouter()
+	mqttStream.Use(middleware.TokenFromQuery, router.authenticate)
+	mqttStream.Handle("", requirePermission(authz.PermMqttMessagesRead, router.streamHandler.SSE)).Methods("GET")
+	mqttStream.Handle("/ws", requirePermission(authz.PermMqttMessagesRead, router.streamHandler.WebSocket)).Methods("GET")
+
+	// Rutas protegidas
+	protected := api.NewRoute().Subrouter()
+	protected.Use(router.authenticate)
+
 	// User routes
-	users := api.PathPrefix("/users").Subrouter()
-	users.HandleFunc("", router.userHandler.CreateUser).Methods("POST")
-	users.HandleFunc("", router.userHandler.ListUsers).Methods("GET")
-	users.HandleFunc("/{id:[0-9]+}", router.userHandler.GetUser).Methods("GET")
-	users.HandleFunc("/{id:[0-9]+}", router.userHandler.UpdateUser).Methods("PUT")
-	users.HandleFunc("/{id:[0-9]+}", router.userHandler.DeleteUser).Methods("DELETE")
-	users.HandleFunc("/{id:[0-9]+}/password", router.userHandler.ChangePassword).Methods("PUT")
-
-	// Health check
-	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"ok","message":"Server is running"}`))
-	}).Methods("GET")
+	users := protected.PathPrefix("/users").Subrouter()
+	users.Handle("", requirePermission(authz.PermUsersRead, router.userHandler.ListUsers)).Methods("GET")
+	users.Handle("/{id:[0-9]+}", requireSelfOrPermission(authz.PermUsersRead, router.userHandler.GetUser)).Methods("GET")
+	users.Handle("/{id:[0-9]+}", requireSelfOrPermission(authz.PermUsersWrite, router.userHandler.UpdateUser)).Methods("PUT")
+	users.Handle("/{id:[0-9]+}", requirePermission(authz.PermUsersDelete, router.userHandler.DeleteUser)).Methods("DELETE")
+	users.Handle("/{id:[0-9]+}/password", requireSelfOrPermission(authz.PermUsersWrite, router.userHandler.ChangePassword)).Methods("PUT")
+	users.Handle("/{id:[0-9]+}/roles", requirePermission(authz.PermRolesManage, router.userHandler.AssignRole)).Methods("POST")
+	users.Handle("/{id:[0-9]+}/roles/{role}", requirePermission(authz.PermRolesManage, router.userHandler.RevokeRole)).Methods("DELETE")
+
+	// MQTT routes
+	mqtt := protected.PathPrefix("/mqtt").Subrouter()
+	mqtt.Handle("/subscriptions", requirePermission(authz.PermMqttSubscriptionsRead, router.mqttHandler.ListSubscriptions)).Methods("GET")
+	mqtt.Handle("/subscriptions", requirePermission(authz.PermMqttSubscriptionsManage, router.mqttHandler.Subscribe)).Methods("POST")
+	mqtt.Handle("/subscriptions", requirePermission(authz.PermMqttSubscriptionsManage, router.mqttHandler.Unsubscribe)).Methods("DELETE")
+	mqtt.Handle("/messages", requirePermission(authz.PermMqttMessagesRead, router.mqttHandler.ListMessages)).Methods("GET")
+	mqtt.Handle("/aggregate", requirePermission(authz.PermMqttMessagesRead, router.mqttHandler.Aggregate)).Methods("GET")
+	mqtt.Handle("/publish", requirePermission(authz.PermMqttPublish, router.mqttHandler.Publish)).Methods("POST")
+	mqtt.Handle("/pipeline", requirePermission(authz.PermMqttSubscriptionsManage, router.mqttHandler.PipelineStats)).Methods("GET")
+	mqtt.Handle("/acls", requirePermission(authz.PermMqttACLsManage, router.aclHandler.ListACLs)).Methods("GET")
+	mqtt.Handle("/acls", requirePermission(authz.PermMqttACLsManage, router.aclHandler.CreateACL)).Methods("POST")
+	mqtt.Handle("/acls/export", requirePermission(authz.PermMqttACLsManage, router.aclHandler.ExportACLs)).Methods("GET")
+	mqtt.Handle("/acls/{id:[0-9]+}", requirePermission(authz.PermMqttACLsManage, router.aclHandler.DeleteACL)).Methods("DELETE")
+	mqtt.Handle("/retention", requirePermission(authz.PermMqttRetentionManage, router.retentionHandler.ListRules)).Methods("GET")
+	mqtt.Handle("/retention", requirePermission(authz.PermMqttRetentionManage, router.retentionHandler.CreateRule)).Methods("POST")
+	mqtt.Handle("/retention/dry-run", requirePermission(authz.PermMqttRetentionManage, router.retentionHandler.DryRun)).Methods("GET")
+	mqtt.Handle("/retention/{id:[0-9]+}", requirePermission(authz.PermMqttRetentionManage, router.retentionHandler.UpdateRule)).Methods("PUT")
+	mqtt.Handle("/retention/{id:[0-9]+}", requirePermission(authz.PermMqttRetentionManage, router.retentionHandler.DeleteRule)).Methods("DELETE")
+	mqtt.Handle("/rollups", requirePermission(authz.PermMqttRollupsManage, router.rollupHandler.ListConfigs)).Methods("GET")
+	mqtt.Handle("/rollups", requirePermission(authz.PermMqttRollupsManage, router.rollupHandler.CreateConfig)).Methods("POST")
+	mqtt.Handle("/rollups/{id:[0-9]+}", requirePermission(authz.PermMqttRollupsManage, router.rollupHandler.UpdateConfig)).Methods("PUT")
+	mqtt.Handle("/rollups/{id:[0-9]+}", requirePermission(authz.PermMqttRollupsManage, router.rollupHandler.DeleteConfig)).Methods("DELETE")
+	mqtt.Handle("/alerts", requirePermission(authz.PermMqttMessagesRead, router.alertHandler.ListAlerts)).Methods("GET")
+	mqtt.Handle("/alerts/rules", requirePermission(authz.PermMqttAlertsManage, router.alertHandler.ListRules)).Methods("GET")
+	mqtt.Handle("/alerts/rules", requirePermission(authz.PermMqttAlertsManage, router.alertHandler.CreateRule)).Methods("POST")
+	mqtt.Handle("/alerts/rules/{id:[0-9]+}", requirePermission(authz.PermMqttAlertsManage, router.alertHandler.UpdateRule)).Methods("PUT")
+	mqtt.Handle("/alerts/rules/{id:[0-9]+}", requirePermission(authz.PermMqttAlertsManage, router.alertHandler.DeleteRule)).Methods("DELETE")
 
 	return r
 }
+
+// requirePermission protege un handler con el permiso indicado
+func requirePermission(perm authz.Permission, h http.HandlerFunc) http.Handler {
+	return middleware.RequirePermission(perm)(h)
+}
+
+// requireSelfOrPermission permite el acceso al propio usuario {id} o a quien tenga el permiso
+func requireSelfOrPermission(perm authz.Permission, h http.HandlerFunc) http.Handler {
+	return middleware.RequireSelfOrPermission(perm, middleware.UserIDFromPath)(h)
+}
@@ -0,0 +1,165 @@
+// Package embedded arranca un broker MQTT 3.1.1 dentro del propio proceso,
+// para los tests de integración y para desarrollar sin un Mosquitto local.
+// Acepta cualquier cliente y permite publicar y suscribirse a cualquier topic:
+// no debe usarse en producción.
+package embedded
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/cmd/config"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultAddress escucha solo en local en un puerto libre elegido por el sistema
+const DefaultAddress = "127.0.0.1:0"
+
+// Options configura el broker embebido
+type Options struct {
+	// Address es la dirección donde escucha; por defecto DefaultAddress
+	Address string
+	// TLS genera una CA y un certificado para localhost y escucha con TLS
+	TLS bool
+	// CertDir es donde se escribe la CA generada; por defecto un directorio temporal
+	CertDir string
+	// Logger recibe los logs del broker; por defecto se descartan
+	Logger *slog.Logger
+}
+
+// Broker es un broker MQTT en marcha dentro del proceso
+type Broker struct {
+	server   *mqtt.Server
+	listener *listeners.TCP
+	tls      bool
+	caFile   string
+	// tempDir se elimina al cerrar si los certificados se generaron en un directorio temporal
+	tempDir string
+}
+
+// Start arranca el broker y no vuelve hasta que está aceptando conexiones
+func Start(opts Options) (*Broker, error) {
+	if opts.Address == "" {
+		opts.Address = DefaultAddress
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	b := &Broker{tls: opts.TLS}
+	listenerConfig := listeners.Config{ID: "embedded", Address: opts.Address}
+
+	if opts.TLS {
+		dir := opts.CertDir
+		if dir == "" {
+			tempDir, err := os.MkdirTemp("", "mqtt-embedded-")
+			if err != nil {
+				return nil, fmt.Errorf("no se pudo crear el directorio de certificados: %w", err)
+			}
+			dir, b.tempDir = tempDir, tempDir
+		}
+
+		cert, caFile, err := generateCertificates(dir)
+		if err != nil {
+			b.removeTempDir()
+			return nil, err
+		}
+		b.caFile = caFile
+		listenerConfig.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+
+	b.server = mqtt.New(&mqtt.Options{
+		Logger:       opts.Logger,
+		InlineClient: true,
+	})
+	if err := b.server.AddHook(new(auth.AllowHook), nil); err != nil {
+		b.removeTempDir()
+		return nil, err
+	}
+
+	// AddListener abre el puerto, así que al volver ya se conoce la dirección real
+	b.listener = listeners.NewTCP(listenerConfig)
+	if err := b.server.AddListener(b.listener); err != nil {
+		b.removeTempDir()
+		return nil, fmt.Errorf("no se pudo escuchar en %s: %w", opts.Address, err)
+	}
+	if err := b.server.Serve(); err != nil {
+		b.server.Close()
+		b.removeTempDir()
+		return nil, err
+	}
+
+	log.Info().Str("url", b.URL()).Bool("tls", opts.TLS).Msg("🧪 Broker MQTT embebido escuchando")
+	return b, nil
+}
+
+// URL devuelve la dirección del broker para MQTT_BROKERS, p. ej. "tcp://127.0.0.1:41873"
+func (b *Broker) URL() string {
+	scheme := "tcp"
+	if b.tls {
+		scheme = "ssl"
+	}
+	return fmt.Sprintf("%s://%s", scheme, b.listener.Address())
+}
+
+// CACertFile devuelve la ruta de la CA generada; vacía si el broker no usa TLS
+func (b *Broker) CACertFile() string {
+	return b.caFile
+}
+
+// ClientConfig adapta la configuración MQTT para conectarse a este broker.
+// Conserva los tiempos de espera y el prefijo del client ID de cfg.
+func (b *Broker) ClientConfig(cfg config.MQTTConfig) config.MQTTConfig {
+	cfg.Brokers = []string{b.URL()}
+	cfg.ClientCert = ""
+	cfg.ClientKey = ""
+	cfg.TLSInsecureSkipVerify = false
+	cfg.CACert = b.caFile
+	cfg.ServerName = ""
+	if b.tls {
+		cfg.ServerName = "localhost"
+	}
+	return cfg
+}
+
+// Publish publica un mensaje desde el propio broker, como si lo enviara un dispositivo
+func (b *Broker) Publish(topic string, payload []byte, retain bool, qos byte) error {
+	return b.server.Publish(topic, payload, retain, qos)
+}
+
+// DisconnectClients corta la conexión de todos los clientes, p. ej. para
+// probar la reconexión. Los clientes pueden volver a conectarse.
+func (b *Broker) DisconnectClients() int {
+	disconnected := 0
+	for _, client := range b.server.Clients.GetAll() {
+		if client.Net.Inline {
+			continue
+		}
+		client.Stop(fmt.Errorf("desconectado por el broker embebido"))
+		disconnected++
+	}
+	return disconnected
+}
+
+// Close detiene el broker, desconecta a los clientes y borra los certificados temporales
+func (b *Broker) Close() error {
+	err := b.server.Close()
+	b.removeTempDir()
+	return err
+}
+
+func (b *Broker) removeTempDir() {
+	if b.tempDir != "" {
+		os.RemoveAll(b.tempDir)
+	}
+}
@@ -0,0 +1,91 @@
+package embedded
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/cmd/config"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/mqttconn"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/stretchr/testify/assert"
+)
+
+func testClientConfig(b *Broker) config.MQTTConfig {
+	return b.ClientConfig(config.MQTTConfig{
+		ClientIDPrefix:   "test",
+		ConnectTimeout:   5 * time.Second,
+		KeepAlive:        30 * time.Second,
+		OperationTimeout: 5 * time.Second,
+		CleanSession:     true,
+	})
+}
+
+// roundTrip se conecta al broker, se suscribe a un topic y comprueba que
+// recibe lo que publica el propio broker
+func roundTrip(t *testing.T, b *Broker) {
+	cfg := testClientConfig(b)
+	opts, err := mqttconn.NewClientOptions(cfg, mqttconn.ClientID(cfg, "roundtrip", time.Now().UnixNano()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := mqtt.NewClient(opts)
+	waitToken(t, client.Connect())
+	defer client.Disconnect(0)
+
+	received := make(chan string, 1)
+	waitToken(t, client.Subscribe("sensores/+/temperatura", 1, func(_ mqtt.Client, msg mqtt.Message) {
+		received <- string(msg.Payload())
+	}))
+
+	if err := b.Publish("sensores/sala1/temperatura", []byte("21.5"), false, 1); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case payload := <-received:
+		assert.Equal(t, "21.5", payload)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Message was not received")
+	}
+}
+
+func waitToken(t *testing.T, token mqtt.Token) {
+	t.Helper()
+	if !token.WaitTimeout(5 * time.Second) {
+		t.Fatal("Timed out waiting for the broker")
+	}
+	if err := token.Error(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStartListensOnRandomPort(t *testing.T) {
+	b, err := Start(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	assert.True(t, strings.HasPrefix(b.URL(), "tcp://127.0.0.1:"))
+	assert.NotEqual(t, "tcp://127.0.0.1:0", b.URL())
+	assert.Empty(t, b.CACertFile())
+	roundTrip(t, b)
+}
+
+func TestStartWithTLS(t *testing.T) {
+	b, err := Start(Options{TLS: true, CertDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	assert.True(t, strings.HasPrefix(b.URL(), "ssl://127.0.0.1:"))
+	assert.FileExists(t, b.CACertFile())
+
+	cfg := testClientConfig(b)
+	assert.True(t, mqttconn.UsesTLS(cfg))
+	assert.Equal(t, b.CACertFile(), cfg.CACert)
+	roundTrip(t, b)
+}
@@ -0,0 +1,109 @@
+// Package mqttconn construye las opciones de conexión con el broker MQTT a
+// partir de la configuración de la aplicación (config.MQTTConfig).
+package mqttconn
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/cmd/config"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// NewClientOptions crea las opciones de un cliente paho con los brokers,
+// credenciales, TLS y tiempos de espera configurados
+func NewClientOptions(cfg config.MQTTConfig, clientID string) (*mqtt.ClientOptions, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, errors.New("no hay ningún broker MQTT configurado")
+	}
+
+	opts := mqtt.NewClientOptions().
+		SetClientID(clientID).
+		SetCleanSession(cfg.CleanSession).
+		SetConnectTimeout(cfg.ConnectTimeout).
+		SetKeepAlive(cfg.KeepAlive).
+		SetPingTimeout(cfg.PingTimeout).
+		SetWriteTimeout(cfg.WriteTimeout).
+		SetAutoReconnect(true).
+		SetMaxReconnectInterval(cfg.MaxReconnectInterval)
+
+	for _, broker := range cfg.Brokers {
+		opts.AddBroker(broker)
+	}
+
+	if cfg.Username != "" {
+		opts.SetUsername(cfg.Username)
+		opts.SetPassword(cfg.Password)
+	}
+
+	if UsesTLS(cfg) {
+		tlsConfig, err := NewTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	return opts, nil
+}
+
+// ClientID genera el client ID de un componente. Con sesión limpia se añade un
+// sufijo único; sin ella el ID debe ser estable para recuperar la sesión del broker.
+func ClientID(cfg config.MQTTConfig, component string, unique int64) string {
+	if cfg.CleanSession {
+		return fmt.Sprintf("%s-%s-%d", cfg.ClientIDPrefix, component, unique)
+	}
+	return fmt.Sprintf("%s-%s", cfg.ClientIDPrefix, component)
+}
+
+// UsesTLS indica si alguno de los brokers usa un esquema cifrado
+func UsesTLS(cfg config.MQTTConfig) bool {
+	for _, broker := range cfg.Brokers {
+		u, err := url.Parse(broker)
+		if err != nil {
+			continue
+		}
+		switch u.Scheme {
+		case "ssl", "tls", "mqtts", "tcps", "wss":
+			return true
+		}
+	}
+	return false
+}
+
+// NewTLSConfig carga la CA y, si se indican, el certificado y la clave de cliente
+func NewTLSConfig(cfg config.MQTTConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.TLSInsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if cfg.CACert != "" {
+		caCert, err := os.ReadFile(cfg.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("no se pudo leer el certificado CA: %w", err)
+		}
+
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("el certificado CA %s no es válido", cfg.CACert)
+		}
+		tlsConfig.RootCAs = caCertPool
+	}
+
+	if cfg.ClientCert != "" || cfg.ClientKey != "" {
+		clientCert, err := tls.LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("no se pudo cargar el certificado de cliente: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,42 @@
+package mqttconn
+
+import (
+	"testing"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/cmd/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsesTLS(t *testing.T) {
+	assert.True(t, UsesTLS(config.MQTTConfig{Brokers: []string{"ssl://localhost:8883"}}))
+	assert.True(t, UsesTLS(config.MQTTConfig{Brokers: []string{"tcp://a:1883", "mqtts://b:8883"}}))
+	assert.False(t, UsesTLS(config.MQTTConfig{Brokers: []string{"tcp://localhost:1883"}}))
+}
+
+func TestClientID(t *testing.T) {
+	cfg := config.MQTTConfig{ClientIDPrefix: "go", CleanSession: true}
+	assert.Equal(t, "go-subscriber-42", ClientID(cfg, "subscriber", 42))
+
+	cfg.CleanSession = false
+	assert.Equal(t, "go-subscriber", ClientID(cfg, "subscriber", 42), "Persistent sessions need a stable client ID")
+}
+
+func TestNewClientOptions(t *testing.T) {
+	_, err := NewClientOptions(config.MQTTConfig{}, "id")
+	assert.Error(t, err, "Should require at least one broker")
+
+	_, err = NewClientOptions(config.MQTTConfig{
+		Brokers: []string{"ssl://localhost:8883"},
+		CACert:  "does-not-exist.crt",
+	}, "id")
+	assert.Error(t, err, "Should fail when the CA certificate cannot be read")
+
+	opts, err := NewClientOptions(config.MQTTConfig{
+		Brokers:  []string{"tcp://localhost:1883"},
+		Username: "sensor1",
+		Password: "secret",
+	}, "id")
+	assert.NoError(t, err)
+	assert.Equal(t, "sensor1", opts.Username)
+	assert.Nil(t, opts.TLSConfig, "Plain tcp:// brokers should not configure TLS")
+}
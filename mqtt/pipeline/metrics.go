@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats es una instantánea de las métricas del pipeline
+type Stats struct {
+	QueueDepth    int    `json:"queue_depth"`
+	QueueCapacity int    `json:"queue_capacity"`
+	Backpressure  string `json:"backpressure"`
+
+	Enqueued  uint64 `json:"enqueued"`
+	Persisted uint64 `json:"persisted"`
+	Dropped   uint64 `json:"dropped"`
+	Spilled   uint64 `json:"spilled"`
+	Replayed  uint64 `json:"replayed"`
+	Failed    uint64 `json:"failed"`
+
+	Flushes         uint64  `json:"flushes"`
+	FlushErrors     uint64  `json:"flush_errors"`
+	LastFlushMillis float64 `json:"last_flush_ms"`
+	AvgFlushMillis  float64 `json:"avg_flush_ms"`
+	MaxFlushMillis  float64 `json:"max_flush_ms"`
+}
+
+// metrics son los contadores internos del pipeline, actualizados sin bloqueos
+type metrics struct {
+	enqueued  atomic.Uint64
+	persisted atomic.Uint64
+	dropped   atomic.Uint64
+	spilled   atomic.Uint64
+	replayed  atomic.Uint64
+	failed    atomic.Uint64
+
+	flushes        atomic.Uint64
+	flushErrors    atomic.Uint64
+	flushTotalNano atomic.Int64
+	flushLastNano  atomic.Int64
+	flushMaxNano   atomic.Int64
+}
+
+// observeFlush registra la duración de una escritura de lote
+func (m *metrics) observeFlush(d time.Duration, err error) {
+	m.flushes.Add(1)
+	if err != nil {
+		m.flushErrors.Add(1)
+	}
+
+	nanos := d.Nanoseconds()
+	m.flushTotalNano.Add(nanos)
+	m.flushLastNano.Store(nanos)
+	for {
+		current := m.flushMaxNano.Load()
+		if nanos <= current || m.flushMaxNano.CompareAndSwap(current, nanos) {
+			return
+		}
+	}
+}
+
+// Stats devuelve las métricas actuales del pipeline
+func (p *Pipeline) Stats() Stats {
+	m := &p.metrics
+	stats := Stats{
+		QueueDepth:      len(p.queue),
+		QueueCapacity:   cap(p.queue),
+		Backpressure:    p.cfg.Backpressure,
+		Enqueued:        m.enqueued.Load(),
+		Persisted:       m.persisted.Load(),
+		Dropped:         m.dropped.Load(),
+		Spilled:         m.spilled.Load(),
+		Replayed:        m.replayed.Load(),
+		Failed:          m.failed.Load(),
+		Flushes:         m.flushes.Load(),
+		FlushErrors:     m.flushErrors.Load(),
+		LastFlushMillis: millis(m.flushLastNano.Load()),
+		MaxFlushMillis:  millis(m.flushMaxNano.Load()),
+	}
+	if stats.Flushes > 0 {
+		stats.AvgFlushMillis = millis(m.flushTotalNano.Load() / int64(stats.Flushes))
+	}
+	return stats
+}
+
+func millis(nanos int64) float64 {
+	return float64(nanos) / float64(time.Millisecond)
+}
@@ -0,0 +1,402 @@
+// Package pipeline desacopla la recepción de mensajes MQTT de su escritura en
+// base de datos: los mensajes se encolan en memoria y unos workers los guardan
+// por lotes cuando se alcanza un tamaño o pasa un intervalo de tiempo.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/cmd/config"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/tracing"
+	"github.com/rs/zerolog/log"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Políticas ante una cola llena
+const (
+	// PolicyBlock bloquea al productor (el callback de paho) hasta que haya hueco
+	PolicyBlock = "block"
+	// PolicyDropOldest descarta el mensaje más antiguo de la cola
+	PolicyDropOldest = "drop_oldest"
+	// PolicySpill escribe en disco los mensajes que no caben y los reintenta después
+	PolicySpill = "spill"
+)
+
+// replayInterval es cada cuánto se reintentan los mensajes guardados en disco
+const replayInterval = 10 * time.Second
+
+var (
+	// ErrClosed se devuelve al encolar en un pipeline cerrado
+	ErrClosed = errors.New("el pipeline de mensajes está cerrado")
+	// ErrInvalidConfig se devuelve cuando la configuración del pipeline no es válida
+	ErrInvalidConfig = errors.New("configuración del pipeline no válida")
+)
+
+// BatchWriter guarda un lote de mensajes en una única operación
+type BatchWriter interface {
+	CreateBatch(ctx context.Context, messages []models.MqttMessage) error
+}
+
+// Pipeline es una cola acotada de mensajes MQTT pendientes de guardar
+type Pipeline struct {
+	cfg    config.PipelineConfig
+	writer BatchWriter
+	queue  chan models.MqttMessage
+	spill  *spillDir
+
+	// mu protege el cierre de la cola frente a los envíos en curso
+	mu      sync.RWMutex
+	started bool
+	closed  bool
+
+	workers    sync.WaitGroup
+	stopReplay chan struct{}
+	replayDone chan struct{}
+
+	// onPersisted recibe cada lote guardado (con sus ids), p. ej. para el stream en vivo
+	onPersisted func([]models.MqttMessage)
+	// onFailed recibe los mensajes que se pierden (descartados o lote fallido sin disco)
+	onFailed func([]models.MqttMessage)
+
+	metrics metrics
+}
+
+// New crea un pipeline con la configuración indicada. Hay que llamar a Start
+// para arrancar los workers y a Close para vaciar la cola al terminar.
+func New(cfg config.PipelineConfig, writer BatchWriter) (*Pipeline, error) {
+	if cfg.QueueSize <= 0 || cfg.BatchSize <= 0 || cfg.Workers <= 0 || cfg.FlushInterval <= 0 {
+		return nil, fmt.Errorf("%w: queue size, batch size, workers y flush interval deben ser positivos", ErrInvalidConfig)
+	}
+
+	switch cfg.Backpressure {
+	case PolicyBlock, PolicyDropOldest:
+	case PolicySpill:
+		if cfg.SpillDir == "" {
+			return nil, fmt.Errorf("%w: la política spill necesita un directorio", ErrInvalidConfig)
+		}
+	default:
+		return nil, fmt.Errorf("%w: política de backpressure desconocida %q (block, drop_oldest, spill)", ErrInvalidConfig, cfg.Backpressure)
+	}
+
+	p := &Pipeline{
+		cfg:        cfg,
+		writer:     writer,
+		queue:      make(chan models.MqttMessage, cfg.QueueSize),
+		stopReplay: make(chan struct{}),
+		replayDone: make(chan struct{}),
+	}
+
+	if cfg.SpillDir != "" {
+		spill, err := openSpillDir(cfg.SpillDir)
+		if err != nil {
+			return nil, err
+		}
+		p.spill = spill
+	}
+
+	return p, nil
+}
+
+// Start arranca los workers y, si hay directorio de desbordamiento, el
+// reintento periódico de los mensajes guardados en disco
+func (p *Pipeline) Start() {
+	log.Info().
+		Int("workers", p.cfg.Workers).
+		Int("queue_size", p.cfg.QueueSize).
+		Int("batch_size", p.cfg.BatchSize).
+		Str("backpressure", p.cfg.Backpressure).
+		Msg("🚚 Pipeline de mensajes MQTT iniciado")
+
+	p.mu.Lock()
+	p.started = true
+	p.mu.Unlock()
+
+	for i := 0; i < p.cfg.Workers; i++ {
+		p.workers.Add(1)
+		go p.worker()
+	}
+
+	if p.spill == nil {
+		close(p.replayDone)
+		return
+	}
+	go p.replayLoop()
+}
+
+// OnPersisted registra una función que recibe cada lote recién guardado, ya
+// con los ids asignados. Debe llamarse antes de Start y no debe bloquearse.
+func (p *Pipeline) OnPersisted(fn func([]models.MqttMessage)) {
+	p.onPersisted = fn
+}
+
+// OnFailed registra una función que recibe los mensajes que se pierden sin
+// guardarse. Debe llamarse antes de Start y no debe bloquearse.
+func (p *Pipeline) OnFailed(fn func([]models.MqttMessage)) {
+	p.onFailed = fn
+}
+
+// Enqueue añade un mensaje a la cola aplicando la política de backpressure
+// si está llena. Con PolicyBlock espera a que los workers liberen hueco.
+func (p *Pipeline) Enqueue(msg models.MqttMessage) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrClosed
+	}
+
+	select {
+	case p.queue <- msg:
+		p.metrics.enqueued.Add(1)
+		return nil
+	default:
+	}
+
+	switch p.cfg.Backpressure {
+	case PolicyDropOldest:
+		for {
+			select {
+			case dropped := <-p.queue:
+				p.metrics.dropped.Add(1)
+				p.notifyFailed([]models.MqttMessage{dropped})
+			default:
+			}
+			select {
+			case p.queue <- msg:
+				p.metrics.enqueued.Add(1)
+				return nil
+			default:
+			}
+		}
+	case PolicySpill:
+		if err := p.spill.write([]models.MqttMessage{msg}); err != nil {
+			p.metrics.failed.Add(1)
+			return fmt.Errorf("error guardando el mensaje en disco: %w", err)
+		}
+		p.metrics.spilled.Add(1)
+		return nil
+	default:
+		p.queue <- msg
+		p.metrics.enqueued.Add(1)
+		return nil
+	}
+}
+
+// Close deja de aceptar mensajes y espera a que los workers guarden lo que
+// queda en la cola. Si ctx vence antes, devuelve su error.
+func (p *Pipeline) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.queue)
+	if !p.started {
+		close(p.replayDone)
+	}
+	p.mu.Unlock()
+
+	close(p.stopReplay)
+
+	done := make(chan struct{})
+	go func() {
+		p.workers.Wait()
+		<-p.replayDone
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return fmt.Errorf("el pipeline no terminó de vaciarse (%d mensajes en cola): %w", len(p.queue), ctx.Err())
+	}
+
+	if p.spill != nil {
+		if err := p.spill.close(); err != nil {
+			return err
+		}
+	}
+
+	stats := p.Stats()
+	log.Info().
+		Uint64("persisted", stats.Persisted).
+		Uint64("dropped", stats.Dropped).
+		Uint64("spilled", stats.Spilled).
+		Uint64("failed", stats.Failed).
+		Msg("🛑 Pipeline de mensajes MQTT vaciado")
+	return nil
+}
+
+// worker acumula mensajes de la cola y los guarda al completar un lote o al
+// vencer el intervalo de flush. Al cerrarse la cola guarda el último lote.
+func (p *Pipeline) worker() {
+	defer p.workers.Done()
+
+	batch := make([]models.MqttMessage, 0, p.cfg.BatchSize)
+	ticker := time.NewTicker(p.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg, ok := <-p.queue:
+			if !ok {
+				p.flush(batch)
+				return
+			}
+			batch = append(batch, msg)
+			if len(batch) >= p.cfg.BatchSize {
+				p.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			p.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// flush guarda un lote. Si falla y hay directorio de desbordamiento, el lote
+// se guarda en disco para reintentarlo; si no, se pierde y se contabiliza.
+func (p *Pipeline) flush(batch []models.MqttMessage) {
+	if len(batch) == 0 {
+		return
+	}
+
+	err := p.write(batch)
+	if err == nil {
+		p.metrics.persisted.Add(uint64(len(batch)))
+		log.Debug().Int("messages", len(batch)).Msg("💾 Lote de mensajes guardado en base de datos")
+		if p.onPersisted != nil {
+			p.onPersisted(batch)
+		}
+		return
+	}
+
+	if p.spill != nil {
+		spillErr := p.spill.write(batch)
+		if spillErr == nil {
+			p.metrics.spilled.Add(uint64(len(batch)))
+			log.Warn().Err(err).Int("messages", len(batch)).Msg("⚠️ Error guardando el lote, se guarda en disco para reintentarlo")
+			return
+		}
+		err = errors.Join(err, spillErr)
+	}
+
+	p.metrics.failed.Add(uint64(len(batch)))
+	log.Error().Err(err).Int("messages", len(batch)).Msg("❌ Error guardando el lote de mensajes, se descarta")
+	p.notifyFailed(batch)
+}
+
+func (p *Pipeline) notifyFailed(messages []models.MqttMessage) {
+	if p.onFailed != nil {
+		p.onFailed(messages)
+	}
+}
+
+// write escribe el lote con el timeout configurado y registra la latencia.
+// El span de la escritura enlaza con el de la recepción de cada mensaje.
+func (p *Pipeline) write(batch []models.MqttMessage) (err error) {
+	ctx := context.Background()
+	if p.cfg.FlushTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.cfg.FlushTimeout)
+		defer cancel()
+	}
+
+	links := make([]trace.Link, 0, len(batch))
+	for _, msg := range batch {
+		if msg.SpanContext.IsValid() {
+			links = append(links, trace.Link{SpanContext: msg.SpanContext})
+		}
+	}
+	ctx, span := tracing.Start(ctx, "persist mqtt_messages",
+		trace.WithLinks(links...),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("mqtt"),
+			semconv.MessagingOperationTypeProcess,
+			semconv.MessagingBatchMessageCount(len(batch)),
+		),
+	)
+	defer tracing.End(span, &err)
+
+	start := time.Now()
+	err = p.writer.CreateBatch(ctx, batch)
+	p.metrics.observeFlush(time.Since(start), err)
+	return err
+}
+
+// replayLoop reintenta los mensajes guardados en disco al arrancar y después
+// periódicamente, siempre que la cola tenga hueco
+func (p *Pipeline) replayLoop() {
+	defer close(p.replayDone)
+
+	ticker := time.NewTicker(replayInterval)
+	defer ticker.Stop()
+
+	for {
+		if len(p.queue) <= cap(p.queue)/2 {
+			p.replay()
+		}
+
+		select {
+		case <-p.stopReplay:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// replay guarda en base de datos los segmentos de disco completos, por lotes.
+// Un segmento que falla a medias se reescribe solo con lo pendiente.
+func (p *Pipeline) replay() {
+	segments, err := p.spill.rotate()
+	if err != nil {
+		log.Error().Err(err).Msg("❌ Error listando los mensajes guardados en disco")
+		return
+	}
+
+	for _, segment := range segments {
+		messages, err := readSegment(segment)
+		if err != nil {
+			log.Error().Err(err).Str("segment", segment).Msg("❌ Error leyendo mensajes guardados en disco")
+			continue
+		}
+
+		written := 0
+		for written < len(messages) {
+			end := min(written+p.cfg.BatchSize, len(messages))
+			if err = p.write(messages[written:end]); err != nil {
+				break
+			}
+			// Los mensajes recuperados se notifican como cualquier lote guardado
+			if p.onPersisted != nil {
+				p.onPersisted(messages[written:end])
+			}
+			written = end
+		}
+		p.metrics.replayed.Add(uint64(written))
+
+		if err != nil {
+			log.Warn().Err(err).Int("pending", len(messages)-written).Msg("⚠️ No se pudieron recuperar los mensajes guardados en disco")
+			if written > 0 {
+				if err := p.spill.replace(segment, messages[written:]); err != nil {
+					log.Error().Err(err).Str("segment", segment).Msg("❌ Error reescribiendo el segmento de disco")
+				}
+			}
+			return
+		}
+
+		if err := removeSegment(segment); err != nil {
+			log.Error().Err(err).Str("segment", segment).Msg("❌ Error borrando el segmento de disco")
+			continue
+		}
+		log.Info().Int("messages", written).Str("segment", segment).Msg("♻️ Mensajes guardados en disco recuperados")
+	}
+}
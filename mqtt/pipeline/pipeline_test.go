@@ -0,0 +1,279 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/cmd/config"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fakeWriter guarda los lotes en memoria; puede fallar o bloquearse a demanda
+type fakeWriter struct {
+	mu      sync.Mutex
+	batches [][]models.MqttMessage
+	fail    bool
+	block   chan struct{}
+}
+
+func (w *fakeWriter) CreateBatch(ctx context.Context, messages []models.MqttMessage) error {
+	if w.block != nil {
+		<-w.block
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fail {
+		return errors.New("database unavailable")
+	}
+	w.batches = append(w.batches, append([]models.MqttMessage(nil), messages...))
+	return nil
+}
+
+func (w *fakeWriter) setFail(fail bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.fail = fail
+}
+
+func (w *fakeWriter) topics() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var topics []string
+	for _, batch := range w.batches {
+		for _, msg := range batch {
+			topics = append(topics, msg.Topic)
+		}
+	}
+	return topics
+}
+
+func testConfig() config.PipelineConfig {
+	return config.PipelineConfig{
+		QueueSize:     10,
+		BatchSize:     5,
+		Workers:       1,
+		FlushInterval: time.Hour,
+		Backpressure:  PolicyBlock,
+	}
+}
+
+func message(i int) models.MqttMessage {
+	return models.MqttMessage{Topic: fmt.Sprintf("sensores/%d", i), Payload: "{}", ReceivedAt: time.Now()}
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	cfg := testConfig()
+	cfg.Backpressure = "ignore"
+	_, err := New(cfg, &fakeWriter{})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+
+	cfg = testConfig()
+	cfg.Backpressure = PolicySpill
+	_, err = New(cfg, &fakeWriter{})
+	assert.ErrorIs(t, err, ErrInvalidConfig, "Spill needs a directory")
+
+	cfg = testConfig()
+	cfg.BatchSize = 0
+	_, err = New(cfg, &fakeWriter{})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestFlushesBySizeAndOnClose(t *testing.T) {
+	writer := &fakeWriter{}
+	p, err := New(testConfig(), writer)
+	assert.NoError(t, err)
+	p.Start()
+
+	for i := 0; i < 7; i++ {
+		assert.NoError(t, p.Enqueue(message(i)))
+	}
+
+	assert.Eventually(t, func() bool { return len(writer.topics()) == 5 }, time.Second, 5*time.Millisecond,
+		"A full batch should be written without waiting for the interval")
+
+	assert.NoError(t, p.Close(context.Background()))
+	assert.Len(t, writer.batches, 2, "Close should flush the partial batch")
+	assert.Len(t, writer.topics(), 7)
+	assert.ErrorIs(t, p.Enqueue(message(8)), ErrClosed)
+
+	stats := p.Stats()
+	assert.Equal(t, uint64(7), stats.Enqueued)
+	assert.Equal(t, uint64(7), stats.Persisted)
+	assert.Equal(t, uint64(2), stats.Flushes)
+}
+
+func TestFlushesByInterval(t *testing.T) {
+	writer := &fakeWriter{}
+	cfg := testConfig()
+	cfg.FlushInterval = 10 * time.Millisecond
+	p, err := New(cfg, writer)
+	assert.NoError(t, err)
+	p.Start()
+	defer p.Close(context.Background())
+
+	assert.NoError(t, p.Enqueue(message(1)))
+	assert.Eventually(t, func() bool { return len(writer.topics()) == 1 }, time.Second, 5*time.Millisecond)
+}
+
+func TestDropOldestWhenFull(t *testing.T) {
+	cfg := testConfig()
+	cfg.QueueSize = 3
+	cfg.Backpressure = PolicyDropOldest
+	writer := &fakeWriter{}
+	p, err := New(cfg, writer)
+	assert.NoError(t, err)
+	var lost []string
+	p.OnFailed(func(messages []models.MqttMessage) {
+		for _, msg := range messages {
+			lost = append(lost, msg.Topic)
+		}
+	})
+
+	// Sin Start la cola no se vacía
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, p.Enqueue(message(i)))
+	}
+	assert.Equal(t, 3, p.Stats().QueueDepth)
+	assert.Equal(t, uint64(2), p.Stats().Dropped)
+	assert.Equal(t, []string{"sensores/0", "sensores/1"}, lost)
+
+	p.Start()
+	assert.NoError(t, p.Close(context.Background()))
+	assert.Equal(t, []string{"sensores/2", "sensores/3", "sensores/4"}, writer.topics())
+}
+
+func TestBlockWaitsForRoom(t *testing.T) {
+	cfg := testConfig()
+	cfg.QueueSize = 1
+	cfg.BatchSize = 1
+	writer := &fakeWriter{block: make(chan struct{})}
+	p, err := New(cfg, writer)
+	assert.NoError(t, err)
+	p.Start()
+
+	// El worker se queda escribiendo el primero y el segundo ocupa la cola
+	assert.NoError(t, p.Enqueue(message(0)))
+	assert.Eventually(t, func() bool { return p.Stats().QueueDepth == 0 }, time.Second, time.Millisecond)
+	assert.NoError(t, p.Enqueue(message(1)))
+
+	enqueued := make(chan struct{})
+	go func() {
+		p.Enqueue(message(2))
+		close(enqueued)
+	}()
+
+	select {
+	case <-enqueued:
+		t.Fatal("Enqueue should block while the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(writer.block)
+	<-enqueued
+	assert.NoError(t, p.Close(context.Background()))
+	assert.Len(t, writer.topics(), 3)
+}
+
+func TestSpillAndReplay(t *testing.T) {
+	cfg := testConfig()
+	cfg.QueueSize = 2
+	cfg.Backpressure = PolicySpill
+	cfg.SpillDir = t.TempDir()
+	writer := &fakeWriter{}
+	p, err := New(cfg, writer)
+	assert.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, p.Enqueue(message(i)))
+	}
+	assert.Equal(t, uint64(2), p.Stats().Spilled)
+
+	// Un lote que no se puede escribir también acaba en disco
+	writer.setFail(true)
+	p.Start()
+	assert.NoError(t, p.Close(context.Background()))
+	assert.Equal(t, uint64(4), p.Stats().Spilled)
+	assert.Empty(t, writer.topics())
+
+	// Al arrancar de nuevo se recupera todo lo guardado en disco
+	writer.setFail(false)
+	p, err = New(cfg, writer)
+	assert.NoError(t, err)
+	var persisted atomic.Int64
+	p.OnPersisted(func(messages []models.MqttMessage) { persisted.Add(int64(len(messages))) })
+	p.Start()
+	assert.Eventually(t, func() bool { return len(writer.topics()) == 4 }, time.Second, 5*time.Millisecond)
+	assert.NoError(t, p.Close(context.Background()))
+	assert.ElementsMatch(t, []string{"sensores/0", "sensores/1", "sensores/2", "sensores/3"}, writer.topics())
+	assert.Equal(t, uint64(4), p.Stats().Replayed)
+	assert.Equal(t, int64(4), persisted.Load(), "Replayed messages are reported as persisted")
+
+	segments, err := p.spill.rotate()
+	assert.NoError(t, err)
+	assert.Empty(t, segments, "Replayed segments should be removed")
+}
+
+func TestFailedBatchIsReported(t *testing.T) {
+	writer := &fakeWriter{fail: true}
+	p, err := New(testConfig(), writer)
+	assert.NoError(t, err)
+	var lost int
+	p.OnFailed(func(messages []models.MqttMessage) { lost += len(messages) })
+	p.OnPersisted(func(messages []models.MqttMessage) { t.Error("Failed batches must not be reported as persisted") })
+
+	p.Start()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, p.Enqueue(message(i)))
+	}
+	assert.NoError(t, p.Close(context.Background()))
+	assert.Equal(t, 3, lost)
+	assert.Equal(t, uint64(3), p.Stats().Failed)
+}
+
+func TestFlushLinksReceiveSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	writer := &fakeWriter{}
+	p, err := New(testConfig(), writer)
+	assert.NoError(t, err)
+	p.Start()
+
+	var received []trace.SpanContext
+	for i := 0; i < 2; i++ {
+		_, span := otel.Tracer("test").Start(context.Background(), "receive")
+		span.End()
+		msg := message(i)
+		msg.SpanContext = span.SpanContext()
+		received = append(received, msg.SpanContext)
+		assert.NoError(t, p.Enqueue(msg))
+	}
+	assert.NoError(t, p.Close(context.Background()))
+
+	var persist sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "persist mqtt_messages" {
+			persist = span
+		}
+	}
+	if persist == nil {
+		t.Fatal("no se registró el span de escritura del lote")
+	}
+	var linked []trace.SpanContext
+	for _, link := range persist.Links() {
+		linked = append(linked, link.SpanContext)
+	}
+	assert.Equal(t, received, linked)
+}
@@ -0,0 +1,150 @@
+package pipeline
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
+)
+
+// segmentPattern identifica los ficheros de mensajes desbordados
+const segmentPattern = "segment-*.jsonl"
+
+// spillDir guarda en disco, en ficheros JSON Lines, los mensajes que no caben
+// en la cola o que no se pudieron escribir. Se escribe siempre en el segmento
+// actual; rotate lo cierra para que los anteriores se puedan recuperar.
+type spillDir struct {
+	dir string
+
+	mu      sync.Mutex
+	current *os.File
+	writer  *bufio.Writer
+}
+
+// openSpillDir crea el directorio si no existe
+func openSpillDir(dir string) (*spillDir, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("no se pudo crear el directorio de desbordamiento: %w", err)
+	}
+	return &spillDir{dir: dir}, nil
+}
+
+// write añade los mensajes al segmento actual y los lleva a disco
+func (s *spillDir) write(messages []models.MqttMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current == nil {
+		name := filepath.Join(s.dir, fmt.Sprintf("segment-%020d.jsonl", time.Now().UnixNano()))
+		file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+		if err != nil {
+			return err
+		}
+		s.current = file
+		s.writer = bufio.NewWriter(file)
+	}
+
+	encoder := json.NewEncoder(s.writer)
+	for i := range messages {
+		if err := encoder.Encode(&messages[i]); err != nil {
+			return err
+		}
+	}
+	return s.writer.Flush()
+}
+
+// rotate cierra el segmento actual y devuelve todos los segmentos cerrados,
+// del más antiguo al más reciente
+func (s *spillDir) rotate() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.closeCurrent(); err != nil {
+		return nil, err
+	}
+
+	segments, err := filepath.Glob(filepath.Join(s.dir, segmentPattern))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(segments)
+	return segments, nil
+}
+
+// replace sustituye un segmento por otro con los mensajes indicados
+func (s *spillDir) replace(segment string, messages []models.MqttMessage) error {
+	tmp := segment + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for i := range messages {
+		if err := encoder.Encode(&messages[i]); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, segment)
+}
+
+// close cierra el segmento actual
+func (s *spillDir) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeCurrent()
+}
+
+func (s *spillDir) closeCurrent() error {
+	if s.current == nil {
+		return nil
+	}
+
+	err := s.writer.Flush()
+	if closeErr := s.current.Close(); err == nil {
+		err = closeErr
+	}
+	s.current = nil
+	s.writer = nil
+	return err
+}
+
+// readSegment lee todos los mensajes de un segmento
+func readSegment(segment string) ([]models.MqttMessage, error) {
+	file, err := os.Open(segment)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var messages []models.MqttMessage
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for decoder.More() {
+		var msg models.MqttMessage
+		if err := decoder.Decode(&msg); err != nil {
+			return nil, fmt.Errorf("%s: %w", segment, err)
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// removeSegment borra un segmento ya recuperado
+func removeSegment(segment string) error {
+	return os.Remove(segment)
+}
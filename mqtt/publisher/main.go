@@ -1,13 +1,14 @@
 package main
 
 import (
-	"crypto/tls"
-	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"os"
 	"time"
 
+	"github.com/JorgeePG/prueba-api-http-postgresql-/cmd/config"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/mqttconn"
+
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -20,42 +21,16 @@ func main() {
 
 	log.Info().Msg("🚀 Iniciando MQTT Publisher")
 
-	// Cargar el certificado CA del broker
-	caCert, err := os.ReadFile("./cert/ca.crt")
+	// Misma configuración de conexión que el suscriptor de la aplicación (MQTT_*)
+	cfg, err := config.Load()
 	if err != nil {
-		log.Fatal().Err(err).Msg("No se pudo leer el certificado CA")
-	}
-
-	caCertPool := x509.NewCertPool()
-	if !caCertPool.AppendCertsFromPEM(caCert) {
-		log.Fatal().Msg("No se pudo agregar el certificado CA al pool")
+		log.Fatal().Err(err).Msg("Error al cargar la configuración")
 	}
 
-	// Intentar cargar certificado de cliente (opcional)
-	var tlsConfig *tls.Config
-	clientCert, err := tls.LoadX509KeyPair("./cert/client.crt", "./cert/client.key")
+	opts, err := mqttconn.NewClientOptions(cfg.MQTT, mqttconn.ClientID(cfg.MQTT, "publisher", time.Now().UnixNano()))
 	if err != nil {
-		log.Warn().Err(err).Msg("No se encontraron certificados de cliente, usando solo CA")
-		tlsConfig = &tls.Config{
-			RootCAs:            caCertPool,
-			InsecureSkipVerify: true, // Solo para pruebas, ponlo en false en producción
-			ServerName:         "localhost",
-		}
-	} else {
-		tlsConfig = &tls.Config{
-			RootCAs:            caCertPool,
-			Certificates:       []tls.Certificate{clientCert},
-			InsecureSkipVerify: true, // Solo para pruebas, ponlo en false en producción
-			ServerName:         "localhost",
-		}
+		log.Fatal().Err(err).Msg("Configuración MQTT no válida")
 	}
-
-	opts := mqtt.NewClientOptions().
-		AddBroker("ssl://localhost:8883").
-		SetClientID("go-publisher").
-		SetTLSConfig(tlsConfig).
-		SetUsername("publisher").
-		SetPassword("publisher")
 	client := mqtt.NewClient(opts)
 
 	if token := client.Connect(); token.Wait() && token.Error() != nil {
@@ -101,7 +76,11 @@ func main() {
 
 		// Publicar mensaje
 		token := client.Publish(currentTopic, 1, false, string(jsonMsg))
-		token.Wait()
+		if !token.WaitTimeout(cfg.MQTT.OperationTimeout) {
+			log.Error().Str("topic", currentTopic).Msg("Tiempo de espera agotado publicando el mensaje")
+		} else if token.Error() != nil {
+			log.Error().Err(token.Error()).Str("topic", currentTopic).Msg("Error publicando el mensaje")
+		}
 
 		log.Info().
 			Str("topic", currentTopic).
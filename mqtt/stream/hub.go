@@ -0,0 +1,176 @@
+// Package stream reparte en vivo los mensajes MQTT recibidos entre los
+// clientes conectados (SSE o WebSocket), cada uno con sus propios filtros.
+package stream
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/mqtttopic"
+)
+
+// Filter decide qué mensajes recibe un cliente
+type Filter struct {
+	// Topics son los filtros MQTT pedidos por el cliente; vacío equivale a "#"
+	Topics []string
+	// Restricted limita los mensajes a los topics de Allowed (ACL del usuario)
+	Restricted bool
+	Allowed    []string
+}
+
+// Matches indica si el mensaje del topic indicado pasa el filtro
+func (f Filter) Matches(topic string) bool {
+	if f.Restricted && !matchesAny(f.Allowed, topic) {
+		return false
+	}
+	return len(f.Topics) == 0 || matchesAny(f.Topics, topic)
+}
+
+func matchesAny(filters []string, topic string) bool {
+	for _, filter := range filters {
+		if mqtttopic.Match(filter, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// Client es un consumidor del stream. Si no lee a tiempo y se llena su
+// buffer, el hub lo expulsa y cierra Done.
+type Client struct {
+	filter   Filter
+	messages chan models.MqttMessage
+	done     chan struct{}
+	once     sync.Once
+	evicted  atomic.Bool
+}
+
+// Messages devuelve el canal con los mensajes del cliente
+func (c *Client) Messages() <-chan models.MqttMessage {
+	return c.messages
+}
+
+// Done se cierra cuando el cliente sale del hub (expulsado o dado de baja)
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Evicted indica si el cliente fue expulsado por no consumir a tiempo
+func (c *Client) Evicted() bool {
+	return c.evicted.Load()
+}
+
+func (c *Client) close() {
+	c.once.Do(func() { close(c.done) })
+}
+
+// Stats resume la actividad del hub
+type Stats struct {
+	Clients   int    `json:"clients"`
+	Delivered uint64 `json:"delivered"`
+	Evicted   uint64 `json:"evicted"`
+}
+
+// Hub reparte los mensajes publicados entre los clientes registrados
+type Hub struct {
+	mu      sync.RWMutex
+	clients map[*Client]struct{}
+	closed  bool
+
+	delivered atomic.Uint64
+	evicted   atomic.Uint64
+}
+
+// NewHub crea un hub sin clientes
+func NewHub() *Hub {
+	return &Hub{clients: make(map[*Client]struct{})}
+}
+
+// Register da de alta un cliente con un buffer de bufferSize mensajes. Si el
+// hub ya está cerrado, el cliente se devuelve con Done cerrado.
+func (h *Hub) Register(filter Filter, bufferSize int) *Client {
+	if bufferSize <= 0 {
+		bufferSize = 1
+	}
+
+	client := &Client{
+		filter:   filter,
+		messages: make(chan models.MqttMessage, bufferSize),
+		done:     make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		client.close()
+		return client
+	}
+	h.clients[client] = struct{}{}
+	return client
+}
+
+// Unregister da de baja un cliente
+func (h *Hub) Unregister(client *Client) {
+	h.mu.Lock()
+	delete(h.clients, client)
+	h.mu.Unlock()
+	client.close()
+}
+
+// Publish entrega los mensajes a los clientes cuyo filtro los acepta. Nunca
+// se bloquea: el cliente que tiene el buffer lleno se expulsa.
+func (h *Hub) Publish(messages []models.MqttMessage) {
+	var slow []*Client
+
+	h.mu.RLock()
+	for client := range h.clients {
+		for _, msg := range messages {
+			if !client.filter.Matches(msg.Topic) {
+				continue
+			}
+			select {
+			case client.messages <- msg:
+				h.delivered.Add(1)
+				continue
+			default:
+			}
+			slow = append(slow, client)
+			break
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range slow {
+		if client.evicted.CompareAndSwap(false, true) {
+			h.evicted.Add(1)
+			h.Unregister(client)
+		}
+	}
+}
+
+// Close da de baja a todos los clientes y rechaza los que se registren después
+func (h *Hub) Close() {
+	h.mu.Lock()
+	h.closed = true
+	clients := h.clients
+	h.clients = make(map[*Client]struct{})
+	h.mu.Unlock()
+
+	for client := range clients {
+		client.close()
+	}
+}
+
+// Stats devuelve la actividad del hub
+func (h *Hub) Stats() Stats {
+	h.mu.RLock()
+	clients := len(h.clients)
+	h.mu.RUnlock()
+
+	return Stats{
+		Clients:   clients,
+		Delivered: h.delivered.Load(),
+		Evicted:   h.evicted.Load(),
+	}
+}
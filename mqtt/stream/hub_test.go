@@ -0,0 +1,89 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func messages(topics ...string) []models.MqttMessage {
+	result := make([]models.MqttMessage, len(topics))
+	for i, topic := range topics {
+		result[i] = models.MqttMessage{ID: i + 1, Topic: topic}
+	}
+	return result
+}
+
+func TestFilterMatches(t *testing.T) {
+	assert.True(t, Filter{}.Matches("sensores/temp"), "No topics means everything")
+	assert.True(t, Filter{Topics: []string{"sensores/+"}}.Matches("sensores/temp"))
+	assert.False(t, Filter{Topics: []string{"sensores/+"}}.Matches("alarmas/puerta"))
+
+	restricted := Filter{Restricted: true, Allowed: []string{"sensores/#"}}
+	assert.True(t, restricted.Matches("sensores/temp"))
+	assert.False(t, restricted.Matches("alarmas/puerta"), "ACLs apply even without topic filters")
+	assert.False(t, Filter{Restricted: true}.Matches("sensores/temp"))
+}
+
+func TestPublishDeliversByFilter(t *testing.T) {
+	hub := NewHub()
+	sensors := hub.Register(Filter{Topics: []string{"sensores/#"}}, 10)
+	all := hub.Register(Filter{}, 10)
+
+	hub.Publish(messages("sensores/temp", "alarmas/puerta"))
+
+	assert.Len(t, sensors.Messages(), 1)
+	assert.Equal(t, "sensores/temp", (<-sensors.Messages()).Topic)
+	assert.Len(t, all.Messages(), 2)
+	assert.Equal(t, uint64(3), hub.Stats().Delivered)
+}
+
+func TestPublishEvictsSlowConsumer(t *testing.T) {
+	hub := NewHub()
+	slow := hub.Register(Filter{}, 1)
+	fast := hub.Register(Filter{}, 10)
+
+	hub.Publish(messages("a", "b"))
+
+	select {
+	case <-slow.Done():
+	default:
+		t.Fatal("Slow consumer should be evicted")
+	}
+	assert.True(t, slow.Evicted())
+	assert.False(t, fast.Evicted())
+	assert.Len(t, fast.Messages(), 2, "Other clients are not affected")
+
+	stats := hub.Stats()
+	assert.Equal(t, 1, stats.Clients)
+	assert.Equal(t, uint64(1), stats.Evicted)
+}
+
+func TestUnregisterAndClose(t *testing.T) {
+	hub := NewHub()
+	client := hub.Register(Filter{}, 1)
+	hub.Unregister(client)
+	hub.Unregister(client)
+	assert.False(t, client.Evicted())
+
+	other := hub.Register(Filter{}, 1)
+	hub.Close()
+	<-other.Done()
+	assert.Equal(t, 0, hub.Stats().Clients)
+}
+
+func TestRegisterAfterClose(t *testing.T) {
+	hub := NewHub()
+	hub.Close()
+
+	client := hub.Register(Filter{}, 1)
+	select {
+	case <-client.Done():
+	default:
+		t.Fatal("Client registered after Close should be done")
+	}
+	hub.Publish([]models.MqttMessage{{Topic: "sensores/sala1"}})
+	assert.Empty(t, client.Messages())
+	assert.Equal(t, 0, hub.Stats().Clients)
+}
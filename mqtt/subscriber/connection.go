@@ -0,0 +1,270 @@
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/mqttconn"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/mqtttopic"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/tracing"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/rs/zerolog/log"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrBrokerUnavailable se devuelve cuando no hay conexión con el broker MQTT
+var ErrBrokerUnavailable = errors.New("broker MQTT no disponible")
+
+// connect devuelve el cliente compartido, creándolo y conectándolo si hace falta.
+// El cliente reintenta la conexión en segundo plano aunque aquí se agote la espera.
+func (sm *SubscriberManager) connect() (mqtt.Client, error) {
+	sm.connMu.Lock()
+	if sm.client == nil {
+		if sm.cfg == nil {
+			sm.connMu.Unlock()
+			return nil, ErrNotConfigured
+		}
+
+		clientID := mqttconn.ClientID(*sm.cfg, "subscriber", time.Now().UnixNano())
+		opts, err := mqttconn.NewClientOptions(*sm.cfg, clientID)
+		if err != nil {
+			sm.connMu.Unlock()
+			return nil, err
+		}
+
+		opts.
+			SetConnectRetry(true).
+			SetConnectRetryInterval(sm.cfg.MaxReconnectInterval).
+			SetConnectionLostHandler(func(client mqtt.Client, err error) {
+				sm.metrics.connectionLost.Add(1)
+				log.Error().Err(err).Msg("🔴 Conexión MQTT perdida")
+			}).
+			SetReconnectingHandler(func(client mqtt.Client, opts *mqtt.ClientOptions) {
+				log.Warn().Strs("brokers", sm.cfg.Brokers).Msg("🔄 Reconectando con el broker MQTT...")
+			}).
+			SetOnConnectHandler(sm.onConnect).
+			SetDefaultPublishHandler(sm.dispatch)
+
+		log.Info().Strs("brokers", sm.cfg.Brokers).Str("client_id", clientID).Msg("🔌 Intentando conectar con el broker MQTT...")
+
+		sm.client = mqtt.NewClient(opts)
+		sm.connectToken = sm.client.Connect()
+	}
+	connectTimeout := sm.cfg.ConnectTimeout
+	client, token := sm.client, sm.connectToken
+	sm.connMu.Unlock()
+
+	if client.IsConnectionOpen() {
+		return client, nil
+	}
+
+	if !token.WaitTimeout(connectTimeout) || !client.IsConnectionOpen() {
+		// Lo registrado se suscribirá en cuanto se establezca la conexión
+		sm.connMu.Lock()
+		sm.pendingResubscribe = true
+		sm.connMu.Unlock()
+		return nil, ErrBrokerUnavailable
+	}
+	if err := token.Error(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBrokerUnavailable, err)
+	}
+
+	return client, nil
+}
+
+// onConnect renueva todas las suscripciones tras una reconexión. En la primera
+// conexión no hace nada: quien la solicitó envía sus propias suscripciones.
+func (sm *SubscriberManager) onConnect(client mqtt.Client) {
+	sm.connMu.Lock()
+	resubscribe := sm.connected || sm.pendingResubscribe
+	sm.connected = true
+	sm.pendingResubscribe = false
+	sm.connMu.Unlock()
+
+	log.Info().Strs("brokers", sm.cfg.Brokers).Msg("🟢 Conectado al broker MQTT como suscriptor")
+	if !resubscribe {
+		return
+	}
+	sm.metrics.reconnects.Add(1)
+
+	topics := sm.GetActiveSubscribers()
+	if len(topics) == 0 {
+		return
+	}
+
+	codes, err := sm.subscribeAll(client, topics)
+	if err != nil {
+		log.Error().Err(err).Int("topics", len(topics)).Msg("❌ Error renovando las suscripciones tras la reconexión")
+		return
+	}
+	for topic, code := range codes {
+		if code > 2 {
+			log.Error().Str("topic", topic).Uint8("code", code).Msg("❌ El broker rechazó la suscripción al reconectar")
+		}
+	}
+
+	log.Info().Int("topics", len(topics)).Msg("♻️ Suscripciones renovadas tras la reconexión")
+}
+
+// subscribeAll envía las suscripciones registradas de los topics en un único
+// paquete SUBSCRIBE. Devuelve el código de retorno de cada topic.
+func (sm *SubscriberManager) subscribeAll(client mqtt.Client, topics []string) (map[string]byte, error) {
+	filters := make(map[string]byte, len(topics))
+
+	sm.mu.RLock()
+	for _, topic := range topics {
+		if info, ok := sm.subscribers[topic]; ok {
+			filters[topic] = info.QOS
+		}
+	}
+	sm.mu.RUnlock()
+
+	if len(filters) == 0 {
+		return map[string]byte{}, nil
+	}
+
+	// Sin callback: los mensajes llegan a dispatch, que elige el handler de cada topic
+	token := client.SubscribeMultiple(filters, nil)
+	if !token.WaitTimeout(sm.cfg.OperationTimeout) {
+		return nil, fmt.Errorf("tiempo de espera agotado esperando la confirmación del broker")
+	}
+	if err := token.Error(); err != nil {
+		return nil, err
+	}
+
+	if st, ok := token.(*mqtt.SubscribeToken); ok {
+		return st.Result(), nil
+	}
+	return filters, nil
+}
+
+// dispatch entrega cada mensaje recibido al handler de la suscripción que le
+// corresponde. Si varias suscripciones coinciden (p. ej. "a/b" y "a/#") se usa
+// solo una, la más específica, para no guardar el mensaje varias veces.
+func (sm *SubscriberManager) dispatch(client mqtt.Client, msg mqtt.Message) {
+	if handler := sm.handlerFor(msg.Topic()); handler != nil {
+		handler(client, msg)
+		return
+	}
+	log.Warn().Str("topic", msg.Topic()).Msg("⚠️ Mensaje recibido sin suscripción asociada")
+}
+
+// handlerFor busca el handler de la suscripción que corresponde al topic
+func (sm *SubscriberManager) handlerFor(topic string) mqtt.MessageHandler {
+	if info := sm.subscriptionFor(topic); info != nil {
+		return info.Handler
+	}
+	return nil
+}
+
+// subscriptionFor busca la suscripción que corresponde al topic: la exacta o,
+// si no la hay, la más específica de las que coinciden
+func (sm *SubscriberManager) subscriptionFor(topic string) *SubscriberInfo {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if info, ok := sm.subscribers[topic]; ok {
+		return info
+	}
+
+	var match *SubscriberInfo
+	for filter, info := range sm.subscribers {
+		if mqtttopic.Match(filter, topic) && (match == nil || mqtttopic.MoreSpecific(filter, match.Topic)) {
+			match = info
+		}
+	}
+	return match
+}
+
+// topicHandler crea el handler de mensajes de una suscripción
+func (sm *SubscriberManager) topicHandler(subscription string) mqtt.MessageHandler {
+	return func(client mqtt.Client, msg mqtt.Message) {
+		sm.handleMessage(subscription, msg)
+	}
+}
+
+// handleMessage guarda en base de datos un mensaje recibido por la suscripción
+// indicada. Con pipeline solo se encola; el guardado se hace por lotes.
+//
+// Cada mensaje abre un span de recepción. El cliente es MQTT 3.1.1, que no
+// tiene propiedades de usuario, así que la traza empieza aquí; un cliente
+// MQTT v5 obtendría el contexto del emisor con tracing.ExtractUserProperties.
+func (sm *SubscriberManager) handleMessage(subscription string, msg mqtt.Message) {
+	log.Debug().Str("subscription", subscription).Str("topic", msg.Topic()).Msg("📥 Mensaje recibido")
+
+	ctx, span := tracing.Start(context.Background(), "receive "+subscription,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("mqtt"),
+			semconv.MessagingOperationTypeReceive,
+			semconv.MessagingDestinationName(msg.Topic()),
+			semconv.MessagingDestinationSubscriptionName(subscription),
+			semconv.MessagingMessageBodySize(len(msg.Payload())),
+		),
+	)
+	var err error
+	defer func() { tracing.End(span, &err) }()
+
+	mqttMessage := models.NewMqttMessage(msg.Topic(), msg.Payload(), int(msg.Qos()), msg.Retained(), time.Now())
+	mqttMessage.SpanContext = span.SpanContext()
+	counters := sm.metrics.topic(subscription)
+	counters.received.Add(1)
+	if sm.onReceived != nil {
+		sm.onReceived(mqttMessage)
+	}
+
+	if sm.pipeline != nil {
+		if err = sm.pipeline.Enqueue(mqttMessage); err != nil {
+			counters.failed.Add(1)
+			log.Error().
+				Err(err).
+				Str("topic", msg.Topic()).
+				Msg("❌ Error encolando mensaje para guardarlo")
+		}
+		return
+	}
+
+	if sm.mqttRepo == nil {
+		log.Warn().Msg("⚠️  Base de datos no configurada, solo registrando mensaje")
+		log.Info().
+			Str("topic", msg.Topic()).
+			Str("payload", string(msg.Payload())).
+			Msg("📥 Mensaje recibido")
+		sm.hub.Publish([]models.MqttMessage{mqttMessage})
+		return
+	}
+
+	if err = sm.mqttRepo.Create(ctx, &mqttMessage); err != nil {
+		counters.failed.Add(1)
+		log.Error().
+			Err(err).
+			Str("topic", msg.Topic()).
+			Msg("❌ Error guardando mensaje en base de datos")
+		return
+	}
+	counters.persisted.Add(1)
+
+	log.Info().
+		Str("topic", msg.Topic()).
+		Int("message_id", mqttMessage.ID).
+		Str("payload", string(msg.Payload())).
+		Msg("💾 Mensaje guardado en base de datos")
+
+	sm.hub.Publish([]models.MqttMessage{mqttMessage})
+}
+
+// topicsOf devuelve los topics de un mapa de filtros en orden estable
+func topicsOf(filters map[string]byte) []string {
+	topics := make([]string, 0, len(filters))
+	for topic := range filters {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
@@ -0,0 +1,257 @@
+package subscriber
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/cmd/config"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/embedded"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/pipeline"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/stream"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+// Los tests de integración usan un broker MQTT embebido en un puerto aleatorio,
+// así que no necesitan un Mosquitto ni certificados.
+
+const receiveTimeout = 5 * time.Second
+
+// startBroker arranca un broker embebido que se cierra al terminar el test
+func startBroker(t *testing.T, tls bool) *embedded.Broker {
+	t.Helper()
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	t.Cleanup(func() { zerolog.SetGlobalLevel(zerolog.TraceLevel) })
+
+	b, err := embedded.Start(embedded.Options{TLS: tls})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+// configureManager conecta el manager al broker con tiempos de espera cortos
+func configureManager(t *testing.T, sm *SubscriberManager, b *embedded.Broker) {
+	t.Helper()
+	cfg := b.ClientConfig(config.MQTTConfig{
+		ClientIDPrefix:       "test",
+		ConnectTimeout:       receiveTimeout,
+		KeepAlive:            30 * time.Second,
+		MaxReconnectInterval: 200 * time.Millisecond,
+		OperationTimeout:     receiveTimeout,
+		CleanSession:         true,
+	})
+	if err := sm.Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sm.DisconnectAll)
+}
+
+// listen registra un cliente en el hub del manager para ver los mensajes recibidos
+func listen(sm *SubscriberManager) *stream.Client {
+	return sm.Stream().Register(stream.Filter{}, 100)
+}
+
+func receive(t *testing.T, client *stream.Client) models.MqttMessage {
+	t.Helper()
+	select {
+	case msg := <-client.Messages():
+		return msg
+	case <-time.After(receiveTimeout):
+		t.Fatal("Message was not received")
+		return models.MqttMessage{}
+	}
+}
+
+func assertNothingReceived(t *testing.T, client *stream.Client) {
+	t.Helper()
+	select {
+	case msg := <-client.Messages():
+		t.Fatalf("Unexpected message on %s", msg.Topic)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func publish(t *testing.T, b *embedded.Broker, topic, payload string) {
+	t.Helper()
+	if err := b.Publish(topic, []byte(payload), false, 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIntegrationAddAndDeleteTopicSubscriber(t *testing.T) {
+	b := startBroker(t, true)
+	sm := GetSubscriberManager()
+	configureManager(t, sm, b)
+	client := listen(sm)
+	defer sm.Stream().Unregister(client)
+
+	assert.NoError(t, AddTopicSubscriber("sensores/+/temperatura", 1, nil))
+	assert.Contains(t, GetActiveTopics(), "sensores/+/temperatura")
+
+	publish(t, b, "sensores/sala1/temperatura", `{"value":21.5}`)
+	msg := receive(t, client)
+	assert.Equal(t, "sensores/sala1/temperatura", msg.Topic)
+	assert.JSONEq(t, `{"value":21.5}`, string(msg.PayloadJSON))
+	assert.Equal(t, 1, msg.QOS)
+
+	assert.NoError(t, DeleteTopicSubscriber("sensores/+/temperatura"))
+	assert.NotContains(t, GetActiveTopics(), "sensores/+/temperatura")
+	assert.ErrorIs(t, DeleteTopicSubscriber("sensores/+/temperatura"), ErrNotSubscribed)
+
+	publish(t, b, "sensores/sala1/temperatura", `{"value":22}`)
+	assertNothingReceived(t, client)
+}
+
+func TestIntegrationDuplicateTopic(t *testing.T) {
+	b := startBroker(t, false)
+	sm := newSubscriberManager()
+	configureManager(t, sm, b)
+	client := listen(sm)
+
+	assert.NoError(t, sm.Subscribe("sensores/#", 1))
+	assert.ErrorIs(t, sm.Subscribe("sensores/#", 0), ErrAlreadySubscribed)
+	assert.NoError(t, sm.Subscribe("sensores/sala1/temperatura", 1))
+
+	// Aunque coinciden dos suscripciones, el mensaje se entrega una sola vez
+	publish(t, b, "sensores/sala1/temperatura", "21.5")
+	assert.Equal(t, "21.5", receive(t, client).Payload)
+	assertNothingReceived(t, client)
+}
+
+// recordingWriter guarda en memoria los lotes que escribe el pipeline
+type recordingWriter struct {
+	mu       sync.Mutex
+	messages []models.MqttMessage
+}
+
+func (w *recordingWriter) CreateBatch(ctx context.Context, messages []models.MqttMessage) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, msg := range messages {
+		msg.ID = len(w.messages) + 1
+		w.messages = append(w.messages, msg)
+	}
+	return nil
+}
+
+func (w *recordingWriter) count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.messages)
+}
+
+func TestIntegrationMessagePersistence(t *testing.T) {
+	b := startBroker(t, false)
+	sm := newSubscriberManager()
+	configureManager(t, sm, b)
+
+	writer := &recordingWriter{}
+	p, err := pipeline.New(config.PipelineConfig{
+		QueueSize:     100,
+		BatchSize:     10,
+		Workers:       1,
+		FlushInterval: 50 * time.Millisecond,
+		FlushTimeout:  time.Second,
+		Backpressure:  pipeline.PolicyBlock,
+	}, writer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm.SetPipeline(p)
+	p.Start()
+	defer p.Close(context.Background())
+
+	assert.NoError(t, sm.Subscribe("sensores/#", 1))
+	publish(t, b, "sensores/sala1/temperatura", `{"value":21.5}`)
+	publish(t, b, "sensores/sala1/humedad", "40%")
+	publish(t, b, "sensores/sala2/temperatura", `{"value":19}`)
+
+	assert.Eventually(t, func() bool { return writer.count() == 3 }, receiveTimeout, 20*time.Millisecond)
+
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+	assert.Equal(t, "sensores/sala1/temperatura", writer.messages[0].Topic)
+	assert.JSONEq(t, `{"value":21.5}`, string(writer.messages[0].PayloadJSON))
+	assert.Equal(t, []byte("40%"), writer.messages[1].PayloadRaw)
+	assert.Equal(t, models.DirectionInbound, writer.messages[2].Direction)
+
+	stats := sm.Stats()
+	assert.Equal(t, 1, stats.ActiveSubscriptions)
+	assert.Equal(t, []TopicStats{{Topic: "sensores/#", Received: 3, Persisted: 3}}, stats.Topics)
+}
+
+func TestIntegrationReconnectRenewsSubscriptions(t *testing.T) {
+	b := startBroker(t, false)
+	sm := newSubscriberManager()
+	configureManager(t, sm, b)
+	client := listen(sm)
+
+	assert.NoError(t, sm.Subscribe("sensores/+/temperatura", 1))
+	publish(t, b, "sensores/sala1/temperatura", "antes")
+	assert.Equal(t, "antes", receive(t, client).Payload)
+
+	// Con sesión limpia el broker olvida las suscripciones: el manager debe renovarlas
+	assert.Equal(t, 1, b.DisconnectClients())
+
+	assert.Eventually(t, func() bool {
+		publish(t, b, "sensores/sala1/temperatura", "después")
+		select {
+		case msg := <-client.Messages():
+			return msg.Payload == "después"
+		case <-time.After(100 * time.Millisecond):
+			return false
+		}
+	}, 10*time.Second, 50*time.Millisecond)
+	assert.True(t, sm.IsSubscribed("sensores/+/temperatura"))
+}
+
+func TestIntegrationPublishRoundTrip(t *testing.T) {
+	b := startBroker(t, false)
+	sm := newSubscriberManager()
+	configureManager(t, sm, b)
+	client := listen(sm)
+
+	assert.NoError(t, sm.Subscribe("actuadores/#", 2))
+
+	for _, qos := range []byte{0, 1, 2} {
+		sent, err := sm.Publish(context.Background(), "actuadores/sala1/luz", []byte(`{"on":true}`), qos, false, false)
+		assert.NoError(t, err)
+		assert.Equal(t, models.DirectionOutbound, sent.Direction)
+
+		msg := receive(t, client)
+		assert.Equal(t, "actuadores/sala1/luz", msg.Topic)
+		assert.Equal(t, int(qos), msg.QOS)
+		assert.Equal(t, models.DirectionInbound, msg.Direction)
+	}
+}
+
+func TestIntegrationConnectionStatus(t *testing.T) {
+	b := startBroker(t, false)
+	sm := newSubscriberManager()
+	assert.False(t, sm.ConnectionStatus().Configured)
+
+	configureManager(t, sm, b)
+	status := sm.ConnectionStatus()
+	assert.True(t, status.Configured)
+	assert.False(t, status.Connected, "The connection is opened with the first subscription")
+	assert.Empty(t, status.Subscriptions)
+
+	assert.NoError(t, sm.Subscribe("sensores/#", 1))
+	assert.NoError(t, sm.Subscribe("actuadores/#", 0))
+	status = sm.ConnectionStatus()
+	assert.True(t, status.Connected)
+	if assert.Len(t, status.Subscriptions, 2) {
+		assert.Equal(t, "actuadores/#", status.Subscriptions[0].Topic)
+		assert.Equal(t, byte(1), status.Subscriptions[1].QOS)
+		assert.True(t, status.Subscriptions[1].Connected)
+	}
+
+	sm.DisconnectAll()
+	assert.False(t, sm.ConnectionStatus().Connected)
+}
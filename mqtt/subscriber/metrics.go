@@ -0,0 +1,117 @@
+package subscriber
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
+)
+
+// NoSubscription agrupa en las métricas los mensajes cuyo topic ya no
+// corresponde a ninguna suscripción (p. ej. se eliminó mientras se guardaban)
+const NoSubscription = "(none)"
+
+// Stats es una instantánea de la actividad MQTT del manager
+type Stats struct {
+	ActiveSubscriptions int  `json:"active_subscriptions"`
+	Connected           bool `json:"connected"`
+	// ConnectionLost cuenta las veces que se perdió la conexión con el broker
+	ConnectionLost uint64 `json:"connection_lost"`
+	// Reconnects cuenta las reconexiones que renovaron las suscripciones
+	Reconnects uint64       `json:"reconnects"`
+	Topics     []TopicStats `json:"topics"`
+}
+
+// TopicStats cuenta los mensajes de una suscripción. Se agrupa por el filtro
+// suscrito y no por el topic concreto para que el número de series esté acotado.
+type TopicStats struct {
+	Topic     string `json:"topic"`
+	Received  uint64 `json:"received"`
+	Persisted uint64 `json:"persisted"`
+	Failed    uint64 `json:"failed"`
+}
+
+type topicCounters struct {
+	received  atomic.Uint64
+	persisted atomic.Uint64
+	failed    atomic.Uint64
+}
+
+// messageMetrics son los contadores de mensajes y conexión del manager
+type messageMetrics struct {
+	mu     sync.RWMutex
+	topics map[string]*topicCounters
+
+	connectionLost atomic.Uint64
+	reconnects     atomic.Uint64
+}
+
+// topic devuelve los contadores de una suscripción, creándolos si hace falta
+func (m *messageMetrics) topic(subscription string) *topicCounters {
+	m.mu.RLock()
+	counters, ok := m.topics[subscription]
+	m.mu.RUnlock()
+	if ok {
+		return counters
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.topics == nil {
+		m.topics = make(map[string]*topicCounters)
+	}
+	if counters, ok = m.topics[subscription]; !ok {
+		counters = &topicCounters{}
+		m.topics[subscription] = counters
+	}
+	return counters
+}
+
+// countPersisted contabiliza un lote guardado por el pipeline
+func (sm *SubscriberManager) countPersisted(messages []models.MqttMessage) {
+	for _, msg := range messages {
+		sm.metrics.topic(sm.subscriptionName(msg.Topic)).persisted.Add(1)
+	}
+}
+
+// countFailed contabiliza los mensajes que se pierden sin guardarse
+func (sm *SubscriberManager) countFailed(messages []models.MqttMessage) {
+	for _, msg := range messages {
+		sm.metrics.topic(sm.subscriptionName(msg.Topic)).failed.Add(1)
+	}
+}
+
+// subscriptionName devuelve el filtro de la suscripción que corresponde al topic
+func (sm *SubscriberManager) subscriptionName(topic string) string {
+	if info := sm.subscriptionFor(topic); info != nil {
+		return info.Topic
+	}
+	return NoSubscription
+}
+
+// Stats devuelve los contadores de mensajes por suscripción y el estado de la conexión
+func (sm *SubscriberManager) Stats() Stats {
+	status := sm.ConnectionStatus()
+	stats := Stats{
+		ActiveSubscriptions: len(sm.GetActiveSubscribers()),
+		Connected:           status.Connected,
+		ConnectionLost:      sm.metrics.connectionLost.Load(),
+		Reconnects:          sm.metrics.reconnects.Load(),
+	}
+
+	sm.metrics.mu.RLock()
+	stats.Topics = make([]TopicStats, 0, len(sm.metrics.topics))
+	for topic, counters := range sm.metrics.topics {
+		stats.Topics = append(stats.Topics, TopicStats{
+			Topic:     topic,
+			Received:  counters.received.Load(),
+			Persisted: counters.persisted.Load(),
+			Failed:    counters.failed.Load(),
+		})
+	}
+	sm.metrics.mu.RUnlock()
+
+	sort.Slice(stats.Topics, func(i, j int) bool { return stats.Topics[i].Topic < stats.Topics[j].Topic })
+	return stats
+}
@@ -0,0 +1,90 @@
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/tracing"
+
+	"github.com/rs/zerolog/log"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// ErrPublishTimeout se devuelve cuando el broker no confirma la publicación a tiempo
+	ErrPublishTimeout = errors.New("tiempo de espera agotado esperando la confirmación de la publicación")
+	// ErrNoDatabase se devuelve al pedir que se guarde un mensaje sin base de datos configurada
+	ErrNoDatabase = errors.New("base de datos no configurada")
+	// ErrNotRecorded se devuelve cuando el mensaje se publicó pero no se pudo guardar
+	ErrNotRecorded = errors.New("mensaje publicado pero no guardado")
+)
+
+// Publish publica el payload en el topic por la conexión compartida y espera
+// la confirmación que corresponde al QoS: ninguna con QoS 0 (basta con
+// enviarlo), PUBACK con QoS 1 y PUBCOMP con QoS 2. Con record el mensaje se
+// guarda después como saliente (outbound); no se reparte por el stream, que
+// solo emite los mensajes recibidos.
+func (sm *SubscriberManager) Publish(ctx context.Context, topic string, payload []byte, qos byte, retain, record bool) (_ models.MqttMessage, err error) {
+	ctx, span := tracing.Start(ctx, "publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("mqtt"),
+			semconv.MessagingOperationTypeSend,
+			semconv.MessagingDestinationName(topic),
+			semconv.MessagingMessageBodySize(len(payload)),
+		),
+	)
+	defer tracing.End(span, &err)
+
+	if topic == "" {
+		return models.MqttMessage{}, ErrEmptyTopic
+	}
+	if qos > 2 {
+		return models.MqttMessage{}, fmt.Errorf("%w: %d", ErrInvalidQOS, qos)
+	}
+	if record && sm.mqttRepo == nil {
+		return models.MqttMessage{}, ErrNoDatabase
+	}
+
+	client, err := sm.connect()
+	if err != nil {
+		return models.MqttMessage{}, err
+	}
+
+	token := client.Publish(topic, qos, retain, payload)
+	if !token.WaitTimeout(sm.cfg.OperationTimeout) {
+		log.Error().Str("topic", topic).Uint8("qos", qos).Msg("❌ El broker no confirmó la publicación a tiempo")
+		return models.MqttMessage{}, ErrPublishTimeout
+	}
+	if err := token.Error(); err != nil {
+		log.Error().Err(err).Str("topic", topic).Msg("❌ Error publicando el mensaje")
+		return models.MqttMessage{}, fmt.Errorf("%w: %v", ErrBrokerUnavailable, err)
+	}
+
+	msg := models.NewMqttMessage(topic, payload, int(qos), retain, time.Now())
+	msg.Direction = models.DirectionOutbound
+	msg.SpanContext = span.SpanContext()
+
+	log.Info().
+		Str("topic", topic).
+		Uint8("qos", qos).
+		Bool("retain", retain).
+		Int("bytes", len(payload)).
+		Msg("📤 Mensaje publicado")
+
+	if !record {
+		return msg, nil
+	}
+
+	if err := sm.mqttRepo.Create(ctx, &msg); err != nil {
+		log.Error().Err(err).Str("topic", topic).Msg("❌ Error guardando el mensaje publicado en base de datos")
+		return msg, fmt.Errorf("%w: %v", ErrNotRecorded, err)
+	}
+
+	log.Info().Str("topic", topic).Int("message_id", msg.ID).Msg("💾 Mensaje publicado guardado en base de datos")
+	return msg, nil
+}
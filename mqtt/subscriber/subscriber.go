@@ -2,14 +2,17 @@ package subscriber
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"database/sql"
+	"errors"
 	"fmt"
-	"os"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/JorgeePG/prueba-api-http-postgresql-/cmd/config"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/mqttconn"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/pipeline"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/mqtt/stream"
 	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/repository"
 
 	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
@@ -18,21 +21,64 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-// SubscriberInfo contiene información sobre un suscriptor activo
+var (
+	// ErrEmptyTopic se devuelve cuando se intenta operar sobre un topic vacío
+	ErrEmptyTopic = errors.New("el topic no puede estar vacío")
+	// ErrAlreadySubscribed se devuelve cuando ya existe un suscriptor para el topic
+	ErrAlreadySubscribed = errors.New("ya existe un suscriptor para el topic")
+	// ErrNotSubscribed se devuelve cuando no existe un suscriptor para el topic
+	ErrNotSubscribed = errors.New("no existe un suscriptor para el topic")
+	// ErrInvalidQOS se devuelve cuando el QoS no es 0, 1 o 2
+	ErrInvalidQOS = errors.New("el QoS debe ser 0, 1 o 2")
+)
+
+// DefaultQOS es el QoS de las suscripciones que no indican otro
+const DefaultQOS byte = 1
+
+// ErrNotConfigured se devuelve al suscribirse sin haber configurado la conexión con el broker
+var ErrNotConfigured = errors.New("la conexión MQTT no está configurada")
+
+// SubscriberInfo contiene información sobre una suscripción activa
 type SubscriberInfo struct {
-	Topic      string
-	Client     mqtt.Client
-	CancelFunc context.CancelFunc
+	Topic        string
+	QOS          byte
+	Handler      mqtt.MessageHandler
+	SubscribedAt time.Time
 }
 
-// SubscriberManager gestiona múltiples suscriptores MQTT
+// SubscriberManager gestiona las suscripciones MQTT de la aplicación sobre una
+// única conexión compartida con el broker. Cada topic tiene su propio handler,
+// y todas las suscripciones se renuevan automáticamente tras una reconexión.
 type SubscriberManager struct {
 	subscribers map[string]*SubscriberInfo
 	mu          sync.RWMutex
-	brokerURL   string
 	db          *sql.DB
 	mqttRepo    *repository.MqttMessageRepository
-	tlsConfig   *tls.Config
+	subsRepo    *repository.MqttSubscriptionRepository
+	cfg         *config.MQTTConfig
+	// pipeline guarda los mensajes por lotes; sin él se guardan uno a uno
+	pipeline *pipeline.Pipeline
+	// hub reparte los mensajes guardados entre los clientes del stream en vivo
+	hub *stream.Hub
+	// onReceived recibe cada mensaje nada más llegar, p. ej. para evaluar alertas
+	onReceived func(models.MqttMessage)
+
+	// Conexión compartida; se crea al registrar la primera suscripción
+	client       mqtt.Client
+	connectToken mqtt.Token
+	connMu       sync.Mutex
+	// connected indica que ya hubo una conexión con este cliente; las siguientes son reconexiones
+	connected bool
+	// pendingResubscribe indica que hay suscripciones sin enviar por no haber conexión
+	pendingResubscribe bool
+
+	metrics messageMetrics
+}
+
+// RestoreResult resume la restauración de las suscripciones persistidas
+type RestoreResult struct {
+	Restored []string
+	Failed   map[string]error
 }
 
 // Instancia global del manager
@@ -42,41 +88,97 @@ var once sync.Once
 // GetSubscriberManager retorna la instancia global del manager
 func GetSubscriberManager() *SubscriberManager {
 	once.Do(func() {
-		globalManager = &SubscriberManager{
-			subscribers: make(map[string]*SubscriberInfo),
-			brokerURL:   "ssl://localhost:8883", // Cambiar a SSL
-		}
-		// Configurar TLS al inicializar
-		globalManager.setupTLS()
+		globalManager = newSubscriberManager()
 	})
 	return globalManager
 }
 
+func newSubscriberManager() *SubscriberManager {
+	return &SubscriberManager{
+		subscribers: make(map[string]*SubscriberInfo),
+		hub:         stream.NewHub(),
+	}
+}
+
+// Configure establece los parámetros de conexión con el broker. Valida la
+// configuración (incluidos los certificados TLS) antes de aceptarla.
+func (sm *SubscriberManager) Configure(cfg config.MQTTConfig) error {
+	if _, err := mqttconn.NewClientOptions(cfg, "validate"); err != nil {
+		return err
+	}
+
+	sm.connMu.Lock()
+	defer sm.connMu.Unlock()
+	sm.cfg = &cfg
+	return nil
+}
+
+// configured indica si se ha llamado a Configure
+func (sm *SubscriberManager) configured() bool {
+	sm.connMu.Lock()
+	defer sm.connMu.Unlock()
+	return sm.cfg != nil
+}
+
 // SetDatabase configura la base de datos para el manager
 func (sm *SubscriberManager) SetDatabase(db *sql.DB) {
 	sm.db = db
 	sm.mqttRepo = repository.NewMqttMessageRepository(db)
+	sm.subsRepo = repository.NewMqttSubscriptionRepository(db)
+}
+
+// SetPipeline hace que los mensajes recibidos se encolen en el pipeline en
+// lugar de guardarse uno a uno desde el callback de paho. Debe llamarse antes
+// de arrancar el pipeline.
+func (sm *SubscriberManager) SetPipeline(p *pipeline.Pipeline) {
+	p.OnPersisted(func(messages []models.MqttMessage) {
+		sm.countPersisted(messages)
+		sm.hub.Publish(messages)
+	})
+	p.OnFailed(sm.countFailed)
+	sm.pipeline = p
 }
 
-func AddTopicSubscriber(topic string) error {
+// OnReceived registra una función que recibe cada mensaje en cuanto llega,
+// antes de guardarlo. Se llama desde el callback de paho, así que no debe
+// bloquearse. Debe llamarse antes de suscribirse a ningún topic.
+func (sm *SubscriberManager) OnReceived(fn func(models.MqttMessage)) {
+	sm.onReceived = fn
+}
+
+// Stream devuelve el hub que reparte en vivo los mensajes recibidos, una vez
+// guardados y con su id
+func (sm *SubscriberManager) Stream() *stream.Hub {
+	return sm.hub
+}
+
+// PipelineStats devuelve las métricas del pipeline de persistencia, si está configurado
+func (sm *SubscriberManager) PipelineStats() (pipeline.Stats, bool) {
+	if sm.pipeline == nil {
+		return pipeline.Stats{}, false
+	}
+	return sm.pipeline.Stats(), true
+}
+
+// AddTopicSubscriber suscribe la conexión compartida al topic y persiste la
+// suscripción para restaurarla en el siguiente arranque. createdBy es el usuario que la crea (opcional).
+func AddTopicSubscriber(topic string, qos byte, createdBy *int) error {
 	manager := GetSubscriberManager()
 
 	// Validación más estricta del topic
 	if topic == "" || len(topic) == 0 {
 		log.Error().Msg("❌ El topic no puede estar vacío")
-		return fmt.Errorf("el topic no puede estar vacío")
+		return ErrEmptyTopic
 	}
 
-	// Verificar si ya existe un suscriptor para este topic
-	if manager.IsSubscribed(topic) {
-		log.Warn().Str("topic", topic).Msg("⚠️ Ya existe un suscriptor para este topic")
-		return fmt.Errorf("ya existe un suscriptor para el topic: %s", topic)
+	if qos > 2 {
+		return fmt.Errorf("%w: %d", ErrInvalidQOS, qos)
 	}
 
-	// Verificar que TLS esté configurado antes de proceder
-	if manager.tlsConfig == nil {
-		log.Error().Str("topic", topic).Msg("❌ TLS no está configurado. No se puede proceder con la suscripción")
-		return fmt.Errorf("TLS no está configurado")
+	// Verificar que la conexión esté configurada antes de proceder
+	if !manager.configured() {
+		log.Error().Str("topic", topic).Msg("❌ Conexión MQTT no configurada. No se puede proceder con la suscripción")
+		return ErrNotConfigured
 	}
 
 	// Log para debug del topic recibido
@@ -86,151 +188,170 @@ func AddTopicSubscriber(topic string) error {
 		Str("topic_bytes", fmt.Sprintf("%+v", []byte(topic))).
 		Msg("🔍 Topic recibido para suscripción")
 
-	go func(topic string) {
-		if topic == "" {
-			log.Error().Msg("El topic no puede estar vacío")
-			return
+	if err := manager.Subscribe(topic, qos); err != nil {
+		return err
+	}
+
+	// Persistir la suscripción; si no se puede guardar se deshace para no perderla en silencio al reiniciar
+	if manager.subsRepo != nil {
+		subscription := &models.MqttSubscription{Topic: topic, QOS: qos, CreatedBy: createdBy}
+		if err := manager.subsRepo.Save(subscription); err != nil {
+			log.Error().Err(err).Str("topic", topic).Msg("❌ Error guardando la suscripción en base de datos")
+			if removeErr := manager.RemoveSubscriber(topic); removeErr != nil {
+				log.Error().Err(removeErr).Str("topic", topic).Msg("❌ Error deshaciendo la suscripción no guardada")
+			}
+			return fmt.Errorf("error guardando la suscripción: %w", err)
 		}
+		log.Info().Str("topic", topic).Int("subscription_id", subscription.ID).Msg("💾 Suscripción guardada en base de datos")
+	}
 
-		log.Info().Str("topic", topic).Msg("🚀 Iniciando MQTT Subscriber")
+	return nil
+}
 
-		// Usar la misma configuración que el publisher
-		clientID := fmt.Sprintf("go-subscriber-%s-%d", topic, time.Now().UnixNano())
+func DeleteTopicSubscriber(topic string) error {
+	manager := GetSubscriberManager()
 
-		// Verificar que TLS esté configurado
-		if manager.tlsConfig == nil {
-			log.Error().Str("topic", topic).Msg("❌ TLS no está configurado correctamente")
-			return
-		}
+	if topic == "" {
+		log.Error().Msg("El topic no puede estar vacío")
+		return ErrEmptyTopic
+	}
 
-		opts := mqtt.NewClientOptions().
-			AddBroker("ssl://localhost:8883").
-			SetClientID(clientID).
-			SetTLSConfig(manager.tlsConfig).
-			SetUsername("publisher").
-			SetPassword("publisher").
-			SetConnectTimeout(10 * time.Second).
-			SetKeepAlive(30 * time.Second).
-			SetPingTimeout(5 * time.Second).
-			SetWriteTimeout(5 * time.Second).
-			SetAutoReconnect(true).
-			SetMaxReconnectInterval(5 * time.Second).
-			SetConnectionLostHandler(func(client mqtt.Client, err error) {
-				log.Error().Err(err).Str("topic", topic).Msg("🔴 Conexión MQTT perdida")
-			}).
-			SetOnConnectHandler(func(client mqtt.Client) {
-				log.Info().Str("topic", topic).Msg("🟢 Cliente MQTT reconectado")
-			})
-
-		client := mqtt.NewClient(opts)
-
-		log.Info().Str("broker", "ssl://localhost:8883").Msg("🔌 Intentando conectar con SSL...")
-
-		if token := client.Connect(); token.Wait() && token.Error() != nil {
-			log.Error().Err(token.Error()).Str("topic", topic).Msg("❌ Error conectando al broker MQTT")
-			return
-		}
-		log.Info().Str("topic", topic).Msg("🟢 Conectado al broker MQTT como suscriptor")
+	// Verificar si existe el suscriptor
+	if !manager.IsSubscribed(topic) {
+		log.Warn().Str("topic", topic).Msg("No existe un suscriptor para este topic")
+		return fmt.Errorf("%w: %s", ErrNotSubscribed, topic)
+	}
 
-		// Crear contexto cancelable
-		ctx, cancel := context.WithCancel(context.Background())
+	log.Info().Str("topic", topic).Msg("🚀 Desuscribiendo del topic")
 
-		// Registrar el suscriptor en el manager ANTES de suscribirse
-		manager.AddSubscriber(topic, client, cancel)
+	if err := manager.RemoveSubscriber(topic); err != nil {
+		log.Error().Err(err).Str("topic", topic).Msg("Error al remover suscriptor")
+		return fmt.Errorf("error al remover suscriptor: %w", err)
+	}
 
-		// Verificar conexión antes de suscribirse
-		if !client.IsConnected() {
-			log.Error().Str("topic", topic).Msg("❌ Cliente no está conectado")
-			manager.RemoveSubscriber(topic)
-			return
+	// Deshabilitar la suscripción para que no se restaure en el siguiente arranque
+	if manager.subsRepo != nil {
+		if err := manager.subsRepo.Disable(topic); err != nil {
+			log.Error().Err(err).Str("topic", topic).Msg("❌ Error deshabilitando la suscripción en base de datos")
+			return fmt.Errorf("error deshabilitando la suscripción: %w", err)
 		}
+	}
 
-		token := client.Subscribe(topic, 1, func(client mqtt.Client, msg mqtt.Message) {
-			log.Info().Str("topic", msg.Topic()).Str("payload", string(msg.Payload())).Msg("🔥 CALLBACK ZEROLOG")
+	log.Info().Str("topic", topic).Msg("✅ Desuscrito del topic")
+	return nil
+}
 
-			// Obtener el manager dentro del callback
-			mgr := GetSubscriberManager()
+// RestoreSubscriptions vuelve a suscribirse a todas las suscripciones habilitadas
+// en base de datos. Si el broker no está disponible las suscripciones quedan
+// registradas y se envían al broker en cuanto se establezca la conexión.
+func (sm *SubscriberManager) RestoreSubscriptions() (*RestoreResult, error) {
+	if sm.subsRepo == nil {
+		return nil, fmt.Errorf("base de datos no configurada")
+	}
 
-			// Crear el mensaje para guardar en BD
-			mqttMessage := &models.MqttMessage{
-				Topic:    msg.Topic(),
-				Payload:  string(msg.Payload()),
-				QOS:      int(msg.Qos()),
-				Retained: msg.Retained(),
-			}
+	subscriptions, err := sm.subsRepo.GetEnabled()
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo las suscripciones: %w", err)
+	}
 
-			// Guardar en la base de datos
-			if mgr.mqttRepo != nil {
-				if err := mgr.mqttRepo.Create(mqttMessage); err != nil {
-					log.Error().
-						Err(err).
-						Str("topic", msg.Topic()).
-						Msg("❌ Error guardando mensaje en base de datos")
-				} else {
-					log.Info().
-						Str("topic", msg.Topic()).
-						Int("message_id", mqttMessage.ID).
-						Str("payload", string(msg.Payload())).
-						Msg("💾 Mensaje guardado en base de datos")
-				}
-			} else {
-				log.Warn().Msg("⚠️  Base de datos no configurada, solo registrando mensaje")
-				log.Info().
-					Str("topic", msg.Topic()).
-					Str("payload", string(msg.Payload())).
-					Msg("📥 Mensaje recibido")
-			}
-		})
+	result := &RestoreResult{
+		Restored: []string{},
+		Failed:   make(map[string]error),
+	}
+	if len(subscriptions) == 0 {
+		return result, nil
+	}
 
-		if token.Wait() && token.Error() != nil {
-			log.Error().Err(token.Error()).Str("topic", topic).Msg("❌ Error suscribiéndose al topic")
-			manager.RemoveSubscriber(topic)
-			return
+	if !sm.configured() {
+		for _, sub := range subscriptions {
+			result.Failed[sub.Topic] = ErrNotConfigured
 		}
+		return result, nil
+	}
 
-		log.Info().Str("topic", topic).Msg("✅ Suscrito al topic correctamente")
+	log.Info().Int("count", len(subscriptions)).Msg("♻️ Restaurando suscripciones MQTT")
 
-		// Esperar cancelación
-		<-ctx.Done()
-		log.Info().Str("topic", topic).Msg("🛑 Cancelación solicitada para el topic")
+	filters := make(map[string]byte, len(subscriptions))
+	for _, sub := range subscriptions {
+		sm.register(sub.Topic, sub.QOS)
+		filters[sub.Topic] = sub.QOS
+	}
 
-		// Desuscribirse del topic antes de desconectar
-		if token := client.Unsubscribe(topic); token.Wait() && token.Error() != nil {
-			log.Error().Err(token.Error()).Str("topic", topic).Msg("Error al desuscribirse del topic")
+	client, err := sm.connect()
+	if err != nil {
+		for topic := range filters {
+			result.Failed[topic] = fmt.Errorf("%w (se suscribirá al reconectar)", err)
 		}
+		return result, nil
+	}
 
-		client.Disconnect(250)
-		manager.RemoveSubscriber(topic)
-		log.Info().Str("topic", topic).Msg("👋 Subscriber finalizado")
-	}(topic)
+	codes, err := sm.subscribeAll(client, topicsOf(filters))
+	for topic := range filters {
+		switch {
+		case err != nil:
+			result.Failed[topic] = err
+		case codes[topic] > 2:
+			sm.unregister(topic)
+			result.Failed[topic] = fmt.Errorf("el broker rechazó la suscripción (código %#x)", codes[topic])
+		default:
+			result.Restored = append(result.Restored, topic)
+		}
+	}
 
-	return nil
+	return result, nil
 }
 
-func DeleteTopicSubscriber(topic string) error {
-	manager := GetSubscriberManager()
+// Subscribe registra el topic con su handler y lo suscribe en la conexión compartida
+func (sm *SubscriberManager) Subscribe(topic string, qos byte) error {
+	if !sm.register(topic, qos) {
+		log.Warn().Str("topic", topic).Msg("⚠️ Ya existe un suscriptor para este topic")
+		return fmt.Errorf("%w: %s", ErrAlreadySubscribed, topic)
+	}
 
-	if topic == "" {
-		log.Error().Msg("El topic no puede estar vacío")
-		return fmt.Errorf("el topic no puede estar vacío")
+	client, err := sm.connect()
+	if err != nil {
+		sm.unregister(topic)
+		return err
 	}
 
-	// Verificar si existe el suscriptor
-	if !manager.IsSubscribed(topic) {
-		log.Warn().Str("topic", topic).Msg("No existe un suscriptor para este topic")
-		return fmt.Errorf("no existe un suscriptor para el topic: %s", topic)
+	codes, err := sm.subscribeAll(client, []string{topic})
+	if err == nil && codes[topic] > 2 {
+		err = fmt.Errorf("el broker rechazó la suscripción (código %#x)", codes[topic])
+	}
+	if err != nil {
+		log.Error().Err(err).Str("topic", topic).Msg("❌ Error suscribiéndose al topic")
+		sm.unregister(topic)
+		return err
 	}
 
-	log.Info().Str("topic", topic).Msg("🚀 Desuscribiendo del topic")
+	log.Info().Str("topic", topic).Uint8("qos", qos).Msg("✅ Suscrito al topic correctamente")
+	return nil
+}
 
-	// Remover el suscriptor del manager (esto cancelará el contexto)
-	if err := manager.RemoveSubscriber(topic); err != nil {
-		log.Error().Err(err).Str("topic", topic).Msg("Error al remover suscriptor")
-		return fmt.Errorf("error al remover suscriptor: %w", err)
+// register añade el topic y su handler al mapa de suscripciones.
+// Devuelve false si el topic ya estaba registrado.
+func (sm *SubscriberManager) register(topic string, qos byte) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if _, exists := sm.subscribers[topic]; exists {
+		return false
 	}
 
-	log.Info().Str("topic", topic).Msg("✅ Desuscrito del topic")
-	return nil
+	sm.subscribers[topic] = &SubscriberInfo{
+		Topic:        topic,
+		QOS:          qos,
+		Handler:      sm.topicHandler(topic),
+		SubscribedAt: time.Now(),
+	}
+	return true
+}
+
+// unregister elimina el topic del mapa de suscripciones
+func (sm *SubscriberManager) unregister(topic string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	delete(sm.subscribers, topic)
 }
 
 // GetActiveTopics devuelve los topics activos (función de conveniencia)
@@ -246,9 +367,15 @@ func DisconnectAllSubscribers() {
 }
 
 // ListMqttMessages obtiene los mensajes MQTT guardados en la base de datos (función de conveniencia)
-func ListMqttMessages(limit int) ([]models.MqttMessage, error) {
+func ListMqttMessages(ctx context.Context, limit int) ([]models.MqttMessage, error) {
 	manager := GetSubscriberManager()
-	return manager.ListMqttMessages(limit)
+	return manager.ListMqttMessages(ctx, limit)
+}
+
+// FindMqttMessages obtiene los mensajes que cumplen la consulta (función de conveniencia)
+func FindMqttMessages(ctx context.Context, q repository.MessageQuery) (*repository.MessagePage, error) {
+	manager := GetSubscriberManager()
+	return manager.FindMqttMessages(ctx, q)
 }
 
 // GetActiveSubscribers devuelve una lista de topics activos
@@ -271,56 +398,118 @@ func (sm *SubscriberManager) IsSubscribed(topic string) bool {
 	return exists
 }
 
-// RemoveSubscriber elimina un suscriptor del manager
-func (sm *SubscriberManager) RemoveSubscriber(topic string) error {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
+// ConnectionStatus resume el estado de la conexión compartida y de cada suscripción
+type ConnectionStatus struct {
+	Configured    bool                 `json:"configured"`
+	Connected     bool                 `json:"connected"`
+	Subscriptions []SubscriptionStatus `json:"subscriptions"`
+}
 
-	subscriber, exists := sm.subscribers[topic]
-	if !exists {
-		return fmt.Errorf("no existe un suscriptor para el topic: %s", topic)
-	}
+// SubscriptionStatus indica si una suscripción está recibiendo mensajes
+type SubscriptionStatus struct {
+	Topic        string    `json:"topic"`
+	QOS          byte      `json:"qos"`
+	Connected    bool      `json:"connected"`
+	SubscribedAt time.Time `json:"subscribed_at"`
+}
 
-	// Cancelar el contexto para detener la goroutine
-	subscriber.CancelFunc()
+// ConnectionStatus devuelve el estado de la conexión con el broker. Todas las
+// suscripciones comparten la conexión, así que están conectadas o no a la vez.
+func (sm *SubscriberManager) ConnectionStatus() ConnectionStatus {
+	sm.connMu.Lock()
+	status := ConnectionStatus{
+		Configured: sm.cfg != nil,
+		Connected:  sm.client != nil && sm.client.IsConnected(),
+	}
+	sm.connMu.Unlock()
 
-	// Eliminar del mapa
-	delete(sm.subscribers, topic)
+	sm.mu.RLock()
+	status.Subscriptions = make([]SubscriptionStatus, 0, len(sm.subscribers))
+	for _, info := range sm.subscribers {
+		status.Subscriptions = append(status.Subscriptions, SubscriptionStatus{
+			Topic:        info.Topic,
+			QOS:          info.QOS,
+			Connected:    status.Connected,
+			SubscribedAt: info.SubscribedAt,
+		})
+	}
+	sm.mu.RUnlock()
 
-	return nil
+	sort.Slice(status.Subscriptions, func(i, j int) bool {
+		return status.Subscriptions[i].Topic < status.Subscriptions[j].Topic
+	})
+	return status
 }
 
-// AddSubscriber agrega un suscriptor al manager
-func (sm *SubscriberManager) AddSubscriber(topic string, client mqtt.Client, cancel context.CancelFunc) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
+// RemoveSubscriber cancela la suscripción al topic en el broker y la elimina del
+// manager. Si el broker no confirma la cancelación, la suscripción se conserva:
+// el broker seguiría enviando sus mensajes y deben tener un handler.
+func (sm *SubscriberManager) RemoveSubscriber(topic string) error {
+	if !sm.IsSubscribed(topic) {
+		return fmt.Errorf("%w: %s", ErrNotSubscribed, topic)
+	}
 
-	sm.subscribers[topic] = &SubscriberInfo{
-		Topic:      topic,
-		Client:     client,
-		CancelFunc: cancel,
+	sm.connMu.Lock()
+	client := sm.client
+	sm.connMu.Unlock()
+
+	if client != nil && client.IsConnectionOpen() {
+		token := client.Unsubscribe(topic)
+		if !token.WaitTimeout(sm.cfg.OperationTimeout) {
+			return fmt.Errorf("tiempo de espera agotado al desuscribirse de %s", topic)
+		}
+		if err := token.Error(); err != nil {
+			return err
+		}
 	}
+
+	sm.unregister(topic)
+	return nil
 }
 
-// DisconnectAll desconecta todos los suscriptores
+// DisconnectAll cierra la conexión compartida y elimina todas las suscripciones.
+// Con sesión limpia se cancelan antes en el broker; con sesión persistente se
+// mantienen para que el broker guarde los mensajes hasta la reconexión.
 func (sm *SubscriberManager) DisconnectAll() {
+	log.Info().Msg("🛑 Desconectando todos los suscriptores...")
+
 	sm.mu.Lock()
-	defer sm.mu.Unlock()
+	topics := make([]string, 0, len(sm.subscribers))
+	for topic := range sm.subscribers {
+		topics = append(topics, topic)
+	}
+	sm.subscribers = make(map[string]*SubscriberInfo)
+	sm.mu.Unlock()
 
-	log.Info().Msg("🛑 Desconectando todos los suscriptores...")
+	sm.connMu.Lock()
+	defer sm.connMu.Unlock()
+	if sm.client == nil {
+		log.Info().Msg("👋 Todos los suscriptores desconectados")
+		return
+	}
 
-	for topic, subscriber := range sm.subscribers {
-		log.Info().Str("topic", topic).Msg("Desconectando suscriptor")
-		subscriber.CancelFunc()
+	if len(topics) > 0 && sm.cfg.CleanSession && sm.client.IsConnectionOpen() {
+		token := sm.client.Unsubscribe(topics...)
+		if !token.WaitTimeout(sm.cfg.OperationTimeout) {
+			log.Warn().Int("topics", len(topics)).Msg("⏱️ Tiempo de espera agotado al cancelar las suscripciones")
+		} else if err := token.Error(); err != nil {
+			log.Warn().Err(err).Msg("⚠️ Error al cancelar las suscripciones")
+		} else {
+			log.Info().Int("topics", len(topics)).Msg("📭 Suscripciones canceladas en el broker")
+		}
 	}
 
-	// Limpiar el mapa
-	sm.subscribers = make(map[string]*SubscriberInfo)
+	sm.client.Disconnect(250)
+	sm.client = nil
+	sm.connectToken = nil
+	sm.connected = false
+	sm.pendingResubscribe = false
+
 	log.Info().Msg("👋 Todos los suscriptores desconectados")
 }
 
 // ListMqttMessages obtiene todos los mensajes MQTT guardados en la base de datos
-func (sm *SubscriberManager) ListMqttMessages(limit int) ([]models.MqttMessage, error) {
+func (sm *SubscriberManager) ListMqttMessages(ctx context.Context, limit int) ([]models.MqttMessage, error) {
 	if sm.mqttRepo == nil {
 		return nil, fmt.Errorf("base de datos no configurada")
 	}
@@ -329,7 +518,7 @@ func (sm *SubscriberManager) ListMqttMessages(limit int) ([]models.MqttMessage,
 		limit = 100 // Límite por defecto
 	}
 
-	messages, err := sm.mqttRepo.GetAll(limit)
+	messages, err := sm.mqttRepo.GetAll(ctx, limit)
 	if err != nil {
 		log.Error().Err(err).Msg("❌ Error obteniendo mensajes MQTT de la base de datos")
 		return nil, err
@@ -343,68 +532,56 @@ func (sm *SubscriberManager) ListMqttMessages(limit int) ([]models.MqttMessage,
 	return messages, nil
 }
 
-// setupTLS configura los certificados TLS para el manager
-func (sm *SubscriberManager) setupTLS() {
-	log.Info().Msg("🔧 [TLS] Iniciando configuración TLS...")
-
-	// 1. Usar la misma ruta que el publisher para consistencia
-	basePath := os.Getenv("CERT_PATH")
-	if basePath == "" {
-		basePath = "mqtt/publisher/cert"
+// FindMqttMessages obtiene una página de los mensajes MQTT que cumplen la
+// consulta: topics permitidos, rango de fechas, filtros sobre el payload...
+func (sm *SubscriberManager) FindMqttMessages(ctx context.Context, q repository.MessageQuery) (*repository.MessagePage, error) {
+	if sm.mqttRepo == nil {
+		return nil, fmt.Errorf("base de datos no configurada")
 	}
 
-	caPath := basePath + "/ca.crt"
-	log.Info().Str("path", caPath).Msg("[TLS] Intentando leer certificado CA")
+	if q.Limit <= 0 {
+		q.Limit = 100 // Límite por defecto
+	}
 
-	caCert, err := os.ReadFile(caPath)
+	page, err := sm.mqttRepo.Find(ctx, q)
 	if err != nil {
-		log.Error().Err(err).Str("path", caPath).Msg("[TLS] ❌ No se pudo leer el certificado CA")
-		// Intentar con ruta alternativa
-		altPath := "./certs/ssl/server.crt"
-		log.Warn().Str("alt_path", altPath).Msg("[TLS] Intentando ruta alternativa")
-		caCert, err = os.ReadFile(altPath)
-		if err != nil {
-			log.Error().Err(err).Str("alt_path", altPath).Msg("[TLS] ❌ Tampoco se encontró certificado en ruta alternativa")
-			return
-		}
-		caPath = altPath
+		log.Error().Err(err).Msg("❌ Error obteniendo mensajes MQTT de la base de datos")
+		return nil, err
 	}
 
-	// 2. Crear el pool de CA
-	caCertPool := x509.NewCertPool()
-	if !caCertPool.AppendCertsFromPEM(caCert) {
-		log.Error().Str("path", caPath).Msg("[TLS] ❌ No se pudo agregar el certificado CA al pool")
-		return
-	}
-	log.Info().Msg("[TLS] ✅ CA agregada correctamente al pool")
+	log.Info().
+		Int("count", len(page.Messages)).
+		Int("limit", q.Limit).
+		Strs("topics", q.Topics).
+		Strs("filters", q.TopicFilters).
+		Int("payload_conditions", len(q.Where)).
+		Str("jsonpath", q.JSONPath).
+		Bool("has_next", page.NextCursor != "").
+		Msg("📋 Mensajes MQTT filtrados obtenidos de la base de datos")
+
+	return page, nil
+}
 
-	// 3. Intentar cargar certificado de cliente (opcional)
-	clientCrtPath := basePath + "/client.crt"
-	clientKeyPath := basePath + "/client.key"
-	log.Info().Str("crt", clientCrtPath).Str("key", clientKeyPath).Msg("[TLS] Intentando cargar certificado de cliente")
+// AggregateMqttMessages calcula las series temporales de un campo numérico del
+// payload de los mensajes MQTT guardados
+func (sm *SubscriberManager) AggregateMqttMessages(ctx context.Context, q repository.AggregateQuery) ([]repository.AggregateSeries, error) {
+	if sm.mqttRepo == nil {
+		return nil, fmt.Errorf("base de datos no configurada")
+	}
 
-	clientCert, err := tls.LoadX509KeyPair(clientCrtPath, clientKeyPath)
+	series, err := sm.mqttRepo.Aggregate(ctx, q)
 	if err != nil {
-		log.Warn().Err(err).
-			Str("crt", clientCrtPath).
-			Str("key", clientKeyPath).
-			Msg("[TLS] ⚠️ No se encontraron certificados de cliente, usando solo CA")
-		sm.tlsConfig = &tls.Config{
-			RootCAs:            caCertPool,
-			InsecureSkipVerify: true, // Solo para pruebas, ponlo en false en producción
-			ServerName:         "localhost",
-		}
-		log.Info().Msg("[TLS] ✅ TLS configurado solo con CA")
-	} else {
-		log.Info().Str("crt", clientCrtPath).Str("key", clientKeyPath).Msg("[TLS] ✅ Certificado de cliente cargado correctamente")
-		sm.tlsConfig = &tls.Config{
-			RootCAs:            caCertPool,
-			Certificates:       []tls.Certificate{clientCert},
-			InsecureSkipVerify: true, // Solo para pruebas, ponlo en false en producción
-			ServerName:         "localhost",
-		}
-		log.Info().Msg("[TLS] ✅ TLS configurado con CA y certificado de cliente")
+		log.Error().Err(err).Msg("❌ Error agregando mensajes MQTT de la base de datos")
+		return nil, err
 	}
 
-	log.Info().Msg("✅ [TLS] Configuración TLS completada exitosamente")
+	log.Info().
+		Int("series", len(series)).
+		Strs("topics", q.Topics).
+		Str("field", q.Field).
+		Dur("bucket", q.Bucket).
+		Strs("functions", q.Functions).
+		Msg("📈 Mensajes MQTT agregados")
+
+	return series, nil
 }
@@ -0,0 +1,145 @@
+package subscriber
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/cmd/config"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/rs/zerolog"
+)
+
+// Los benchmarks comparan el diseño anterior (un cliente y una goroutine por
+// topic) con la conexión compartida. Necesitan un broker sin TLS, por ejemplo:
+//
+//	MQTT_BENCH_BROKER=tcp://localhost:1883 go test -run ^$ -bench . ./mqtt/subscriber/
+//
+// Además del tiempo se informa del número de conexiones abiertas, las
+// goroutines creadas y la memoria de heap retenida por las suscripciones.
+
+var benchTopicCounts = []int{10, 100}
+
+func benchBroker(b *testing.B) string {
+	broker := os.Getenv("MQTT_BENCH_BROKER")
+	if broker == "" {
+		b.Skip("MQTT_BENCH_BROKER no configurado")
+	}
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	return broker
+}
+
+func benchTopics(n int) []string {
+	topics := make([]string, n)
+	for i := range topics {
+		topics[i] = fmt.Sprintf("bench/sensor%d/temperatura", i)
+	}
+	return topics
+}
+
+// subscribePerTopic reproduce el diseño anterior: un cliente conectado y una
+// goroutine esperando cancelación por cada topic
+func subscribePerTopic(b *testing.B, broker string, topics []string) func() {
+	cancels := make([]context.CancelFunc, 0, len(topics))
+	done := make(chan struct{}, len(topics))
+
+	for _, topic := range topics {
+		opts := mqtt.NewClientOptions().
+			AddBroker(broker).
+			SetClientID(fmt.Sprintf("go-subscriber-%s-%d", topic, time.Now().UnixNano())).
+			SetAutoReconnect(true)
+		client := mqtt.NewClient(opts)
+		if token := client.Connect(); token.Wait() && token.Error() != nil {
+			b.Fatal(token.Error())
+		}
+		if token := client.Subscribe(topic, 1, func(mqtt.Client, mqtt.Message) {}); token.Wait() && token.Error() != nil {
+			b.Fatal(token.Error())
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancels = append(cancels, cancel)
+		go func(topic string) {
+			<-ctx.Done()
+			client.Unsubscribe(topic).Wait()
+			client.Disconnect(0)
+			done <- struct{}{}
+		}(topic)
+	}
+
+	return func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+		for range cancels {
+			<-done
+		}
+	}
+}
+
+// subscribeShared usa el SubscriberManager con una única conexión
+func subscribeShared(b *testing.B, broker string, topics []string) func() {
+	sm := newSubscriberManager()
+	err := sm.Configure(config.MQTTConfig{
+		Brokers:          []string{broker},
+		ClientIDPrefix:   "bench",
+		ConnectTimeout:   10 * time.Second,
+		KeepAlive:        30 * time.Second,
+		OperationTimeout: 10 * time.Second,
+		CleanSession:     true,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, topic := range topics {
+		if err := sm.Subscribe(topic, 1); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return sm.DisconnectAll
+}
+
+func runSubscriptionBenchmark(b *testing.B, connections func(n int) int, subscribe func(*testing.B, string, []string) func()) {
+	broker := benchBroker(b)
+
+	for _, n := range benchTopicCounts {
+		b.Run(fmt.Sprintf("topics=%d", n), func(b *testing.B) {
+			topics := benchTopics(n)
+			var heap, goroutines float64
+
+			for i := 0; i < b.N; i++ {
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				goroutinesBefore := runtime.NumGoroutine()
+
+				teardown := subscribe(b, broker, topics)
+
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				heap += float64(int64(after.HeapAlloc) - int64(before.HeapAlloc))
+				goroutines += float64(runtime.NumGoroutine() - goroutinesBefore)
+
+				b.StopTimer()
+				teardown()
+				b.StartTimer()
+			}
+
+			b.ReportMetric(float64(connections(n)), "connections")
+			b.ReportMetric(goroutines/float64(b.N), "goroutines")
+			b.ReportMetric(heap/float64(b.N), "heap-B")
+		})
+	}
+}
+
+func BenchmarkSubscribePerTopicClients(b *testing.B) {
+	runSubscriptionBenchmark(b, func(n int) int { return n }, subscribePerTopic)
+}
+
+func BenchmarkSubscribeSharedClient(b *testing.B) {
+	runSubscriptionBenchmark(b, func(int) int { return 1 }, subscribeShared)
+}
@@ -0,0 +1,107 @@
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/cmd/config"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeMessage implementa mqtt.Message para probar el reparto de mensajes
+type fakeMessage struct {
+	mqtt.Message
+	topic string
+}
+
+func (m *fakeMessage) Topic() string { return m.topic }
+
+// fakeClient implementa mqtt.Client con una conexión abierta cuyas
+// desuscripciones terminan con unsubscribeErr
+type fakeClient struct {
+	mqtt.Client
+	unsubscribeErr error
+}
+
+func (c *fakeClient) IsConnectionOpen() bool { return true }
+
+func (c *fakeClient) Unsubscribe(topics ...string) mqtt.Token {
+	return &fakeToken{err: c.unsubscribeErr}
+}
+
+// fakeToken es un token ya completado
+type fakeToken struct {
+	err error
+}
+
+func (t *fakeToken) Wait() bool                     { return true }
+func (t *fakeToken) WaitTimeout(time.Duration) bool { return true }
+func (t *fakeToken) Done() <-chan struct{}          { done := make(chan struct{}); close(done); return done }
+func (t *fakeToken) Error() error                   { return t.err }
+
+func TestDispatchUsesSingleMatchingSubscription(t *testing.T) {
+	sm := newSubscriberManager()
+
+	calls := map[string]int{}
+	for _, filter := range []string{"sensores/#", "sensores/sala1/temperatura", "sensores/+/humedad"} {
+		filter := filter
+		sm.subscribers[filter] = &SubscriberInfo{
+			Topic:   filter,
+			Handler: func(mqtt.Client, mqtt.Message) { calls[filter]++ },
+		}
+	}
+
+	sm.dispatch(nil, &fakeMessage{topic: "sensores/sala1/temperatura"})
+	sm.dispatch(nil, &fakeMessage{topic: "sensores/sala2/humedad"})
+	sm.dispatch(nil, &fakeMessage{topic: "sensores/sala2/presion"})
+	sm.dispatch(nil, &fakeMessage{topic: "actuadores/sala1"})
+
+	assert.Equal(t, 1, calls["sensores/sala1/temperatura"], "Exact subscriptions take precedence")
+	assert.Equal(t, 1, calls["sensores/+/humedad"])
+	assert.Equal(t, 1, calls["sensores/#"])
+}
+
+func TestRegisterRejectsDuplicateTopics(t *testing.T) {
+	sm := newSubscriberManager()
+
+	assert.True(t, sm.register("sensores/#", 1))
+	assert.False(t, sm.register("sensores/#", 0))
+	assert.Equal(t, byte(1), sm.subscribers["sensores/#"].QOS)
+
+	sm.unregister("sensores/#")
+	assert.False(t, sm.IsSubscribed("sensores/#"))
+}
+
+func TestRemoveSubscriberKeepsTopicWhenBrokerFails(t *testing.T) {
+	sm := newSubscriberManager()
+	sm.cfg = &config.MQTTConfig{OperationTimeout: time.Second}
+	client := &fakeClient{unsubscribeErr: errors.New("not authorized")}
+	sm.client = client
+	sm.register("sensores/#", 1)
+
+	assert.Error(t, sm.RemoveSubscriber("sensores/#"))
+	assert.True(t, sm.IsSubscribed("sensores/#"), "The broker still delivers the topic, so its handler stays")
+
+	client.unsubscribeErr = nil
+	assert.NoError(t, sm.RemoveSubscriber("sensores/#"))
+	assert.False(t, sm.IsSubscribed("sensores/#"))
+}
+
+func TestPublishValidatesBeforeConnecting(t *testing.T) {
+	sm := newSubscriberManager()
+
+	_, err := sm.Publish(context.Background(), "", []byte("21.5"), 1, false, false)
+	assert.ErrorIs(t, err, ErrEmptyTopic)
+
+	_, err = sm.Publish(context.Background(), "sensores/sala1", []byte("21.5"), 3, false, false)
+	assert.ErrorIs(t, err, ErrInvalidQOS)
+
+	_, err = sm.Publish(context.Background(), "sensores/sala1", []byte("21.5"), 1, false, true)
+	assert.ErrorIs(t, err, ErrNoDatabase, "Recording requires a database")
+
+	_, err = sm.Publish(context.Background(), "sensores/sala1", []byte("21.5"), 1, false, false)
+	assert.ErrorIs(t, err, ErrNotConfigured)
+}
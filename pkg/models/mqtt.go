@@ -1,7 +1,19 @@
 package models
 
 import (
+	"bytes"
+	"encoding/json"
+	"strings"
 	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Dirección de un mensaje MQTT: recibido por una suscripción o publicado desde la API
+const (
+	DirectionInbound  = "inbound"
+	DirectionOutbound = "outbound"
 )
 
 type MqttMessage struct {
@@ -11,4 +23,54 @@ type MqttMessage struct {
 	ReceivedAt time.Time `json:"received_at" db:"received_at"`
 	QOS        int       `json:"qos" db:"qos"`
 	Retained   bool      `json:"retained" db:"retained"`
+	// PayloadJSON es el payload cuando es un JSON válido; permite filtrar por su contenido
+	PayloadJSON json.RawMessage `json:"payload_json,omitempty" db:"payload_json"`
+	// PayloadRaw conserva los bytes originales de los payloads que no son JSON
+	PayloadRaw []byte `json:"payload_raw,omitempty" db:"payload_raw"`
+	// Direction indica si el mensaje se recibió (inbound) o se publicó desde la API (outbound)
+	Direction string `json:"direction" db:"direction"`
+	// SpanContext es el span de la recepción del mensaje, para enlazarlo con el
+	// de su escritura en base de datos. No se guarda ni se serializa.
+	SpanContext trace.SpanContext `json:"-" db:"-"`
+}
+
+// NewMqttMessage crea un mensaje a partir del payload recibido. Si es JSON se
+// guarda también en PayloadJSON; si no, se conservan sus bytes en PayloadRaw.
+// Payload queda como texto legible aunque el original no sea UTF-8.
+func NewMqttMessage(topic string, payload []byte, qos int, retained bool, receivedAt time.Time) MqttMessage {
+	msg := MqttMessage{
+		Topic:      topic,
+		Payload:    payloadText(payload),
+		ReceivedAt: receivedAt,
+		QOS:        qos,
+		Retained:   retained,
+		Direction:  DirectionInbound,
+	}
+
+	// PostgreSQL no admite \u0000 en JSONB
+	if json.Valid(payload) && !bytes.Contains(payload, []byte(`\u0000`)) {
+		msg.PayloadJSON = json.RawMessage(bytes.Clone(payload))
+	} else {
+		msg.PayloadRaw = bytes.Clone(payload)
+	}
+	return msg
+}
+
+// payloadText convierte el payload en texto válido para una columna TEXT
+func payloadText(payload []byte) string {
+	text := string(payload)
+	if utf8.ValidString(text) && !strings.Contains(text, "\x00") {
+		return text
+	}
+	return strings.ToValidUTF8(strings.ReplaceAll(text, "\x00", ""), "\uFFFD")
+}
+
+// MqttSubscription es una suscripción a un topic persistida para restaurarla al arrancar
+type MqttSubscription struct {
+	ID        int       `json:"id" db:"id"`
+	Topic     string    `json:"topic" db:"topic"`
+	QOS       byte      `json:"qos" db:"qos"`
+	CreatedBy *int      `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	Enabled   bool      `json:"enabled" db:"enabled"`
 }
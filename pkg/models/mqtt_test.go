@@ -0,0 +1,33 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMqttMessageJSONPayload(t *testing.T) {
+	payload := []byte(`{"id":1,"message":"hola","source":"go-publisher"}`)
+	msg := NewMqttMessage("sensores/temp", payload, 1, false, time.Now())
+
+	assert.Equal(t, string(payload), msg.Payload)
+	assert.JSONEq(t, string(payload), string(msg.PayloadJSON))
+	assert.Nil(t, msg.PayloadRaw)
+	assert.Equal(t, DirectionInbound, msg.Direction)
+}
+
+func TestNewMqttMessageRawPayload(t *testing.T) {
+	msg := NewMqttMessage("sensores/temp", []byte("21.5 C"), 0, true, time.Now())
+	assert.Equal(t, "21.5 C", msg.Payload)
+	assert.Nil(t, msg.PayloadJSON)
+	assert.Equal(t, []byte("21.5 C"), msg.PayloadRaw)
+
+	binary := []byte{0xff, 0x00, 'o', 'k'}
+	msg = NewMqttMessage("sensores/bin", binary, 0, false, time.Now())
+	assert.Equal(t, "�ok", msg.Payload, "Text column should get valid UTF-8 without NUL bytes")
+	assert.Equal(t, binary, msg.PayloadRaw, "Original bytes should be kept")
+
+	msg = NewMqttMessage("sensores/nul", []byte(`{"a":"\u0000"}`), 0, false, time.Now())
+	assert.Nil(t, msg.PayloadJSON, "PostgreSQL JSONB rejects \\u0000")
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"errors"
+	"strings"
+	"unicode"
 
 	"github.com/volatiletech/null/v8"
 )
@@ -29,11 +31,12 @@ type CreateUserRequest struct {
 
 // UpdateUserRequest representa la solicitud para actualizar un usuario
 type UpdateUserRequest struct {
-	Username string      `json:"username"`
-	Email    string      `json:"email"`
-	Password string      `json:"password,omitempty"`
-	FullName null.String `json:"full_name"`
-	IsActive null.Bool   `json:"is_active"`
+	Username    string      `json:"username"`
+	Email       string      `json:"email"`
+	Password    string      `json:"password,omitempty"`
+	OldPassword string      `json:"old_password,omitempty"` // Obligatoria para cambiar la contraseña propia
+	FullName    null.String `json:"full_name"`
+	IsActive    null.Bool   `json:"is_active"`
 }
 
 // Response representa una respuesta estándar de la API
@@ -62,6 +65,9 @@ func (req *CreateUserRequest) Validate() error {
 	if req.Username == "" {
 		return errors.New("username is required")
 	}
+	if !validUsername(req.Username) {
+		return errUsernameCharacters
+	}
 	if req.Email == "" {
 		return errors.New("email is required")
 	}
@@ -77,3 +83,20 @@ func (req *CreateUserRequest) SetDefaults() {
 		req.IsActive = null.BoolFrom(true)
 	}
 }
+
+// Validate valida los campos que se quieren cambiar del usuario
+func (req *UpdateUserRequest) Validate() error {
+	if req.Username != "" && !validUsername(req.Username) {
+		return errUsernameCharacters
+	}
+	return nil
+}
+
+// errUsernameCharacters se devuelve cuando el nombre de usuario tiene espacios o caracteres de control
+var errUsernameCharacters = errors.New("username must not contain whitespace or control characters")
+
+// validUsername indica si el nombre de usuario no tiene espacios ni caracteres
+// de control, que permitirían inyectar reglas al exportarlo al acl_file de Mosquitto
+func validUsername(username string) bool {
+	return strings.IndexFunc(username, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) < 0
+}
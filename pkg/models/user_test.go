@@ -0,0 +1,18 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserRequestsRejectUnsafeUsernames(t *testing.T) {
+	create := CreateUserRequest{Username: "x\ntopic readwrite #", Email: "a@example.com", Password: "secreto"}
+	assert.Error(t, create.Validate())
+
+	update := UpdateUserRequest{Username: "ana lopez"}
+	assert.Error(t, update.Validate())
+
+	update = UpdateUserRequest{Email: "b@example.com"}
+	assert.NoError(t, update.Validate(), "The username is optional on updates")
+}
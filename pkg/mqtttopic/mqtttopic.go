@@ -0,0 +1,210 @@
+// Package mqtttopic implementa la sintaxis de topics y filtros MQTT 3.1.1:
+// validación, comprobación de coincidencias con los comodines + y #, y
+// traducción de filtros a expresiones regulares para consultas SQL.
+package mqtttopic
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+const (
+	// SingleLevel es el comodín que sustituye a un único nivel del topic
+	SingleLevel = "+"
+	// MultiLevel es el comodín que sustituye a cualquier número de niveles finales
+	MultiLevel = "#"
+
+	separator = "/"
+	maxLength = 65535
+)
+
+var (
+	// ErrEmpty se devuelve cuando el topic o el filtro están vacíos
+	ErrEmpty = errors.New("el topic no puede estar vacío")
+	// ErrInvalidTopic se devuelve cuando un topic no es válido para publicar
+	ErrInvalidTopic = errors.New("topic MQTT no válido")
+	// ErrInvalidFilter se devuelve cuando un filtro de suscripción no es válido
+	ErrInvalidFilter = errors.New("filtro de topic MQTT no válido")
+)
+
+// ValidateTopic comprueba que topic sea un nombre de topic válido para publicar
+// (sin comodines).
+func ValidateTopic(topic string) error {
+	if topic == "" {
+		return ErrEmpty
+	}
+	if len(topic) > maxLength || strings.ContainsAny(topic, "\x00+#") {
+		return ErrInvalidTopic
+	}
+	return nil
+}
+
+// ValidateFilter comprueba que filter sea un filtro de suscripción válido:
+// + debe ocupar un nivel completo y # solo puede aparecer como último nivel.
+func ValidateFilter(filter string) error {
+	if filter == "" {
+		return ErrEmpty
+	}
+	if len(filter) > maxLength || strings.Contains(filter, "\x00") {
+		return ErrInvalidFilter
+	}
+
+	levels := strings.Split(filter, separator)
+	for i, level := range levels {
+		switch {
+		case level == MultiLevel:
+			if i != len(levels)-1 {
+				return ErrInvalidFilter
+			}
+		case level == SingleLevel:
+		case strings.ContainsAny(level, "+#"):
+			return ErrInvalidFilter
+		}
+	}
+	return nil
+}
+
+// HasWildcards indica si el filtro contiene algún comodín
+func HasWildcards(filter string) bool {
+	return strings.ContainsAny(filter, "+#")
+}
+
+// Match indica si el topic coincide con el filtro. Como en los brokers, los
+// topics que empiezan por $ no coinciden con filtros que empiezan por comodín.
+func Match(filter, topic string) bool {
+	if ValidateFilter(filter) != nil || ValidateTopic(topic) != nil {
+		return false
+	}
+	return Covers(filter, topic)
+}
+
+// Covers indica si todo topic que coincide con sub coincide también con filter,
+// es decir, si filter es igual o más amplio que sub. Sirve para comprobar que
+// una suscripción con comodines queda dentro de lo permitido por una regla.
+func Covers(filter, sub string) bool {
+	if strings.HasPrefix(sub, "$") && (strings.HasPrefix(filter, SingleLevel) || strings.HasPrefix(filter, MultiLevel)) {
+		return false
+	}
+
+	f := strings.Split(filter, separator)
+	s := strings.Split(sub, separator)
+
+	for i, level := range f {
+		if level == MultiLevel {
+			return true
+		}
+		if i >= len(s) {
+			return false
+		}
+
+		switch {
+		case s[i] == MultiLevel:
+			return false
+		case level == SingleLevel:
+		case s[i] == SingleLevel || s[i] != level:
+			return false
+		}
+	}
+
+	return len(f) == len(s)
+}
+
+// Overlaps indica si algún topic coincide a la vez con los filtros a y b
+func Overlaps(a, b string) bool {
+	if (strings.HasPrefix(a, "$") && startsWithWildcard(b)) || (strings.HasPrefix(b, "$") && startsWithWildcard(a)) {
+		return false
+	}
+
+	fa := strings.Split(a, separator)
+	fb := strings.Split(b, separator)
+
+	for i := 0; i < len(fa) && i < len(fb); i++ {
+		switch {
+		case fa[i] == MultiLevel || fb[i] == MultiLevel:
+			return true
+		case fa[i] == SingleLevel || fb[i] == SingleLevel:
+		case fa[i] != fb[i]:
+			return false
+		}
+	}
+
+	// Si uno es más largo solo coinciden si le sobra "/#", que también
+	// coincide con el nivel padre
+	switch {
+	case len(fa) == len(fb):
+		return true
+	case len(fa) == len(fb)+1:
+		return fa[len(fb)] == MultiLevel
+	case len(fb) == len(fa)+1:
+		return fb[len(fa)] == MultiLevel
+	}
+	return false
+}
+
+// MoreSpecific indica si el filtro a es más específico que b. Un filtro sin
+// comodines gana siempre a uno con comodines; entre filtros con comodines gana
+// el de más niveles sin contar el # final (que también coincide con el nivel
+// padre), después el de menos comodines y, a igualdad, el primero en orden
+// alfabético para ser determinista. Cuando varios filtros coinciden con un
+// topic, manda el más específico.
+func MoreSpecific(a, b string) bool {
+	if wa, wb := HasWildcards(a), HasWildcards(b); wa != wb {
+		return !wa
+	}
+	if la, lb := specificLevels(a), specificLevels(b); la != lb {
+		return la > lb
+	}
+	wa, wb := strings.Count(a, SingleLevel)+strings.Count(a, MultiLevel), strings.Count(b, SingleLevel)+strings.Count(b, MultiLevel)
+	if wa != wb {
+		return wa < wb
+	}
+	return a < b
+}
+
+// startsWithWildcard indica si el primer nivel del filtro es un comodín
+func startsWithWildcard(filter string) bool {
+	return strings.HasPrefix(filter, SingleLevel) || strings.HasPrefix(filter, MultiLevel)
+}
+
+// specificLevels cuenta los niveles del filtro sin el # final
+func specificLevels(filter string) int {
+	levels := strings.Count(filter, separator) + 1
+	if filter == MultiLevel || strings.HasSuffix(filter, separator+MultiLevel) {
+		levels--
+	}
+	return levels
+}
+
+// Regexp traduce el filtro a una expresión regular anclada que acepta los
+// mismos topics que Match. Es compatible con el operador ~ de PostgreSQL.
+func Regexp(filter string) string {
+	var b strings.Builder
+	b.WriteString("^")
+
+	for i, level := range strings.Split(filter, separator) {
+		switch level {
+		case MultiLevel:
+			if i == 0 {
+				b.WriteString(`([^$].*)?`)
+			} else {
+				// "a/#" también coincide con el nivel padre "a"
+				b.WriteString(`(/.*)?`)
+			}
+		case SingleLevel:
+			if i == 0 {
+				b.WriteString(`([^/$][^/]*)?`)
+			} else {
+				b.WriteString(`/[^/]*`)
+			}
+		default:
+			if i > 0 {
+				b.WriteString(separator)
+			}
+			b.WriteString(regexp.QuoteMeta(level))
+		}
+	}
+
+	b.WriteString("$")
+	return b.String()
+}
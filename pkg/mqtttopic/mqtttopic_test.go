@@ -0,0 +1,91 @@
+package mqtttopic
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var matchCases = []struct {
+	filter string
+	topic  string
+	match  bool
+}{
+	{"sensores/sala1/temperatura", "sensores/sala1/temperatura", true},
+	{"sensores/sala1/temperatura", "sensores/sala2/temperatura", false},
+	{"sensores/+/temperatura", "sensores/sala1/temperatura", true},
+	{"sensores/+/temperatura", "sensores/sala1/humedad", false},
+	{"sensores/+/temperatura", "sensores//temperatura", true},
+	{"sensores/#", "sensores", true},
+	{"sensores/#", "sensores/sala1/temperatura", true},
+	{"sensores/#", "actuadores/sala1", false},
+	{"sensores/+", "sensores/sala1/temperatura", false},
+	{"#", "sensores/sala1", true},
+	{"+/+", "sensores/sala1", true},
+	{"#", "$SYS/broker/uptime", false},
+	{"+/broker/uptime", "$SYS/broker/uptime", false},
+	{"$SYS/#", "$SYS/broker/uptime", true},
+	{"sensores/sala.1", "sensores/salax1", false},
+}
+
+func TestMatch(t *testing.T) {
+	for _, c := range matchCases {
+		assert.Equal(t, c.match, Match(c.filter, c.topic), "Match(%q, %q)", c.filter, c.topic)
+	}
+}
+
+func TestRegexpAgreesWithMatch(t *testing.T) {
+	for _, c := range matchCases {
+		re := regexp.MustCompile(Regexp(c.filter))
+		assert.Equal(t, c.match, re.MatchString(c.topic), "Regexp(%q) = %q on %q", c.filter, Regexp(c.filter), c.topic)
+	}
+}
+
+func TestValidateFilter(t *testing.T) {
+	for _, filter := range []string{"a", "a/b", "+", "#", "a/+/c", "a/#", "/"} {
+		assert.NoError(t, ValidateFilter(filter), filter)
+	}
+	assert.ErrorIs(t, ValidateFilter(""), ErrEmpty)
+	for _, filter := range []string{"a/#/c", "a+", "a/b#", "sensores/sala+1"} {
+		assert.ErrorIs(t, ValidateFilter(filter), ErrInvalidFilter, filter)
+	}
+
+	assert.NoError(t, ValidateTopic("sensores/sala1"))
+	assert.ErrorIs(t, ValidateTopic("sensores/+"), ErrInvalidTopic)
+}
+
+func TestCovers(t *testing.T) {
+	assert.True(t, Covers("sensores/#", "sensores/+/temperatura"))
+	assert.True(t, Covers("sensores/+/temperatura", "sensores/+/temperatura"))
+	assert.True(t, Covers("#", "sensores/#"))
+	assert.False(t, Covers("sensores/+/temperatura", "sensores/#"))
+	assert.False(t, Covers("sensores/sala1/+", "sensores/+/temperatura"))
+	assert.False(t, Covers("sensores/+", "sensores/+/temperatura"))
+}
+
+func TestMoreSpecific(t *testing.T) {
+	assert.True(t, MoreSpecific("sensores/sala1/temperatura", "sensores/#"))
+	assert.True(t, MoreSpecific("sensores/sala1", "sensores/+"))
+	assert.True(t, MoreSpecific("+/sala1/temperatura", "sensores/+"))
+	assert.True(t, MoreSpecific("sensores/#", "#"))
+	assert.False(t, MoreSpecific("#", "sensores/#"))
+	assert.False(t, MoreSpecific("sensores/#", "sensores/#"))
+	assert.NotEqual(t, MoreSpecific("a/+", "b/+"), MoreSpecific("b/+", "a/+"), "Ties must be broken deterministically")
+
+	assert.True(t, MoreSpecific("a", "a/#"), "a/# also matches its parent a, where the exact filter wins")
+	assert.False(t, MoreSpecific("a/#", "a"))
+	assert.True(t, MoreSpecific("a/+", "a/#"))
+	assert.True(t, MoreSpecific("a/b", "+/b/#"))
+}
+
+func TestOverlaps(t *testing.T) {
+	assert.True(t, Overlaps("a", "a/#"))
+	assert.True(t, Overlaps("a/+", "a/#"))
+	assert.True(t, Overlaps("+/sala1/temperatura", "sensores/+/+"))
+	assert.True(t, Overlaps("#", "sensores/sala1"))
+	assert.False(t, Overlaps("sensores/#", "actuadores/+"))
+	assert.False(t, Overlaps("a", "a/+"))
+	assert.False(t, Overlaps("a/b/#", "a"))
+	assert.False(t, Overlaps("#", "$SYS/broker"), "Wildcards at the first level do not match $ topics")
+}
@@ -0,0 +1,115 @@
+// Package password proporciona el hasheo y la verificación de contraseñas de usuario.
+//
+// Los hashes se guardan en formato autodescriptivo ($argon2id$..., $2a$...), de modo
+// que un Manager puede verificar contraseñas creadas con cualquier algoritmo soportado
+// y detectar cuándo un hash debe regenerarse con los parámetros actuales.
+package password
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Algoritmos soportados
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+var (
+	// ErrEmptyPassword se devuelve al intentar hashear una contraseña vacía
+	ErrEmptyPassword = errors.New("password is required")
+	// ErrUnknownHashFormat se devuelve cuando el hash almacenado no corresponde a ningún algoritmo soportado
+	ErrUnknownHashFormat = errors.New("unknown password hash format")
+)
+
+// Hasher define las operaciones de un algoritmo de hasheo de contraseñas
+type Hasher interface {
+	// Hash genera el hash codificado de la contraseña
+	Hash(password string) (string, error)
+
+	// Verify comprueba en tiempo constante si la contraseña corresponde al hash
+	Verify(password, encoded string) (bool, error)
+
+	// Supports indica si el hash codificado pertenece a este algoritmo
+	Supports(encoded string) bool
+
+	// NeedsRehash indica si el hash se generó con parámetros distintos a los actuales
+	NeedsRehash(encoded string) bool
+}
+
+// Manager hashea con un algoritmo principal y verifica con cualquiera de los soportados
+type Manager struct {
+	primary Hasher
+	hashers []Hasher
+}
+
+// NewManager crea un Manager que usa primary para los hashes nuevos.
+// Los hashers adicionales solo se usan para verificar hashes existentes.
+func NewManager(primary Hasher, others ...Hasher) *Manager {
+	return &Manager{
+		primary: primary,
+		hashers: append([]Hasher{primary}, others...),
+	}
+}
+
+// Config contiene los parámetros de los algoritmos de hasheo
+type Config struct {
+	Algorithm  string
+	Argon2     Argon2Params
+	BcryptCost int
+}
+
+// NewManagerFromConfig crea un Manager con el algoritmo principal indicado en la configuración
+func NewManagerFromConfig(cfg Config) (*Manager, error) {
+	argon := NewArgon2idHasher(cfg.Argon2)
+	bcrypt := NewBcryptHasher(cfg.BcryptCost)
+
+	switch cfg.Algorithm {
+	case "", AlgorithmArgon2id:
+		return NewManager(argon, bcrypt), nil
+	case AlgorithmBcrypt:
+		return NewManager(bcrypt, argon), nil
+	default:
+		return nil, fmt.Errorf("unsupported password hashing algorithm: %q", cfg.Algorithm)
+	}
+}
+
+// Hash genera el hash de la contraseña con el algoritmo principal
+func (m *Manager) Hash(password string) (string, error) {
+	if password == "" {
+		return "", ErrEmptyPassword
+	}
+	return m.primary.Hash(password)
+}
+
+// Verify comprueba la contraseña contra el hash almacenado. needsRehash indica que la
+// contraseña es correcta pero el hash debería regenerarse con el algoritmo y parámetros actuales.
+func (m *Manager) Verify(password, encoded string) (ok bool, needsRehash bool, err error) {
+	hasher := m.hasherFor(encoded)
+	if hasher == nil {
+		return false, false, ErrUnknownHashFormat
+	}
+
+	ok, err = hasher.Verify(password, encoded)
+	if err != nil || !ok {
+		return false, false, err
+	}
+
+	return true, hasher != m.primary || hasher.NeedsRehash(encoded), nil
+}
+
+// IsHashed indica si el valor almacenado es un hash reconocido (y no una contraseña en claro)
+func (m *Manager) IsHashed(encoded string) bool {
+	return m.hasherFor(encoded) != nil
+}
+
+// hasherFor devuelve el hasher capaz de verificar el hash codificado
+func (m *Manager) hasherFor(encoded string) Hasher {
+	for _, h := range m.hashers {
+		if h.Supports(encoded) {
+			return h
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package password
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Parámetros baratos para que los tests sean rápidos
+var testArgon2Params = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1}
+
+func TestArgon2idHashAndVerify(t *testing.T) {
+	manager := NewManager(NewArgon2idHasher(testArgon2Params), NewBcryptHasher(4))
+
+	hash, err := manager.Hash("password123")
+	assert.NoError(t, err)
+	assert.Contains(t, hash, "$argon2id$v=19$m=1024,t=1,p=1$")
+	assert.True(t, manager.IsHashed(hash))
+
+	ok, needsRehash, err := manager.Verify("password123", hash)
+	assert.NoError(t, err)
+	assert.True(t, ok, "Should accept the right password")
+	assert.False(t, needsRehash, "Should not rehash with the same parameters")
+
+	ok, _, err = manager.Verify("wrong", hash)
+	assert.NoError(t, err)
+	assert.False(t, ok, "Should reject a wrong password")
+}
+
+func TestVerifyRequestsRehashWhenParametersChange(t *testing.T) {
+	old := NewManager(NewArgon2idHasher(testArgon2Params))
+	hash, err := old.Hash("password123")
+	assert.NoError(t, err)
+
+	stronger := testArgon2Params
+	stronger.Iterations = 2
+	current := NewManager(NewArgon2idHasher(stronger))
+
+	ok, needsRehash, err := current.Verify("password123", hash)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, needsRehash, "Should rehash when argon2id parameters change")
+}
+
+func TestBcryptHashesAreVerifiedAndMigrated(t *testing.T) {
+	bcryptOnly := NewManager(NewBcryptHasher(4))
+	hash, err := bcryptOnly.Hash("password123")
+	assert.NoError(t, err)
+
+	manager := NewManager(NewArgon2idHasher(testArgon2Params), NewBcryptHasher(4))
+	ok, needsRehash, err := manager.Verify("password123", hash)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, needsRehash, "Should migrate bcrypt hashes to the primary algorithm")
+}
+
+func TestPlaintextIsNotAHash(t *testing.T) {
+	manager := NewManager(NewArgon2idHasher(testArgon2Params), NewBcryptHasher(4))
+
+	assert.False(t, manager.IsHashed("password123"))
+
+	ok, _, err := manager.Verify("password123", "password123")
+	assert.ErrorIs(t, err, ErrUnknownHashFormat)
+	assert.False(t, ok, "Should never accept a plaintext stored password")
+
+	_, err = manager.Hash("")
+	assert.ErrorIs(t, err, ErrEmptyPassword)
+}
+
+func TestNewManagerFromConfig(t *testing.T) {
+	manager, err := NewManagerFromConfig(Config{Algorithm: AlgorithmBcrypt, BcryptCost: 4})
+	assert.NoError(t, err)
+
+	hash, err := manager.Hash("password123")
+	assert.NoError(t, err)
+	assert.Contains(t, hash, "$2a$04$")
+
+	_, err = NewManagerFromConfig(Config{Algorithm: "md5"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,470 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/mqtttopic"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/tracing"
+	"github.com/lib/pq"
+)
+
+// Funciones de agregación admitidas
+const (
+	AggregateAvg   = "avg"
+	AggregateMin   = "min"
+	AggregateMax   = "max"
+	AggregateSum   = "sum"
+	AggregateCount = "count"
+)
+
+// Cómo se rellenan los intervalos sin valores
+const (
+	// FillNull devuelve los intervalos vacíos con count 0 y el resto de funciones a null
+	FillNull = "null"
+	// FillZero devuelve los intervalos vacíos con todas las funciones a 0
+	FillZero = "zero"
+	// FillNone omite los intervalos vacíos
+	FillNone = "none"
+)
+
+const (
+	// defaultAggregateRange es el periodo agregado si la consulta no indica from
+	defaultAggregateRange = 24 * time.Hour
+	// maxAggregateBuckets limita los intervalos de cada serie
+	maxAggregateBuckets = 10000
+)
+
+// aggregateFunctions son las funciones admitidas en el orden en que se devuelven
+var aggregateFunctions = []string{AggregateAvg, AggregateMin, AggregateMax, AggregateSum, AggregateCount}
+
+// AggregateQuery describe una serie temporal calculada sobre un campo numérico
+// del payload JSON de los mensajes
+type AggregateQuery struct {
+	// Restricted y TopicFilters limitan los topics igual que en MessageQuery
+	Restricted   bool
+	TopicFilters []string
+
+	// Topics son topics exactos o filtros con comodines; se devuelve una serie por topic
+	Topics []string
+	// Field es la expresión JSONPath del valor, p. ej. $.value
+	Field string
+	// Bucket es la duración de cada intervalo. Los intervalos se alinean con
+	// la época Unix, así que los de 5m empiezan en :00, :05, :10...
+	Bucket time.Duration
+	// Functions son las funciones que se calculan en cada intervalo
+	Functions []string
+	// From (incluido) y To (excluido) acotan received_at
+	From time.Time
+	To   time.Time
+	// Fill indica qué hacer con los intervalos sin valores (null, zero o none)
+	Fill string
+
+	// Segments reparte [From, To) entre las tablas de agregados y
+	// mqtt_messages (ver PlanSegments); sin tramos se usan solo los mensajes
+	Segments []AggregateSegment
+}
+
+// Resolution devuelve la resolución más gruesa de los agregados que usa la
+// consulta, o raw si se calcula solo sobre los mensajes
+func (q AggregateQuery) Resolution() string {
+	resolution := RawResolution
+	var coarsest time.Duration
+	for _, segment := range q.Segments {
+		if segment.Resolution != nil && segment.Resolution.Duration > coarsest {
+			coarsest = segment.Resolution.Duration
+			resolution = segment.Resolution.Name
+		}
+	}
+	return resolution
+}
+
+// AggregateSeries es la serie temporal de un topic, con un punto por intervalo
+type AggregateSeries struct {
+	Topic  string           `json:"topic"`
+	Points []AggregatePoint `json:"points"`
+}
+
+// AggregatePoint son los valores de las funciones pedidas en el intervalo que
+// empieza en Bucket. Un valor nil es un intervalo sin valores (null en JSON).
+type AggregatePoint struct {
+	Bucket time.Time
+	Values map[string]*float64
+}
+
+// MarshalJSON devuelve el punto como un objeto plano: {"bucket": ..., "avg": ..., "count": ...}
+func (p AggregatePoint) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	bucket, err := json.Marshal(p.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteString(`{"bucket":`)
+	buf.Write(bucket)
+
+	for _, fn := range aggregateFunctions {
+		value, ok := p.Values[fn]
+		if !ok {
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(`,"` + fn + `":`)
+		buf.Write(encoded)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// ParseAggregateQuery interpreta los parámetros de una petición de agregación:
+// topic (repetible y obligatorio), field (JSONPath), bucket (5m, 1h, 1d...), fn
+// (avg,min,max,sum,count; avg por defecto), from y to (RFC 3339; por defecto
+// las últimas 24 horas hasta now) y fill (null, zero o none).
+func ParseAggregateQuery(values url.Values, now time.Time) (AggregateQuery, error) {
+	q := AggregateQuery{Fill: FillNull}
+
+	topics, err := ParseTopicFilters(values["topic"])
+	if err != nil {
+		return q, err
+	}
+	if len(topics) == 0 {
+		return q, fmt.Errorf("%w: topic es obligatorio", ErrInvalidMessageQuery)
+	}
+	q.Topics = topics
+
+	if q.Field = strings.TrimSpace(values.Get("field")); q.Field == "" {
+		return q, fmt.Errorf("%w: field es obligatorio (p. ej. $.value)", ErrInvalidMessageQuery)
+	}
+
+	if q.Bucket, err = ParseBucket(values.Get("bucket")); err != nil {
+		return q, err
+	}
+
+	if q.Functions, err = parseAggregateFunctions(values.Get("fn")); err != nil {
+		return q, err
+	}
+
+	q.To = now
+	if value := values.Get("to"); value != "" {
+		if q.To, err = time.Parse(time.RFC3339, value); err != nil {
+			return q, fmt.Errorf("%w: to debe tener formato RFC 3339", ErrInvalidMessageQuery)
+		}
+	}
+	q.From = q.To.Add(-defaultAggregateRange)
+	if value := values.Get("from"); value != "" {
+		if q.From, err = time.Parse(time.RFC3339, value); err != nil {
+			return q, fmt.Errorf("%w: from debe tener formato RFC 3339", ErrInvalidMessageQuery)
+		}
+	}
+	if !q.From.Before(q.To) {
+		return q, fmt.Errorf("%w: from debe ser anterior a to", ErrInvalidMessageQuery)
+	}
+	if buckets := len(bucketStarts(q.From, q.To, q.Bucket, maxAggregateBuckets+1)); buckets > maxAggregateBuckets {
+		return q, fmt.Errorf("%w: el rango abarca más de %d intervalos, aumente bucket", ErrInvalidMessageQuery, maxAggregateBuckets)
+	}
+
+	switch fill := values.Get("fill"); fill {
+	case "":
+	case FillNull, FillZero, FillNone:
+		q.Fill = fill
+	default:
+		return q, fmt.Errorf("%w: fill debe ser null, zero o none", ErrInvalidMessageQuery)
+	}
+
+	return q, nil
+}
+
+// ParseBucket interpreta la duración de un intervalo: las de time.ParseDuration
+// (30s, 5m, 1h30m) o un número de días (1d, 7d). El mínimo es un segundo.
+func ParseBucket(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("%w: bucket es obligatorio (p. ej. 5m)", ErrInvalidMessageQuery)
+	}
+
+	var bucket time.Duration
+	if days, found := strings.CutSuffix(s, "d"); found {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("%w: bucket no válido: %q", ErrInvalidMessageQuery, s)
+		}
+		bucket = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if bucket, err = time.ParseDuration(s); err != nil {
+			return 0, fmt.Errorf("%w: bucket no válido: %q", ErrInvalidMessageQuery, s)
+		}
+	}
+
+	if bucket < time.Second || bucket%time.Second != 0 {
+		return 0, fmt.Errorf("%w: bucket debe ser un número entero de segundos", ErrInvalidMessageQuery)
+	}
+	return bucket, nil
+}
+
+// parseAggregateFunctions interpreta la lista de funciones separadas por comas
+// y las devuelve sin repetir en el orden de aggregateFunctions
+func parseAggregateFunctions(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return []string{AggregateAvg}, nil
+	}
+
+	requested := map[string]bool{}
+	for _, fn := range strings.Split(s, ",") {
+		fn = strings.ToLower(strings.TrimSpace(fn))
+		if !slices.Contains(aggregateFunctions, fn) {
+			return nil, fmt.Errorf("%w: fn %q no válida (avg, min, max, sum, count)", ErrInvalidMessageQuery, fn)
+		}
+		requested[fn] = true
+	}
+
+	functions := make([]string, 0, len(requested))
+	for _, fn := range aggregateFunctions {
+		if requested[fn] {
+			functions = append(functions, fn)
+		}
+	}
+	return functions, nil
+}
+
+// Aggregate calcula con date_bin las funciones pedidas sobre el campo numérico
+// del payload en cada intervalo, con una serie por topic. Los mensajes que no
+// son JSON o cuyo campo no es un número no cuentan. Los intervalos vacíos se
+// completan según q.Fill en todo el rango [From, To). Los tramos de
+// q.Segments con resolución se leen de las tablas de agregados.
+func (r *MqttMessageRepository) Aggregate(ctx context.Context, q AggregateQuery) (_ []AggregateSeries, err error) {
+	ctx, span := tracing.StartDB(ctx, "MqttMessageRepository.Aggregate", "SELECT", "mqtt_messages")
+	defer tracing.End(span, &err)
+
+	if q.Restricted && len(q.TopicFilters) == 0 {
+		return []AggregateSeries{}, nil
+	}
+
+	segments := q.Segments
+	if len(segments) == 0 {
+		segments = []AggregateSegment{{From: q.From, To: q.To}}
+	}
+
+	byTopic := map[string]map[int64]aggregateRow{}
+	for _, segment := range segments {
+		if err := r.aggregateSegment(ctx, q, segment, byTopic); err != nil {
+			return nil, err
+		}
+	}
+
+	topics := seriesTopics(q, byTopic)
+	series := make([]AggregateSeries, 0, len(topics))
+	for _, topic := range topics {
+		series = append(series, AggregateSeries{Topic: topic, Points: fillBuckets(q, byTopic[topic])})
+	}
+	return series, nil
+}
+
+// aggregateSegment agrega un tramo de la consulta y suma el resultado a los
+// intervalos de byTopic, que pueden tener ya valores de otros tramos
+func (r *MqttMessageRepository) aggregateSegment(ctx context.Context, q AggregateQuery, segment AggregateSegment, byTopic map[string]map[int64]aggregateRow) error {
+	var conditions []string
+	var args []interface{}
+	addArg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	field := addArg(q.Field)
+	bucket := addArg(int64(q.Bucket / time.Second))
+
+	if q.Restricted {
+		patterns := make([]string, len(q.TopicFilters))
+		for i, filter := range q.TopicFilters {
+			patterns[i] = mqtttopic.Regexp(filter)
+		}
+		conditions = append(conditions, "topic ~ ANY("+addArg(pq.Array(patterns))+")")
+	}
+	if len(q.Topics) > 0 {
+		conditions = append(conditions, topicCondition(q.Topics, addArg))
+	}
+
+	// Los intervalos se alinean con la época Unix, igual que bucketStart
+	var query string
+	if segment.Resolution == nil {
+		conditions = append(conditions,
+			"received_at >= "+addArg(segment.From),
+			"received_at < "+addArg(segment.To),
+			"payload_json IS NOT NULL")
+		query = `
+            SELECT topic, bucket, count(value), sum(value), min(value), max(value)
+            FROM (
+                SELECT topic,
+                    date_bin(make_interval(secs => ` + bucket + `), received_at, TIMESTAMPTZ '1970-01-01 00:00:00+00') AS bucket,
+                    CASE WHEN jsonb_typeof(field) = 'number' THEN (field #>> '{}')::double precision END AS value
+                FROM mqtt_messages
+                CROSS JOIN LATERAL jsonb_path_query_first(payload_json, ` + field + `::jsonpath) AS extracted(field)
+                WHERE ` + strings.Join(conditions, " AND ") + `
+            ) AS numeric_values
+            WHERE value IS NOT NULL
+            GROUP BY topic, bucket
+        `
+	} else {
+		conditions = append(conditions,
+			"field = "+field,
+			"bucket >= "+addArg(segment.From),
+			"bucket < "+addArg(segment.To))
+		query = `
+            SELECT topic, date_bin(make_interval(secs => ` + bucket + `), bucket, TIMESTAMPTZ '1970-01-01 00:00:00+00') AS query_bucket,
+                sum(count), sum(sum), min(min), max(max)
+            FROM ` + pq.QuoteIdentifier(segment.Resolution.Table) + `
+            WHERE ` + strings.Join(conditions, " AND ") + `
+            GROUP BY topic, query_bucket
+        `
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return payloadFilterError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var topic string
+		var row aggregateRow
+		if err := rows.Scan(&topic, &row.bucket, &row.count, &row.sum, &row.min, &row.max); err != nil {
+			return err
+		}
+		if byTopic[topic] == nil {
+			byTopic[topic] = map[int64]aggregateRow{}
+		}
+		key := row.bucket.UnixNano()
+		if existing, found := byTopic[topic][key]; found {
+			row = existing.merge(row)
+		}
+		byTopic[topic][key] = row
+	}
+	return rows.Err()
+}
+
+// seriesTopics devuelve ordenados los topics que llevan serie: los que tienen
+// valores y los topics exactos pedidos aunque no tengan ninguno (si el usuario
+// puede leerlos), para que la serie aparezca con sus intervalos vacíos
+func seriesTopics(q AggregateQuery, byTopic map[string]map[int64]aggregateRow) []string {
+	topics := make([]string, 0, len(byTopic))
+	for topic := range byTopic {
+		topics = append(topics, topic)
+	}
+
+	for _, topic := range q.Topics {
+		if mqtttopic.HasWildcards(topic) || byTopic[topic] != nil || !readable(q, topic) {
+			continue
+		}
+		topics = append(topics, topic)
+	}
+
+	sort.Strings(topics)
+	return slices.Compact(topics)
+}
+
+// readable indica si el topic cumple la restricción de TopicFilters
+func readable(q AggregateQuery, topic string) bool {
+	if !q.Restricted {
+		return true
+	}
+	for _, filter := range q.TopicFilters {
+		if mqtttopic.Match(filter, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// aggregateRow es un intervalo con valores. Se guardan count, sum, min y max
+// porque se pueden combinar los de varios tramos; la media es sum / count.
+type aggregateRow struct {
+	bucket time.Time
+	count  int64
+	sum    float64
+	min    float64
+	max    float64
+}
+
+// merge combina los valores de dos tramos del mismo intervalo
+func (r aggregateRow) merge(other aggregateRow) aggregateRow {
+	r.count += other.count
+	r.sum += other.sum
+	r.min = min(r.min, other.min)
+	r.max = max(r.max, other.max)
+	return r
+}
+
+// value devuelve el resultado de la función fn en el intervalo
+func (r aggregateRow) value(fn string) float64 {
+	switch fn {
+	case AggregateAvg:
+		return r.sum / float64(r.count)
+	case AggregateMin:
+		return r.min
+	case AggregateMax:
+		return r.max
+	case AggregateSum:
+		return r.sum
+	default:
+		return float64(r.count)
+	}
+}
+
+// fillBuckets devuelve un punto por cada intervalo de [From, To) con las
+// funciones pedidas. rows son los intervalos con valores indexados por el
+// inicio en nanosegundos. Los vacíos llevan count 0 y el resto de funciones a
+// null (FillNull), todo a 0 (FillZero) o se omiten (FillNone).
+func fillBuckets(q AggregateQuery, rows map[int64]aggregateRow) []AggregatePoint {
+	points := []AggregatePoint{}
+	for _, start := range bucketStarts(q.From, q.To, q.Bucket, maxAggregateBuckets) {
+		row, found := rows[start.UnixNano()]
+		if !found && q.Fill == FillNone {
+			continue
+		}
+
+		point := AggregatePoint{Bucket: start, Values: make(map[string]*float64, len(q.Functions))}
+		for _, fn := range q.Functions {
+			var value *float64
+			switch {
+			case found:
+				v := row.value(fn)
+				value = &v
+			case fn == AggregateCount || q.Fill == FillZero:
+				value = new(float64)
+			}
+			point.Values[fn] = value
+		}
+		points = append(points, point)
+	}
+	return points
+}
+
+// bucketStarts devuelve el inicio de los intervalos que abarcan [from, to),
+// como mucho limit. El primero puede empezar antes de from.
+func bucketStarts(from, to time.Time, bucket time.Duration, limit int) []time.Time {
+	var starts []time.Time
+	for start := bucketStart(from, bucket); start.Before(to) && len(starts) < limit; start = start.Add(bucket) {
+		starts = append(starts, start)
+	}
+	return starts
+}
+
+// bucketStart devuelve el inicio del intervalo que contiene t, alineado con la
+// época Unix como date_bin con origen 1970-01-01
+func bucketStart(t time.Time, bucket time.Duration) time.Time {
+	ns := t.UnixNano()
+	offset := ns % int64(bucket)
+	if offset < 0 {
+		offset += int64(bucket)
+	}
+	return time.Unix(0, ns-offset).UTC()
+}
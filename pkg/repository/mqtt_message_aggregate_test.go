@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAggregateQuery(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	values := url.Values{
+		"topic":  {"sensors/temperature"},
+		"field":  {"$.value"},
+		"bucket": {"5m"},
+		"fn":     {"max, avg,count,avg"},
+		"from":   {"2025-06-01T10:00:00Z"},
+		"to":     {"2025-06-01T11:00:00Z"},
+		"fill":   {"zero"},
+	}
+
+	q, err := ParseAggregateQuery(values, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"sensors/temperature"}, q.Topics)
+	assert.Equal(t, "$.value", q.Field)
+	assert.Equal(t, 5*time.Minute, q.Bucket)
+	assert.Equal(t, []string{"avg", "max", "count"}, q.Functions, "Functions are deduplicated in a fixed order")
+	assert.Equal(t, time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC), q.From)
+	assert.Equal(t, FillZero, q.Fill)
+
+	q, err = ParseAggregateQuery(url.Values{"topic": {"sensors/+"}, "field": {"$.value"}, "bucket": {"1h"}}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{AggregateAvg}, q.Functions)
+	assert.Equal(t, now, q.To)
+	assert.Equal(t, now.Add(-24*time.Hour), q.From, "Defaults to the last 24 hours")
+	assert.Equal(t, FillNull, q.Fill)
+}
+
+func TestParseAggregateQueryInvalid(t *testing.T) {
+	now := time.Now()
+	valid := func() url.Values {
+		return url.Values{"topic": {"sensors/temperature"}, "field": {"$.value"}, "bucket": {"5m"}}
+	}
+
+	cases := map[string]func(url.Values){
+		"missing topic":  func(v url.Values) { v.Del("topic") },
+		"invalid topic":  func(v url.Values) { v.Set("topic", "sensors/#/x") },
+		"missing field":  func(v url.Values) { v.Del("field") },
+		"missing bucket": func(v url.Values) { v.Del("bucket") },
+		"invalid fn":     func(v url.Values) { v.Set("fn", "avg,median") },
+		"invalid from":   func(v url.Values) { v.Set("from", "yesterday") },
+		"reversed range": func(v url.Values) {
+			v.Set("from", "2025-06-02T00:00:00Z")
+			v.Set("to", "2025-06-01T00:00:00Z")
+		},
+		"too many buckets": func(v url.Values) { v.Set("bucket", "1s") },
+		"invalid fill":     func(v url.Values) { v.Set("fill", "previous") },
+	}
+	for name, mutate := range cases {
+		values := valid()
+		mutate(values)
+		_, err := ParseAggregateQuery(values, now)
+		assert.ErrorIs(t, err, ErrInvalidMessageQuery, name)
+	}
+}
+
+func TestParseBucket(t *testing.T) {
+	for input, expected := range map[string]time.Duration{
+		"30s":   30 * time.Second,
+		"5m":    5 * time.Minute,
+		"1h30m": 90 * time.Minute,
+		"7d":    7 * 24 * time.Hour,
+	} {
+		bucket, err := ParseBucket(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, bucket, input)
+	}
+
+	for _, input := range []string{"", "5", "500ms", "1.5s", "-5m", "0d", "xd"} {
+		_, err := ParseBucket(input)
+		assert.ErrorIs(t, err, ErrInvalidMessageQuery, input)
+	}
+}
+
+func TestBucketStartAlignsWithUnixEpoch(t *testing.T) {
+	at := time.Date(2025, 6, 1, 10, 7, 42, 0, time.FixedZone("CEST", 2*3600))
+
+	assert.Equal(t, time.Date(2025, 6, 1, 8, 5, 0, 0, time.UTC), bucketStart(at, 5*time.Minute))
+	assert.Equal(t, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), bucketStart(at, 24*time.Hour), "Daily buckets start at UTC midnight")
+
+	starts := bucketStarts(at, at.Add(10*time.Minute), 5*time.Minute, maxAggregateBuckets)
+	assert.Len(t, starts, 3, "The first bucket may start before from")
+}
+
+func TestFillBuckets(t *testing.T) {
+	from := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	q := AggregateQuery{
+		Bucket:    5 * time.Minute,
+		Functions: []string{AggregateAvg, AggregateCount},
+		From:      from,
+		To:        from.Add(15 * time.Minute),
+		Fill:      FillNull,
+	}
+	second := from.Add(5 * time.Minute)
+	rows := map[int64]aggregateRow{
+		second.UnixNano(): {bucket: second, count: 2, sum: 43, min: 21, max: 22},
+	}
+
+	points := fillBuckets(q, rows)
+	if assert.Len(t, points, 3) {
+		assert.Equal(t, from, points[0].Bucket)
+		assert.Nil(t, points[0].Values[AggregateAvg], "Empty buckets have no average")
+		assert.Equal(t, 0.0, *points[0].Values[AggregateCount], "Empty buckets count zero values")
+		assert.Equal(t, 21.5, *points[1].Values[AggregateAvg])
+		assert.Equal(t, 2.0, *points[1].Values[AggregateCount])
+		assert.NotContains(t, points[1].Values, AggregateMax, "Only the requested functions are returned")
+	}
+
+	q.Fill = FillZero
+	points = fillBuckets(q, rows)
+	assert.Equal(t, 0.0, *points[2].Values[AggregateAvg])
+
+	q.Fill = FillNone
+	points = fillBuckets(q, rows)
+	if assert.Len(t, points, 1) {
+		assert.Equal(t, second, points[0].Bucket)
+	}
+
+	assert.Empty(t, fillBuckets(q, nil))
+}
+
+func TestSeriesTopics(t *testing.T) {
+	byTopic := map[string]map[int64]aggregateRow{"sensors/b/temperature": {}}
+	q := AggregateQuery{Topics: []string{"sensors/+/temperature", "sensors/a/temperature", "sensors/b/temperature"}}
+
+	assert.Equal(t, []string{"sensors/a/temperature", "sensors/b/temperature"}, seriesTopics(q, byTopic),
+		"Exact topics without values still get a series")
+
+	q.Restricted = true
+	q.TopicFilters = []string{"sensors/b/#"}
+	assert.Equal(t, []string{"sensors/b/temperature"}, seriesTopics(q, byTopic), "Unreadable topics are left out")
+}
+
+func TestAggregatePointJSON(t *testing.T) {
+	avg := 21.5
+	point := AggregatePoint{
+		Bucket: time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC),
+		Values: map[string]*float64{AggregateCount: new(float64), AggregateAvg: &avg, AggregateMax: nil},
+	}
+
+	encoded, err := json.Marshal(point)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.JSONEq(t, `{"bucket":"2025-06-01T10:00:00Z","avg":21.5,"max":null,"count":0}`, string(encoded))
+}
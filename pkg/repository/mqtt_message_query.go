@@ -0,0 +1,248 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/mqtttopic"
+)
+
+var (
+	// ErrInvalidMessageQuery se devuelve cuando algún parámetro de la consulta de mensajes no es válido
+	ErrInvalidMessageQuery = errors.New("consulta de mensajes no válida")
+	// ErrInvalidPayloadFilter se devuelve cuando un filtro sobre el payload no es válido
+	ErrInvalidPayloadFilter = fmt.Errorf("%w: filtro de payload no válido", ErrInvalidMessageQuery)
+)
+
+// MessageQuery describe qué mensajes MQTT listar
+type MessageQuery struct {
+	// Restricted limita el resultado a los topics que cumplen TopicFilters;
+	// con Restricted y sin filtros no se devuelve ningún mensaje
+	Restricted   bool
+	TopicFilters []string
+
+	// Topics son topics exactos o filtros con comodines + y #; basta con que
+	// el mensaje cumpla uno
+	Topics []string
+	// From (incluido) y To (excluido) acotan received_at
+	From     *time.Time
+	To       *time.Time
+	QOS      *int
+	Retained *bool
+	// Direction limita el resultado a los mensajes recibidos (inbound) o publicados (outbound)
+	Direction string
+
+	// Where exige que el payload JSON contenga cada uno de los valores
+	Where []PayloadCondition
+	// JSONPath exige que el payload JSON tenga algún elemento que cumpla la
+	// expresión, p. ej. `$.temperature ? (@ > 20)`
+	JSONPath string
+
+	// Ascending ordena del más antiguo al más reciente; por defecto al revés
+	Ascending bool
+	// After continúa la paginación tras el último mensaje de la página anterior
+	After *Cursor
+	// AfterID devuelve los mensajes con id mayor, ordenados por id (para
+	// reanudar un stream desde Last-Event-ID). Tiene prioridad sobre After.
+	AfterID int
+	Limit   int
+}
+
+// MessagePage es una página de mensajes. NextCursor está vacío en la última.
+type MessagePage struct {
+	Messages   []models.MqttMessage
+	NextCursor string
+}
+
+// Cursor identifica la posición de un mensaje en el orden (received_at, id)
+type Cursor struct {
+	ReceivedAt time.Time `json:"t"`
+	ID         int       `json:"id"`
+}
+
+// CursorAt devuelve el cursor que apunta al mensaje indicado
+func CursorAt(msg models.MqttMessage) Cursor {
+	return Cursor{ReceivedAt: msg.ReceivedAt, ID: msg.ID}
+}
+
+// String codifica el cursor como un token opaco apto para URLs
+func (c Cursor) String() string {
+	encoded, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// ParseCursor decodifica un cursor generado por Cursor.String
+func ParseCursor(s string) (*Cursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: cursor no válido", ErrInvalidMessageQuery)
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(decoded, &cursor); err != nil || cursor.ReceivedAt.IsZero() {
+		return nil, fmt.Errorf("%w: cursor no válido", ErrInvalidMessageQuery)
+	}
+	return &cursor, nil
+}
+
+// ParseTopicFilters valida los filtros de topic de una petición (?topic=
+// repetible) y descarta los vacíos
+func ParseTopicFilters(values []string) ([]string, error) {
+	var topics []string
+	for _, topic := range values {
+		if topic = strings.TrimSpace(topic); topic == "" {
+			continue
+		}
+		if err := mqtttopic.ValidateFilter(topic); err != nil {
+			return nil, fmt.Errorf("%w: topic: %v", ErrInvalidMessageQuery, err)
+		}
+		topics = append(topics, topic)
+	}
+	return topics, nil
+}
+
+// ParseMessageQuery interpreta los parámetros de una petición de listado:
+// topic (repetible), from, to (RFC 3339), qos, retained, direction (inbound|outbound),
+// sort (asc|desc), cursor, where (repetible) y jsonpath. El límite y los topics permitidos los fija quien llama.
+func ParseMessageQuery(values url.Values) (MessageQuery, error) {
+	var q MessageQuery
+
+	topics, err := ParseTopicFilters(values["topic"])
+	if err != nil {
+		return q, err
+	}
+	q.Topics = topics
+
+	for name, target := range map[string]**time.Time{"from": &q.From, "to": &q.To} {
+		if value := values.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return q, fmt.Errorf("%w: %s debe tener formato RFC 3339", ErrInvalidMessageQuery, name)
+			}
+			*target = &t
+		}
+	}
+	if q.From != nil && q.To != nil && !q.From.Before(*q.To) {
+		return q, fmt.Errorf("%w: from debe ser anterior a to", ErrInvalidMessageQuery)
+	}
+
+	if value := values.Get("qos"); value != "" {
+		qos, err := strconv.Atoi(value)
+		if err != nil || qos < 0 || qos > 2 {
+			return q, fmt.Errorf("%w: qos debe ser 0, 1 o 2", ErrInvalidMessageQuery)
+		}
+		q.QOS = &qos
+	}
+
+	if value := values.Get("retained"); value != "" {
+		retained, err := strconv.ParseBool(value)
+		if err != nil {
+			return q, fmt.Errorf("%w: retained debe ser true o false", ErrInvalidMessageQuery)
+		}
+		q.Retained = &retained
+	}
+
+	switch direction := values.Get("direction"); direction {
+	case "":
+	case models.DirectionInbound, models.DirectionOutbound:
+		q.Direction = direction
+	default:
+		return q, fmt.Errorf("%w: direction debe ser inbound o outbound", ErrInvalidMessageQuery)
+	}
+
+	switch values.Get("sort") {
+	case "", "desc":
+	case "asc":
+		q.Ascending = true
+	default:
+		return q, fmt.Errorf("%w: sort debe ser asc o desc", ErrInvalidMessageQuery)
+	}
+
+	if value := values.Get("cursor"); value != "" {
+		cursor, err := ParseCursor(value)
+		if err != nil {
+			return q, err
+		}
+		q.After = cursor
+	}
+
+	where, err := ParsePayloadConditions(values["where"])
+	if err != nil {
+		return q, err
+	}
+	q.Where = where
+	q.JSONPath = values.Get("jsonpath")
+
+	return q, nil
+}
+
+// PayloadCondition exige que el campo Path del payload JSON valga Value
+type PayloadCondition struct {
+	Path  []string
+	Value interface{}
+}
+
+// ParsePayloadCondition interpreta un filtro "campo:valor". El campo admite
+// subcampos separados por puntos ("meta.device:abc"). El valor se compara como
+// JSON si lo es (números, true, false, null, "cadena") y como texto si no.
+func ParsePayloadCondition(s string) (PayloadCondition, error) {
+	field, raw, found := strings.Cut(s, ":")
+	if !found {
+		return PayloadCondition{}, fmt.Errorf("%w: se esperaba campo:valor en %q", ErrInvalidPayloadFilter, s)
+	}
+
+	path := strings.Split(strings.TrimSpace(field), ".")
+	for _, part := range path {
+		if part == "" {
+			return PayloadCondition{}, fmt.Errorf("%w: campo vacío en %q", ErrInvalidPayloadFilter, s)
+		}
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		value = raw
+	}
+	if _, isObject := value.(map[string]interface{}); isObject {
+		value = raw
+	}
+	if _, isArray := value.([]interface{}); isArray {
+		value = raw
+	}
+
+	return PayloadCondition{Path: path, Value: value}, nil
+}
+
+// ParsePayloadConditions interpreta varios filtros "campo:valor" (p. ej. los
+// parámetros ?where= repetidos de una petición)
+func ParsePayloadConditions(values []string) ([]PayloadCondition, error) {
+	conditions := make([]PayloadCondition, 0, len(values))
+	for _, value := range values {
+		condition, err := ParsePayloadCondition(value)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+	return conditions, nil
+}
+
+// containment devuelve el documento JSON que debe contener el payload
+func (c PayloadCondition) containment() (string, error) {
+	doc := c.Value
+	for i := len(c.Path) - 1; i >= 0; i-- {
+		doc = map[string]interface{}{c.Path[i]: doc}
+	}
+
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
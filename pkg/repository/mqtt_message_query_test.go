@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePayloadCondition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"source:go-publisher", `{"source":"go-publisher"}`},
+		{"id:5", `{"id":5}`},
+		{`id:"5"`, `{"id":"5"}`},
+		{"active:true", `{"active":true}`},
+		{"meta.device:abc", `{"meta":{"device":"abc"}}`},
+		{"message:hola: mundo", `{"message":"hola: mundo"}`},
+		{`tags:["a"]`, `{"tags":"[\"a\"]"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			condition, err := ParsePayloadCondition(tt.input)
+			assert.NoError(t, err)
+
+			doc, err := condition.containment()
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.expected, doc)
+		})
+	}
+}
+
+func TestParsePayloadConditionInvalid(t *testing.T) {
+	for _, input := range []string{"source", "", ":value", "meta..device:abc"} {
+		_, err := ParsePayloadCondition(input)
+		assert.ErrorIs(t, err, ErrInvalidPayloadFilter, input)
+	}
+
+	_, err := ParsePayloadConditions([]string{"source:go-publisher", "broken"})
+	assert.ErrorIs(t, err, ErrInvalidPayloadFilter)
+}
+
+func TestParseMessageQuery(t *testing.T) {
+	values := url.Values{
+		"topic":     {"sensores/+/temp"},
+		"from":      {"2024-01-01T00:00:00Z"},
+		"to":        {"2024-01-02T00:00:00+02:00"},
+		"qos":       {"1"},
+		"retained":  {"false"},
+		"direction": {"outbound"},
+		"sort":      {"asc"},
+		"where":     {"source:go-publisher"},
+		"jsonpath":  {"$.id ? (@ > 10)"},
+	}
+
+	q, err := ParseMessageQuery(values)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sensores/+/temp"}, q.Topics)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), q.From.UTC())
+	assert.Equal(t, time.Date(2024, 1, 1, 22, 0, 0, 0, time.UTC), q.To.UTC())
+	assert.Equal(t, 1, *q.QOS)
+	assert.False(t, *q.Retained)
+	assert.Equal(t, "outbound", q.Direction)
+	assert.True(t, q.Ascending)
+	assert.Len(t, q.Where, 1)
+	assert.Equal(t, "$.id ? (@ > 10)", q.JSONPath)
+	assert.Nil(t, q.After)
+}
+
+func TestParseMessageQueryInvalid(t *testing.T) {
+	invalid := []url.Values{
+		{"topic": {"sensores/#/temp"}},
+		{"from": {"ayer"}},
+		{"from": {"2024-01-02T00:00:00Z"}, "to": {"2024-01-01T00:00:00Z"}},
+		{"qos": {"3"}},
+		{"retained": {"quizá"}},
+		{"direction": {"sideways"}},
+		{"sort": {"random"}},
+		{"cursor": {"not-a-cursor"}},
+		{"where": {"source"}},
+	}
+
+	for _, values := range invalid {
+		_, err := ParseMessageQuery(values)
+		assert.ErrorIs(t, err, ErrInvalidMessageQuery, values.Encode())
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	receivedAt := time.Date(2024, 5, 1, 12, 30, 0, 123456000, time.UTC)
+	cursor := CursorAt(models.MqttMessage{ID: 42, ReceivedAt: receivedAt})
+
+	parsed, err := ParseCursor(cursor.String())
+	assert.NoError(t, err)
+	assert.Equal(t, 42, parsed.ID)
+	assert.True(t, receivedAt.Equal(parsed.ReceivedAt))
+
+	q, err := ParseMessageQuery(url.Values{"cursor": {cursor.String()}})
+	assert.NoError(t, err)
+	assert.Equal(t, 42, q.After.ID)
+}
@@ -1,11 +1,25 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/mqtttopic"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/tracing"
+	"github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
 )
 
+// messageColumns son las columnas que se leen de mqtt_messages, en el orden de scanMessages
+const messageColumns = `id, topic, payload, received_at, qos, retained, payload_json, payload_raw, direction`
+
+// defaultMessageLimit es el tamaño de página si la consulta no indica otro
+const defaultMessageLimit = 100
+
 type MqttMessageRepository struct {
 	db *sql.DB
 }
@@ -14,76 +28,259 @@ func NewMqttMessageRepository(db *sql.DB) *MqttMessageRepository {
 	return &MqttMessageRepository{db: db}
 }
 
-func (r *MqttMessageRepository) Create(message *models.MqttMessage) error {
+func (r *MqttMessageRepository) Create(ctx context.Context, message *models.MqttMessage) (err error) {
+	ctx, span := tracing.StartDB(ctx, "MqttMessageRepository.Create", "INSERT", "mqtt_messages")
+	defer tracing.End(span, &err)
+
 	query := `
-        INSERT INTO mqtt_messages (topic, payload, qos, retained)
-        VALUES ($1, $2, $3, $4)
+        INSERT INTO mqtt_messages (topic, payload, qos, retained, payload_json, payload_raw, direction)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
         RETURNING id, received_at
     `
 
-	err := r.db.QueryRow(
+	err = r.db.QueryRowContext(
+		ctx,
 		query,
 		message.Topic,
 		message.Payload,
 		message.QOS,
 		message.Retained,
+		nullableJSON(message.PayloadJSON),
+		message.PayloadRaw,
+		messageDirection(*message),
 	).Scan(&message.ID, &message.ReceivedAt)
 
 	return err
 }
 
-func (r *MqttMessageRepository) GetByTopic(topic string, limit int) ([]models.MqttMessage, error) {
-	query := `
-        SELECT id, topic, payload, received_at, qos, retained
-        FROM mqtt_messages
-        WHERE topic = $1
-        ORDER BY received_at DESC
-        LIMIT $2
-    `
+// CreateBatch guarda un lote de mensajes con COPY dentro de una transacción:
+// o se guardan todos o ninguno. Conserva el received_at de cada mensaje y
+// asigna a cada uno su ID.
+func (r *MqttMessageRepository) CreateBatch(ctx context.Context, messages []models.MqttMessage) (err error) {
+	if len(messages) == 0 {
+		return nil
+	}
+	ctx, span := tracing.StartDB(ctx, "MqttMessageRepository.CreateBatch", "COPY", "mqtt_messages",
+		semconv.DBOperationBatchSize(len(messages)))
+	defer tracing.End(span, &err)
 
-	rows, err := r.db.Query(query, topic, limit)
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	defer tx.Rollback()
+
+	// COPY no devuelve los ids generados: se reservan antes para poder asignarlos
+	if err := reserveMessageIDs(ctx, tx, messages); err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("mqtt_messages",
+		"id", "topic", "payload", "received_at", "qos", "retained", "payload_json", "payload_raw", "direction"))
+	if err != nil {
+		return fmt.Errorf("error preparando COPY: %w", err)
+	}
+
+	for _, msg := range messages {
+		if _, err := stmt.ExecContext(ctx, msg.ID, msg.Topic, msg.Payload, msg.ReceivedAt, msg.QOS, msg.Retained,
+			nullableJSON(msg.PayloadJSON), msg.PayloadRaw, messageDirection(msg)); err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return err
+	}
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// reserveMessageIDs asigna a los mensajes ids de la secuencia de mqtt_messages
+func reserveMessageIDs(ctx context.Context, tx *sql.Tx, messages []models.MqttMessage) error {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT nextval(pg_get_serial_sequence('mqtt_messages', 'id')) FROM generate_series(1, $1)`, len(messages))
+	if err != nil {
+		return fmt.Errorf("error reservando ids: %w", err)
 	}
 	defer rows.Close()
 
-	var messages []models.MqttMessage
-	for rows.Next() {
-		var msg models.MqttMessage
-		err := rows.Scan(
-			&msg.ID,
-			&msg.Topic,
-			&msg.Payload,
-			&msg.ReceivedAt,
-			&msg.QOS,
-			&msg.Retained,
-		)
-		if err != nil {
-			return nil, err
+	i := 0
+	for rows.Next() && i < len(messages) {
+		if err := rows.Scan(&messages[i].ID); err != nil {
+			return err
 		}
-		messages = append(messages, msg)
+		i++
+	}
+	if err := rows.Err(); err != nil {
+		return err
 	}
+	if i != len(messages) {
+		return fmt.Errorf("se reservaron %d ids para %d mensajes", i, len(messages))
+	}
+	return nil
+}
 
-	return messages, rows.Err()
+func (r *MqttMessageRepository) GetByTopic(ctx context.Context, topic string, limit int) ([]models.MqttMessage, error) {
+	page, err := r.Find(ctx, MessageQuery{Topics: []string{topic}, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	return page.Messages, nil
 }
 
-func (r *MqttMessageRepository) GetAll(limit int) ([]models.MqttMessage, error) {
-	query := `
-        SELECT id, topic, payload, received_at, qos, retained
-        FROM mqtt_messages
-        ORDER BY received_at DESC
-        LIMIT $1
-    `
+func (r *MqttMessageRepository) GetAll(ctx context.Context, limit int) ([]models.MqttMessage, error) {
+	page, err := r.Find(ctx, MessageQuery{Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	return page.Messages, nil
+}
+
+// GetByTopicFilters obtiene los últimos mensajes cuyos topics coinciden con
+// alguno de los filtros MQTT indicados (admiten los comodines + y #)
+func (r *MqttMessageRepository) GetByTopicFilters(ctx context.Context, filters []string, limit int) ([]models.MqttMessage, error) {
+	page, err := r.Find(ctx, MessageQuery{Restricted: true, TopicFilters: filters, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	return page.Messages, nil
+}
+
+// Find obtiene una página de mensajes que cumplen la consulta. La paginación
+// es por cursor sobre (received_at, id), así que no recorre las filas ya
+// devueltas como haría un OFFSET. Los filtros sobre el payload solo
+// encuentran mensajes JSON y usan el índice GIN de payload_json.
+func (r *MqttMessageRepository) Find(ctx context.Context, q MessageQuery) (_ *MessagePage, err error) {
+	ctx, span := tracing.StartDB(ctx, "MqttMessageRepository.Find", "SELECT", "mqtt_messages")
+	defer tracing.End(span, &err)
+
+	if q.Restricted && len(q.TopicFilters) == 0 {
+		return &MessagePage{Messages: []models.MqttMessage{}}, nil
+	}
+	if q.Limit <= 0 {
+		q.Limit = defaultMessageLimit
+	}
+
+	var conditions []string
+	var args []interface{}
+	addArg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if q.Restricted {
+		patterns := make([]string, len(q.TopicFilters))
+		for i, filter := range q.TopicFilters {
+			patterns[i] = mqtttopic.Regexp(filter)
+		}
+		conditions = append(conditions, "topic ~ ANY("+addArg(pq.Array(patterns))+")")
+	}
+
+	if len(q.Topics) > 0 {
+		conditions = append(conditions, topicCondition(q.Topics, addArg))
+	}
+
+	if q.From != nil {
+		conditions = append(conditions, "received_at >= "+addArg(*q.From))
+	}
+	if q.To != nil {
+		conditions = append(conditions, "received_at < "+addArg(*q.To))
+	}
+	if q.QOS != nil {
+		conditions = append(conditions, "qos = "+addArg(*q.QOS))
+	}
+	if q.Retained != nil {
+		conditions = append(conditions, "retained = "+addArg(*q.Retained))
+	}
+	if q.Direction != "" {
+		conditions = append(conditions, "direction = "+addArg(q.Direction))
+	}
+
+	for _, condition := range q.Where {
+		doc, err := condition.containment()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPayloadFilter, err)
+		}
+		conditions = append(conditions, "payload_json @> "+addArg(doc)+"::jsonb")
+	}
+
+	if q.JSONPath != "" {
+		conditions = append(conditions, "payload_json @? "+addArg(q.JSONPath)+"::jsonpath")
+	}
+
+	order, comparison := "DESC", "<"
+	if q.Ascending {
+		order, comparison = "ASC", ">"
+	}
+	orderBy := fmt.Sprintf("received_at %s, id %s", order, order)
+	switch {
+	case q.AfterID > 0:
+		conditions = append(conditions, "id > "+addArg(q.AfterID))
+		orderBy = "id ASC"
+	case q.After != nil:
+		conditions = append(conditions, fmt.Sprintf("(received_at, id) %s (%s, %s)",
+			comparison, addArg(q.After.ReceivedAt), addArg(q.After.ID)))
+	}
 
-	rows, err := r.db.Query(query, limit)
+	query := `SELECT ` + messageColumns + ` FROM mqtt_messages`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	// Se pide una fila de más para saber si hay página siguiente
+	query += ` ORDER BY ` + orderBy + ` LIMIT ` + addArg(q.Limit+1)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, payloadFilterError(err)
+	}
+	messages, err := scanMessages(rows)
 	if err != nil {
 		return nil, err
 	}
+
+	page := &MessagePage{Messages: messages}
+	if page.Messages == nil {
+		page.Messages = []models.MqttMessage{}
+	}
+	if len(page.Messages) > q.Limit {
+		page.Messages = page.Messages[:q.Limit]
+		if q.AfterID == 0 {
+			page.NextCursor = CursorAt(page.Messages[q.Limit-1]).String()
+		}
+	}
+	return page, nil
+}
+
+// topicCondition filtra por una lista de topics: con igualdad si son exactos
+// y con expresiones regulares si alguno tiene comodines
+func topicCondition(topics []string, addArg func(interface{}) string) string {
+	wildcards := false
+	for _, topic := range topics {
+		wildcards = wildcards || mqtttopic.HasWildcards(topic)
+	}
+	if !wildcards {
+		return "topic = ANY(" + addArg(pq.Array(topics)) + ")"
+	}
+
+	patterns := make([]string, len(topics))
+	for i, topic := range topics {
+		patterns[i] = mqtttopic.Regexp(topic)
+	}
+	return "topic ~ ANY(" + addArg(pq.Array(patterns)) + ")"
+}
+
+// scanMessages lee las filas con las columnas de messageColumns y las cierra
+func scanMessages(rows *sql.Rows) ([]models.MqttMessage, error) {
 	defer rows.Close()
 
 	var messages []models.MqttMessage
 	for rows.Next() {
 		var msg models.MqttMessage
+		var payloadJSON []byte
 		err := rows.Scan(
 			&msg.ID,
 			&msg.Topic,
@@ -91,12 +288,42 @@ func (r *MqttMessageRepository) GetAll(limit int) ([]models.MqttMessage, error)
 			&msg.ReceivedAt,
 			&msg.QOS,
 			&msg.Retained,
+			&payloadJSON,
+			&msg.PayloadRaw,
+			&msg.Direction,
 		)
 		if err != nil {
 			return nil, err
 		}
+		msg.PayloadJSON = payloadJSON
 		messages = append(messages, msg)
 	}
 
 	return messages, rows.Err()
 }
+
+// nullableJSON convierte un payload JSON vacío en NULL
+func nullableJSON(payload []byte) interface{} {
+	if len(payload) == 0 {
+		return nil
+	}
+	return string(payload)
+}
+
+// messageDirection devuelve la dirección del mensaje; sin indicar se considera recibido
+func messageDirection(msg models.MqttMessage) string {
+	if msg.Direction == "" {
+		return models.DirectionInbound
+	}
+	return msg.Direction
+}
+
+// payloadFilterError distingue una expresión JSONPath mal escrita (error de
+// sintaxis de PostgreSQL) de un fallo de la base de datos
+func payloadFilterError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "42601" {
+		return fmt.Errorf("%w: %s", ErrInvalidPayloadFilter, pqErr.Message)
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+package repository
+
+import "time"
+
+// RollupResolution es una de las resoluciones en las que se guardan agregados
+// de los mensajes: cada una se calcula a partir de la anterior y la primera a
+// partir de mqtt_messages
+type RollupResolution struct {
+	Name     string
+	Duration time.Duration
+	Table    string
+}
+
+// RollupResolutions son las resoluciones de los agregados, de la más fina a la más gruesa
+var RollupResolutions = []RollupResolution{
+	{Name: "1m", Duration: time.Minute, Table: "mqtt_rollups_1m"},
+	{Name: "1h", Duration: time.Hour, Table: "mqtt_rollups_1h"},
+	{Name: "1d", Duration: 24 * time.Hour, Table: "mqtt_rollups_1d"},
+}
+
+// RawResolution es el nombre con el que se indica que una serie se calculó
+// sobre los mensajes, sin agregados
+const RawResolution = "raw"
+
+// AggregateSegment es un tramo de una consulta de agregación. Los tramos con
+// Resolution se leen de la tabla de agregados; los demás, de mqtt_messages.
+type AggregateSegment struct {
+	Resolution *RollupResolution
+	From       time.Time
+	To         time.Time
+}
+
+// Source devuelve el nombre de la resolución del tramo o raw
+func (s AggregateSegment) Source() string {
+	if s.Resolution == nil {
+		return RawResolution
+	}
+	return s.Resolution.Name
+}
+
+// PlanSegments reparte [from, to) entre las tablas de agregados y
+// mqtt_messages. Solo se usan las resoluciones que dividen bucket (así cada
+// intervalo de la consulta está formado por intervalos enteros de la
+// resolución) y que tienen watermark, hasta el que están calculadas. Se empieza
+// por la más gruesa; los extremos que no cubre se reparten entre las más finas
+// y, en último término, los mensajes.
+func PlanSegments(from, to time.Time, bucket time.Duration, watermarks map[string]time.Time) []AggregateSegment {
+	var usable []RollupResolution
+	for i := len(RollupResolutions) - 1; i >= 0; i-- {
+		resolution := RollupResolutions[i]
+		if _, ok := watermarks[resolution.Name]; ok && bucket%resolution.Duration == 0 {
+			usable = append(usable, resolution)
+		}
+	}
+	return planSegments(from, to, usable, watermarks)
+}
+
+// planSegments cubre [from, to) con la primera resolución de usable y el resto
+// con las siguientes
+func planSegments(from, to time.Time, usable []RollupResolution, watermarks map[string]time.Time) []AggregateSegment {
+	if !from.Before(to) {
+		return nil
+	}
+	if len(usable) == 0 {
+		return []AggregateSegment{{From: from, To: to}}
+	}
+
+	resolution := usable[0]
+	end := to
+	if watermark := watermarks[resolution.Name]; watermark.Before(end) {
+		end = watermark
+	}
+	start := bucketStart(from, resolution.Duration)
+	if start.Before(from) {
+		start = start.Add(resolution.Duration)
+	}
+	end = bucketStart(end, resolution.Duration)
+	if !start.Before(end) {
+		return planSegments(from, to, usable[1:], watermarks)
+	}
+
+	segments := planSegments(from, start, usable[1:], watermarks)
+	segments = append(segments, AggregateSegment{Resolution: &resolution, From: start, To: end})
+	return append(segments, planSegments(end, to, usable[1:], watermarks)...)
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// segmentSummary resume un tramo como "resolución desde-hasta" para comparar planes
+func segmentSummary(segments []AggregateSegment) []string {
+	summary := make([]string, len(segments))
+	for i, segment := range segments {
+		summary[i] = segment.Source() + " " + segment.From.UTC().Format("02T15:04") + "-" + segment.To.UTC().Format("02T15:04")
+	}
+	return summary
+}
+
+func TestPlanSegmentsUsesCoarsestResolution(t *testing.T) {
+	from := time.Date(2025, 6, 1, 10, 7, 0, 0, time.UTC)
+	to := time.Date(2025, 6, 3, 15, 30, 0, 0, time.UTC)
+	watermarks := map[string]time.Time{
+		"1m": time.Date(2025, 6, 3, 15, 20, 0, 0, time.UTC),
+		"1h": time.Date(2025, 6, 3, 15, 0, 0, 0, time.UTC),
+		"1d": time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC),
+	}
+
+	assert.Equal(t, []string{
+		"1m 01T10:07-01T11:00",
+		"1h 01T11:00-02T00:00",
+		"1d 02T00:00-03T00:00",
+		"1h 03T00:00-03T15:00",
+		"1m 03T15:00-03T15:20",
+		"raw 03T15:20-03T15:30",
+	}, segmentSummary(PlanSegments(from, to, 24*time.Hour, watermarks)),
+		"Ends not covered by a resolution fall back to finer ones and finally to raw messages")
+
+	assert.Equal(t, []string{
+		"1m 01T10:07-03T15:20",
+		"raw 03T15:20-03T15:30",
+	}, segmentSummary(PlanSegments(from, to, 5*time.Minute, watermarks)), "Only resolutions dividing the bucket are used")
+
+	assert.Equal(t, []string{"raw 01T10:07-03T15:30"}, segmentSummary(PlanSegments(from, to, 90*time.Second, watermarks)))
+	assert.Equal(t, []string{"raw 01T10:07-03T15:30"}, segmentSummary(PlanSegments(from, to, time.Hour, nil)),
+		"Without watermarks there are no rollups for the topic")
+}
+
+func TestPlanSegmentsBeforeWatermarkStart(t *testing.T) {
+	// Los agregados todavía no llegan al rango pedido
+	from := time.Date(2025, 6, 3, 16, 0, 0, 0, time.UTC)
+	to := from.Add(2 * time.Hour)
+	watermarks := map[string]time.Time{"1m": from.Add(-time.Hour), "1h": from.Add(-time.Hour)}
+
+	assert.Equal(t, []string{"raw 03T16:00-03T18:00"}, segmentSummary(PlanSegments(from, to, time.Hour, watermarks)))
+}
+
+func TestAggregateQueryResolution(t *testing.T) {
+	q := AggregateQuery{}
+	assert.Equal(t, RawResolution, q.Resolution())
+
+	q.Segments = []AggregateSegment{
+		{Resolution: &RollupResolutions[0]},
+		{Resolution: &RollupResolutions[1]},
+		{},
+	}
+	assert.Equal(t, "1h", q.Resolution())
+}
+
+func TestAggregateRowMerge(t *testing.T) {
+	a := aggregateRow{count: 2, sum: 40, min: 19, max: 21}
+	b := aggregateRow{count: 3, sum: 75, min: 23, max: 27}
+
+	merged := a.merge(b)
+	assert.Equal(t, int64(5), merged.count)
+	assert.Equal(t, 19.0, merged.min)
+	assert.Equal(t, 27.0, merged.max)
+	assert.Equal(t, 23.0, merged.value(AggregateAvg))
+}
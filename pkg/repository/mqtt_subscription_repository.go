@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
+)
+
+type MqttSubscriptionRepository struct {
+	db *sql.DB
+}
+
+func NewMqttSubscriptionRepository(db *sql.DB) *MqttSubscriptionRepository {
+	return &MqttSubscriptionRepository{db: db}
+}
+
+// Save guarda la suscripción habilitada. Si el topic ya existía (por ejemplo
+// deshabilitado) se vuelve a habilitar con los nuevos datos.
+func (r *MqttSubscriptionRepository) Save(subscription *models.MqttSubscription) error {
+	query := `
+        INSERT INTO mqtt_subscriptions (topic, qos, created_by, enabled)
+        VALUES ($1, $2, $3, TRUE)
+        ON CONFLICT (topic) DO UPDATE
+        SET qos = EXCLUDED.qos,
+            created_by = EXCLUDED.created_by,
+            created_at = CURRENT_TIMESTAMP,
+            enabled = TRUE
+        RETURNING id, created_at, enabled
+    `
+
+	return r.db.QueryRow(
+		query,
+		subscription.Topic,
+		subscription.QOS,
+		subscription.CreatedBy,
+	).Scan(&subscription.ID, &subscription.CreatedAt, &subscription.Enabled)
+}
+
+// Disable deshabilita la suscripción del topic para que no se restaure
+func (r *MqttSubscriptionRepository) Disable(topic string) error {
+	query := `
+        UPDATE mqtt_subscriptions
+        SET enabled = FALSE
+        WHERE topic = $1
+    `
+
+	_, err := r.db.Exec(query, topic)
+	return err
+}
+
+// GetEnabled obtiene las suscripciones habilitadas
+func (r *MqttSubscriptionRepository) GetEnabled() ([]models.MqttSubscription, error) {
+	query := `
+        SELECT id, topic, qos, created_by, created_at, enabled
+        FROM mqtt_subscriptions
+        WHERE enabled = TRUE
+        ORDER BY created_at
+    `
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscriptions []models.MqttSubscription
+	for rows.Next() {
+		var sub models.MqttSubscription
+		var createdBy sql.NullInt64
+		err := rows.Scan(
+			&sub.ID,
+			&sub.Topic,
+			&sub.QOS,
+			&createdBy,
+			&sub.CreatedAt,
+			&sub.Enabled,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if createdBy.Valid {
+			id := int(createdBy.Int64)
+			sub.CreatedBy = &id
+		}
+		subscriptions = append(subscriptions, sub)
+	}
+
+	return subscriptions, rows.Err()
+}
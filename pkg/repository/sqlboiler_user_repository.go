@@ -67,7 +67,9 @@ func (r *SQLBoilerUserRepository) Update(ctx context.Context, id int, req *model
 		dbUser.Email = req.Email
 	}
 	if req.Password != "" {
+		// Cambiar la contraseña levanta la obligación de resetearla
 		dbUser.Password = req.Password
+		dbUser.PasswordResetRequired = false
 	}
 	if req.FullName.Valid {
 		dbUser.FullName = req.FullName
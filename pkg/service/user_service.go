@@ -2,21 +2,33 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/password"
 	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/repository"
 	"github.com/rs/zerolog/log"
 )
 
+var (
+	// ErrOldPasswordRequired se devuelve al cambiar la contraseña propia sin dar la actual
+	ErrOldPasswordRequired = errors.New("old_password is required to change your own password")
+	// ErrInvalidOldPassword se devuelve al cambiar la contraseña sin dar la actual correcta
+	ErrInvalidOldPassword = errors.New("invalid old password")
+)
+
 // UserService maneja la lógica de negocio para usuarios
 type UserService struct {
 	userRepo repository.UserRepository
+	hasher   *password.Manager
 }
 
 // NewUserService crea una nueva instancia del servicio
-func NewUserService(userRepo repository.UserRepository) *UserService {
+func NewUserService(userRepo repository.UserRepository, hasher *password.Manager) *UserService {
 	return &UserService{
 		userRepo: userRepo,
+		hasher:   hasher,
 	}
 }
 
@@ -34,6 +46,13 @@ func (s *UserService) CreateUser(ctx context.Context, req *models.CreateUserRequ
 
 	log.Info().Msgf("Creating user with username: %s", req.Username)
 
+	// Nunca se guarda la contraseña en claro
+	passwordHash, err := s.hasher.Hash(req.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+	req.Password = passwordHash
+
 	// Crear usuario
 	return s.userRepo.Create(ctx, req)
 }
@@ -44,9 +63,44 @@ func (s *UserService) GetUser(ctx context.Context, id int) (*models.User, error)
 	return s.userRepo.GetByID(ctx, id)
 }
 
-// UpdateUser actualiza un usuario
-func (s *UserService) UpdateUser(ctx context.Context, id int, req *models.UpdateUserRequest) (*models.User, error) {
+// UpdateUser actualiza un usuario. Si self, el usuario cambia su propia cuenta y
+// para cambiar la contraseña debe dar la actual; un administrador puede fijarla sin ella.
+func (s *UserService) UpdateUser(ctx context.Context, id int, req *models.UpdateUserRequest, self bool) (*models.User, error) {
 	log.Info().Msgf("Updating user with ID: %d", id)
+
+	if err := req.Validate(); err != nil {
+		log.Info().
+			Msgf("Invalid user update request: %v", err)
+		return nil, err
+	}
+
+	if req.Password != "" && self {
+		if req.OldPassword == "" {
+			return nil, ErrOldPasswordRequired
+		}
+
+		// Como en el cambio de contraseña de la API nueva, hay que conocer la actual
+		user, err := s.userRepo.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		ok, _, err := s.hasher.Verify(req.OldPassword, user.Password)
+		if err != nil && !errors.Is(err, password.ErrUnknownHashFormat) {
+			return nil, fmt.Errorf("failed to verify password: %w", err)
+		}
+		if !ok {
+			return nil, ErrInvalidOldPassword
+		}
+	}
+
+	if req.Password != "" {
+		passwordHash, err := s.hasher.Hash(req.Password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash password: %w", err)
+		}
+		req.Password = passwordHash
+	}
+
 	return s.userRepo.Update(ctx, id, req)
 }
 
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/models"
+	"github.com/JorgeePG/prueba-api-http-postgresql-/pkg/password"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeUserRepo guarda un único usuario y anota la última actualización
+type fakeUserRepo struct {
+	user    *models.User
+	updated *models.UpdateUserRequest
+}
+
+func (r *fakeUserRepo) Create(ctx context.Context, user *models.CreateUserRequest) (*models.User, error) {
+	return nil, nil
+}
+
+func (r *fakeUserRepo) GetByID(ctx context.Context, id int) (*models.User, error) {
+	return r.user, nil
+}
+
+func (r *fakeUserRepo) Update(ctx context.Context, id int, user *models.UpdateUserRequest) (*models.User, error) {
+	r.updated = user
+	return r.user, nil
+}
+
+func (r *fakeUserRepo) Delete(ctx context.Context, id int) error {
+	return nil
+}
+
+func (r *fakeUserRepo) List(ctx context.Context) ([]*models.User, error) {
+	return nil, nil
+}
+
+func TestUpdateUserPasswordChecksOldPasswordOnlyForSelf(t *testing.T) {
+	ctx := context.Background()
+	hasher := password.NewManager(password.NewArgon2idHasher(password.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1}))
+	hash, err := hasher.Hash("actual")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &fakeUserRepo{user: &models.User{ID: 1, Username: "ana", Password: hash}}
+	service := NewUserService(repo, hasher)
+
+	_, err = service.UpdateUser(ctx, 1, &models.UpdateUserRequest{Password: "nueva-clave"}, true)
+	assert.ErrorIs(t, err, ErrOldPasswordRequired)
+	_, err = service.UpdateUser(ctx, 1, &models.UpdateUserRequest{Password: "nueva-clave", OldPassword: "otra"}, true)
+	assert.ErrorIs(t, err, ErrInvalidOldPassword)
+	assert.Nil(t, repo.updated, "Nothing is saved without the right old password")
+
+	_, err = service.UpdateUser(ctx, 1, &models.UpdateUserRequest{Password: "nueva-clave", OldPassword: "actual"}, true)
+	if assert.NoError(t, err) {
+		ok, _, _ := hasher.Verify("nueva-clave", repo.updated.Password)
+		assert.True(t, ok, "The new password is stored hashed")
+	}
+
+	repo.updated = nil
+	_, err = service.UpdateUser(ctx, 1, &models.UpdateUserRequest{Password: "fijada"}, false)
+	if assert.NoError(t, err, "Administrators set other users' passwords without the old one") {
+		ok, _, _ := hasher.Verify("fijada", repo.updated.Password)
+		assert.True(t, ok)
+	}
+}
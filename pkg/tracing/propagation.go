@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// ExtractHTTP devuelve ctx con el contexto de traza de las cabeceras
+// traceparent/tracestate de la petición
+func ExtractHTTP(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// UserProperty es una propiedad de usuario de un paquete MQTT v5
+type UserProperty struct {
+	Key   string
+	Value string
+}
+
+// UserProperties adapta las propiedades de usuario de MQTT v5 a
+// propagation.TextMapCarrier, para llevar traceparent en los mensajes
+type UserProperties []UserProperty
+
+// Get devuelve el valor de la primera propiedad con esa clave
+func (p *UserProperties) Get(key string) string {
+	for _, prop := range *p {
+		if prop.Key == key {
+			return prop.Value
+		}
+	}
+	return ""
+}
+
+// Set sustituye la propiedad con esa clave o la añade
+func (p *UserProperties) Set(key, value string) {
+	for i, prop := range *p {
+		if prop.Key == key {
+			(*p)[i].Value = value
+			return
+		}
+	}
+	*p = append(*p, UserProperty{Key: key, Value: value})
+}
+
+// Keys devuelve las claves de todas las propiedades
+func (p *UserProperties) Keys() []string {
+	keys := make([]string, 0, len(*p))
+	for _, prop := range *p {
+		keys = append(keys, prop.Key)
+	}
+	return keys
+}
+
+// InjectUserProperties añade a props el contexto de traza de ctx
+func InjectUserProperties(ctx context.Context, props UserProperties) UserProperties {
+	otel.GetTextMapPropagator().Inject(ctx, &props)
+	return props
+}
+
+// ExtractUserProperties devuelve ctx con el contexto de traza de las
+// propiedades de usuario de un mensaje MQTT v5
+func ExtractUserProperties(ctx context.Context, props UserProperties) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, &props)
+}
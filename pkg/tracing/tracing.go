@@ -0,0 +1,160 @@
+// Package tracing configura OpenTelemetry para la aplicación: el proveedor de
+// trazas global, su exportador (OTLP, stdout o fichero) y la propagación del
+// contexto W3C (traceparent/tracestate) entre HTTP y MQTT.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName identifica las trazas creadas por esta aplicación
+const InstrumentationName = "github.com/JorgeePG/prueba-api-http-postgresql-"
+
+// Exportadores soportados
+const (
+	// ExporterNone no exporta: las trazas no se registran, pero el contexto se propaga
+	ExporterNone = "none"
+	// ExporterOTLP envía las trazas por OTLP/HTTP a Endpoint
+	ExporterOTLP = "otlp"
+	// ExporterStdout escribe las trazas como JSON en la salida estándar
+	ExporterStdout = "stdout"
+	// ExporterFile escribe las trazas como JSON, una por línea, en File
+	ExporterFile = "file"
+)
+
+// ErrInvalidConfig se devuelve cuando la configuración de trazas no es válida
+var ErrInvalidConfig = errors.New("configuración de trazas no válida")
+
+// Config es la configuración del exportador de trazas
+type Config struct {
+	Exporter    string
+	ServiceName string
+	// Endpoint es la URL del colector OTLP (p. ej. http://localhost:4318); vacío
+	// usa las variables OTEL_EXPORTER_OTLP_* estándar
+	Endpoint string
+	// File es el fichero del exportador "file"
+	File string
+	// SampleRatio es la fracción de trazas nuevas que se registran (0 a 1). Las
+	// que llegan con un padre respetan su decisión.
+	SampleRatio float64
+}
+
+// Setup configura el proveedor de trazas global y el propagador W3C. Devuelve
+// una función que exporta las trazas pendientes y libera el exportador.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if cfg.Exporter == "" || cfg.Exporter == ExporterNone {
+		return func(context.Context) error { return nil }, nil
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, fmt.Errorf("%w: la fracción de muestreo debe estar entre 0 y 1", ErrInvalidConfig)
+	}
+
+	exporter, closer, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			err = errors.Join(err, closer.Close())
+		}
+		return err
+	}, nil
+}
+
+// newExporter crea el exportador indicado. Si abre un fichero, lo devuelve
+// para cerrarlo al terminar.
+func newExporter(ctx context.Context, cfg Config) (sdktrace.SpanExporter, io.Closer, error) {
+	switch cfg.Exporter {
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		}
+		exporter, err := otlptracehttp.New(ctx, opts...)
+		return exporter, nil, err
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+		return exporter, nil, err
+	case ExporterFile:
+		if cfg.File == "" {
+			return nil, nil, fmt.Errorf("%w: el exportador file necesita un fichero", ErrInvalidConfig)
+		}
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error abriendo el fichero de trazas: %w", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return exporter, f, nil
+	default:
+		return nil, nil, fmt.Errorf("%w: exportador desconocido %q (none, otlp, stdout, file)", ErrInvalidConfig, cfg.Exporter)
+	}
+}
+
+// Tracer devuelve el tracer de la aplicación del proveedor global
+func Tracer() trace.Tracer {
+	return otel.Tracer(InstrumentationName)
+}
+
+// Start abre un span hijo del que haya en ctx con el tracer de la aplicación
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, opts...)
+}
+
+// StartDB abre un span de cliente para una consulta a PostgreSQL. operation es
+// la operación SQL (SELECT, INSERT...) y table la tabla principal.
+func StartDB(ctx context.Context, name, operation, table string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append([]attribute.KeyValue{
+		semconv.DBSystemNamePostgreSQL,
+		semconv.DBOperationName(operation),
+		semconv.DBCollectionName(table),
+	}, attrs...)
+	return Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// End cierra el span registrando como error del span el de *errp, si lo hay.
+// Pensado para usarse con defer y el error de retorno con nombre.
+func End(span trace.Span, errp *error) {
+	if errp != nil && *errp != nil {
+		span.RecordError(*errp)
+		span.SetStatus(codes.Error, (*errp).Error())
+	}
+	span.End()
+}
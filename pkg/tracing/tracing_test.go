@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestSetupRejectsInvalidConfig(t *testing.T) {
+	cases := []Config{
+		{Exporter: "jaeger", SampleRatio: 1},
+		{Exporter: ExporterFile, SampleRatio: 1},
+		{Exporter: ExporterStdout, SampleRatio: 1.5},
+	}
+	for _, cfg := range cases {
+		_, err := Setup(context.Background(), cfg)
+		assert.ErrorIs(t, err, ErrInvalidConfig, cfg.Exporter)
+	}
+}
+
+func TestFileExporterWritesSpans(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "traces.jsonl")
+	shutdown, err := Setup(context.Background(), Config{
+		Exporter:    ExporterFile,
+		ServiceName: "test",
+		File:        file,
+		SampleRatio: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { otel.SetTracerProvider(sdktrace.NewTracerProvider()) })
+
+	_, span := Start(context.Background(), "UserService.Create")
+	End(span, nil)
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(data), `"Name":"UserService.Create"`)
+	assert.Contains(t, string(data), `"Value":"test"`)
+}
+
+func TestUserPropertiesCarryTraceContext(t *testing.T) {
+	if _, err := Setup(context.Background(), Config{Exporter: ExporterNone}); err != nil {
+		t.Fatal(err)
+	}
+	provider := sdktrace.NewTracerProvider()
+	defer provider.Shutdown(context.Background())
+
+	ctx, span := provider.Tracer("test").Start(context.Background(), "publish")
+	defer span.End()
+
+	props := InjectUserProperties(ctx, UserProperties{{Key: "content-type", Value: "application/json"}})
+	assert.Equal(t, "application/json", props.Get("content-type"))
+	assert.NotEmpty(t, props.Get("traceparent"))
+
+	extracted := ExtractUserProperties(context.Background(), props)
+	_, child := provider.Tracer("test").Start(extracted, "receive")
+	defer child.End()
+	assert.Equal(t, span.SpanContext().TraceID(), child.SpanContext().TraceID())
+}
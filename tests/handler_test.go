@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math/rand"
 	"net/http"
+	"os"
 	"testing"
 	"time"
 
@@ -13,11 +14,12 @@ import (
 )
 
 type user struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	FullName string `json:"full_name"`
-	IsActive bool   `json:"is_active"`
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	Password    string `json:"password"`
+	OldPassword string `json:"old_password,omitempty"`
+	FullName    string `json:"full_name"`
+	IsActive    bool   `json:"is_active"`
 }
 
 type Response struct {
@@ -28,6 +30,79 @@ type Response struct {
 
 var apiURL = "http://localhost:8080"
 
+// loginAsNewUser crea un usuario nuevo y devuelve un access token para él
+func loginAsNewUser(t *testing.T) string {
+	t.Helper()
+
+	timestamp := time.Now().UnixNano()
+	random := rand.Intn(10000)
+	u := user{
+		Username: fmt.Sprintf("authuser_%d_%d", timestamp, random),
+		Email:    fmt.Sprintf("authuser_%d_%d@example.com", timestamp, random),
+		Password: "password123",
+		FullName: "Auth User",
+		IsActive: true,
+	}
+
+	jsonData, err := json.Marshal(u)
+	assert.NoError(t, err, "Should marshal user to JSON without error")
+
+	resp, err := http.Post(apiURL+"/add", "application/json", bytes.NewBuffer(jsonData))
+	assert.NoError(t, err, "Should create the auth user without error")
+	resp.Body.Close()
+
+	return login(t, u.Username, u.Password)
+}
+
+// loginAsAdmin devuelve un access token del administrador configurado en
+// TEST_ADMIN_LOGIN/TEST_ADMIN_PASSWORD (ver el comando grant-role).
+func loginAsAdmin(t *testing.T) string {
+	t.Helper()
+
+	adminLogin, adminPassword := os.Getenv("TEST_ADMIN_LOGIN"), os.Getenv("TEST_ADMIN_PASSWORD")
+	if adminLogin == "" || adminPassword == "" {
+		t.Skip("TEST_ADMIN_LOGIN/TEST_ADMIN_PASSWORD no configurados")
+	}
+
+	return login(t, adminLogin, adminPassword)
+}
+
+// login obtiene un access token con las credenciales indicadas
+func login(t *testing.T, username, password string) string {
+	t.Helper()
+
+	loginData, err := json.Marshal(map[string]string{"login": username, "password": password})
+	assert.NoError(t, err, "Should marshal login request without error")
+
+	loginResp, err := http.Post(apiURL+"/api/v1/auth/login", "application/json", bytes.NewBuffer(loginData))
+	assert.NoError(t, err, "Should log in without error")
+	defer loginResp.Body.Close()
+	assert.Equal(t, http.StatusOK, loginResp.StatusCode, "Should return 200 OK for a valid login")
+
+	var response struct {
+		Data struct {
+			AccessToken string `json:"access_token"`
+		} `json:"data"`
+	}
+	err = json.NewDecoder(loginResp.Body).Decode(&response)
+	assert.NoError(t, err, "Should decode login response without error")
+	assert.NotEmpty(t, response.Data.AccessToken, "Should receive an access token")
+
+	return response.Data.AccessToken
+}
+
+// doAuthorized hace una petición con el access token en la cabecera Authorization
+func doAuthorized(t *testing.T, method, url, token string, body []byte) (*http.Response, error) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
+	assert.NoError(t, err, "Should build HTTP request without error")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return http.DefaultClient.Do(req)
+}
+
 func TestAddUserOk(t *testing.T) {
 	addURL := apiURL + "/add"
 
@@ -143,7 +218,7 @@ func TestDeleteUserOk(t *testing.T) {
 	// Ahora eliminar el usuario usando el id
 	deleteURL := apiURL + "/delete"
 
-	respDel, err := http.Get(deleteURL + fmt.Sprintf("?id=%d", uid.ID))
+	respDel, err := doAuthorized(t, http.MethodGet, deleteURL+fmt.Sprintf("?id=%d", uid.ID), loginAsAdmin(t), nil)
 	assert.NoError(t, err, "Should make HTTP request to delete without error")
 	defer respDel.Body.Close()
 
@@ -161,7 +236,7 @@ func TestDeleteBad(t *testing.T) {
 	// Eliminar un usuario usando un id inválido
 	deleteURL := apiURL + "/delete"
 
-	respDel, err := http.Get(deleteURL + fmt.Sprintf("?id=%d", -1)) // Usando un id inválido
+	respDel, err := doAuthorized(t, http.MethodGet, deleteURL+fmt.Sprintf("?id=%d", -1), loginAsAdmin(t), nil) // Usando un id inválido
 	assert.NoError(t, err, "Should make HTTP request to delete without error")
 	defer respDel.Body.Close()
 
@@ -216,7 +291,7 @@ func TestListUsers(t *testing.T) {
 	// Ahora hacer la petición GET para listar usuarios
 	listURL := apiURL + "/"
 
-	respList, err := http.Get(listURL)
+	respList, err := doAuthorized(t, http.MethodGet, listURL, loginAsAdmin(t), nil)
 	assert.NoError(t, err, "Should make HTTP request to list users without error")
 	defer respList.Body.Close()
 
@@ -272,11 +347,12 @@ func TestUpdateUserOk(t *testing.T) {
 	// Ahora actualizar el usuario
 	updateURL := apiURL + "/update"
 	updatedUser := user{
-		Username: fmt.Sprintf("updated_%d_%d", timestamp, random),
-		Email:    fmt.Sprintf("updated_%d_%d@example.com", timestamp, random),
-		Password: "newpassword123",
-		FullName: fmt.Sprintf("Updated User %d", timestamp),
-		IsActive: false,
+		Username:    fmt.Sprintf("updated_%d_%d", timestamp, random),
+		Email:       fmt.Sprintf("updated_%d_%d@example.com", timestamp, random),
+		Password:    "newpassword123",
+		OldPassword: originalUser.Password,
+		FullName:    fmt.Sprintf("Updated User %d", timestamp),
+		IsActive:    false,
 	}
 
 	// Convertir a JSON
@@ -284,7 +360,9 @@ func TestUpdateUserOk(t *testing.T) {
 	assert.NoError(t, err, "Should marshal updated user to JSON without error")
 
 	// Hacer la petición HTTP POST al endpoint de update
-	updateResp, err := http.Post(updateURL+fmt.Sprintf("?id=%d", uid.ID), "application/json", bytes.NewBuffer(updateJsonData))
+	// Un usuario puede actualizar sus propios datos sin permisos adicionales
+	token := login(t, originalUser.Username, originalUser.Password)
+	updateResp, err := doAuthorized(t, http.MethodPost, updateURL+fmt.Sprintf("?id=%d", uid.ID), token, updateJsonData)
 	assert.NoError(t, err, "Should make HTTP request to update without error")
 	defer updateResp.Body.Close()
 
@@ -317,7 +395,7 @@ func TestUpdateUserBad(t *testing.T) {
 	assert.NoError(t, err, "Should marshal user to JSON without error")
 
 	// Hacer la petición HTTP POST con un ID inválido
-	updateResp, err := http.Post(updateURL+"?id=-1", "application/json", bytes.NewBuffer(jsonData))
+	updateResp, err := doAuthorized(t, http.MethodPost, updateURL+"?id=-1", loginAsAdmin(t), jsonData)
 	assert.NoError(t, err, "Should make HTTP request to update without error")
 	defer updateResp.Body.Close()
 
@@ -350,7 +428,7 @@ func TestUpdateUserBadMissingID(t *testing.T) {
 	assert.NoError(t, err, "Should marshal user to JSON without error")
 
 	// Hacer la petición HTTP POST sin ID
-	updateResp, err := http.Post(updateURL, "application/json", bytes.NewBuffer(jsonData))
+	updateResp, err := doAuthorized(t, http.MethodPost, updateURL, loginAsAdmin(t), jsonData)
 	assert.NoError(t, err, "Should make HTTP request to update without error")
 	defer updateResp.Body.Close()
 
@@ -365,3 +443,35 @@ func TestUpdateUserBadMissingID(t *testing.T) {
 
 	t.Logf("Update user missing ID test completed successfully: %s", response.Message)
 }
+
+func TestDeleteWithoutToken(t *testing.T) {
+	// Las rutas protegidas rechazan peticiones sin access token
+	deleteURL := apiURL + "/delete"
+
+	respDel, err := http.Get(deleteURL + "?id=1")
+	assert.NoError(t, err, "Should make HTTP request to delete without error")
+	defer respDel.Body.Close()
+
+	assert.Equal(t, http.StatusUnauthorized, respDel.StatusCode, "Should return 401 Unauthorized without access token")
+
+	var response Response
+	err = json.NewDecoder(respDel.Body).Decode(&response)
+	assert.NoError(t, err, "Should decode delete response without error")
+	assert.Equal(t, "error", response.Status, "Response status should be error without access token")
+}
+
+func TestDeleteForbiddenForViewer(t *testing.T) {
+	// Un usuario sin roles asignados (viewer) no puede borrar usuarios
+	deleteURL := apiURL + "/delete"
+
+	respDel, err := doAuthorized(t, http.MethodGet, deleteURL+"?id=1", loginAsNewUser(t), nil)
+	assert.NoError(t, err, "Should make HTTP request to delete without error")
+	defer respDel.Body.Close()
+
+	assert.Equal(t, http.StatusForbidden, respDel.StatusCode, "Should return 403 Forbidden for a viewer")
+
+	var response Response
+	err = json.NewDecoder(respDel.Body).Decode(&response)
+	assert.NoError(t, err, "Should decode delete response without error")
+	assert.Equal(t, "error", response.Status, "Response status should be error for a viewer")
+}